@@ -0,0 +1,192 @@
+package gorill
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"unsafe"
+)
+
+// DefaultAlignment is the default block size to which an AlignedWriter aligns
+// its writes.
+const DefaultAlignment = 4096
+
+// DefaultAlignedBufSize is the default size of the staging buffer of an
+// AlignedWriter.
+const DefaultAlignedBufSize = 16 * DefaultAlignment
+
+// AlignedWriter is an io.WriteCloser that only ever writes whole blocks to the
+// underlying io.WriteCloser, from a staging buffer whose memory address is also
+// aligned to the block size. This satisfies the requirements of file
+// descriptors opened with O_DIRECT, allowing very high throughput log capture
+// without polluting the page cache.
+//
+// Because every write is a multiple of the block size, the final partial block
+// is padded with zero bytes when the AlignedWriter is closed. When the
+// underlying io.WriteCloser is an *os.File, the file is then truncated to
+// remove the padding.
+type AlignedWriter struct {
+	alignment int
+	buf       []byte // buf is the aligned staging buffer
+	bufSize   int
+	fh        *os.File // fh is nil when iowc is not a file
+	halted    bool
+	iowc      io.WriteCloser
+	n         int   // n is the number of bytes staged in buf
+	start     int64 // start is the file offset when the writer was created
+	written   int64 // written is the number of bytes accepted by Write
+}
+
+// AlignedWriterSetter is any function that modifies an AlignedWriter being
+// instantiated.
+type AlignedWriterSetter func(*AlignedWriter) error
+
+// Alignment is used to configure the block size of a new AlignedWriter. It must
+// be a power of 2, and ought to match the logical block size of the device to
+// which the file is written.
+func Alignment(size int) AlignedWriterSetter {
+	return func(aw *AlignedWriter) error {
+		if size <= 0 || size&(size-1) != 0 {
+			return fmt.Errorf("alignment must be a power of 2: %d", size)
+		}
+		aw.alignment = size
+		return nil
+	}
+}
+
+// AlignedBufSize is used to configure the size of the staging buffer of a new
+// AlignedWriter. It must be a multiple of the alignment.
+func AlignedBufSize(size int) AlignedWriterSetter {
+	return func(aw *AlignedWriter) error {
+		if size <= 0 {
+			return fmt.Errorf("buffer size must be greater than 0: %d", size)
+		}
+		aw.bufSize = size
+		return nil
+	}
+}
+
+// NewAlignedWriter returns an AlignedWriter that writes aligned blocks to iowc.
+//
+//   fh, err := os.OpenFile("capture.log", os.O_CREATE|os.O_WRONLY|syscall.O_DIRECT, 0644)
+//   if err != nil {
+//       return err
+//   }
+//   aw, err := gorill.NewAlignedWriter(fh, gorill.AlignedBufSize(1<<20))
+//   if err != nil {
+//       fh.Close()
+//       return err
+//   }
+//   spooler, err := gorill.NewSpooledWriteCloser(aw, gorill.BufSize(1<<20))
+func NewAlignedWriter(iowc io.WriteCloser, setters ...AlignedWriterSetter) (*AlignedWriter, error) {
+	aw := &AlignedWriter{
+		alignment: DefaultAlignment,
+		bufSize:   DefaultAlignedBufSize,
+		iowc:      iowc,
+	}
+	for _, setter := range setters {
+		if err := setter(aw); err != nil {
+			return nil, err
+		}
+	}
+	if aw.bufSize%aw.alignment != 0 {
+		return nil, fmt.Errorf("buffer size must be a multiple of alignment %d: %d", aw.alignment, aw.bufSize)
+	}
+	if fh, ok := iowc.(*os.File); ok {
+		if start, err := fh.Seek(0, io.SeekCurrent); err == nil {
+			aw.fh, aw.start = fh, start
+		}
+	}
+	aw.buf = alignedBuffer(aw.bufSize, aw.alignment)
+	return aw, nil
+}
+
+// alignedBuffer returns a byte slice of the specified size whose first byte is
+// located at a memory address that is a multiple of alignment.
+func alignedBuffer(size, alignment int) []byte {
+	buf := make([]byte, size+alignment)
+	offset := int(uintptr(unsafe.Pointer(&buf[0])) & uintptr(alignment-1))
+	if offset != 0 {
+		offset = alignment - offset
+	}
+	return buf[offset : offset+size : offset+size]
+}
+
+// Write stages data in the aligned buffer, writing the buffer to the underlying
+// io.WriteCloser each time it fills.
+func (aw *AlignedWriter) Write(data []byte) (int, error) {
+	if aw.halted {
+		return 0, ErrWriteAfterClose{}
+	}
+	var written int
+	for written < len(data) {
+		n := copy(aw.buf[aw.n:], data[written:])
+		aw.n += n
+		written += n
+		aw.written += int64(n)
+		if aw.n == len(aw.buf) {
+			if err := aw.writeBlocks(aw.n); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// writeBlocks writes the first size bytes of the staging buffer, which must be
+// a multiple of the alignment, then moves any remaining staged bytes to the
+// front of the buffer.
+func (aw *AlignedWriter) writeBlocks(size int) error {
+	if size == 0 {
+		return nil
+	}
+	nw, err := aw.iowc.Write(aw.buf[:size])
+	if err == nil && nw < size {
+		err = io.ErrShortWrite
+	}
+	if err != nil {
+		return err
+	}
+	aw.n = copy(aw.buf, aw.buf[size:aw.n])
+	return nil
+}
+
+// Flush writes all complete blocks staged in the buffer to the underlying
+// io.WriteCloser. Bytes of a final partial block remain staged until either
+// more data completes the block, or the AlignedWriter is closed.
+func (aw *AlignedWriter) Flush() error {
+	if aw.halted {
+		return ErrWriteAfterClose{}
+	}
+	return aw.writeBlocks(aw.n - aw.n%aw.alignment)
+}
+
+// Unwrap returns the underlying io.WriteCloser.
+func (aw *AlignedWriter) Unwrap() io.WriteCloser { return aw.iowc }
+
+// Close pads any final partial block with zero bytes and writes it, truncates
+// the file to remove the padding when the underlying io.WriteCloser is an
+// *os.File, then closes the underlying io.WriteCloser.
+func (aw *AlignedWriter) Close() error {
+	if aw.halted {
+		return nil
+	}
+	aw.halted = true
+
+	var errors ErrList
+	padded := aw.n
+	if remainder := padded % aw.alignment; remainder != 0 {
+		padded += aw.alignment - remainder
+		for i := aw.n; i < padded; i++ {
+			aw.buf[i] = 0
+		}
+		aw.n = padded
+	}
+	err := aw.writeBlocks(padded)
+	errors.Append(err)
+	if err == nil && padded > 0 && aw.fh != nil {
+		errors.Append(aw.fh.Truncate(aw.start + aw.written))
+	}
+	errors.Append(aw.iowc.Close())
+	return errors.Err()
+}
@@ -0,0 +1,94 @@
+package gorill
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+	"unsafe"
+)
+
+// blockRecorder records the size of each write.
+type blockRecorder struct {
+	bytes.Buffer
+	sizes []int
+}
+
+func (br *blockRecorder) Write(data []byte) (int, error) {
+	br.sizes = append(br.sizes, len(data))
+	return br.Buffer.Write(data)
+}
+
+func (br *blockRecorder) Close() error { return nil }
+
+func TestAlignedWriter(t *testing.T) {
+	t.Run("invalid alignment", func(t *testing.T) {
+		_, err := NewAlignedWriter(NewNopCloseBuffer(), Alignment(1000))
+		ensureError(t, err, "alignment must be a power of 2")
+	})
+
+	t.Run("invalid buffer size", func(t *testing.T) {
+		_, err := NewAlignedWriter(NewNopCloseBuffer(), Alignment(512), AlignedBufSize(1000))
+		ensureError(t, err, "buffer size must be a multiple of alignment")
+	})
+
+	t.Run("aligned buffer", func(t *testing.T) {
+		for _, alignment := range []int{512, 4096} {
+			buf := alignedBuffer(alignment, alignment)
+			if got := len(buf); got != alignment {
+				t.Errorf("GOT: %v; WANT: %v", got, alignment)
+			}
+			if got := int(uintptr(unsafe.Pointer(&buf[0])) % uintptr(alignment)); got != 0 {
+				t.Errorf("GOT: %v; WANT: %v", got, 0)
+			}
+		}
+	})
+
+	t.Run("writes whole blocks", func(t *testing.T) {
+		br := new(blockRecorder)
+		aw, err := NewAlignedWriter(br, Alignment(16), AlignedBufSize(32))
+		ensureError(t, err)
+
+		for _, piece := range []string{"abc", alphabet, alphabet, "xyz"} {
+			n, err := aw.Write([]byte(piece))
+			ensureError(t, err)
+			if got, want := n, len(piece); got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		}
+		ensureError(t, aw.Flush())
+		ensureError(t, aw.Close())
+
+		for _, size := range br.sizes {
+			if size%16 != 0 {
+				t.Errorf("GOT: %v; WANT: multiple of 16", size)
+			}
+		}
+		want := "abc" + alphabet + alphabet + "xyz"
+		if got := br.String(); got[:len(want)] != want || len(got)%16 != 0 {
+			t.Errorf("GOT: %q; WANT: %q plus padding", got, want)
+		}
+
+		_, err = aw.Write([]byte("after"))
+		testErrorType(t, err, ErrWriteAfterClose{})
+	})
+
+	t.Run("file truncated to remove padding", func(t *testing.T) {
+		fh, err := ioutil.TempFile("", "gorill")
+		ensureError(t, err)
+		defer os.Remove(fh.Name())
+
+		aw, err := NewAlignedWriter(fh, Alignment(512), AlignedBufSize(1024))
+		ensureError(t, err)
+		want := bytes.Repeat([]byte(alphabet), 100)
+		_, err = aw.Write(want)
+		ensureError(t, err)
+		ensureError(t, aw.Close())
+
+		got, err := ioutil.ReadFile(fh.Name())
+		ensureError(t, err)
+		if !bytes.Equal(got, want) {
+			t.Errorf("GOT: %v bytes; WANT: %v bytes", len(got), len(want))
+		}
+	})
+}
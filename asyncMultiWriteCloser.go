@@ -0,0 +1,368 @@
+package gorill
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultAsyncQueueLength is the default number of writes queued for each writer of an
+// AsyncMultiWriteCloser.
+const DefaultAsyncQueueLength = 64
+
+// AsyncMultiWriteCloser is an io.WriteCloser that writes to a list of io.WriteCloser instances,
+// each from its own go-routine fed by its own bounded queue, so that a slow writer does not delay
+// writes to the others. Write enqueues a copy of the data for each writer and returns without
+// waiting for the writes to complete. What happens when a writer's queue is full is determined by
+// its AsyncFullPolicy; by default Write blocks until the queue has room.
+//
+// An io.WriteCloser whose Write returns an error is removed and closed, and the writes queued for it
+// are discarded.
+type AsyncMultiWriteCloser struct {
+	// Statistics are accessed atomically, and kept first for 64-bit alignment.
+	statDropped int64
+	statEvicted int64
+
+	clock        Clock // clock times writes to measure their latency
+	destinations map[io.WriteCloser]*asyncDestination
+	halted       bool
+	leaveOpen    bool // leaveOpen is set when evicted writers are not closed
+	lock         sync.RWMutex
+	onFull       AsyncFullPolicy
+	queueLength  int
+	running      sync.WaitGroup // running counts the destination and eviction go-routines
+}
+
+// asyncDestination is a writer of an AsyncMultiWriteCloser along with its queue.
+type asyncDestination struct {
+	full     int32 // full counts consecutive writes that found the queue full, accessed atomically
+	evicting int32 // evicting is set once the destination is being evicted, accessed atomically
+
+	counters *writerCounters
+	done     chan struct{} // done is closed when the go-routine has written or discarded the queue
+	iowc     io.WriteCloser
+	onFull   AsyncFullPolicy
+	queue    chan []byte
+}
+
+// AsyncFullPolicy determines what an AsyncMultiWriteCloser does with a write for an io.WriteCloser
+// whose queue is full.
+type AsyncFullPolicy struct {
+	dropOldest bool // dropOldest discards the oldest queued write to make room
+	evictAfter int  // evictAfter is the number of consecutive full queues that evict the writer
+}
+
+// BlockWhenFull is the default AsyncFullPolicy. Write blocks until the queue of the io.WriteCloser
+// has room, so a slow writer eventually slows the producer, but never misses data.
+var BlockWhenFull = AsyncFullPolicy{}
+
+// DropOldestWhenFull is an AsyncFullPolicy that discards the oldest write queued for the
+// io.WriteCloser to make room for the new one, so a slow writer misses data, but always receives
+// the most recent writes.
+var DropOldestWhenFull = AsyncFullPolicy{dropOldest: true}
+
+// EvictAfterFull returns an AsyncFullPolicy that discards a write for the io.WriteCloser when its
+// queue is full, and removes and closes the writer once count consecutive writes found its queue
+// full, after it writes the data already queued.
+func EvictAfterFull(count int) AsyncFullPolicy {
+	if count < 1 {
+		count = 1
+	}
+	return AsyncFullPolicy{evictAfter: count}
+}
+
+// AsyncMultiWriteCloserSetter is any function that modifies an AsyncMultiWriteCloser being
+// instantiated.
+type AsyncMultiWriteCloserSetter func(*AsyncMultiWriteCloser) error
+
+// AsyncQueueLength is used to configure the number of writes queued for each writer of a new
+// AsyncMultiWriteCloser before its queue is full.
+func AsyncQueueLength(length int) AsyncMultiWriteCloserSetter {
+	return func(amw *AsyncMultiWriteCloser) error {
+		if length <= 0 {
+			return fmt.Errorf("queue length must be greater than 0: %d", length)
+		}
+		amw.queueLength = length
+		return nil
+	}
+}
+
+// AsyncCloseEvicted is used to configure whether a new AsyncMultiWriteCloser closes an
+// io.WriteCloser it removes because its Write returned an error or it fell behind. It does by
+// default. Writers still attached when the AsyncMultiWriteCloser is closed are always closed.
+func AsyncCloseEvicted(enabled bool) AsyncMultiWriteCloserSetter {
+	return func(amw *AsyncMultiWriteCloser) error {
+		amw.leaveOpen = !enabled
+		return nil
+	}
+}
+
+// AsyncClock is used to configure the clock a new AsyncMultiWriteCloser uses to measure the latency
+// of writes reported by WriterStats.
+func AsyncClock(clock Clock) AsyncMultiWriteCloserSetter {
+	return func(amw *AsyncMultiWriteCloser) error {
+		if clock == nil {
+			return fmt.Errorf("clock must not be nil")
+		}
+		amw.clock = clock
+		return nil
+	}
+}
+
+// AsyncOnFull is used to configure the AsyncFullPolicy of the writers added to a new
+// AsyncMultiWriteCloser with Add.
+func AsyncOnFull(policy AsyncFullPolicy) AsyncMultiWriteCloserSetter {
+	return func(amw *AsyncMultiWriteCloser) error {
+		amw.onFull = policy
+		return nil
+	}
+}
+
+// NewAsyncMultiWriteCloser returns an AsyncMultiWriteCloser that is go-routine safe, with no
+// writers.
+//
+//   amw, err := gorill.NewAsyncMultiWriteCloser(gorill.AsyncQueueLength(1024))
+//   if err != nil {
+//       return err
+//   }
+//   defer amw.Close()
+//   amw.Add(localFile)
+//   amw.Add(remoteConn) // a slow remote does not delay writes to the local file
+func NewAsyncMultiWriteCloser(setters ...AsyncMultiWriteCloserSetter) (*AsyncMultiWriteCloser, error) {
+	amw := &AsyncMultiWriteCloser{
+		clock:        systemClock{},
+		destinations: make(map[io.WriteCloser]*asyncDestination),
+		queueLength:  DefaultAsyncQueueLength,
+	}
+	for _, setter := range setters {
+		if err := setter(amw); err != nil {
+			return nil, err
+		}
+	}
+	return amw, nil
+}
+
+// Add adds an io.WriteCloser to the list of writers, starting the go-routine that writes to it. It
+// receives every write made after Add returns. It returns the number of io.WriteCloser instances
+// attached to the AsyncMultiWriteCloser.
+func (amw *AsyncMultiWriteCloser) Add(w io.WriteCloser) int {
+	return amw.AddWithPolicy(w, 0, amw.onFull)
+}
+
+// AddWriter adds an io.Writer that must never be closed, such as os.Stdout, like Add, after
+// wrapping it with NopCloseWriter, so that neither Close nor eviction closes it. Because wrapping
+// the same io.Writer again yields an equal value, it can be removed with
+// Remove(gorill.NopCloseWriter(w)).
+//
+//   amw.AddWriter(os.Stderr)
+func (amw *AsyncMultiWriteCloser) AddWriter(w io.Writer) int {
+	return amw.Add(NopCloseWriter(w))
+}
+
+// AddWithPolicy adds an io.WriteCloser like Add, but with its own high-water mark, which is the
+// number of writes queued for it before its queue is full, and its own AsyncFullPolicy. A
+// high-water mark that is not greater than 0 uses the queue length of the AsyncMultiWriteCloser.
+//
+//   amw.AddWithPolicy(archive, 0, gorill.BlockWhenFull)            // never misses data
+//   amw.AddWithPolicy(dashboard, 16, gorill.DropOldestWhenFull)    // only needs recent data
+//   amw.AddWithPolicy(subscriber, 256, gorill.EvictAfterFull(10))  // must keep up
+func (amw *AsyncMultiWriteCloser) AddWithPolicy(w io.WriteCloser, highWater int, policy AsyncFullPolicy) int {
+	amw.lock.Lock()
+	defer amw.lock.Unlock()
+
+	if highWater <= 0 {
+		highWater = amw.queueLength
+	}
+	if _, ok := amw.destinations[w]; !ok && !amw.halted {
+		d := &asyncDestination{
+			counters: &writerCounters{stats: WriterStats{Writer: w}},
+			done:     make(chan struct{}),
+			iowc:     w,
+			onFull:   policy,
+			queue:    make(chan []byte, highWater),
+		}
+		amw.destinations[w] = d
+		amw.running.Add(1)
+		go amw.drain(d)
+	}
+	return len(amw.destinations)
+}
+
+// drain writes each queued write to the destination until its queue is closed. After the first
+// error, it discards the remaining queued writes, and evicts the destination.
+func (amw *AsyncMultiWriteCloser) drain(d *asyncDestination) {
+	defer amw.running.Done()
+	defer close(d.done)
+
+	var failed bool
+	for data := range d.queue {
+		if failed {
+			continue
+		}
+		started := amw.clock.Now()
+		n, err := d.iowc.Write(data)
+		d.counters.record(n, err, amw.clock.Now().Sub(started))
+		if err != nil {
+			failed = true
+			// Evict from another go-routine, because a Write blocked on
+			// this queue holds the lock needed to remove it.
+			amw.running.Add(1)
+			go amw.evict(d)
+		}
+	}
+}
+
+// enqueue queues data for the destination, applying its AsyncFullPolicy when its queue is full.
+// The caller must hold the read lock.
+func (amw *AsyncMultiWriteCloser) enqueue(d *asyncDestination, data []byte) {
+	if atomic.LoadInt32(&d.evicting) == 1 {
+		return
+	}
+	select {
+	case d.queue <- data:
+		atomic.StoreInt32(&d.full, 0)
+		return
+	default:
+	}
+
+	switch {
+	case d.onFull.dropOldest:
+		for {
+			select {
+			case <-d.queue:
+				atomic.AddInt64(&amw.statDropped, 1)
+			default:
+			}
+			select {
+			case d.queue <- data:
+				return
+			default: // another write refilled the queue
+			}
+		}
+	case d.onFull.evictAfter > 0:
+		atomic.AddInt64(&amw.statDropped, 1)
+		if atomic.AddInt32(&d.full, 1) >= int32(d.onFull.evictAfter) && atomic.CompareAndSwapInt32(&d.evicting, 0, 1) {
+			amw.running.Add(1)
+			go amw.evict(d) // the caller holds the read lock
+		}
+	default:
+		d.queue <- data
+	}
+}
+
+// evict removes a destination whose Write returned an error, or which fell too far behind, and
+// closes it, unless it was already removed.
+func (amw *AsyncMultiWriteCloser) evict(d *asyncDestination) {
+	defer amw.running.Done()
+
+	amw.lock.Lock()
+	if amw.destinations[d.iowc] != d {
+		amw.lock.Unlock()
+		return
+	}
+	atomic.AddInt64(&amw.statEvicted, 1)
+	delete(amw.destinations, d.iowc)
+	close(d.queue)
+	amw.lock.Unlock()
+
+	<-d.done
+	if !amw.leaveOpen {
+		_ = d.iowc.Close()
+	}
+}
+
+// Remove removes an io.WriteCloser from the list of writers, after the writes already queued for it
+// have been written, without closing it. It returns the number of io.WriteCloser instances attached
+// to the AsyncMultiWriteCloser.
+func (amw *AsyncMultiWriteCloser) Remove(w io.WriteCloser) int {
+	amw.lock.Lock()
+	d, ok := amw.destinations[w]
+	if ok {
+		delete(amw.destinations, w)
+		close(d.queue)
+	}
+	count := len(amw.destinations)
+	amw.lock.Unlock()
+
+	if ok {
+		<-d.done
+	}
+	return count
+}
+
+// Count returns the number of io.WriteCloser instances attached to the AsyncMultiWriteCloser.
+func (amw *AsyncMultiWriteCloser) Count() int {
+	amw.lock.RLock()
+	defer amw.lock.RUnlock()
+
+	return len(amw.destinations)
+}
+
+// WriterStats returns the statistics of each io.WriteCloser attached to the AsyncMultiWriteCloser,
+// in no particular order. The statistics of a writer are discarded when it is removed.
+func (amw *AsyncMultiWriteCloser) WriterStats() []WriterStats {
+	amw.lock.RLock()
+	defer amw.lock.RUnlock()
+
+	stats := make([]WriterStats, 0, len(amw.destinations))
+	for _, d := range amw.destinations {
+		stats = append(stats, d.counters.snapshot())
+	}
+	return stats
+}
+
+// Write enqueues a copy of data for each writer, and returns len(data) without waiting for the
+// writes to complete. When the queue of a writer is full, it applies the writer's AsyncFullPolicy.
+func (amw *AsyncMultiWriteCloser) Write(data []byte) (int, error) {
+	amw.lock.RLock()
+	defer amw.lock.RUnlock()
+
+	if amw.halted {
+		return 0, ErrWriteAfterClose{}
+	}
+	if len(amw.destinations) > 0 {
+		buf := append([]byte(nil), data...) // shared by every queue, which only reads it
+		for _, d := range amw.destinations {
+			amw.enqueue(d, buf)
+		}
+	}
+	return len(data), nil
+}
+
+// Close waits for every writer to write the data queued for it, then flushes each writer that has a
+// Flush method returning an error, and closes each writer. Subsequent writes return
+// ErrWriteAfterClose. When writers return errors, the returned ErrList holds a MemberError
+// identifying each writer that failed.
+func (amw *AsyncMultiWriteCloser) Close() error {
+	amw.lock.Lock()
+	if amw.halted {
+		amw.lock.Unlock()
+		return nil
+	}
+	amw.halted = true
+	destinations := make([]*asyncDestination, 0, len(amw.destinations))
+	for w, d := range amw.destinations {
+		destinations = append(destinations, d)
+		delete(amw.destinations, w)
+		close(d.queue)
+	}
+	amw.lock.Unlock()
+
+	amw.running.Wait()
+	var errors ErrList
+	for i, d := range destinations {
+		errors.AppendMember(i, d.iowc, flushAndClose(d.iowc))
+	}
+	return errors.Err()
+}
+
+// Stats returns the number of writers, the number of writes dropped because the queue of a writer
+// was full, and the number of writers removed because they returned an error or fell behind.
+func (amw *AsyncMultiWriteCloser) Stats() map[string]int64 {
+	return map[string]int64{
+		"dropped": atomic.LoadInt64(&amw.statDropped),
+		"evicted": atomic.LoadInt64(&amw.statEvicted),
+		"writers": int64(amw.Count()),
+	}
+}
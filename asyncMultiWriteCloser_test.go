@@ -0,0 +1,287 @@
+package gorill
+
+import (
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAsyncMultiWriteCloser(t *testing.T) {
+	t.Run("invalid queue length", func(t *testing.T) {
+		_, err := NewAsyncMultiWriteCloser(AsyncQueueLength(0))
+		ensureError(t, err, "queue length must be greater than 0")
+	})
+
+	t.Run("writes to every writer", func(t *testing.T) {
+		amw, err := NewAsyncMultiWriteCloser()
+		ensureError(t, err)
+		bb1, bb2 := NewNopCloseBuffer(), NewNopCloseBuffer()
+		amw.Add(bb1)
+		if got, want := amw.Add(bb2), 2; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		buf := []byte(alphabet)
+		n, err := amw.Write(buf)
+		ensureError(t, err)
+		if got, want := n, len(alphabet); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		buf[0] = 'X' // the queued copy is not affected
+
+		ensureError(t, amw.Close())
+		for _, bb := range []*NopCloseBuffer{bb1, bb2} {
+			if got, want := bb.String(), alphabet; got != want {
+				t.Errorf("GOT: %q; WANT: %q", got, want)
+			}
+			if !bb.IsClosed() {
+				t.Errorf("GOT: %v; WANT: %v", false, true)
+			}
+		}
+
+		_, err = amw.Write(buf)
+		testErrorType(t, err, ErrWriteAfterClose{})
+		ensureError(t, amw.Close())
+	})
+
+	t.Run("slow writer does not block others", func(t *testing.T) {
+		amw, err := NewAsyncMultiWriteCloser()
+		ensureError(t, err)
+		stuck := &gatedWriteCloser{started: make(chan struct{}, 3), gate: make(chan struct{})}
+		fast := new(lockedRecorder)
+		amw.Add(stuck)
+		amw.Add(fast)
+
+		for i := 0; i < 3; i++ {
+			_, err = amw.Write([]byte(alphabet))
+			ensureError(t, err)
+		}
+		<-stuck.started
+		deadline := time.Now().Add(time.Second)
+		want := alphabet + alphabet + alphabet
+		for {
+			got, _ := fast.snapshot()
+			if got == want {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("GOT: %q; WANT: %q", got, want)
+			}
+			time.Sleep(time.Millisecond)
+		}
+
+		close(stuck.gate)
+		ensureError(t, amw.Close())
+		if !stuck.isClosed() {
+			t.Errorf("GOT: %v; WANT: %v", false, true)
+		}
+	})
+
+	t.Run("evicts failed writer", func(t *testing.T) {
+		amw, err := NewAsyncMultiWriteCloser()
+		ensureError(t, err)
+		bad := &testWriteCloser{}
+		amw.Add(bad)
+
+		_, err = amw.Write([]byte(alphabet))
+		ensureError(t, err)
+		deadline := time.Now().Add(time.Second)
+		for amw.Count() > 0 && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		if got, want := amw.Count(), 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureError(t, amw.Close())
+	})
+
+	t.Run("remove flushes queued writes", func(t *testing.T) {
+		amw, err := NewAsyncMultiWriteCloser()
+		ensureError(t, err)
+		bb := NewNopCloseBuffer()
+		amw.Add(bb)
+		_, err = amw.Write([]byte(alphabet))
+		ensureError(t, err)
+
+		if got, want := amw.Remove(bb), 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := bb.String(), alphabet; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+		if bb.IsClosed() {
+			t.Errorf("GOT: %v; WANT: %v", true, false)
+		}
+		ensureError(t, amw.Close())
+	})
+}
+
+func TestAsyncMultiWriteCloserFullPolicy(t *testing.T) {
+	t.Run("drop oldest", func(t *testing.T) {
+		amw, err := NewAsyncMultiWriteCloser()
+		ensureError(t, err)
+		stuck := &gatedWriteCloser{started: make(chan struct{}, 10), gate: make(chan struct{})}
+		amw.AddWithPolicy(stuck, 2, DropOldestWhenFull)
+
+		_, err = amw.Write([]byte("1"))
+		ensureError(t, err)
+		<-stuck.started // "1" is being written, leaving the queue empty
+		for _, s := range []string{"2", "3", "4", "5"} {
+			_, err = amw.Write([]byte(s))
+			ensureError(t, err)
+		}
+		if got, want := amw.Stats()["dropped"], int64(2); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		close(stuck.gate)
+		ensureError(t, amw.Close())
+		if got, want := len(stuck.started), 2; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want) // "4" and "5" were written after "1"
+		}
+	})
+
+	t.Run("evict after full", func(t *testing.T) {
+		amw, err := NewAsyncMultiWriteCloser(AsyncQueueLength(1), AsyncOnFull(EvictAfterFull(2)))
+		ensureError(t, err)
+		stuck := &gatedWriteCloser{started: make(chan struct{}, 10), gate: make(chan struct{})}
+		fast := NewNopCloseBuffer()
+		amw.Add(stuck)
+		amw.AddWithPolicy(fast, 10, BlockWhenFull)
+
+		_, err = amw.Write([]byte("1"))
+		ensureError(t, err)
+		<-stuck.started
+		for _, s := range []string{"2", "3", "4"} { // "2" fills the queue
+			_, err = amw.Write([]byte(s))
+			ensureError(t, err)
+		}
+
+		deadline := time.Now().Add(time.Second)
+		for amw.Count() > 1 && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		if got, want := amw.Count(), 1; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		stats := amw.Stats()
+		if got, want := stats["dropped"], int64(2); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := stats["evicted"], int64(1); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		close(stuck.gate)
+		ensureError(t, amw.Close())
+		if got, want := fast.String(), "1234"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+		if !stuck.isClosed() {
+			t.Errorf("GOT: %v; WANT: %v", false, true)
+		}
+	})
+}
+
+func TestAsyncMultiWriteCloserWriterStats(t *testing.T) {
+	amw, err := NewAsyncMultiWriteCloser()
+	ensureError(t, err)
+	bb := NewNopCloseBuffer()
+	amw.Add(bb)
+	_, err = amw.Write([]byte(alphabet))
+	ensureError(t, err)
+
+	deadline := time.Now().Add(time.Second)
+	var stats []WriterStats
+	for {
+		stats = amw.WriterStats()
+		if len(stats) == 1 && stats[0].Bytes == int64(len(alphabet)) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("GOT: %v; WANT: %d bytes", stats, len(alphabet))
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if got, want := stats[0].Writer, io.WriteCloser(bb); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	ensureError(t, amw.Close())
+}
+
+func TestAsyncMultiWriteCloserCloseEvicted(t *testing.T) {
+	amw, err := NewAsyncMultiWriteCloser(AsyncCloseEvicted(false))
+	ensureError(t, err)
+	bad := &testWriteCloser{}
+	amw.Add(bad)
+
+	_, err = amw.Write([]byte(alphabet))
+	ensureError(t, err)
+	deadline := time.Now().Add(time.Second)
+	for amw.Count() > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	ensureError(t, amw.Close()) // waits for the eviction to complete
+	if bad.IsClosed() {
+		t.Errorf("GOT: %v; WANT: %v", true, false)
+	}
+}
+
+func TestAsyncMultiWriteCloserAddWriter(t *testing.T) {
+	amw, err := NewAsyncMultiWriteCloser()
+	ensureError(t, err)
+	bb := NewNopCloseBuffer()
+	if got, want := amw.AddWriter(bb), 1; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	_, err = amw.Write([]byte(alphabet))
+	ensureError(t, err)
+	ensureError(t, amw.Close())
+	if bb.String() != alphabet {
+		t.Errorf("GOT: %v; WANT: %v", bb.String(), alphabet)
+	}
+	if bb.IsClosed() {
+		t.Errorf("GOT: %v; WANT: %v", true, false)
+	}
+}
+
+func TestAsyncMultiWriteCloserCloseFlushes(t *testing.T) {
+	amw, err := NewAsyncMultiWriteCloser()
+	ensureError(t, err)
+	fr := &flushRecorder{}
+	amw.Add(fr)
+	ensureError(t, amw.Close(), "close failed")
+	if want := []string{"flush", "close"}; !reflect.DeepEqual(fr.calls, want) {
+		t.Errorf("GOT: %v; WANT: %v", fr.calls, want)
+	}
+}
+
+func TestAsyncMultiWriteCloserClock(t *testing.T) {
+	_, err := NewAsyncMultiWriteCloser(AsyncClock(nil))
+	ensureError(t, err, "clock must not be nil")
+
+	fc := NewFakeClock(time.Unix(0, 0))
+	amw, err := NewAsyncMultiWriteCloser(AsyncClock(fc))
+	ensureError(t, err)
+	amw.Add(&advancingWriteCloser{NopCloseBuffer: NewNopCloseBuffer(), clock: fc, d: 5 * time.Millisecond})
+	_, err = amw.Write([]byte(alphabet))
+	ensureError(t, err)
+
+	deadline := time.Now().Add(time.Second)
+	var stats []WriterStats
+	for {
+		stats = amw.WriterStats()
+		if len(stats) == 1 && stats[0].Bytes == int64(len(alphabet)) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("GOT: %v; WANT: %d bytes", stats, len(alphabet))
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if got, want := stats[0].LastLatency, 5*time.Millisecond; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	ensureError(t, amw.Close())
+}
@@ -0,0 +1,213 @@
+package gorill
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// AuditRecord describes a single operation performed on a stream wrapped by an
+// AuditWriter or an AuditReader. It never includes the payload itself.
+type AuditRecord struct {
+	Label    string        // Label identifies the audited stream, when configured
+	Op       string        // Op is one of OpRead, OpWrite, or OpClose
+	Time     time.Time     // Time is when the operation started
+	Bytes    int           // Bytes is the number of bytes transferred
+	Duration time.Duration // Duration is how long the operation took
+	Err      error         // Err is the error returned by the operation, if any
+}
+
+// AuditFormatter converts an AuditRecord to the bytes written to the audit
+// sink. It ought to return a complete, newline terminated record.
+type AuditFormatter func(AuditRecord) []byte
+
+// auditJSON is the structure of a record written by the default formatter.
+type auditJSON struct {
+	Label      string `json:"label,omitempty"`
+	Op         string `json:"op"`
+	Time       string `json:"time"`
+	Bytes      int    `json:"bytes"`
+	DurationNS int64  `json:"duration_ns"`
+	Err        string `json:"error,omitempty"`
+}
+
+// formatAuditJSON formats an AuditRecord as a single line of JSON.
+func formatAuditJSON(rec AuditRecord) []byte {
+	aj := auditJSON{
+		Label:      rec.Label,
+		Op:         rec.Op,
+		Time:       rec.Time.UTC().Format(time.RFC3339Nano),
+		Bytes:      rec.Bytes,
+		DurationNS: int64(rec.Duration),
+	}
+	if rec.Err != nil {
+		aj.Err = rec.Err.Error()
+	}
+	buf, _ := json.Marshal(aj) // cannot fail for this structure
+	return append(buf, '\n')
+}
+
+// auditor writes audit records to a sink.
+type auditor struct {
+	clock  Clock
+	format AuditFormatter
+	label  string
+	lock   sync.Mutex
+	sink   io.Writer
+}
+
+// AuditSetter is any function that modifies an AuditWriter or AuditReader
+// being instantiated.
+type AuditSetter func(*auditor) error
+
+// AuditFormat is used to configure how audit records are formatted. By default
+// each record is written as a single line of JSON.
+func AuditFormat(format AuditFormatter) AuditSetter {
+	return func(a *auditor) error {
+		a.format = format
+		return nil
+	}
+}
+
+// AuditClock is used to configure the clock read to timestamp audit records
+// and to measure the duration of each operation.
+func AuditClock(clock Clock) AuditSetter {
+	return func(a *auditor) error {
+		if clock == nil {
+			return fmt.Errorf("clock must not be nil")
+		}
+		a.clock = clock
+		return nil
+	}
+}
+
+// AuditLabel is used to configure a label included in every audit record, to
+// distinguish records from multiple streams written to the same sink.
+func AuditLabel(label string) AuditSetter {
+	return func(a *auditor) error {
+		a.label = label
+		return nil
+	}
+}
+
+func newAuditor(sink io.Writer, setters []AuditSetter) (*auditor, error) {
+	a := &auditor{clock: systemClock{}, format: formatAuditJSON, sink: sink}
+	for _, setter := range setters {
+		if err := setter(a); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// record writes an audit record for an operation that started at the
+// specified time. When the operation succeeded, it returns the error from
+// writing the audit record, so that a failure to audit is never silent.
+func (a *auditor) record(op string, started time.Time, n int, err error) error {
+	buf := a.format(AuditRecord{
+		Label:    a.label,
+		Op:       op,
+		Time:     started,
+		Bytes:    n,
+		Duration: a.clock.Now().Sub(started),
+		Err:      err,
+	})
+	a.lock.Lock()
+	_, aerr := a.sink.Write(buf)
+	a.lock.Unlock()
+	if err == nil {
+		err = aerr
+	}
+	return err
+}
+
+// AuditWriter is an io.WriteCloser that writes an audit record describing each
+// operation to a secondary sink, providing an I/O trail without modifying
+// application code.
+//
+// When writing an audit record fails for an operation that otherwise
+// succeeded, the operation returns the error from the audit sink. Multiple
+// audited streams that share a sink require the sink to be safe for concurrent
+// use, such as a LockingWriteCloser.
+type AuditWriter struct {
+	a    *auditor
+	iowc io.WriteCloser
+}
+
+// NewAuditWriter returns an AuditWriter that writes to iowc, and writes an
+// audit record for each operation to sink.
+//
+//   aw, err := gorill.NewAuditWriter(payments, auditLog, gorill.AuditLabel("payments"))
+//   if err != nil {
+//       return err
+//   }
+//   defer aw.Close()
+func NewAuditWriter(iowc io.WriteCloser, sink io.Writer, setters ...AuditSetter) (*AuditWriter, error) {
+	a, err := newAuditor(sink, setters)
+	if err != nil {
+		return nil, err
+	}
+	return &AuditWriter{a: a, iowc: iowc}, nil
+}
+
+// Write writes data to the underlying io.WriteCloser, then writes an audit
+// record describing the write.
+func (aw *AuditWriter) Write(data []byte) (int, error) {
+	started := aw.a.clock.Now()
+	n, err := aw.iowc.Write(data)
+	return n, aw.a.record(OpWrite, started, n, err)
+}
+
+// Unwrap returns the audited io.WriteCloser.
+func (aw *AuditWriter) Unwrap() io.WriteCloser { return aw.iowc }
+
+// Close closes the underlying io.WriteCloser, then writes an audit record
+// describing the close.
+func (aw *AuditWriter) Close() error {
+	started := aw.a.clock.Now()
+	err := aw.iowc.Close()
+	return aw.a.record(OpClose, started, 0, err)
+}
+
+// AuditReader is an io.ReadCloser that writes an audit record describing each
+// operation to a secondary sink, providing an I/O trail without modifying
+// application code.
+//
+// The io.EOF returned at the end of the stream is recorded like any other
+// error. When writing an audit record fails for an operation that otherwise
+// succeeded, the operation returns the error from the audit sink.
+type AuditReader struct {
+	a    *auditor
+	iorc io.ReadCloser
+}
+
+// NewAuditReader returns an AuditReader that reads from iorc, and writes an
+// audit record for each operation to sink.
+func NewAuditReader(iorc io.ReadCloser, sink io.Writer, setters ...AuditSetter) (*AuditReader, error) {
+	a, err := newAuditor(sink, setters)
+	if err != nil {
+		return nil, err
+	}
+	return &AuditReader{a: a, iorc: iorc}, nil
+}
+
+// Read reads from the underlying io.ReadCloser, then writes an audit record
+// describing the read.
+func (ar *AuditReader) Read(buf []byte) (int, error) {
+	started := ar.a.clock.Now()
+	n, err := ar.iorc.Read(buf)
+	return n, ar.a.record(OpRead, started, n, err)
+}
+
+// Unwrap returns the audited io.ReadCloser.
+func (ar *AuditReader) Unwrap() io.ReadCloser { return ar.iorc }
+
+// Close closes the underlying io.ReadCloser, then writes an audit record
+// describing the close.
+func (ar *AuditReader) Close() error {
+	started := ar.a.clock.Now()
+	err := ar.iorc.Close()
+	return ar.a.record(OpClose, started, 0, err)
+}
@@ -0,0 +1,112 @@
+package gorill
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func decodeAuditRecords(tb testing.TB, buf []byte) []map[string]interface{} {
+	tb.Helper()
+	var records []map[string]interface{}
+	for _, line := range strings.SplitAfter(string(buf), "\n") {
+		if line == "" {
+			continue
+		}
+		var rec map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			tb.Fatalf("cannot decode audit record %q: %s", line, err)
+		}
+		records = append(records, rec)
+	}
+	return records
+}
+
+func TestAuditWriter(t *testing.T) {
+	payload := new(bytes.Buffer)
+	sink := new(bytes.Buffer)
+	aw, err := NewAuditWriter(NopCloseWriter(payload), sink, AuditLabel("payments"))
+	ensureError(t, err)
+
+	n, err := aw.Write([]byte(alphabet))
+	ensureError(t, err)
+	if got, want := n, len(alphabet); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	ensureError(t, aw.Close())
+
+	if got, want := payload.String(), alphabet; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	if strings.Contains(sink.String(), "abcdef") {
+		t.Errorf("GOT: %q; WANT: audit records without payload", sink.String())
+	}
+	records := decodeAuditRecords(t, sink.Bytes())
+	if got, want := len(records), 2; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := records[0]["op"], OpWrite; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := records[0]["bytes"], float64(len(alphabet)); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := records[0]["label"], "payments"; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if _, ok := records[0]["error"]; ok {
+		t.Errorf("GOT: %v; WANT: no error", records[0]["error"])
+	}
+	if got, want := records[1]["op"], OpClose; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
+
+func TestAuditWriterRecordsError(t *testing.T) {
+	sink := new(bytes.Buffer)
+	aw, err := NewAuditWriter(&testWriteCloser{}, sink)
+	ensureError(t, err)
+
+	_, err = aw.Write([]byte(alphabet))
+	testErrorType(t, err, io.ErrShortWrite)
+
+	records := decodeAuditRecords(t, sink.Bytes())
+	if got, want := records[0]["error"], io.ErrShortWrite.Error(); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
+
+func TestAuditWriterSinkError(t *testing.T) {
+	aw, err := NewAuditWriter(NopCloseWriter(new(bytes.Buffer)), &testWriteCloser{})
+	ensureError(t, err)
+
+	n, err := aw.Write([]byte(alphabet))
+	testErrorType(t, err, io.ErrShortWrite)
+	if got, want := n, len(alphabet); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
+
+func TestAuditReader(t *testing.T) {
+	sink := new(bytes.Buffer)
+	ar, err := NewAuditReader(ioutil.NopCloser(strings.NewReader(alphabet)), sink,
+		AuditFormat(func(rec AuditRecord) []byte {
+			return []byte(fmt.Sprintf("%s %d %v\n", rec.Op, rec.Bytes, rec.Err))
+		}))
+	ensureError(t, err)
+
+	buf, err := ioutil.ReadAll(ar)
+	ensureError(t, err)
+	if got, want := string(buf), alphabet; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	ensureError(t, ar.Close())
+
+	if got, want := sink.String(), "read 27 <nil>\nread 0 EOF\nclose 0 <nil>\n"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+}
@@ -0,0 +1,172 @@
+package benchmarks
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/karrick/gorill"
+)
+
+var writeSizes = []struct {
+	name string
+	size int
+}{
+	{"64B", 64},
+	{"1KiB", 1024},
+	{"16KiB", 16 * 1024},
+}
+
+// measure runs write b.N times, and reports the write system calls made per
+// operation when the platform can count them.
+func measure(b *testing.B, size int, write func([]byte) error, finish func() error) {
+	b.Helper()
+	buf := make([]byte, size)
+	b.SetBytes(int64(size))
+	before, countErr := WriteSyscalls()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := write(buf); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := finish(); err != nil {
+		b.Fatal(err)
+	}
+	b.StopTimer()
+	if countErr == nil {
+		if after, err := WriteSyscalls(); err == nil {
+			b.ReportMetric(float64(after-before)/float64(b.N), "syscalls/op")
+		}
+	}
+}
+
+func writeTo(iow io.Writer) func([]byte) error {
+	return func(buf []byte) error {
+		_, err := iow.Write(buf)
+		return err
+	}
+}
+
+func tempFile(b *testing.B) *os.File {
+	b.Helper()
+	fh, err := ioutil.TempFile("", "gorill-bench")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { _ = os.Remove(fh.Name()) })
+	return fh
+}
+
+// loopback returns the client side of a TCP connection whose server side
+// discards everything it receives.
+func loopback(b *testing.B) net.Conn {
+	b.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		server, err := l.Accept()
+		if err != nil {
+			return
+		}
+		_, _ = io.Copy(ioutil.Discard, server)
+		_ = server.Close()
+	}()
+	client, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		b.Fatal(err)
+	}
+	return client
+}
+
+func BenchmarkFileRaw(b *testing.B) {
+	for _, ws := range writeSizes {
+		b.Run(ws.name, func(b *testing.B) {
+			fh := tempFile(b)
+			measure(b, ws.size, writeTo(fh), fh.Close)
+		})
+	}
+}
+
+func BenchmarkFileSpooled(b *testing.B) {
+	for _, ws := range writeSizes {
+		b.Run(ws.name, func(b *testing.B) {
+			sw, err := gorill.NewSpooledWriteCloser(tempFile(b))
+			if err != nil {
+				b.Fatal(err)
+			}
+			measure(b, ws.size, writeTo(sw), sw.Close)
+		})
+	}
+}
+
+func BenchmarkFileCoalescing(b *testing.B) {
+	for _, ws := range writeSizes {
+		b.Run(ws.name, func(b *testing.B) {
+			cw, err := gorill.NewCoalescingWriter(tempFile(b))
+			if err != nil {
+				b.Fatal(err)
+			}
+			measure(b, ws.size, writeTo(cw), cw.Close)
+		})
+	}
+}
+
+func BenchmarkConnRaw(b *testing.B) {
+	for _, ws := range writeSizes {
+		b.Run(ws.name, func(b *testing.B) {
+			conn := loopback(b)
+			measure(b, ws.size, writeTo(conn), conn.Close)
+		})
+	}
+}
+
+func BenchmarkConnSpooled(b *testing.B) {
+	for _, ws := range writeSizes {
+		b.Run(ws.name, func(b *testing.B) {
+			sw, err := gorill.NewSpooledWriteCloser(loopback(b))
+			if err != nil {
+				b.Fatal(err)
+			}
+			measure(b, ws.size, writeTo(sw), sw.Close)
+		})
+	}
+}
+
+func BenchmarkConnCoalescing(b *testing.B) {
+	for _, ws := range writeSizes {
+		b.Run(ws.name, func(b *testing.B) {
+			cw, err := gorill.NewCoalescingWriter(loopback(b))
+			if err != nil {
+				b.Fatal(err)
+			}
+			measure(b, ws.size, writeTo(cw), cw.Close)
+		})
+	}
+}
+
+func BenchmarkFanOut(b *testing.B) {
+	const writers = 16
+	for _, concurrency := range []struct {
+		name  string
+		limit int
+	}{
+		{"PerWriter", 0},
+		{"Concurrency4", 4},
+		{"Serial", 1},
+	} {
+		b.Run(concurrency.name, func(b *testing.B) {
+			mw := gorill.NewMultiWriteCloserFanOut()
+			for i := 0; i < writers; i++ {
+				mw.Add(tempFile(b))
+			}
+			mw.SetConcurrency(concurrency.limit)
+			measure(b, 1024, writeTo(mw), mw.Close)
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package gorill
+
+import (
+	"io"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// BOMEncoding identifies the text encoding indicated by a byte order mark.
+type BOMEncoding int
+
+const (
+	// BOMNone indicates the stream did not start with a byte order mark.
+	BOMNone BOMEncoding = iota
+
+	// BOMUTF8 indicates the stream started with the UTF-8 byte order mark.
+	BOMUTF8
+
+	// BOMUTF16LE indicates the stream started with the UTF-16 little endian
+	// byte order mark.
+	BOMUTF16LE
+
+	// BOMUTF16BE indicates the stream started with the UTF-16 big endian byte
+	// order mark.
+	BOMUTF16BE
+)
+
+// String returns the name of the encoding.
+func (e BOMEncoding) String() string {
+	switch e {
+	case BOMNone:
+		return "none"
+	case BOMUTF8:
+		return "UTF-8"
+	case BOMUTF16LE:
+		return "UTF-16LE"
+	case BOMUTF16BE:
+		return "UTF-16BE"
+	default:
+		return "unknown"
+	}
+}
+
+// BOMReader is an io.Reader that detects and removes a byte order mark from the
+// start of the source io.Reader. When configured to do so, it also transcodes
+// UTF-16 encoded streams to UTF-8, so text produced on Windows can be consumed
+// by the line oriented utilities in this library.
+type BOMReader struct {
+	ior       io.Reader
+	transcode bool
+
+	detected bool
+	encoding BOMEncoding
+	head     []byte // head holds bytes read during detection that are not BOM
+	err      error  // err holds error encountered during detection
+
+	// The following are used only while transcoding.
+	carry   []byte // carry holds an odd trailing byte from previous read
+	high    rune   // high holds a pending high surrogate, or 0
+	pending []byte // pending holds transcoded bytes not yet returned
+	scratch []byte
+}
+
+// BOMReaderSetter is any function that modifies a BOMReader being instantiated.
+type BOMReaderSetter func(*BOMReader) error
+
+// TranscodeUTF16 is used to configure a new BOMReader to transcode a UTF-16
+// stream to UTF-8. Invalid UTF-16 sequences are replaced by U+FFFD.
+func TranscodeUTF16() BOMReaderSetter {
+	return func(br *BOMReader) error {
+		br.transcode = true
+		return nil
+	}
+}
+
+// NewBOMReader returns a BOMReader that removes any byte order mark from the
+// start of ior.
+//
+//   br, err := gorill.NewBOMReader(fh, gorill.TranscodeUTF16())
+//   if err != nil {
+//       return err
+//   }
+//   lines := bufio.NewScanner(br)
+func NewBOMReader(ior io.Reader, setters ...BOMReaderSetter) (*BOMReader, error) {
+	br := &BOMReader{ior: ior}
+	for _, setter := range setters {
+		if err := setter(br); err != nil {
+			return nil, err
+		}
+	}
+	return br, nil
+}
+
+// Encoding returns the encoding indicated by the byte order mark at the start
+// of the stream. It reads from the source io.Reader when no bytes have yet been
+// read, and returns any error encountered while doing so, other than io.EOF.
+func (br *BOMReader) Encoding() (BOMEncoding, error) {
+	br.detect()
+	if br.err == io.EOF {
+		return br.encoding, nil
+	}
+	return br.encoding, br.err
+}
+
+// detect reads enough bytes to identify a byte order mark.
+func (br *BOMReader) detect() {
+	if br.detected {
+		return
+	}
+	br.detected = true
+
+	var reserved [3]byte
+	var n int
+	for n < len(reserved) && br.err == nil {
+		var nr int
+		nr, br.err = br.ior.Read(reserved[n:])
+		n += nr
+	}
+	head := reserved[:n]
+
+	switch {
+	case n >= 3 && head[0] == 0xEF && head[1] == 0xBB && head[2] == 0xBF:
+		br.encoding = BOMUTF8
+		head = head[3:]
+	case n >= 2 && head[0] == 0xFF && head[1] == 0xFE:
+		br.encoding = BOMUTF16LE
+		head = head[2:]
+	case n >= 2 && head[0] == 0xFE && head[1] == 0xFF:
+		br.encoding = BOMUTF16BE
+		head = head[2:]
+	}
+	br.head = append([]byte(nil), head...)
+}
+
+// Read reads up to len(p) bytes into p. It returns the number of bytes read (0
+// <= n <= len(p)) and any error encountered.
+func (br *BOMReader) Read(p []byte) (int, error) {
+	br.detect()
+	if br.transcode && (br.encoding == BOMUTF16LE || br.encoding == BOMUTF16BE) {
+		return br.readTranscoded(p)
+	}
+	if len(br.head) > 0 {
+		n := copy(p, br.head)
+		br.head = br.head[n:]
+		return n, nil
+	}
+	if br.err != nil {
+		return 0, br.err
+	}
+	return br.ior.Read(p)
+}
+
+// readTranscoded reads UTF-16 from the source and returns it as UTF-8.
+func (br *BOMReader) readTranscoded(p []byte) (int, error) {
+	for len(br.pending) == 0 {
+		if len(br.head) > 0 {
+			br.carry = append(br.carry, br.head...)
+			br.head = nil
+		}
+		if br.err != nil {
+			br.finishTranscode()
+			if len(br.pending) == 0 {
+				return 0, br.err
+			}
+			break
+		}
+		if br.scratch == nil {
+			br.scratch = make([]byte, DefaultBufSize)
+		}
+		var n int
+		n, br.err = br.ior.Read(br.scratch)
+		br.carry = append(br.carry, br.scratch[:n]...)
+		br.transcodeCarry()
+	}
+	n := copy(p, br.pending)
+	br.pending = br.pending[n:]
+	return n, nil
+}
+
+// transcodeCarry converts as many complete UTF-16 code units from carry as
+// possible, appending the UTF-8 result to pending.
+func (br *BOMReader) transcodeCarry() {
+	i := 0
+	for ; i+1 < len(br.carry); i += 2 {
+		var u uint16
+		if br.encoding == BOMUTF16LE {
+			u = uint16(br.carry[i]) | uint16(br.carry[i+1])<<8
+		} else {
+			u = uint16(br.carry[i])<<8 | uint16(br.carry[i+1])
+		}
+		r := rune(u)
+		switch {
+		case r >= 0xD800 && r < 0xDC00: // high surrogate
+			if br.high != 0 {
+				br.appendRune(utf8.RuneError)
+			}
+			br.high = r
+		case r >= 0xDC00 && r < 0xE000: // low surrogate
+			if br.high != 0 {
+				br.appendRune(utf16.DecodeRune(br.high, r))
+				br.high = 0
+			} else {
+				br.appendRune(utf8.RuneError)
+			}
+		default:
+			if br.high != 0 {
+				br.appendRune(utf8.RuneError)
+				br.high = 0
+			}
+			br.appendRune(r)
+		}
+	}
+	br.carry = br.carry[:copy(br.carry, br.carry[i:])]
+}
+
+// finishTranscode flushes any incomplete sequence at the end of the stream as
+// U+FFFD.
+func (br *BOMReader) finishTranscode() {
+	if br.high != 0 {
+		br.appendRune(utf8.RuneError)
+		br.high = 0
+	}
+	if len(br.carry) > 0 {
+		br.appendRune(utf8.RuneError)
+		br.carry = br.carry[:0]
+	}
+}
+
+func (br *BOMReader) appendRune(r rune) {
+	var buf [utf8.UTFMax]byte
+	n := utf8.EncodeRune(buf[:], r)
+	br.pending = append(br.pending, buf[:n]...)
+}
+
+// Unwrap returns the underlying io.Reader.
+func (br *BOMReader) Unwrap() io.Reader { return br.ior }
@@ -0,0 +1,66 @@
+package gorill
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+	"testing/iotest"
+)
+
+func TestBOMReader(t *testing.T) {
+	test := func(t *testing.T, input []byte, transcode bool, wantEncoding BOMEncoding, want string) {
+		t.Helper()
+		var setters []BOMReaderSetter
+		if transcode {
+			setters = append(setters, TranscodeUTF16())
+		}
+		br, err := NewBOMReader(bytes.NewReader(input), setters...)
+		ensureError(t, err)
+		enc, err := br.Encoding()
+		ensureError(t, err)
+		if got, want := enc, wantEncoding; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		buf, err := ioutil.ReadAll(br)
+		ensureError(t, err)
+		if got, want := string(buf), want; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	}
+
+	t.Run("empty", func(t *testing.T) {
+		test(t, nil, false, BOMNone, "")
+	})
+	t.Run("short without bom", func(t *testing.T) {
+		test(t, []byte("a"), false, BOMNone, "a")
+	})
+	t.Run("without bom", func(t *testing.T) {
+		test(t, []byte("abc\n"), false, BOMNone, "abc\n")
+	})
+	t.Run("utf-8", func(t *testing.T) {
+		test(t, []byte("\xEF\xBB\xBFabc\n"), false, BOMUTF8, "abc\n")
+	})
+	t.Run("utf-16le without transcoding", func(t *testing.T) {
+		test(t, []byte("\xFF\xFEa\x00"), false, BOMUTF16LE, "a\x00")
+	})
+	t.Run("utf-16le", func(t *testing.T) {
+		// "a€😀\n"
+		test(t, []byte("\xFF\xFEa\x00\xAC\x20\x3D\xD8\x00\xDE\n\x00"), true, BOMUTF16LE, "a€😀\n")
+	})
+	t.Run("utf-16be", func(t *testing.T) {
+		test(t, []byte("\xFE\xFF\x00a\x20\xAC\xD8\x3D\xDE\x00\x00\n"), true, BOMUTF16BE, "a€😀\n")
+	})
+	t.Run("utf-16 invalid sequences", func(t *testing.T) {
+		// unpaired low surrogate, unpaired high surrogate, odd trailing byte
+		test(t, []byte("\xFF\xFE\x00\xDCa\x00\x3D\xD8b\x00c"), true, BOMUTF16LE, "�a�b�")
+	})
+	t.Run("utf-16 split across reads", func(t *testing.T) {
+		br, err := NewBOMReader(iotest.OneByteReader(bytes.NewReader([]byte("\xFF\xFE\x3D\xD8\x00\xDEa\x00"))), TranscodeUTF16())
+		ensureError(t, err)
+		buf, err := ioutil.ReadAll(iotest.OneByteReader(br))
+		ensureError(t, err)
+		if got, want := string(buf), "😀a"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+}
@@ -0,0 +1,292 @@
+package gorill
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// SlowConsumerPolicy determines what a BroadcastReader does when a consumer's
+// buffer does not have room for the next chunk of data read from the source.
+type SlowConsumerPolicy int
+
+const (
+	// BlockSlowConsumer causes the BroadcastReader to stop reading from its
+	// source until the slow consumer has made room in its buffer. This policy
+	// guarantees the consumer receives every byte, at the expense of all other
+	// consumers.
+	BlockSlowConsumer SlowConsumerPolicy = iota
+
+	// DropSlowConsumerData causes the bytes that do not fit in the consumer's
+	// buffer to be discarded for that consumer only.
+	DropSlowConsumerData
+
+	// EvictSlowConsumer causes the consumer to be detached from the
+	// BroadcastReader. After it reads the bytes remaining in its buffer, its
+	// Read method returns ErrSlowConsumer.
+	EvictSlowConsumer
+)
+
+// ErrSlowConsumer is returned by a BroadcastConsumer after it has been evicted
+// from its BroadcastReader for not keeping up with the source.
+type ErrSlowConsumer struct{}
+
+// Error returns a string representation of a ErrSlowConsumer error instance.
+func (e ErrSlowConsumer) Error() string {
+	return "consumer evicted for not keeping up with broadcast"
+}
+
+// BroadcastReader reads from a single source io.ReadCloser exactly once, and
+// delivers a copy of each byte read to every one of its attached consumers. It
+// is the read side mirror of MultiWriteCloserFanOut.
+//
+// The source is not read from until the first consumer is added. Consumers
+// added later only receive bytes read from the source after they were added.
+type BroadcastReader struct {
+	lock      sync.Mutex
+	consumers map[*BroadcastConsumer]struct{}
+	err       error // err is the terminal error returned by the source
+	iorc      io.ReadCloser
+	halted    bool
+	started   bool
+}
+
+// NewBroadcastReader returns a BroadcastReader that will read from the provided
+// io.ReadCloser.
+//
+//   br := gorill.NewBroadcastReader(stdout)
+//   first, _ := br.Add(4096, gorill.BlockSlowConsumer)
+//   second, _ := br.Add(4096, gorill.DropSlowConsumerData)
+//   go io.Copy(os.Stdout, first)
+//   go io.Copy(logFile, second)
+func NewBroadcastReader(iorc io.ReadCloser) *BroadcastReader {
+	return &BroadcastReader{
+		consumers: make(map[*BroadcastConsumer]struct{}),
+		iorc:      iorc,
+	}
+}
+
+// Add returns a new BroadcastConsumer that receives a copy of all bytes read
+// from the source from this point forward. Each consumer buffers at most
+// bufSize bytes that have not yet been read, and policy determines what
+// happens when the consumer's buffer is full.
+func (br *BroadcastReader) Add(bufSize int, policy SlowConsumerPolicy) (*BroadcastConsumer, error) {
+	if bufSize <= 0 {
+		return nil, fmt.Errorf("buffer size must be greater than 0: %d", bufSize)
+	}
+	switch policy {
+	case BlockSlowConsumer, DropSlowConsumerData, EvictSlowConsumer:
+	default:
+		return nil, fmt.Errorf("invalid slow consumer policy: %d", policy)
+	}
+
+	c := &BroadcastConsumer{br: br, bufSize: bufSize, policy: policy}
+	c.cond = sync.NewCond(&c.lock)
+
+	br.lock.Lock()
+	defer br.lock.Unlock()
+
+	if br.halted || br.err != nil {
+		// Source has already been exhausted, so new consumer only sees the
+		// terminal condition.
+		c.err = br.err
+		if c.err == nil {
+			c.err = io.EOF
+		}
+		return c, nil
+	}
+
+	br.consumers[c] = struct{}{}
+	if !br.started {
+		br.started = true
+		go br.pump()
+	}
+	return c, nil
+}
+
+// Unwrap returns the io.ReadCloser being broadcast.
+func (br *BroadcastReader) Unwrap() io.ReadCloser { return br.iorc }
+
+// Close closes the source io.ReadCloser, and causes all consumers to return
+// io.EOF after they have read the bytes remaining in their buffers.
+func (br *BroadcastReader) Close() error {
+	br.lock.Lock()
+	if br.halted {
+		br.lock.Unlock()
+		return nil
+	}
+	br.halted = true
+	consumers := br.detachAll()
+	br.lock.Unlock()
+
+	for _, c := range consumers {
+		c.finish(io.EOF)
+	}
+	return br.iorc.Close()
+}
+
+// Count returns the number of consumers attached to the BroadcastReader.
+func (br *BroadcastReader) Count() int {
+	br.lock.Lock()
+	defer br.lock.Unlock()
+
+	return len(br.consumers)
+}
+
+// detachAll removes and returns all attached consumers. Caller must hold the
+// lock.
+func (br *BroadcastReader) detachAll() []*BroadcastConsumer {
+	consumers := make([]*BroadcastConsumer, 0, len(br.consumers))
+	for c := range br.consumers {
+		consumers = append(consumers, c)
+	}
+	br.consumers = make(map[*BroadcastConsumer]struct{})
+	return consumers
+}
+
+// snapshot returns the list of attached consumers.
+func (br *BroadcastReader) snapshot() []*BroadcastConsumer {
+	br.lock.Lock()
+	defer br.lock.Unlock()
+
+	consumers := make([]*BroadcastConsumer, 0, len(br.consumers))
+	for c := range br.consumers {
+		consumers = append(consumers, c)
+	}
+	return consumers
+}
+
+// remove detaches the specified consumer.
+func (br *BroadcastReader) remove(c *BroadcastConsumer) {
+	br.lock.Lock()
+	delete(br.consumers, c)
+	br.lock.Unlock()
+}
+
+// pump reads from the source until it returns an error, delivering the bytes
+// read to all attached consumers.
+func (br *BroadcastReader) pump() {
+	buf := make([]byte, bufSize)
+	for {
+		n, err := br.iorc.Read(buf)
+		if n > 0 {
+			for _, c := range br.snapshot() {
+				if !c.push(buf[:n]) {
+					br.remove(c)
+				}
+			}
+		}
+		if err != nil {
+			br.lock.Lock()
+			br.err = err
+			consumers := br.detachAll()
+			br.lock.Unlock()
+
+			for _, c := range consumers {
+				c.finish(err)
+			}
+			return
+		}
+	}
+}
+
+// BroadcastConsumer is an io.ReadCloser that receives a copy of the bytes read
+// by its BroadcastReader.
+type BroadcastConsumer struct {
+	br      *BroadcastReader
+	buf     bytes.Buffer
+	bufSize int
+	cond    *sync.Cond
+	err     error // err is returned after buf is drained
+	halted  bool
+	lock    sync.Mutex
+	policy  SlowConsumerPolicy
+}
+
+// Read reads up to len(p) bytes into p. It blocks until data is available, and
+// returns the source's terminal error after all buffered data has been read.
+func (c *BroadcastConsumer) Read(p []byte) (int, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for c.buf.Len() == 0 && c.err == nil && !c.halted {
+		c.cond.Wait()
+	}
+	if c.halted {
+		return 0, ErrReadAfterClose{}
+	}
+	if c.buf.Len() == 0 {
+		return 0, c.err
+	}
+	n, _ := c.buf.Read(p)
+	c.cond.Broadcast() // wake the source pump if blocked on this consumer
+	return n, nil
+}
+
+// Close detaches the consumer from its BroadcastReader, and discards any
+// buffered data.
+func (c *BroadcastConsumer) Close() error {
+	c.br.remove(c)
+
+	c.lock.Lock()
+	c.halted = true
+	c.buf.Reset()
+	c.cond.Broadcast()
+	c.lock.Unlock()
+	return nil
+}
+
+// Len returns the number of bytes buffered and not yet read by the consumer.
+func (c *BroadcastConsumer) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.buf.Len()
+}
+
+// push appends data to the consumer buffer according to its slow consumer
+// policy. It returns false when the consumer ought to be detached.
+func (c *BroadcastConsumer) push(data []byte) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for len(data) > 0 {
+		if c.halted || c.err != nil {
+			return false
+		}
+		room := c.bufSize - c.buf.Len()
+		if room >= len(data) {
+			c.buf.Write(data)
+			break
+		}
+		switch c.policy {
+		case BlockSlowConsumer:
+			if room > 0 {
+				c.buf.Write(data[:room])
+				data = data[room:]
+				c.cond.Broadcast()
+			}
+			c.cond.Wait()
+		case DropSlowConsumerData:
+			c.buf.Write(data[:room])
+			data = nil
+		case EvictSlowConsumer:
+			c.err = ErrSlowConsumer{}
+			c.cond.Broadcast()
+			return false
+		}
+	}
+	c.cond.Broadcast()
+	return true
+}
+
+// finish records the terminal error for the consumer.
+func (c *BroadcastConsumer) finish(err error) {
+	c.lock.Lock()
+	if c.err == nil {
+		c.err = err
+	}
+	c.cond.Broadcast()
+	c.lock.Unlock()
+}
@@ -0,0 +1,91 @@
+package gorill
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func TestBroadcastReader(t *testing.T) {
+	t.Run("invalid buffer size", func(t *testing.T) {
+		br := NewBroadcastReader(NopCloseReader(bytes.NewReader(nil)))
+		_, err := br.Add(0, BlockSlowConsumer)
+		ensureError(t, err, "buffer size must be greater than 0")
+	})
+
+	t.Run("every consumer receives every byte", func(t *testing.T) {
+		br := NewBroadcastReader(NopCloseReader(bytes.NewReader(largeBuf)))
+		br.lock.Lock() // prevent pump from reading until all consumers attached
+		br.started = true
+		br.lock.Unlock()
+
+		var consumers []*BroadcastConsumer
+		for i := 0; i < 3; i++ {
+			c, err := br.Add(64, BlockSlowConsumer)
+			ensureError(t, err)
+			consumers = append(consumers, c)
+		}
+		go br.pump()
+
+		results := make(chan []byte, len(consumers))
+		for _, c := range consumers {
+			go func(c io.Reader) {
+				buf, _ := ioutil.ReadAll(c)
+				results <- buf
+			}(c)
+		}
+		for range consumers {
+			if got, want := <-results, largeBuf; !bytes.Equal(got, want) {
+				t.Errorf("GOT: %v; WANT: %v", len(got), len(want))
+			}
+		}
+	})
+
+	t.Run("drop policy discards overflow", func(t *testing.T) {
+		br := NewBroadcastReader(NopCloseReader(bytes.NewReader([]byte(alphabet))))
+		c, err := br.Add(4, DropSlowConsumerData)
+		ensureError(t, err)
+
+		buf, err := ioutil.ReadAll(c)
+		ensureError(t, err)
+		if got, want := string(buf), "abcd"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("evict policy detaches consumer", func(t *testing.T) {
+		br := NewBroadcastReader(NopCloseReader(bytes.NewReader([]byte(alphabet))))
+		c, err := br.Add(4, EvictSlowConsumer)
+		ensureError(t, err)
+
+		_, err = ioutil.ReadAll(c)
+		testErrorType(t, err, ErrSlowConsumer{})
+		if got, want := br.Count(), 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("read after close", func(t *testing.T) {
+		br := NewBroadcastReader(NopCloseReader(bytes.NewReader([]byte(alphabet))))
+		c, err := br.Add(64, BlockSlowConsumer)
+		ensureError(t, err)
+		ensureError(t, c.Close())
+
+		_, err = c.Read(make([]byte, 8))
+		testErrorType(t, err, ErrReadAfterClose{})
+	})
+
+	t.Run("consumer added after source exhausted", func(t *testing.T) {
+		br := NewBroadcastReader(NopCloseReader(bytes.NewReader(nil)))
+		ensureError(t, br.Close())
+		c, err := br.Add(64, BlockSlowConsumer)
+		ensureError(t, err)
+
+		n, err := c.Read(make([]byte, 8))
+		ensureError(t, err, "EOF")
+		if got, want := n, 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
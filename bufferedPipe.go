@@ -0,0 +1,238 @@
+package gorill
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// DefaultBufferedPipeSize is the default number of bytes buffered in each
+// direction of a buffered pipe.
+const DefaultBufferedPipeSize = 64 * 1024
+
+// pipeAddr is the net.Addr of both ends of a buffered pipe.
+type pipeAddr struct{}
+
+func (pipeAddr) Network() string { return "pipe" }
+func (pipeAddr) String() string  { return "pipe" }
+
+// pipeDeadline is a deadline for one kind of operation on one end of a buffered
+// pipe. It wakes the waiters of its buffer when it expires.
+type pipeDeadline struct {
+	clock Clock
+	t     time.Time
+	timer Timer
+}
+
+// set changes the deadline, arranging for cond to be broadcast when it
+// expires. Caller must hold the lock associated with cond.
+func (d *pipeDeadline) set(t time.Time, cond *sync.Cond) {
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	d.t = t
+	if !t.IsZero() {
+		d.timer = d.clock.AfterFunc(t.Sub(d.clock.Now()), func() {
+			cond.L.Lock()
+			cond.Broadcast()
+			cond.L.Unlock()
+		})
+	}
+}
+
+// expired returns true when the deadline has passed.
+func (d *pipeDeadline) expired() bool {
+	return !d.t.IsZero() && !d.clock.Now().Before(d.t)
+}
+
+// pipeBuffer holds the bytes in flight in one direction of a buffered pipe.
+type pipeBuffer struct {
+	buf           bytes.Buffer
+	cond          *sync.Cond
+	lock          sync.Mutex
+	readDeadline  pipeDeadline
+	readerClosed  bool
+	size          int
+	writeDeadline pipeDeadline
+	writerClosed  bool
+}
+
+func newPipeBuffer(size int, clock Clock) *pipeBuffer {
+	pb := &pipeBuffer{
+		readDeadline:  pipeDeadline{clock: clock},
+		size:          size,
+		writeDeadline: pipeDeadline{clock: clock},
+	}
+	pb.cond = sync.NewCond(&pb.lock)
+	return pb
+}
+
+func (pb *pipeBuffer) read(p []byte) (int, error) {
+	pb.lock.Lock()
+	defer pb.lock.Unlock()
+
+	for {
+		switch {
+		case pb.readerClosed:
+			return 0, io.ErrClosedPipe
+		case pb.readDeadline.expired():
+			return 0, ErrDeadlineExceeded{}
+		case pb.buf.Len() > 0:
+			n, _ := pb.buf.Read(p)
+			pb.cond.Broadcast() // wake writer waiting for room
+			return n, nil
+		case pb.writerClosed:
+			return 0, io.EOF
+		case len(p) == 0:
+			return 0, nil
+		}
+		pb.cond.Wait()
+	}
+}
+
+func (pb *pipeBuffer) write(data []byte) (int, error) {
+	pb.lock.Lock()
+	defer pb.lock.Unlock()
+
+	var written int
+	for {
+		switch {
+		case pb.writerClosed, pb.readerClosed:
+			return written, io.ErrClosedPipe
+		case pb.writeDeadline.expired():
+			return written, ErrDeadlineExceeded{}
+		}
+		if room := pb.size - pb.buf.Len(); room > 0 {
+			if room > len(data)-written {
+				room = len(data) - written
+			}
+			pb.buf.Write(data[written : written+room])
+			written += room
+			pb.cond.Broadcast() // wake reader waiting for data
+		}
+		if written == len(data) {
+			return written, nil
+		}
+		pb.cond.Wait()
+	}
+}
+
+// BufferedPipeConn is one end of an in-memory, full duplex, buffered pipe
+// created by NewBufferedPipe. Unlike net.Pipe, writes complete as soon as the
+// data fits in the buffer, rather than waiting for the other end to read it. It
+// implements net.Conn, including deadlines, so it may be used with http.Server
+// and http.Transport in tests and for in-process transports.
+type BufferedPipeConn struct {
+	in  *pipeBuffer // in holds bytes written by the other end
+	out *pipeBuffer // out holds bytes written by this end
+}
+
+// BufferedPipeSetter is any function that modifies the configuration of a
+// buffered pipe being instantiated.
+type BufferedPipeSetter func(*bufferedPipeConfig) error
+
+// bufferedPipeConfig holds the configuration shared by both ends of a buffered
+// pipe.
+type bufferedPipeConfig struct {
+	clock Clock
+}
+
+// BufferedPipeClock is used to configure the Clock that times the deadlines of
+// a buffered pipe. It is intended for tests, which may provide a FakeClock.
+func BufferedPipeClock(clock Clock) BufferedPipeSetter {
+	return func(c *bufferedPipeConfig) error {
+		if clock == nil {
+			return fmt.Errorf("clock must not be nil")
+		}
+		c.clock = clock
+		return nil
+	}
+}
+
+// NewBufferedPipe returns the two ends of a buffered pipe, each of which
+// buffers at most size bytes written to it that the other end has not yet read.
+// It returns an error when size is not greater than 0.
+//
+//   client, server, err := gorill.NewBufferedPipe(gorill.DefaultBufferedPipeSize)
+//   if err != nil {
+//       return err
+//   }
+//   transport := &http.Transport{
+//       DialContext: func(context.Context, string, string) (net.Conn, error) {
+//           return client, nil
+//       },
+//   }
+func NewBufferedPipe(size int, setters ...BufferedPipeSetter) (*BufferedPipeConn, *BufferedPipeConn, error) {
+	if size <= 0 {
+		return nil, nil, fmt.Errorf("buffer size must be greater than 0: %d", size)
+	}
+	c := &bufferedPipeConfig{clock: systemClock{}}
+	for _, setter := range setters {
+		if err := setter(c); err != nil {
+			return nil, nil, err
+		}
+	}
+	ab, ba := newPipeBuffer(size, c.clock), newPipeBuffer(size, c.clock)
+	return &BufferedPipeConn{in: ba, out: ab}, &BufferedPipeConn{in: ab, out: ba}, nil
+}
+
+// Read reads up to len(p) bytes written by the other end into p. It returns
+// io.EOF after the other end is closed and all data it wrote has been read.
+func (c *BufferedPipeConn) Read(p []byte) (int, error) { return c.in.read(p) }
+
+// Write writes data to the buffer read by the other end, blocking while the
+// buffer is full.
+func (c *BufferedPipeConn) Write(data []byte) (int, error) { return c.out.write(data) }
+
+// Close closes this end of the pipe. The other end reads any data already
+// written followed by io.EOF, and its writes fail with io.ErrClosedPipe.
+func (c *BufferedPipeConn) Close() error {
+	for _, pb := range []*pipeBuffer{c.in, c.out} {
+		pb.lock.Lock()
+		if pb == c.in {
+			pb.readerClosed = true
+			pb.buf.Reset()
+		} else {
+			pb.writerClosed = true
+		}
+		pb.cond.Broadcast()
+		pb.lock.Unlock()
+	}
+	return nil
+}
+
+// LocalAddr returns the address of this end of the pipe.
+func (c *BufferedPipeConn) LocalAddr() net.Addr { return pipeAddr{} }
+
+// RemoteAddr returns the address of the other end of the pipe.
+func (c *BufferedPipeConn) RemoteAddr() net.Addr { return pipeAddr{} }
+
+// SetDeadline sets both the read and write deadlines.
+func (c *BufferedPipeConn) SetDeadline(t time.Time) error {
+	c.SetReadDeadline(t)
+	return c.SetWriteDeadline(t)
+}
+
+// SetReadDeadline sets the time after which pending and future reads fail with
+// ErrDeadlineExceeded. A zero value disables the deadline.
+func (c *BufferedPipeConn) SetReadDeadline(t time.Time) error {
+	c.in.lock.Lock()
+	c.in.readDeadline.set(t, c.in.cond)
+	c.in.cond.Broadcast()
+	c.in.lock.Unlock()
+	return nil
+}
+
+// SetWriteDeadline sets the time after which pending and future writes fail
+// with ErrDeadlineExceeded. A zero value disables the deadline.
+func (c *BufferedPipeConn) SetWriteDeadline(t time.Time) error {
+	c.out.lock.Lock()
+	c.out.writeDeadline.set(t, c.out.cond)
+	c.out.cond.Broadcast()
+	c.out.lock.Unlock()
+	return nil
+}
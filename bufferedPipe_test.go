@@ -0,0 +1,158 @@
+package gorill
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Ensure BufferedPipeConn satisfies net.Conn.
+var _ net.Conn = &BufferedPipeConn{}
+
+func TestBufferedPipe(t *testing.T) {
+	t.Run("invalid size", func(t *testing.T) {
+		_, _, err := NewBufferedPipe(0)
+		ensureError(t, err, "buffer size must be greater than 0: 0")
+	})
+
+	t.Run("write completes without reader", func(t *testing.T) {
+		a, b, err := NewBufferedPipe(64)
+		ensureError(t, err)
+		n, err := a.Write([]byte(alphabet))
+		ensureError(t, err)
+		if got, want := n, len(alphabet); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureError(t, a.Close())
+		buf, err := ioutil.ReadAll(b)
+		ensureError(t, err)
+		if got, want := string(buf), alphabet; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+		_, err = b.Write([]byte(alphabet))
+		testErrorType(t, err, io.ErrClosedPipe)
+	})
+
+	t.Run("large write blocks until read", func(t *testing.T) {
+		a, b, err := NewBufferedPipe(4)
+		ensureError(t, err)
+		go func() {
+			_, _ = a.Write([]byte(alphabet))
+			_ = a.Close()
+		}()
+		buf, err := ioutil.ReadAll(b)
+		ensureError(t, err)
+		if got, want := string(buf), alphabet; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("read deadline", func(t *testing.T) {
+		_, b, err := NewBufferedPipe(4)
+		ensureError(t, err)
+		ensureError(t, b.SetReadDeadline(time.Now().Add(10*time.Millisecond)))
+		_, err = b.Read(make([]byte, 4))
+		if !errors.Is(err, ErrDeadlineExceeded{}) {
+			t.Errorf("GOT: %v; WANT: %v", err, ErrDeadlineExceeded{})
+		}
+		if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+			t.Errorf("GOT: %v; WANT: timeout", err)
+		}
+		ensureError(t, b.SetReadDeadline(time.Time{}))
+	})
+
+	t.Run("write deadline", func(t *testing.T) {
+		a, _, err := NewBufferedPipe(4)
+		ensureError(t, err)
+		ensureError(t, a.SetDeadline(time.Now().Add(10*time.Millisecond)))
+		n, err := a.Write([]byte(alphabet))
+		if !errors.Is(err, ErrDeadlineExceeded{}) {
+			t.Errorf("GOT: %v; WANT: %v", err, ErrDeadlineExceeded{})
+		}
+		if got, want := n, 4; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("nil clock", func(t *testing.T) {
+		_, _, err := NewBufferedPipe(4, BufferedPipeClock(nil))
+		ensureError(t, err, "clock must not be nil")
+	})
+
+	t.Run("clock", func(t *testing.T) {
+		fc := NewFakeClock(time.Now())
+		_, b, err := NewBufferedPipe(4, BufferedPipeClock(fc))
+		ensureError(t, err)
+		ensureError(t, b.SetReadDeadline(fc.Now().Add(time.Hour)))
+
+		errs := make(chan error, 1)
+		go func() {
+			_, err := b.Read(make([]byte, 4))
+			errs <- err
+		}()
+
+		fc.Advance(time.Hour - time.Nanosecond)
+		select {
+		case err := <-errs:
+			t.Fatalf("GOT: %v; WANT: Read to block until the deadline", err)
+		case <-time.After(10 * time.Millisecond):
+		}
+		fc.Advance(time.Nanosecond)
+		if err := <-errs; !errors.Is(err, ErrDeadlineExceeded{}) {
+			t.Errorf("GOT: %v; WANT: %v", err, ErrDeadlineExceeded{})
+		}
+	})
+}
+
+// connListener is a net.Listener that returns each connection sent to it.
+type connListener struct {
+	conns chan net.Conn
+	once  sync.Once
+	done  chan struct{}
+}
+
+func (l *connListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, io.ErrClosedPipe
+	}
+}
+
+func (l *connListener) Close() error   { l.once.Do(func() { close(l.done) }); return nil }
+func (l *connListener) Addr() net.Addr { return pipeAddr{} }
+
+func TestBufferedPipeHTTP(t *testing.T) {
+	l := &connListener{conns: make(chan net.Conn, 1), done: make(chan struct{})}
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(alphabet))
+	})}
+	go server.Serve(l)
+	defer server.Close()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(context.Context, string, string) (net.Conn, error) {
+			c, s, err := NewBufferedPipe(DefaultBufferedPipeSize)
+			if err != nil {
+				return nil, err
+			}
+			l.conns <- s
+			return c, nil
+		},
+	}}
+	resp, err := client.Get("http://pipe/")
+	ensureError(t, err)
+	defer resp.Body.Close()
+	buf, err := ioutil.ReadAll(resp.Body)
+	ensureError(t, err)
+	if got, want := string(buf), alphabet; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+}
@@ -0,0 +1,91 @@
+package gorill
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// BufferedReadWriteCloser is an io.ReadWriteCloser that buffers reads from,
+// and spools writes to, a single underlying io.ReadWriteCloser, such as a
+// network connection. It is similar to bufio.ReadWriter, but its Close method
+// flushes any spooled data before closing the underlying io.ReadWriteCloser
+// exactly once.
+type BufferedReadWriteCloser struct {
+	br      *bufio.Reader
+	closeMu sync.Mutex
+	halted  bool
+	rwc     io.ReadWriteCloser
+	spooler *SpooledWriteCloser
+}
+
+// NewBufferedReadWriteCloser returns a BufferedReadWriteCloser that reads from
+// rwc using a buffer of readSize bytes, and writes to rwc through a
+// SpooledWriteCloser with a buffer of writeSize bytes. Additional setters may
+// be provided to further configure the SpooledWriteCloser, for instance its
+// flush periodicity.
+//
+//   brwc, err := gorill.NewBufferedReadWriteCloser(conn, 4096, 8192, gorill.Flush(time.Second))
+//   if err != nil {
+//       return err
+//   }
+//   defer brwc.Close() // flushes pending writes before closing conn
+func NewBufferedReadWriteCloser(rwc io.ReadWriteCloser, readSize, writeSize int, setters ...SpooledWriteCloserSetter) (*BufferedReadWriteCloser, error) {
+	if readSize <= 0 {
+		return nil, fmt.Errorf("read buffer size must be greater than 0: %d", readSize)
+	}
+	// The spooler must not close rwc, because Close closes rwc after the
+	// spooler has flushed.
+	spooler, err := NewSpooledWriteCloser(NopCloseWriter(rwc), append([]SpooledWriteCloserSetter{BufSize(writeSize)}, setters...)...)
+	if err != nil {
+		return nil, err
+	}
+	return &BufferedReadWriteCloser{
+		br:      bufio.NewReaderSize(rwc, readSize),
+		rwc:     rwc,
+		spooler: spooler,
+	}, nil
+}
+
+// Read reads up to len(p) bytes into p from the read buffer, filling the read
+// buffer from the underlying io.ReadWriteCloser as needed.
+func (b *BufferedReadWriteCloser) Read(p []byte) (int, error) {
+	return b.br.Read(p)
+}
+
+// Reader returns the bufio.Reader used for reading, which provides methods
+// such as ReadString and Peek.
+func (b *BufferedReadWriteCloser) Reader() *bufio.Reader { return b.br }
+
+// Write spools data to be written to the underlying io.ReadWriteCloser.
+func (b *BufferedReadWriteCloser) Write(data []byte) (int, error) {
+	return b.spooler.Write(data)
+}
+
+// Flush causes all spooled data to be written to the underlying
+// io.ReadWriteCloser.
+func (b *BufferedReadWriteCloser) Flush() error {
+	return b.spooler.Flush()
+}
+
+// Unwrap returns the underlying io.ReadWriteCloser.
+func (b *BufferedReadWriteCloser) Unwrap() io.ReadWriteCloser { return b.rwc }
+
+// Close flushes spooled data, then closes the underlying io.ReadWriteCloser,
+// returning any errors from either operation. Subsequent calls to Close return
+// nil.
+func (b *BufferedReadWriteCloser) Close() error {
+	b.closeMu.Lock()
+	defer b.closeMu.Unlock()
+
+	if b.halted {
+		return nil
+	}
+	b.halted = true
+
+	var errors ErrList
+	errors.Append(b.spooler.Close())
+	errors.Append(b.rwc.Close())
+	return errors.Err()
+}
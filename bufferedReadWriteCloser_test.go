@@ -0,0 +1,60 @@
+package gorill
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// testReadWriteCloser records the order of writes and close.
+type testReadWriteCloser struct {
+	io.Reader
+	bytes.Buffer
+	closed         int
+	writtenAtClose string
+}
+
+func (rwc *testReadWriteCloser) Read(p []byte) (int, error) { return rwc.Reader.Read(p) }
+
+func (rwc *testReadWriteCloser) Close() error {
+	rwc.closed++
+	rwc.writtenAtClose = rwc.Buffer.String()
+	return nil
+}
+
+func TestBufferedReadWriteCloser(t *testing.T) {
+	t.Run("invalid read size", func(t *testing.T) {
+		_, err := NewBufferedReadWriteCloser(&testReadWriteCloser{}, 0, 16)
+		ensureError(t, err, "read buffer size must be greater than 0")
+	})
+
+	t.Run("invalid write size", func(t *testing.T) {
+		_, err := NewBufferedReadWriteCloser(&testReadWriteCloser{}, 16, 0)
+		ensureError(t, err, "buffer size must be greater than 0")
+	})
+
+	t.Run("close flushes before closing", func(t *testing.T) {
+		rwc := &testReadWriteCloser{Reader: bytes.NewReader([]byte("request\n"))}
+		brwc, err := NewBufferedReadWriteCloser(rwc, 16, 1024)
+		ensureError(t, err)
+
+		line, err := brwc.Reader().ReadString('\n')
+		ensureError(t, err)
+		if got, want := line, "request\n"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+
+		_, err = brwc.Write([]byte("response\n"))
+		ensureError(t, err)
+
+		ensureError(t, brwc.Close())
+		ensureError(t, brwc.Close())
+
+		if got, want := rwc.closed, 1; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := rwc.writtenAtClose, "response\n"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+}
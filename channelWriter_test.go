@@ -20,8 +20,8 @@ func newChannelWriter(iowc io.WriteCloser) *channelWriter {
 		iowc: iowc,
 		jobs: make(chan rillJob, 1),
 	}
+	w.jobsDone.Add(1)
 	go func(w *channelWriter) {
-		w.jobsDone.Add(1)
 		for job := range w.jobs {
 			n, err := w.iowc.Write(job.data)
 			job.results <- rillResult{n, err}
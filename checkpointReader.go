@@ -0,0 +1,115 @@
+package gorill
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// CheckpointReader is an io.ReadCloser that tracks the absolute offset of the
+// bytes consumed from a stream, and periodically reports that offset, so that
+// an ingestion job may record its progress and later resume from the last
+// checkpoint rather than from the start of the stream.
+type CheckpointReader struct {
+	callback func(offset int64)
+	every    int64
+	iorc     io.ReadCloser
+	last     int64 // last is the offset most recently reported
+	offset   int64
+	resume   int64
+}
+
+// CheckpointReaderSetter is any function that modifies a CheckpointReader being
+// instantiated.
+type CheckpointReaderSetter func(*CheckpointReader) error
+
+// CheckpointEvery is used to configure a CheckpointReader to invoke callback
+// with the current offset each time at least the specified number of bytes
+// have been consumed since the previous checkpoint, and once more at the end
+// of the stream.
+func CheckpointEvery(size int64, callback func(offset int64)) CheckpointReaderSetter {
+	return func(cr *CheckpointReader) error {
+		if size <= 0 {
+			return fmt.Errorf("checkpoint size must be greater than 0: %d", size)
+		}
+		cr.callback = callback
+		cr.every = size
+		return nil
+	}
+}
+
+// CheckpointResume is used to configure a CheckpointReader to resume reading
+// at the specified offset, such as one previously reported to a checkpoint
+// callback. When the underlying io.ReadCloser is an io.Seeker it seeks to the
+// offset, otherwise it reads and discards bytes up to the offset.
+func CheckpointResume(offset int64) CheckpointReaderSetter {
+	return func(cr *CheckpointReader) error {
+		if offset < 0 {
+			return fmt.Errorf("resume offset must not be negative: %d", offset)
+		}
+		cr.resume = offset
+		return nil
+	}
+}
+
+// NewCheckpointReader returns a CheckpointReader that reads from iorc.
+//
+//   fh, err := os.Open(path)
+//   if err != nil {
+//       return err
+//   }
+//   cr, err := gorill.NewCheckpointReader(fh,
+//       gorill.CheckpointResume(state.Offset),
+//       gorill.CheckpointEvery(1<<20, func(offset int64) { state.Save(offset) }))
+//   if err != nil {
+//       return err
+//   }
+//   defer cr.Close()
+func NewCheckpointReader(iorc io.ReadCloser, setters ...CheckpointReaderSetter) (*CheckpointReader, error) {
+	cr := &CheckpointReader{iorc: iorc}
+	for _, setter := range setters {
+		if err := setter(cr); err != nil {
+			return nil, err
+		}
+	}
+	if cr.resume > 0 {
+		if s, ok := iorc.(io.Seeker); ok {
+			if _, err := s.Seek(cr.resume, io.SeekStart); err != nil {
+				return nil, fmt.Errorf("cannot seek to resume offset %d: %s", cr.resume, err)
+			}
+		} else if n, err := io.CopyN(ioutil.Discard, iorc, cr.resume); err != nil {
+			return nil, fmt.Errorf("cannot discard to resume offset %d: only %d bytes: %s", cr.resume, n, err)
+		}
+		cr.offset = cr.resume
+		cr.last = cr.resume
+	}
+	return cr, nil
+}
+
+// Offset returns the absolute offset in the stream of the next byte to be
+// read.
+func (cr *CheckpointReader) Offset() int64 {
+	return cr.offset
+}
+
+// Read reads from the underlying io.ReadCloser, and invokes the checkpoint
+// callback when due.
+func (cr *CheckpointReader) Read(buf []byte) (int, error) {
+	n, err := cr.iorc.Read(buf)
+	cr.offset += int64(n)
+	if cr.callback != nil {
+		if cr.offset-cr.last >= cr.every || (err == io.EOF && cr.offset > cr.last) {
+			cr.last = cr.offset
+			cr.callback(cr.offset)
+		}
+	}
+	return n, err
+}
+
+// Unwrap returns the underlying io.ReadCloser.
+func (cr *CheckpointReader) Unwrap() io.ReadCloser { return cr.iorc }
+
+// Close closes the underlying io.ReadCloser.
+func (cr *CheckpointReader) Close() error {
+	return cr.iorc.Close()
+}
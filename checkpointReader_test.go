@@ -0,0 +1,72 @@
+package gorill
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestCheckpointReaderCallbacks(t *testing.T) {
+	var checkpoints []int64
+	cr, err := NewCheckpointReader(ioutil.NopCloser(iotest.OneByteReader(strings.NewReader(alphabet))),
+		CheckpointEvery(10, func(offset int64) { checkpoints = append(checkpoints, offset) }))
+	ensureError(t, err)
+
+	buf, err := ioutil.ReadAll(cr)
+	ensureError(t, err)
+	if got, want := string(buf), alphabet; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	if got, want := cr.Offset(), int64(len(alphabet)); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	want := []int64{10, 20, 27}
+	if len(checkpoints) != len(want) || checkpoints[0] != want[0] || checkpoints[1] != want[1] || checkpoints[2] != want[2] {
+		t.Errorf("GOT: %v; WANT: %v", checkpoints, want)
+	}
+	ensureError(t, cr.Close())
+}
+
+func TestCheckpointReaderResumeDiscard(t *testing.T) {
+	cr, err := NewCheckpointReader(ioutil.NopCloser(strings.NewReader(alphabet)), CheckpointResume(20))
+	ensureError(t, err)
+
+	buf, err := ioutil.ReadAll(cr)
+	ensureError(t, err)
+	if got, want := string(buf), alphabet[20:]; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	if got, want := cr.Offset(), int64(len(alphabet)); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	_, err = NewCheckpointReader(ioutil.NopCloser(strings.NewReader(alphabet)), CheckpointResume(100))
+	ensureError(t, err, "cannot discard to resume offset 100")
+}
+
+func TestCheckpointReaderResumeSeek(t *testing.T) {
+	fh, err := ioutil.TempFile("", "gorill")
+	ensureError(t, err)
+	defer os.Remove(fh.Name())
+	_, err = fh.WriteString(alphabet)
+	ensureError(t, err)
+
+	cr, err := NewCheckpointReader(fh, CheckpointResume(24))
+	ensureError(t, err)
+	buf, err := ioutil.ReadAll(cr)
+	ensureError(t, err)
+	if got, want := string(buf), "yz\n"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	ensureError(t, cr.Close())
+}
+
+func TestCheckpointReaderInvalid(t *testing.T) {
+	_, err := NewCheckpointReader(ioutil.NopCloser(strings.NewReader(alphabet)), CheckpointEvery(0, nil))
+	ensureError(t, err, "checkpoint size must be greater than 0")
+
+	_, err = NewCheckpointReader(ioutil.NopCloser(strings.NewReader(alphabet)), CheckpointResume(-1))
+	ensureError(t, err, "resume offset must not be negative")
+}
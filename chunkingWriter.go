@@ -0,0 +1,145 @@
+package gorill
+
+import (
+	"crypto/sha256"
+	"fmt"
+)
+
+// Default content defined chunk sizes used by ChunkingWriter.
+const (
+	DefaultMinChunkSize = 2 * 1024
+	DefaultAvgChunkSize = 8 * 1024
+	DefaultMaxChunkSize = 64 * 1024
+)
+
+// gearTable holds the pseudo-random values used by the rolling hash. It is
+// deterministic so the same content always produces the same chunk boundaries.
+var gearTable [256]uint64
+
+func init() {
+	// splitmix64
+	var state uint64 = 0x9E3779B97F4A7C15
+	for i := range gearTable {
+		state += 0x9E3779B97F4A7C15
+		z := state
+		z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+		z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+		gearTable[i] = z ^ (z >> 31)
+	}
+}
+
+// ChunkCallback is invoked by a ChunkingWriter with each chunk and the SHA-256
+// digest of its contents. The chunk slice is only valid until the callback
+// returns.
+type ChunkCallback func(chunk []byte, sum [sha256.Size]byte) error
+
+// ChunkingWriter is an io.WriteCloser that splits the stream written to it at
+// content defined boundaries, as determined by a rolling hash, and emits each
+// chunk to a callback along with its SHA-256 digest. Because boundaries depend
+// on content rather than offset, inserting bytes into a stream only changes the
+// chunks near the insertion, making ChunkingWriter suitable for building
+// deduplicating storage and backup tools.
+type ChunkingWriter struct {
+	buf      []byte
+	callback ChunkCallback
+	halted   bool
+	hash     uint64
+	mask     uint64
+	max      int
+	min      int
+}
+
+// ChunkingWriterSetter is any function that modifies a ChunkingWriter being
+// instantiated.
+type ChunkingWriterSetter func(*ChunkingWriter) error
+
+// ChunkSizes is used to configure the minimum, average, and maximum chunk sizes
+// of a new ChunkingWriter. The average size must be a power of two.
+func ChunkSizes(min, avg, max int) ChunkingWriterSetter {
+	return func(cw *ChunkingWriter) error {
+		if min <= 0 {
+			return fmt.Errorf("minimum chunk size must be greater than 0: %d", min)
+		}
+		if avg < min || max < avg {
+			return fmt.Errorf("chunk sizes must satisfy min <= avg <= max: %d, %d, %d", min, avg, max)
+		}
+		if avg&(avg-1) != 0 {
+			return fmt.Errorf("average chunk size must be a power of two: %d", avg)
+		}
+		cw.min, cw.mask, cw.max = min, uint64(avg-1), max
+		return nil
+	}
+}
+
+// NewChunkingWriter returns a ChunkingWriter that invokes callback for each
+// chunk of the stream written to it.
+//
+//   cw, err := gorill.NewChunkingWriter(func(chunk []byte, sum [sha256.Size]byte) error {
+//       return store.PutIfAbsent(sum, chunk)
+//   })
+//   if err != nil {
+//       return err
+//   }
+//   if _, err = io.Copy(cw, fh); err != nil {
+//       return err
+//   }
+//   return cw.Close() // emits the final chunk
+func NewChunkingWriter(callback ChunkCallback, setters ...ChunkingWriterSetter) (*ChunkingWriter, error) {
+	if callback == nil {
+		return nil, fmt.Errorf("chunk callback must not be nil")
+	}
+	cw := &ChunkingWriter{
+		callback: callback,
+		mask:     DefaultAvgChunkSize - 1,
+		max:      DefaultMaxChunkSize,
+		min:      DefaultMinChunkSize,
+	}
+	for _, setter := range setters {
+		if err := setter(cw); err != nil {
+			return nil, err
+		}
+	}
+	cw.buf = make([]byte, 0, cw.max)
+	return cw, nil
+}
+
+// Write appends data to the current chunk, emitting each chunk to the callback
+// as its boundary is found. When the callback returns an error, Write returns
+// that error along with the number of bytes of data consumed before the
+// boundary.
+func (cw *ChunkingWriter) Write(data []byte) (int, error) {
+	if cw.halted {
+		return 0, ErrWriteAfterClose{}
+	}
+	for i, b := range data {
+		cw.buf = append(cw.buf, b)
+		cw.hash = (cw.hash << 1) + gearTable[b]
+		l := len(cw.buf)
+		if l >= cw.max || (l >= cw.min && cw.hash&cw.mask == 0) {
+			if err := cw.emit(); err != nil {
+				return i + 1, err
+			}
+		}
+	}
+	return len(data), nil
+}
+
+// emit invokes the callback with the current chunk, then starts a new chunk.
+func (cw *ChunkingWriter) emit() error {
+	err := cw.callback(cw.buf, sha256.Sum256(cw.buf))
+	cw.buf = cw.buf[:0]
+	cw.hash = 0
+	return err
+}
+
+// Close emits the final chunk, if any. It does not close anything else.
+func (cw *ChunkingWriter) Close() error {
+	if cw.halted {
+		return nil
+	}
+	cw.halted = true
+	if len(cw.buf) == 0 {
+		return nil
+	}
+	return cw.emit()
+}
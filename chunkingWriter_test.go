@@ -0,0 +1,84 @@
+package gorill
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"testing"
+)
+
+func chunkStream(tb testing.TB, data []byte, setters ...ChunkingWriterSetter) [][sha256.Size]byte {
+	tb.Helper()
+	var sums [][sha256.Size]byte
+	var total int
+	cw, err := NewChunkingWriter(func(chunk []byte, sum [sha256.Size]byte) error {
+		if sha256.Sum256(chunk) != sum {
+			tb.Errorf("chunk digest mismatch")
+		}
+		total += len(chunk)
+		sums = append(sums, sum)
+		return nil
+	}, setters...)
+	ensureError(tb, err)
+	_, err = io.Copy(cw, bytes.NewReader(data))
+	ensureError(tb, err)
+	ensureError(tb, cw.Close())
+	if got, want := total, len(data); got != want {
+		tb.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	return sums
+}
+
+func TestChunkingWriter(t *testing.T) {
+	t.Run("invalid sizes", func(t *testing.T) {
+		noop := func([]byte, [sha256.Size]byte) error { return nil }
+		_, err := NewChunkingWriter(noop, ChunkSizes(64, 100, 1024))
+		ensureError(t, err, "power of two")
+		_, err = NewChunkingWriter(noop, ChunkSizes(64, 32, 1024))
+		ensureError(t, err, "min <= avg <= max")
+		_, err = NewChunkingWriter(nil)
+		ensureError(t, err, "must not be nil")
+	})
+
+	// Build pseudo-random content without depending on math/rand.
+	data := make([]byte, 256*1024)
+	var state uint32 = 1
+	for i := range data {
+		state = state*1664525 + 1013904223
+		data[i] = byte(state >> 24)
+	}
+
+	t.Run("chunk sizes respected", func(t *testing.T) {
+		cw, err := NewChunkingWriter(func(chunk []byte, _ [sha256.Size]byte) error {
+			if len(chunk) > 4096 {
+				t.Errorf("chunk too large: %d", len(chunk))
+			}
+			return nil
+		}, ChunkSizes(512, 1024, 4096))
+		ensureError(t, err)
+		_, err = cw.Write(data)
+		ensureError(t, err)
+		ensureError(t, cw.Close())
+	})
+
+	t.Run("insertion only changes nearby chunks", func(t *testing.T) {
+		before := chunkStream(t, data, ChunkSizes(512, 1024, 4096))
+
+		modified := append(append(append([]byte(nil), data[:100000]...), "inserted"...), data[100000:]...)
+		after := chunkStream(t, modified, ChunkSizes(512, 1024, 4096))
+
+		seen := make(map[[sha256.Size]byte]struct{})
+		for _, sum := range before {
+			seen[sum] = struct{}{}
+		}
+		var common int
+		for _, sum := range after {
+			if _, ok := seen[sum]; ok {
+				common++
+			}
+		}
+		if common < len(before)-3 {
+			t.Errorf("GOT: %v common chunks; WANT: at least %v", common, len(before)-3)
+		}
+	})
+}
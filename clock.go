@@ -0,0 +1,284 @@
+package gorill
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock provides the current time, timers, and tickers to the time-dependent
+// types in this package. By default they use the system clock; a test may
+// configure them with a FakeClock to control time deterministically.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// After waits for the duration to elapse, then sends the current time on
+	// the returned channel.
+	After(d time.Duration) <-chan time.Time
+
+	// AfterFunc waits for the duration to elapse, then calls f.
+	AfterFunc(d time.Duration, f func()) Timer
+
+	// NewTimer returns a Timer that sends the current time on its channel once
+	// the duration elapses.
+	NewTimer(d time.Duration) Timer
+
+	// NewTicker returns a Ticker that sends the current time on its channel
+	// after each period. It panics when period is not greater than 0.
+	NewTicker(period time.Duration) Ticker
+
+	// Sleep pauses the calling go-routine for at least the duration.
+	Sleep(d time.Duration)
+}
+
+// Timer is a single event scheduled by Clock.NewTimer or Clock.AfterFunc.
+type Timer interface {
+	// C returns the channel on which the time is delivered, or nil for a Timer
+	// returned by AfterFunc, which calls its function instead.
+	C() <-chan time.Time
+
+	// Reset changes the Timer to fire once the duration elapses, returning
+	// false if it had already fired or been stopped. Like time.Timer, a Timer
+	// returned by NewTimer should be stopped, and its channel drained, before
+	// it is reset.
+	Reset(d time.Duration) bool
+
+	// Stop prevents the Timer from firing, returning false if it has already
+	// fired or been stopped.
+	Stop() bool
+}
+
+// Ticker delivers ticks at intervals, as returned by Clock.NewTicker.
+type Ticker interface {
+	// C returns the channel on which ticks are delivered.
+	C() <-chan time.Time
+
+	// Stop turns off the Ticker. It does not close the channel.
+	Stop()
+}
+
+// systemClock is the Clock backed by the time package.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return systemTimer{time.AfterFunc(d, f)}
+}
+func (systemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{time.NewTimer(d)}
+}
+func (systemClock) NewTicker(period time.Duration) Ticker {
+	return systemTicker{time.NewTicker(period)}
+}
+func (systemClock) Sleep(d time.Duration) { time.Sleep(d) }
+
+type systemTimer struct{ *time.Timer }
+
+func (t systemTimer) C() <-chan time.Time { return t.Timer.C }
+
+type systemTicker struct{ *time.Ticker }
+
+func (t systemTicker) C() <-chan time.Time { return t.Ticker.C }
+
+// FakeClock is a Clock whose time only changes when Advance is called, which
+// makes tests of time-dependent types fast and deterministic.
+//
+//   clock := gorill.NewFakeClock(time.Unix(0, 0))
+//   cw, err := gorill.NewCoalescingWriter(iowc, gorill.CoalesceClock(clock))
+//   if err != nil {
+//       t.Fatal(err)
+//   }
+//   cw.Write([]byte("small"))  // held for the coalescing window
+//   clock.Advance(time.Second) // writes the held bytes
+type FakeClock struct {
+	cond    *sync.Cond
+	lock    sync.Mutex
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+// fakeWaiter is a timer, ticker, or sleeper waiting for a FakeClock to reach
+// a particular time.
+type fakeWaiter struct {
+	c      chan time.Time
+	clock  *FakeClock
+	f      func()
+	period time.Duration // period is greater than 0 for tickers
+	when   time.Time
+}
+
+// NewFakeClock returns a FakeClock whose current time is now.
+func NewFakeClock(now time.Time) *FakeClock {
+	fc := &FakeClock{now: now}
+	fc.cond = sync.NewCond(&fc.lock)
+	return fc
+}
+
+// Now returns the current time of the FakeClock.
+func (fc *FakeClock) Now() time.Time {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+	return fc.now
+}
+
+// After returns a channel on which the time is sent once the FakeClock has
+// been advanced by at least d.
+func (fc *FakeClock) After(d time.Duration) <-chan time.Time {
+	return fc.schedule(d, 0, nil).c
+}
+
+// AfterFunc arranges for f to be called, from the go-routine that calls
+// Advance, once the FakeClock has been advanced by at least d.
+func (fc *FakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	return fc.schedule(d, 0, f)
+}
+
+// NewTimer returns a Timer that sends the time on its channel once the
+// FakeClock has been advanced by at least d.
+func (fc *FakeClock) NewTimer(d time.Duration) Timer {
+	return fc.schedule(d, 0, nil)
+}
+
+// NewTicker returns a Ticker that delivers a tick each time the FakeClock is
+// advanced by another period. Like time.Ticker, it drops ticks for a slow
+// receiver.
+func (fc *FakeClock) NewTicker(period time.Duration) Ticker {
+	if period <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	return fakeTicker{fc.schedule(period, period, nil)}
+}
+
+// Sleep blocks until the FakeClock has been advanced by at least d, by another
+// go-routine.
+func (fc *FakeClock) Sleep(d time.Duration) {
+	<-fc.After(d)
+}
+
+func (fc *FakeClock) schedule(d, period time.Duration, f func()) *fakeWaiter {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+
+	w := &fakeWaiter{c: make(chan time.Time, 1), clock: fc, f: f, period: period}
+	fc.add(w, d)
+	return w
+}
+
+// add schedules the waiter to fire once the FakeClock has been advanced by at
+// least d, or immediately, when a channel waiter has no time left to wait. The
+// caller must hold the lock.
+func (fc *FakeClock) add(w *fakeWaiter, d time.Duration) {
+	w.when = fc.now.Add(d)
+	if d <= 0 && w.period == 0 && w.f == nil {
+		w.fire(fc.now)
+		return
+	}
+	fc.waiters = append(fc.waiters, w)
+	fc.cond.Broadcast()
+}
+
+// remove removes the waiter from the FakeClock, returning false when it was
+// not waiting. The caller must hold the lock.
+func (fc *FakeClock) remove(w *fakeWaiter) bool {
+	for i, other := range fc.waiters {
+		if other == w {
+			fc.waiters = append(fc.waiters[:i], fc.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Advance moves the time of the FakeClock forward by d, firing each timer and
+// ticker that comes due, in time order, and calling functions scheduled by
+// AfterFunc before it returns.
+func (fc *FakeClock) Advance(d time.Duration) {
+	fc.lock.Lock()
+	end := fc.now.Add(d)
+	for {
+		sort.SliceStable(fc.waiters, func(i, j int) bool { return fc.waiters[i].when.Before(fc.waiters[j].when) })
+		if len(fc.waiters) == 0 || fc.waiters[0].when.After(end) {
+			break
+		}
+		w := fc.waiters[0]
+		fc.now = w.when
+		if w.period > 0 {
+			w.when = w.when.Add(w.period)
+		} else {
+			fc.waiters = fc.waiters[1:]
+		}
+		if w.f != nil {
+			// Release the lock so f may use the clock.
+			fc.lock.Unlock()
+			w.f()
+			fc.lock.Lock()
+		} else {
+			w.fire(fc.now)
+		}
+	}
+	fc.now = end
+	fc.lock.Unlock()
+}
+
+// BlockUntil blocks until at least count timers, tickers, and sleepers are
+// waiting on the FakeClock, which lets a test wait for a go-routine to start
+// waiting before advancing the clock.
+func (fc *FakeClock) BlockUntil(count int) {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+	for len(fc.waiters) < count {
+		fc.cond.Wait()
+	}
+}
+
+// fire sends now on the channel without blocking.
+func (w *fakeWaiter) fire(now time.Time) {
+	select {
+	case w.c <- now:
+	default:
+	}
+}
+
+// fakeTicker is the Ticker returned by FakeClock.NewTicker.
+type fakeTicker struct{ w *fakeWaiter }
+
+func (t fakeTicker) C() <-chan time.Time { return t.w.c }
+func (t fakeTicker) Stop()               { t.w.Stop() }
+
+// C returns the channel of a timer, or nil for a function scheduled by
+// AfterFunc.
+func (w *fakeWaiter) C() <-chan time.Time {
+	if w.f != nil {
+		return nil
+	}
+	return w.c
+}
+
+// Reset schedules the waiter to fire once its FakeClock has been advanced by
+// at least d, discarding a time delivered but not yet received, and returns
+// false when it had already fired or been stopped.
+func (w *fakeWaiter) Reset(d time.Duration) bool {
+	fc := w.clock
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+
+	active := fc.remove(w)
+	select {
+	case <-w.c:
+	default:
+	}
+	fc.add(w, d)
+	return active
+}
+
+// Stop removes the waiter from its FakeClock, returning false when it has
+// already fired or been stopped.
+func (w *fakeWaiter) Stop() bool {
+	fc := w.clock
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+
+	return fc.remove(w)
+}
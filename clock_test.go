@@ -0,0 +1,167 @@
+package gorill
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	epoch := time.Unix(0, 0)
+
+	t.Run("now", func(t *testing.T) {
+		fc := NewFakeClock(epoch)
+		fc.Advance(time.Minute)
+		if got, want := fc.Now(), epoch.Add(time.Minute); !got.Equal(want) {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("after", func(t *testing.T) {
+		fc := NewFakeClock(epoch)
+		c := fc.After(time.Second)
+		fc.Advance(999 * time.Millisecond)
+		select {
+		case got := <-c:
+			t.Fatalf("GOT: %v; WANT: nothing", got)
+		default:
+		}
+		fc.Advance(time.Millisecond)
+		select {
+		case got := <-c:
+			if want := epoch.Add(time.Second); !got.Equal(want) {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		default:
+			t.Fatal("GOT: nothing; WANT: time")
+		}
+	})
+
+	t.Run("after func in time order", func(t *testing.T) {
+		fc := NewFakeClock(epoch)
+		var order []time.Duration
+		for _, d := range []time.Duration{3 * time.Second, time.Second, 2 * time.Second} {
+			d := d
+			fc.AfterFunc(d, func() {
+				order = append(order, d)
+				if got, want := fc.Now(), epoch.Add(d); !got.Equal(want) {
+					t.Errorf("GOT: %v; WANT: %v", got, want)
+				}
+			})
+		}
+		fc.Advance(time.Minute)
+		if got, want := len(order), 3; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		for i := 1; i < len(order); i++ {
+			if order[i] < order[i-1] {
+				t.Errorf("GOT: %v; WANT: ascending", order)
+			}
+		}
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		fc := NewFakeClock(epoch)
+		var called bool
+		timer := fc.AfterFunc(time.Second, func() { called = true })
+		if got, want := timer.Stop(), true; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := timer.Stop(), false; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		fc.Advance(time.Minute)
+		if called {
+			t.Errorf("GOT: %v; WANT: %v", called, false)
+		}
+	})
+
+	t.Run("timer", func(t *testing.T) {
+		fc := NewFakeClock(epoch)
+		timer := fc.NewTimer(time.Second)
+		fc.Advance(time.Second)
+		select {
+		case got := <-timer.C():
+			if want := epoch.Add(time.Second); !got.Equal(want) {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		default:
+			t.Fatal("GOT: nothing; WANT: time")
+		}
+		if got, want := timer.Stop(), false; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		fc := NewFakeClock(epoch)
+		timer := fc.NewTimer(time.Second)
+		fc.Advance(500 * time.Millisecond)
+		if got, want := timer.Reset(time.Second), true; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		fc.Advance(900 * time.Millisecond)
+		select {
+		case got := <-timer.C():
+			t.Fatalf("GOT: %v; WANT: nothing", got)
+		default:
+		}
+		fc.Advance(100 * time.Millisecond)
+		select {
+		case got := <-timer.C():
+			if want := epoch.Add(1500 * time.Millisecond); !got.Equal(want) {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		default:
+			t.Fatal("GOT: nothing; WANT: time")
+		}
+
+		var calls int
+		af := fc.AfterFunc(time.Second, func() { calls++ })
+		if af.C() != nil {
+			t.Errorf("GOT: %v; WANT: %v", af.C(), nil)
+		}
+		fc.Advance(time.Second)
+		if got, want := af.Reset(time.Second), false; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		fc.Advance(time.Second)
+		if got, want := calls, 2; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("ticker", func(t *testing.T) {
+		fc := NewFakeClock(epoch)
+		ticker := fc.NewTicker(time.Second)
+		defer ticker.Stop()
+		for i := 1; i <= 3; i++ {
+			fc.Advance(time.Second)
+			select {
+			case got := <-ticker.C():
+				if want := epoch.Add(time.Duration(i) * time.Second); !got.Equal(want) {
+					t.Errorf("GOT: %v; WANT: %v", got, want)
+				}
+			default:
+				t.Fatalf("tick %d: GOT: nothing; WANT: time", i)
+			}
+		}
+	})
+
+	t.Run("ticker panics on invalid period", func(t *testing.T) {
+		ensurePanic(t, "non-positive interval for NewTicker", func() {
+			NewFakeClock(epoch).NewTicker(0)
+		})
+	})
+
+	t.Run("sleep", func(t *testing.T) {
+		fc := NewFakeClock(epoch)
+		done := make(chan struct{})
+		go func() {
+			fc.Sleep(time.Hour)
+			close(done)
+		}()
+		fc.BlockUntil(1)
+		fc.Advance(time.Hour)
+		<-done // an unexpired sleep fails this test by timing out
+	})
+}
@@ -0,0 +1,239 @@
+package gorill
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// DefaultCoalesceWindow is the default period a CoalescingWriter waits for more
+// writes before writing the bytes it has collected.
+const DefaultCoalesceWindow = time.Millisecond
+
+// CoalescingWriter is an io.WriteCloser that merges small writes arriving within
+// a short window into a single write to the underlying io.WriteCloser, similar
+// to Nagle's algorithm for TCP. This reduces the number of system calls made on
+// behalf of chatty producers, such as encoders that write each field
+// separately, while bounding the added latency to the window.
+//
+// Because collected bytes are written from another go-routine once the window
+// elapses, an error writing them is returned by the next call to Write, Flush,
+// or Close.
+type CoalescingWriter struct {
+	clock     Clock
+	disabled  bool
+	err       error // err is the sticky error from a deferred write
+	halted    bool
+	iowc      io.WriteCloser
+	lock      sync.Mutex
+	pending   []byte
+	threshold int
+	timer     Timer
+	window    time.Duration
+}
+
+// CoalescingWriterSetter is any function that modifies a CoalescingWriter being
+// instantiated.
+type CoalescingWriterSetter func(*CoalescingWriter) error
+
+// CoalesceThreshold is used to configure the size of a new CoalescingWriter's
+// buffer. Collected bytes are written as soon as they reach this size, and
+// writes at least this large are not coalesced.
+func CoalesceThreshold(size int) CoalescingWriterSetter {
+	return func(cw *CoalescingWriter) error {
+		if size <= 0 {
+			return fmt.Errorf("threshold must be greater than 0: %d", size)
+		}
+		cw.threshold = size
+		return nil
+	}
+}
+
+// CoalesceWindow is used to configure the longest period a new
+// CoalescingWriter holds collected bytes before writing them.
+func CoalesceWindow(window time.Duration) CoalescingWriterSetter {
+	return func(cw *CoalescingWriter) error {
+		if window <= 0 {
+			return fmt.Errorf("window must be greater than 0: %s", window)
+		}
+		cw.window = window
+		return nil
+	}
+}
+
+// CoalesceClock is used to configure the clock a new CoalescingWriter uses to
+// time its coalescing window.
+func CoalesceClock(clock Clock) CoalescingWriterSetter {
+	return func(cw *CoalescingWriter) error {
+		if clock == nil {
+			return fmt.Errorf("clock must not be nil")
+		}
+		cw.clock = clock
+		return nil
+	}
+}
+
+// NewCoalescingWriter returns a CoalescingWriter that coalesces small writes to
+// iowc.
+//
+//   cw, err := gorill.NewCoalescingWriter(conn, gorill.CoalesceWindow(500*time.Microsecond))
+//   if err != nil {
+//       return err
+//   }
+//   enc := json.NewEncoder(cw)
+func NewCoalescingWriter(iowc io.WriteCloser, setters ...CoalescingWriterSetter) (*CoalescingWriter, error) {
+	cw := &CoalescingWriter{
+		clock:     systemClock{},
+		iowc:      iowc,
+		threshold: DefaultBufSize,
+		window:    DefaultCoalesceWindow,
+	}
+	for _, setter := range setters {
+		if err := setter(cw); err != nil {
+			return nil, err
+		}
+	}
+	cw.pending = make([]byte, 0, cw.threshold)
+	return cw, nil
+}
+
+// Write collects data to be written along with other small writes, or writes
+// it immediately when it is at least as large as the threshold, or when
+// coalescing is disabled.
+func (cw *CoalescingWriter) Write(data []byte) (int, error) {
+	cw.lock.Lock()
+	defer cw.lock.Unlock()
+
+	if cw.halted {
+		return 0, ErrWriteAfterClose{}
+	}
+	if cw.err != nil {
+		return 0, cw.err
+	}
+	if cw.disabled || len(data) >= cw.threshold {
+		if err := cw.flush(); err != nil {
+			return 0, err
+		}
+		return cw.iowc.Write(data)
+	}
+	if len(cw.pending)+len(data) > cw.threshold {
+		if err := cw.flush(); err != nil {
+			return 0, err
+		}
+	}
+	cw.pending = append(cw.pending, data...)
+	if len(cw.pending) == cw.threshold {
+		if err := cw.flush(); err != nil {
+			return len(data), err
+		}
+	} else if cw.timer == nil {
+		cw.timer = cw.clock.AfterFunc(cw.window, cw.expire)
+	}
+	return len(data), nil
+}
+
+// expire writes the collected bytes when the window elapses.
+func (cw *CoalescingWriter) expire() {
+	cw.lock.Lock()
+	defer cw.lock.Unlock()
+
+	cw.timer = nil
+	if !cw.halted {
+		cw.err = cw.flush()
+	}
+}
+
+// SetWriteDeadline sets the write deadline of the underlying io.WriteCloser.
+func (cw *CoalescingWriter) SetWriteDeadline(t time.Time) error {
+	return SetWriteDeadline(cw.iowc, t)
+}
+
+// flush writes the collected bytes. Caller must hold the lock.
+func (cw *CoalescingWriter) flush() error {
+	return cw.flushWithContext(context.Background())
+}
+
+// flushWithContext writes the collected bytes, passing ctx to the underlying
+// io.WriteCloser when it has a WriteContext method. Caller must hold the lock.
+func (cw *CoalescingWriter) flushWithContext(ctx context.Context) error {
+	if cw.timer != nil {
+		cw.timer.Stop()
+		cw.timer = nil
+	}
+	if len(cw.pending) == 0 {
+		return nil
+	}
+	var n int
+	var err error
+	if cwc, ok := cw.iowc.(interface {
+		WriteContext(context.Context, []byte) (int, error)
+	}); ok {
+		n, err = cwc.WriteContext(ctx, cw.pending)
+	} else {
+		n, err = cw.iowc.Write(cw.pending)
+	}
+	if err == nil && n < len(cw.pending) {
+		err = io.ErrShortWrite
+	}
+	cw.pending = cw.pending[:0]
+	return err
+}
+
+// Flush immediately writes any collected bytes.
+func (cw *CoalescingWriter) Flush() error {
+	return cw.flushContext(context.Background())
+}
+
+// flushContext immediately writes any collected bytes like Flush, passing ctx
+// to the underlying io.WriteCloser when it has a WriteContext method.
+func (cw *CoalescingWriter) flushContext(ctx context.Context) error {
+	cw.lock.Lock()
+	defer cw.lock.Unlock()
+
+	if cw.halted {
+		return ErrWriteAfterClose{}
+	}
+	if cw.err != nil {
+		return cw.err
+	}
+	return cw.flushWithContext(ctx)
+}
+
+// SetCoalescing enables or disables coalescing, similar to the TCP_NODELAY
+// socket option. Disabling coalescing writes any collected bytes, after which
+// each write is passed through immediately.
+func (cw *CoalescingWriter) SetCoalescing(enabled bool) error {
+	cw.lock.Lock()
+	defer cw.lock.Unlock()
+
+	cw.disabled = !enabled
+	if cw.disabled && cw.err == nil {
+		return cw.flush()
+	}
+	return cw.err
+}
+
+// Unwrap returns the underlying io.WriteCloser.
+func (cw *CoalescingWriter) Unwrap() io.WriteCloser { return cw.iowc }
+
+// Close writes any collected bytes, then closes the underlying io.WriteCloser.
+func (cw *CoalescingWriter) Close() error {
+	cw.lock.Lock()
+	defer cw.lock.Unlock()
+
+	if cw.halted {
+		return nil
+	}
+	cw.halted = true
+	var errors ErrList
+	errors.Append(cw.err)
+	if cw.err == nil {
+		errors.Append(cw.flush())
+	} else if cw.timer != nil {
+		cw.timer.Stop()
+	}
+	errors.Append(cw.iowc.Close())
+	return errors.Err()
+}
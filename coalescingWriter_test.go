@@ -0,0 +1,171 @@
+package gorill
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lockedRecorder is a concurrency safe blockRecorder.
+type lockedRecorder struct {
+	lock sync.Mutex
+	br   blockRecorder
+}
+
+func (lr *lockedRecorder) Write(data []byte) (int, error) {
+	lr.lock.Lock()
+	defer lr.lock.Unlock()
+	return lr.br.Write(data)
+}
+
+func (lr *lockedRecorder) Close() error { return nil }
+
+func (lr *lockedRecorder) snapshot() (string, []int) {
+	lr.lock.Lock()
+	defer lr.lock.Unlock()
+	return lr.br.String(), append([]int(nil), lr.br.sizes...)
+}
+
+func TestCoalescingWriter(t *testing.T) {
+	t.Run("invalid threshold", func(t *testing.T) {
+		_, err := NewCoalescingWriter(NewNopCloseBuffer(), CoalesceThreshold(0))
+		ensureError(t, err, "threshold must be greater than 0")
+	})
+
+	t.Run("merges small writes", func(t *testing.T) {
+		lr := new(lockedRecorder)
+		cw, err := NewCoalescingWriter(lr, CoalesceThreshold(16), CoalesceWindow(time.Hour))
+		ensureError(t, err)
+		for _, piece := range []string{"abc", "def", "ghi"} {
+			_, err = cw.Write([]byte(piece))
+			ensureError(t, err)
+		}
+		if _, sizes := lr.snapshot(); len(sizes) != 0 {
+			t.Errorf("GOT: %v; WANT: %v", sizes, nil)
+		}
+		ensureError(t, cw.Flush())
+		got, sizes := lr.snapshot()
+		if want := "abcdefghi"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+		if want := []int{9}; len(sizes) != 1 || sizes[0] != 9 {
+			t.Errorf("GOT: %v; WANT: %v", sizes, want)
+		}
+	})
+
+	t.Run("threshold", func(t *testing.T) {
+		lr := new(lockedRecorder)
+		cw, err := NewCoalescingWriter(lr, CoalesceThreshold(8), CoalesceWindow(time.Hour))
+		ensureError(t, err)
+		for _, piece := range []string{"abcde", "fghij", "0123456789"} {
+			_, err = cw.Write([]byte(piece))
+			ensureError(t, err)
+		}
+		got, sizes := lr.snapshot()
+		if want := "abcdefghij0123456789"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+		if want := []int{5, 5, 10}; len(sizes) != 3 {
+			t.Errorf("GOT: %v; WANT: %v", sizes, want)
+		}
+	})
+
+	t.Run("window", func(t *testing.T) {
+		lr := new(lockedRecorder)
+		cw, err := NewCoalescingWriter(lr, CoalesceWindow(time.Millisecond))
+		ensureError(t, err)
+		_, err = cw.Write([]byte(alphabet))
+		ensureError(t, err)
+
+		deadline := time.Now().Add(time.Second)
+		for {
+			if got, _ := lr.snapshot(); got == alphabet {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("GOT: nothing written; WANT: written after window")
+			}
+			time.Sleep(time.Millisecond)
+		}
+		ensureError(t, cw.Close())
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		lr := new(lockedRecorder)
+		cw, err := NewCoalescingWriter(lr, CoalesceWindow(time.Hour))
+		ensureError(t, err)
+		_, err = cw.Write([]byte("abc"))
+		ensureError(t, err)
+		ensureError(t, cw.SetCoalescing(false))
+		_, err = cw.Write([]byte("def"))
+		ensureError(t, err)
+		if _, sizes := lr.snapshot(); len(sizes) != 2 {
+			t.Errorf("GOT: %v; WANT: %v", sizes, []int{3, 3})
+		}
+	})
+
+	t.Run("deferred error", func(t *testing.T) {
+		cw, err := NewCoalescingWriter(NopCloseWriter(ShortWriter(new(bytes.Buffer), 1)), CoalesceWindow(time.Millisecond))
+		ensureError(t, err)
+		_, err = cw.Write([]byte(alphabet))
+		ensureError(t, err)
+		time.Sleep(20 * time.Millisecond)
+		_, err = cw.Write([]byte(alphabet))
+		ensureError(t, err, "short write")
+		ensureError(t, cw.Close(), "short write")
+		_, err = cw.Write([]byte(alphabet))
+		testErrorType(t, err, ErrWriteAfterClose{})
+	})
+	t.Run("fake clock", func(t *testing.T) {
+		clock := NewFakeClock(time.Unix(0, 0))
+		lr := new(lockedRecorder)
+		cw, err := NewCoalescingWriter(lr, CoalesceClock(clock), CoalesceWindow(time.Second))
+		ensureError(t, err)
+		_, err = cw.Write([]byte("held"))
+		ensureError(t, err)
+		clock.Advance(999 * time.Millisecond)
+		if got, _ := lr.snapshot(); got != "" {
+			t.Errorf("GOT: %q; WANT: %q", got, "")
+		}
+		clock.Advance(time.Millisecond)
+		if got, _ := lr.snapshot(); got != "held" {
+			t.Errorf("GOT: %q; WANT: %q", got, "held")
+		}
+		ensureError(t, cw.Close())
+	})
+
+	t.Run("nil clock", func(t *testing.T) {
+		_, err := NewCoalescingWriter(NewNopCloseBuffer(), CoalesceClock(nil))
+		ensureError(t, err, "clock must not be nil")
+	})
+}
+
+func benchmarkTinyWrites(b *testing.B, coalesce bool) {
+	conn, done := loopback(b)
+	defer done()
+
+	var w interface {
+		Write([]byte) (int, error)
+	} = conn
+	if coalesce {
+		cw, err := NewCoalescingWriter(conn)
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer cw.Flush()
+		w = cw
+	}
+	payload := []byte("field=value;")
+	b.SetBytes(int64(len(payload)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := w.Write(payload); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTinyWritesDirect(b *testing.B)     { benchmarkTinyWrites(b, false) }
+func BenchmarkTinyWritesCoalescing(b *testing.B) { benchmarkTinyWrites(b, true) }
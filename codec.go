@@ -0,0 +1,118 @@
+package gorill
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Codec is a compression algorithm that can wrap a stream, allowing sinks and
+// sources to compress by configuration rather than by depending on a
+// particular compression library. A Codec for an algorithm outside of the
+// standard library may be registered with RegisterCodec.
+type Codec interface {
+	// NewWriter returns an io.WriteCloser that compresses what is written to
+	// it, and writes the result to iow. Closing it flushes the compressed
+	// stream, but does not close iow.
+	NewWriter(iow io.Writer) io.WriteCloser
+
+	// NewReader returns an io.ReadCloser that decompresses what it reads
+	// from ior. Closing it does not close ior.
+	NewReader(ior io.Reader) (io.ReadCloser, error)
+}
+
+var codecRegistry = struct {
+	lock       sync.RWMutex
+	byName     map[string]Codec
+	extensions map[string]string // extensions maps a file extension to a codec name
+}{
+	byName:     make(map[string]Codec),
+	extensions: make(map[string]string),
+}
+
+func init() {
+	RegisterCodec("gzip", gzipCodec{}, ".gz", ".gzip")
+	RegisterCodec("zlib", zlibCodec{}, ".zz", ".zlib")
+}
+
+// RegisterCodec makes codec available by name, and by each of the specified
+// file extensions, such as ".zst". Names and extensions are case insensitive,
+// and the leading period of an extension is optional. Registering a name or
+// extension again replaces the previous registration.
+//
+//   gorill.RegisterCodec("zstd", zstdCodec{}, ".zst")
+func RegisterCodec(name string, codec Codec, extensions ...string) {
+	name = strings.ToLower(name)
+
+	codecRegistry.lock.Lock()
+	defer codecRegistry.lock.Unlock()
+
+	codecRegistry.byName[name] = codec
+	for _, ext := range extensions {
+		codecRegistry.extensions[normalizeExtension(ext)] = name
+	}
+}
+
+func normalizeExtension(ext string) string {
+	return "." + strings.TrimPrefix(strings.ToLower(ext), ".")
+}
+
+// LookupCodec returns the Codec registered with the specified name or file
+// extension.
+func LookupCodec(nameOrExtension string) (Codec, bool) {
+	key := strings.ToLower(nameOrExtension)
+
+	codecRegistry.lock.RLock()
+	defer codecRegistry.lock.RUnlock()
+
+	if codec, ok := codecRegistry.byName[key]; ok {
+		return codec, true
+	}
+	if name, ok := codecRegistry.extensions[normalizeExtension(key)]; ok {
+		codec, ok := codecRegistry.byName[name]
+		return codec, ok
+	}
+	return nil, false
+}
+
+// WrapCompress returns an io.WriteCloser that compresses what is written to it
+// with the Codec registered with the specified name or file extension, and
+// writes the result to iow. It returns ErrUnsupportedCompression when no such
+// Codec is registered.
+//
+//   zw, err := gorill.WrapCompress(filepath.Ext(path), fh)
+//   if err != nil {
+//       return err
+//   }
+func WrapCompress(nameOrExtension string, iow io.Writer) (io.WriteCloser, error) {
+	codec, ok := LookupCodec(nameOrExtension)
+	if !ok {
+		return nil, ErrUnsupportedCompression(nameOrExtension)
+	}
+	return codec.NewWriter(iow), nil
+}
+
+// WrapDecompress returns an io.ReadCloser that decompresses what it reads from
+// ior with the Codec registered with the specified name or file extension. It
+// returns ErrUnsupportedCompression when no such Codec is registered.
+func WrapDecompress(nameOrExtension string, ior io.Reader) (io.ReadCloser, error) {
+	codec, ok := LookupCodec(nameOrExtension)
+	if !ok {
+		return nil, ErrUnsupportedCompression(nameOrExtension)
+	}
+	return codec.NewReader(ior)
+}
+
+type gzipCodec struct{}
+
+func (gzipCodec) NewWriter(iow io.Writer) io.WriteCloser { return gzip.NewWriter(iow) }
+
+func (gzipCodec) NewReader(ior io.Reader) (io.ReadCloser, error) { return gzip.NewReader(ior) }
+
+type zlibCodec struct{}
+
+func (zlibCodec) NewWriter(iow io.Writer) io.WriteCloser { return zlib.NewWriter(iow) }
+
+func (zlibCodec) NewReader(ior io.Reader) (io.ReadCloser, error) { return zlib.NewReader(ior) }
@@ -0,0 +1,66 @@
+package gorill
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func TestCodecRoundTrip(t *testing.T) {
+	for _, key := range []string{"gzip", "GZIP", ".gz", "gz", "zlib", ".zz"} {
+		bb := new(bytes.Buffer)
+		zw, err := WrapCompress(key, bb)
+		ensureError(t, err)
+		_, err = zw.Write([]byte(alphabet))
+		ensureError(t, err)
+		ensureError(t, zw.Close())
+
+		zr, err := WrapDecompress(key, bb)
+		ensureError(t, err)
+		buf, err := ioutil.ReadAll(zr)
+		ensureError(t, err)
+		ensureError(t, zr.Close())
+		if got, want := string(buf), alphabet; got != want {
+			t.Errorf("%s: GOT: %q; WANT: %q", key, got, want)
+		}
+	}
+}
+
+func TestCodecUnsupported(t *testing.T) {
+	_, err := WrapCompress(".xz", new(bytes.Buffer))
+	testErrorType(t, err, ErrUnsupportedCompression(".xz"))
+
+	_, err = WrapDecompress("lz4", new(bytes.Buffer))
+	testErrorType(t, err, ErrUnsupportedCompression("lz4"))
+}
+
+// identityCodec passes bytes through unchanged.
+type identityCodec struct{}
+
+func (identityCodec) NewWriter(iow io.Writer) io.WriteCloser { return NopCloseWriter(iow) }
+
+func (identityCodec) NewReader(ior io.Reader) (io.ReadCloser, error) {
+	return NopCloseReader(ior), nil
+}
+
+func TestRegisterCodec(t *testing.T) {
+	RegisterCodec("Identity", identityCodec{}, "ID")
+	defer func() {
+		codecRegistry.lock.Lock()
+		delete(codecRegistry.byName, "identity")
+		delete(codecRegistry.extensions, ".id")
+		codecRegistry.lock.Unlock()
+	}()
+
+	codec, ok := LookupCodec(".id")
+	if !ok {
+		t.Fatalf("GOT: %v; WANT: %v", ok, true)
+	}
+	if _, ok = codec.(identityCodec); !ok {
+		t.Errorf("GOT: %T; WANT: %T", codec, identityCodec{})
+	}
+	if _, ok = LookupCodec("identity"); !ok {
+		t.Errorf("GOT: %v; WANT: %v", ok, true)
+	}
+}
@@ -0,0 +1,236 @@
+package gorill
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+)
+
+// LinePrefixWriter is an io.WriteCloser that writes each line written to it to
+// the underlying io.Writer preceded by a prefix. Each complete line is written
+// with a single Write call while holding a lock, which may be shared by
+// several LinePrefixWriters so their lines do not interleave.
+type LinePrefixWriter struct {
+	iow     io.Writer
+	lock    *sync.Mutex
+	pending []byte
+	prefix  []byte
+}
+
+// NewLinePrefixWriter returns a LinePrefixWriter that writes each line
+// preceded by prefix to iow.
+func NewLinePrefixWriter(iow io.Writer, prefix string) *LinePrefixWriter {
+	return &LinePrefixWriter{iow: iow, lock: new(sync.Mutex), prefix: []byte(prefix)}
+}
+
+// Write writes each complete line in data to the underlying io.Writer, and
+// buffers any final partial line until it is completed by a subsequent write
+// or the LinePrefixWriter is closed.
+func (lp *LinePrefixWriter) Write(data []byte) (int, error) {
+	total := len(data)
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			lp.pending = append(lp.pending, data...)
+			break
+		}
+		line := append(append(append([]byte(nil), lp.prefix...), lp.pending...), data[:i+1]...)
+		lp.pending = lp.pending[:0]
+		data = data[i+1:]
+		if err := lp.write(line); err != nil {
+			return total - len(data) - i - 1, err
+		}
+	}
+	return total, nil
+}
+
+func (lp *LinePrefixWriter) write(line []byte) error {
+	lp.lock.Lock()
+	defer lp.lock.Unlock()
+	_, err := lp.iow.Write(line)
+	return err
+}
+
+// Unwrap returns the underlying io.Writer.
+func (lp *LinePrefixWriter) Unwrap() io.Writer { return lp.iow }
+
+// Close writes any final partial line, followed by a newline. It does not
+// close the underlying io.Writer.
+func (lp *LinePrefixWriter) Close() error {
+	if len(lp.pending) == 0 {
+		return nil
+	}
+	line := append(append(append([]byte(nil), lp.prefix...), lp.pending...), '\n')
+	lp.pending = nil
+	return lp.write(line)
+}
+
+// CombinedLinePrefix configures cmd to write both its standard output and
+// standard error to iow, one line at a time, with each line preceded by the
+// prefix for the stream from which it came. It must be called before cmd is
+// started. The returned io.Closer must be closed after cmd.Wait returns in
+// order to write any final lines not terminated by a newline.
+//
+//   cmd := exec.Command("make", "test")
+//   output := gorill.CombinedLinePrefix(cmd, os.Stderr, "[out] ", "[err] ")
+//   err := cmd.Run()
+//   output.Close()
+func CombinedLinePrefix(cmd *exec.Cmd, iow io.Writer, stdoutPrefix, stderrPrefix string) io.Closer {
+	stdout := NewLinePrefixWriter(iow, stdoutPrefix)
+	stderr := NewLinePrefixWriter(iow, stderrPrefix)
+	stderr.lock = stdout.lock // serialize lines from both streams
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	return closerFunc(func() error {
+		var errors ErrList
+		errors.Append(stdout.Close())
+		errors.Append(stderr.Close())
+		return errors.Err()
+	})
+}
+
+// closerFunc adapts a function to the io.Closer interface.
+type closerFunc func() error
+
+func (f closerFunc) Close() error { return f() }
+
+// ErrOutputLimit is returned after the captured output of a command when the
+// command wrote more than the preset limit.
+type ErrOutputLimit int64
+
+// Error returns a string representation of an ErrOutputLimit error instance.
+func (e ErrOutputLimit) Error() string {
+	return fmt.Sprintf("output exceeded limit of %d bytes", int64(e))
+}
+
+// limitedBuffer is an io.Writer that retains at most limit bytes, and discards
+// the remainder so the writer is never blocked.
+type limitedBuffer struct {
+	buf      bytes.Buffer // not embedded, to hide its ReadFrom method
+	limit    int64
+	exceeded bool
+}
+
+func (lb *limitedBuffer) Write(data []byte) (int, error) {
+	if room := lb.limit - int64(lb.buf.Len()); int64(len(data)) > room {
+		lb.exceeded = true
+		lb.buf.Write(data[:room])
+	} else {
+		lb.buf.Write(data)
+	}
+	return len(data), nil
+}
+
+// escrow returns an EscrowReader holding the captured bytes.
+func (lb *limitedBuffer) escrow() *EscrowReader {
+	er := &EscrowReader{buf: lb.buf.Bytes(), rerr: io.EOF}
+	if lb.exceeded {
+		er.rerr = ErrOutputLimit(lb.limit)
+	}
+	return er
+}
+
+// CaptureOutput runs cmd, capturing at most limit bytes of each of its standard
+// output and standard error, which are returned as EscrowReaders that may be
+// read multiple times. Output beyond the limit is discarded, so a verbose
+// command is never blocked, and the EscrowReader returns ErrOutputLimit after
+// the bytes it holds. The returned error is the result of running cmd.
+//
+//   stdout, stderr, err := gorill.CaptureOutput(exec.Command("git", "status"), 1<<20)
+//   if err != nil {
+//       return fmt.Errorf("%s: %s", err, stderr.Bytes())
+//   }
+func CaptureOutput(cmd *exec.Cmd, limit int64) (*EscrowReader, *EscrowReader, error) {
+	if limit < 0 {
+		return nil, nil, fmt.Errorf("limit must not be negative: %d", limit)
+	}
+	stdout := &limitedBuffer{limit: limit}
+	stderr := &limitedBuffer{limit: limit}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	err := cmd.Run()
+	return stdout.escrow(), stderr.escrow(), err
+}
+
+// CommandReadCloser is an io.ReadCloser that reads the standard output of a
+// running command. Because it is an io.ReadCloser, it may be wrapped by other
+// readers in this library, such as a TimedReadCloser to enforce a timeout on
+// every read.
+type CommandReadCloser struct {
+	cmd    *exec.Cmd
+	iorc   io.ReadCloser
+	lock   sync.Mutex
+	waited bool
+	werr   error // werr is the error returned by cmd.Wait
+}
+
+// StartCommand starts cmd, and returns a CommandReadCloser that reads its
+// standard output. After the final byte of output is read, Read waits for the
+// command to exit, and returns the error from cmd.Wait when it is not nil,
+// rather than io.EOF, so a failed command is not mistaken for a successful
+// one.
+//
+//   crc, err := gorill.StartCommand(exec.Command("tail", "-f", "app.log"))
+//   if err != nil {
+//       return err
+//   }
+//   trc := gorill.NewTimedReadCloser(crc, time.Minute)
+//   defer trc.Close() // stops the command if it is still running
+func StartCommand(cmd *exec.Cmd) (*CommandReadCloser, error) {
+	iorc, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err = cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &CommandReadCloser{cmd: cmd, iorc: iorc}, nil
+}
+
+// Read reads up to len(p) bytes of the command's standard output into p.
+func (crc *CommandReadCloser) Read(p []byte) (int, error) {
+	n, err := crc.iorc.Read(p)
+	if err == io.EOF {
+		if werr := crc.wait(); werr != nil {
+			err = werr
+		}
+	}
+	return n, err
+}
+
+// wait waits for the command to exit, returning the error from cmd.Wait.
+func (crc *CommandReadCloser) wait() error {
+	crc.lock.Lock()
+	defer crc.lock.Unlock()
+
+	if !crc.waited {
+		crc.waited = true
+		crc.werr = crc.cmd.Wait()
+	}
+	return crc.werr
+}
+
+// Unwrap returns the command's standard output pipe.
+func (crc *CommandReadCloser) Unwrap() io.ReadCloser { return crc.iorc }
+
+// Close kills the command when it has not yet exited, and releases its
+// resources. It returns the error from cmd.Wait when the command had already
+// exited, and nil when it was killed.
+func (crc *CommandReadCloser) Close() error {
+	crc.lock.Lock()
+	waited := crc.waited
+	crc.lock.Unlock()
+	if waited {
+		return crc.werr
+	}
+
+	// Closing standard output causes a well behaved command to exit when it
+	// next writes; kill it in case it is not writing.
+	_ = crc.iorc.Close()
+	killed := crc.cmd.Process.Kill() == nil
+	err := crc.wait()
+	if killed {
+		return nil
+	}
+	return err
+}
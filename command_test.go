@@ -0,0 +1,120 @@
+package gorill
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func shellCommand(tb testing.TB, script string) *exec.Cmd {
+	tb.Helper()
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		tb.Skip(err)
+	}
+	return exec.Command(sh, "-c", script)
+}
+
+func TestLinePrefixWriter(t *testing.T) {
+	bb := new(bytes.Buffer)
+	lp := NewLinePrefixWriter(bb, "> ")
+	for _, piece := range []string{"one\ntw", "o\n", "three"} {
+		n, err := lp.Write([]byte(piece))
+		ensureError(t, err)
+		if got, want := n, len(piece); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	}
+	ensureError(t, lp.Close())
+	if got, want := bb.String(), "> one\n> two\n> three\n"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+}
+
+func TestCombinedLinePrefix(t *testing.T) {
+	cmd := shellCommand(t, "echo out1; echo err1 >&2; printf out2")
+	bb := new(bytes.Buffer)
+	output := CombinedLinePrefix(cmd, bb, "[out] ", "[err] ")
+	ensureError(t, cmd.Run())
+	ensureError(t, output.Close())
+
+	lines := strings.Split(bb.String(), "\n")
+	for _, want := range []string{"[out] out1", "[err] err1", "[out] out2"} {
+		var found bool
+		for _, line := range lines {
+			if line == want {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("GOT: %q; WANT: %q", bb.String(), want)
+		}
+	}
+}
+
+func TestCaptureOutput(t *testing.T) {
+	t.Run("within limit", func(t *testing.T) {
+		stdout, stderr, err := CaptureOutput(shellCommand(t, "echo hello; echo oops >&2"), 100)
+		ensureError(t, err)
+		if got, want := string(stdout.Bytes()), "hello\n"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+		if got, want := string(stderr.Bytes()), "oops\n"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+		ensureError(t, stdout.Err())
+	})
+
+	t.Run("exceeds limit", func(t *testing.T) {
+		stdout, _, err := CaptureOutput(shellCommand(t, "echo hello world"), 5)
+		ensureError(t, err)
+		buf, err := ioutil.ReadAll(stdout)
+		testErrorType(t, err, ErrOutputLimit(5))
+		if got, want := string(buf), "hello"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("command fails", func(t *testing.T) {
+		_, _, err := CaptureOutput(shellCommand(t, "exit 3"), 5)
+		ensureError(t, err, "exit status 3")
+	})
+}
+
+func TestStartCommand(t *testing.T) {
+	t.Run("output then exit status", func(t *testing.T) {
+		crc, err := StartCommand(shellCommand(t, "echo hello; exit 2"))
+		ensureError(t, err)
+		buf, err := ioutil.ReadAll(crc)
+		ensureError(t, err, "exit status 2")
+		if got, want := string(buf), "hello\n"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+		ensureError(t, crc.Close(), "exit status 2")
+	})
+
+	t.Run("timed reads and close stops command", func(t *testing.T) {
+		crc, err := StartCommand(shellCommand(t, "echo ready; exec sleep 60"))
+		ensureError(t, err)
+		trc := NewTimedReadCloser(crc, 50*time.Millisecond)
+
+		buf := make([]byte, 64)
+		n, err := trc.Read(buf)
+		ensureError(t, err)
+		ensureBuffer(t, buf, n, "ready\n")
+		_, err = trc.Read(buf)
+		testErrorType(t, err, ErrTimeout(50*time.Millisecond))
+
+		done := make(chan error, 1)
+		go func() { done <- trc.Close() }()
+		select {
+		case err = <-done:
+			ensureError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("GOT: close blocked; WANT: command killed")
+		}
+	})
+}
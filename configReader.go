@@ -0,0 +1,177 @@
+package gorill
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// ConfigReader is an io.Reader that strips comment lines, and optionally blank
+// lines, from the source io.Reader while streaming. A comment line is a line
+// whose first non-whitespace characters are the comment prefix. Because lines
+// are removed, line numbers reported by a downstream parser will not match the
+// source, so ConfigReader provides the SourceLine method to map the number of a
+// line it returned back to the number of the line in the source.
+//
+// Every line returned by ConfigReader is terminated by a newline, even when the
+// final line of the source is not.
+type ConfigReader struct {
+	br        *bufio.Reader
+	prefix    []byte
+	skipBlank bool
+
+	pending []byte // pending holds bytes ready to be returned by Read
+	err     error  // err is returned after pending is drained
+
+	dropping bool // dropping is true while inside a long line being discarded
+	partial  bool // partial is true while inside a long line being kept
+
+	srcLine int        // srcLine is the number of lines read from source
+	outLine int        // outLine is the number of lines returned
+	jumps   []lineJump // jumps records where returned lines skip source lines
+}
+
+// lineJump records that returned line out corresponds to source line src.
+type lineJump struct {
+	out, src int
+}
+
+// ConfigReaderSetter is any function that modifies a ConfigReader being
+// instantiated.
+type ConfigReaderSetter func(*ConfigReader) error
+
+// CommentPrefix is used to configure the prefix that identifies comment lines
+// for a new ConfigReader. The default prefix is "#".
+func CommentPrefix(prefix string) ConfigReaderSetter {
+	return func(cr *ConfigReader) error {
+		if prefix == "" {
+			return fmt.Errorf("comment prefix must not be empty")
+		}
+		cr.prefix = []byte(prefix)
+		return nil
+	}
+}
+
+// SkipBlankLines is used to configure a new ConfigReader to also strip lines
+// that are empty or contain only whitespace.
+func SkipBlankLines() ConfigReaderSetter {
+	return func(cr *ConfigReader) error {
+		cr.skipBlank = true
+		return nil
+	}
+}
+
+// NewConfigReader returns a ConfigReader that strips comment lines from ior.
+//
+//   cr, err := gorill.NewConfigReader(fh, gorill.CommentPrefix(";"), gorill.SkipBlankLines())
+//   if err != nil {
+//       return err
+//   }
+//   lines := bufio.NewScanner(cr)
+//   for n := 1; lines.Scan(); n++ {
+//       if err := parse(lines.Text()); err != nil {
+//           return fmt.Errorf("line %d: %s", cr.SourceLine(n), err)
+//       }
+//   }
+func NewConfigReader(ior io.Reader, setters ...ConfigReaderSetter) (*ConfigReader, error) {
+	cr := &ConfigReader{
+		br:     bufio.NewReaderSize(&LineTerminatedReader{R: ior}, DefaultBufSize),
+		prefix: []byte("#"),
+	}
+	for _, setter := range setters {
+		if err := setter(cr); err != nil {
+			return nil, err
+		}
+	}
+	return cr, nil
+}
+
+// Line returns the number of lines read from the source so far.
+func (cr *ConfigReader) Line() int { return cr.srcLine }
+
+// SourceLine returns the line number in the source of the nth line returned by
+// the ConfigReader, where the first line is 1. It returns 0 when n is less than
+// 1 or greater than the number of lines returned thus far.
+func (cr *ConfigReader) SourceLine(n int) int {
+	if n < 1 || n > cr.outLine {
+		return 0
+	}
+	// Find the final jump at or before the requested line.
+	i := sort.Search(len(cr.jumps), func(i int) bool { return cr.jumps[i].out > n }) - 1
+	if i < 0 {
+		return n
+	}
+	return cr.jumps[i].src + n - cr.jumps[i].out
+}
+
+// Read reads up to len(p) bytes into p. It returns the number of bytes read (0
+// <= n <= len(p)) and any error encountered.
+func (cr *ConfigReader) Read(p []byte) (int, error) {
+	for len(cr.pending) == 0 && cr.err == nil {
+		cr.fill()
+	}
+	if len(cr.pending) == 0 {
+		return 0, cr.err
+	}
+	n := copy(p, cr.pending)
+	cr.pending = cr.pending[n:]
+	return n, nil
+}
+
+// fill reads the next slice from the source, and appends it to pending when it
+// belongs to a line that is kept.
+func (cr *ConfigReader) fill() {
+	cr.pending = cr.pending[:0]
+
+	slice, err := cr.br.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		err = nil
+	}
+	if len(slice) > 0 {
+		eol := slice[len(slice)-1] == '\n'
+		switch {
+		case cr.dropping:
+			cr.dropping = !eol
+		case cr.partial:
+			cr.pending = append(cr.pending, slice...)
+			cr.partial = !eol
+		default:
+			cr.srcLine++
+			if cr.isKept(slice) {
+				cr.outLine++
+				if cr.srcLine-cr.outLine != cr.skipped() {
+					cr.jumps = append(cr.jumps, lineJump{out: cr.outLine, src: cr.srcLine})
+				}
+				cr.pending = append(cr.pending, slice...)
+				cr.partial = !eol
+			} else {
+				cr.dropping = !eol
+			}
+		}
+	}
+	cr.err = err
+}
+
+// skipped returns the number of source lines skipped before the most recently
+// recorded jump.
+func (cr *ConfigReader) skipped() int {
+	if len(cr.jumps) == 0 {
+		return 0
+	}
+	j := cr.jumps[len(cr.jumps)-1]
+	return j.src - j.out
+}
+
+// isKept returns true when the line beginning with slice ought to be returned.
+func (cr *ConfigReader) isKept(slice []byte) bool {
+	trimmed := bytes.TrimLeft(slice, " \t")
+	if bytes.HasPrefix(trimmed, cr.prefix) {
+		return false
+	}
+	if cr.skipBlank && len(bytes.TrimRight(trimmed, "\r\n")) == 0 {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,63 @@
+package gorill
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestConfigReader(t *testing.T) {
+	const source = "# leading comment\nalpha = 1\n\n  # indented comment\nbravo = 2\n   \ncharlie = 3"
+
+	t.Run("invalid prefix", func(t *testing.T) {
+		_, err := NewConfigReader(strings.NewReader(source), CommentPrefix(""))
+		ensureError(t, err, "comment prefix must not be empty")
+	})
+
+	t.Run("keeps blank lines by default", func(t *testing.T) {
+		cr, err := NewConfigReader(strings.NewReader(source))
+		ensureError(t, err)
+		buf, err := ioutil.ReadAll(cr)
+		ensureError(t, err)
+		if got, want := string(buf), "alpha = 1\n\nbravo = 2\n   \ncharlie = 3\n"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+		for i, want := range []int{0, 2, 3, 5, 6, 7, 0} {
+			if got := cr.SourceLine(i); got != want {
+				t.Errorf("Line %d: GOT: %v; WANT: %v", i, got, want)
+			}
+		}
+	})
+
+	t.Run("skips blank lines", func(t *testing.T) {
+		cr, err := NewConfigReader(strings.NewReader(source), SkipBlankLines())
+		ensureError(t, err)
+		buf, err := ioutil.ReadAll(cr)
+		ensureError(t, err)
+		if got, want := string(buf), "alpha = 1\nbravo = 2\ncharlie = 3\n"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+		for i, want := range []int{0, 2, 5, 7, 0} {
+			if got := cr.SourceLine(i); got != want {
+				t.Errorf("Line %d: GOT: %v; WANT: %v", i, got, want)
+			}
+		}
+		if got, want := cr.Line(), 7; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("custom prefix and long lines", func(t *testing.T) {
+		long := strings.Repeat("x", 3*DefaultBufSize)
+		cr, err := NewConfigReader(strings.NewReader("; "+long+"\n"+long+"\n"), CommentPrefix(";"))
+		ensureError(t, err)
+		buf, err := ioutil.ReadAll(cr)
+		ensureError(t, err)
+		if got, want := string(buf), long+"\n"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", len(got), len(want))
+		}
+		if got, want := cr.SourceLine(1), 2; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
@@ -0,0 +1,92 @@
+package gorill
+
+import (
+	"io"
+	"math"
+	"sync"
+)
+
+// CountingStatsReader is an io.Reader that passes through all bytes read from
+// the source io.Reader, while accumulating a histogram of byte values. The
+// histogram may be used to estimate the Shannon entropy of the data, which is
+// useful for content sniffing, and for deciding whether data is worth
+// compressing. Its methods may be called from another go-routine while it is
+// being read.
+type CountingStatsReader struct {
+	ior    io.Reader
+	lock   sync.Mutex
+	counts [256]int64
+	total  int64
+}
+
+// NewCountingStatsReader returns a CountingStatsReader that reads from ior.
+//
+//   csr := gorill.NewCountingStatsReader(fh)
+//   if _, err := io.Copy(w, csr); err != nil {
+//       return err
+//   }
+//   if csr.Entropy() > 7.5 {
+//       // data is likely already compressed or encrypted
+//   }
+func NewCountingStatsReader(ior io.Reader) *CountingStatsReader {
+	return &CountingStatsReader{ior: ior}
+}
+
+// Read reads up to len(p) bytes into p, recording the value of each byte read.
+func (r *CountingStatsReader) Read(p []byte) (int, error) {
+	n, err := r.ior.Read(p)
+	if n > 0 {
+		r.lock.Lock()
+		for _, b := range p[:n] {
+			r.counts[b]++
+		}
+		r.total += int64(n)
+		r.lock.Unlock()
+	}
+	return n, err
+}
+
+// Counts returns the number of times each byte value has been read.
+func (r *CountingStatsReader) Counts() [256]int64 {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.counts
+}
+
+// Total returns the number of bytes read.
+func (r *CountingStatsReader) Total() int64 {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.total
+}
+
+// Stats returns the number of bytes read.
+func (r *CountingStatsReader) Stats() map[string]int64 {
+	return map[string]int64{"bytes": r.Total()}
+}
+
+// Entropy returns the Shannon entropy of the bytes read so far, in bits per
+// byte, ranging from 0 when every byte had the same value, to 8 when every byte
+// value occurred equally often. It returns 0 when no bytes have been read.
+func (r *CountingStatsReader) Entropy() float64 {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.total == 0 {
+		return 0
+	}
+	var entropy float64
+	total := float64(r.total)
+	for _, c := range r.counts {
+		if c > 0 {
+			p := float64(c) / total
+			entropy -= p * math.Log2(p)
+		}
+	}
+	return entropy
+}
+
+// Unwrap returns the underlying io.Reader.
+func (r *CountingStatsReader) Unwrap() io.Reader { return r.ior }
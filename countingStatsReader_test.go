@@ -0,0 +1,46 @@
+package gorill
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math"
+	"testing"
+)
+
+func TestCountingStatsReader(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		r := NewCountingStatsReader(bytes.NewReader(nil))
+		_, err := ioutil.ReadAll(r)
+		ensureError(t, err)
+		if got, want := r.Entropy(), 0.0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("single value", func(t *testing.T) {
+		r := NewCountingStatsReader(bytes.NewReader([]byte("aaaa")))
+		buf, err := ioutil.ReadAll(r)
+		ensureError(t, err)
+		if got, want := string(buf), "aaaa"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+		if got, want := r.Counts()['a'], int64(4); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := r.Total(), int64(4); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := r.Entropy(), 0.0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("uniform", func(t *testing.T) {
+		r := NewCountingStatsReader(bytes.NewReader(largeBuf))
+		_, err := ioutil.ReadAll(r)
+		ensureError(t, err)
+		if got, want := r.Entropy(), 8.0; math.Abs(got-want) > 1e-9 {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
@@ -0,0 +1,72 @@
+package gorill
+
+import "io"
+
+// CRLFWriter is an io.Writer that converts each bare newline character written
+// to it into a carriage return and newline sequence before writing to the
+// underlying io.Writer. Newline characters already preceded by a carriage
+// return are left alone, even when the carriage return was the final byte of
+// the previous Write. This is useful when sending line oriented output to wire
+// protocols such as SMTP, IMAP, and HTTP headers.
+type CRLFWriter struct {
+	iow        io.Writer
+	buf        []byte // buf is scratch space re-used between writes
+	wasFinalCR bool
+}
+
+// NewCRLFWriter returns a CRLFWriter that writes to iow.
+//
+//   w := gorill.NewCRLFWriter(conn)
+//   fmt.Fprintf(w, "HELO %s\n", hostname) // conn receives "HELO example.com\r\n"
+func NewCRLFWriter(iow io.Writer) *CRLFWriter {
+	return &CRLFWriter{iow: iow}
+}
+
+// Write writes data to the underlying io.Writer, converting each bare newline
+// into a carriage return and newline sequence. It returns the number of bytes
+// from data that were written, which does not include any inserted carriage
+// return characters.
+func (w *CRLFWriter) Write(data []byte) (int, error) {
+	if len(data) == 0 {
+		return 0, nil
+	}
+
+	out := w.buf[:0]
+	prevCR := w.wasFinalCR
+	for _, b := range data {
+		if b == '\n' && !prevCR {
+			out = append(out, '\r')
+		}
+		out = append(out, b)
+		prevCR = b == '\r'
+	}
+	w.buf = out
+
+	nw, err := w.iow.Write(out)
+	if err == nil && nw != len(out) {
+		err = io.ErrShortWrite
+	}
+	if err != nil {
+		// Determine how many bytes of data were completely written.
+		var ni int
+		prevCR = w.wasFinalCR
+		for i, no := 0, 0; i < len(data); i++ {
+			if data[i] == '\n' && !prevCR {
+				no++
+			}
+			no++
+			if no > nw {
+				break
+			}
+			prevCR = data[i] == '\r'
+			ni = i + 1
+		}
+		w.wasFinalCR = prevCR
+		return ni, err
+	}
+	w.wasFinalCR = prevCR
+	return len(data), nil
+}
+
+// Unwrap returns the underlying io.Writer.
+func (w *CRLFWriter) Unwrap() io.Writer { return w.iow }
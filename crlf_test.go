@@ -0,0 +1,51 @@
+package gorill
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestCRLFWriter(t *testing.T) {
+	t.Run("converts bare newlines", func(t *testing.T) {
+		bb := new(bytes.Buffer)
+		w := NewCRLFWriter(bb)
+
+		n, err := w.Write([]byte("one\ntwo\r\nthree\n"))
+		ensureError(t, err)
+		if got, want := n, 15; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := bb.String(), "one\r\ntwo\r\nthree\r\n"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("carriage return ends previous write", func(t *testing.T) {
+		bb := new(bytes.Buffer)
+		w := NewCRLFWriter(bb)
+
+		_, err := w.Write([]byte("one\r"))
+		ensureError(t, err)
+		_, err = w.Write([]byte("\ntwo\n"))
+		ensureError(t, err)
+		_, err = w.Write([]byte("\n"))
+		ensureError(t, err)
+		if got, want := bb.String(), "one\r\ntwo\r\n\r\n"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("short write", func(t *testing.T) {
+		bb := new(bytes.Buffer)
+		w := NewCRLFWriter(ShortWriter(bb, 4))
+
+		n, err := w.Write([]byte("ab\ncd"))
+		if err != io.ErrShortWrite {
+			t.Errorf("GOT: %v; WANT: %v", err, io.ErrShortWrite)
+		}
+		if got, want := n, 3; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
@@ -0,0 +1,12 @@
+//go:build go1.15
+// +build go1.15
+
+package gorill
+
+import "os"
+
+// Is returns true when target is os.ErrDeadlineExceeded, so callers may test for the error returned
+// by the standard library when a deadline expires.
+func (e ErrDeadlineExceeded) Is(target error) bool {
+	return target == os.ErrDeadlineExceeded
+}
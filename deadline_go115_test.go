@@ -0,0 +1,19 @@
+//go:build go1.15
+// +build go1.15
+
+package gorill
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestErrDeadlineExceededIsOSErrDeadlineExceeded(t *testing.T) {
+	if !errors.Is(ErrDeadlineExceeded{}, os.ErrDeadlineExceeded) {
+		t.Errorf("GOT: %v; WANT: %v", false, true)
+	}
+	if !os.IsTimeout(ErrDeadlineExceeded{}) {
+		t.Errorf("GOT: %v; WANT: %v", false, true)
+	}
+}
@@ -0,0 +1,111 @@
+package gorill
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// DebugTapWriter is an io.WriteCloser that passes data through to the
+// underlying io.WriteCloser unchanged, while rendering it as a hex and ASCII
+// dump to a secondary io.Writer, in the same layout as `hexdump -C`. Dumping
+// may be turned on and off at runtime, so a tap may be left in place on a
+// connection and only enabled while diagnosing a protocol issue.
+//
+// Each write is dumped separately, preceded by a header line, and offsets
+// continue from one write to the next, so write boundaries are visible in the
+// dump.
+type DebugTapWriter struct {
+	enabled int32 // enabled is accessed atomically
+	iowc    io.WriteCloser
+	lock    sync.Mutex
+	offset  int64
+	tap     io.Writer
+}
+
+// NewDebugTapWriter returns an enabled DebugTapWriter that writes to iowc, and
+// dumps what it writes to tap.
+//
+//   debug := gorill.NewDebugTapWriter(conn, os.Stderr)
+//   debug.SetEnabled(*verbose)
+//   _, err := debug.Write(request)
+func NewDebugTapWriter(iowc io.WriteCloser, tap io.Writer) *DebugTapWriter {
+	return &DebugTapWriter{enabled: 1, iowc: iowc, tap: tap}
+}
+
+// Enabled returns true when the DebugTapWriter is dumping what it writes.
+func (dt *DebugTapWriter) Enabled() bool {
+	return atomic.LoadInt32(&dt.enabled) == 1
+}
+
+// SetEnabled turns dumping on or off.
+func (dt *DebugTapWriter) SetEnabled(enabled bool) {
+	var v int32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreInt32(&dt.enabled, v)
+}
+
+// Write writes data to the underlying io.WriteCloser, then dumps the bytes
+// written when enabled. An error writing the dump is ignored so that debugging
+// never changes the behavior of the stream.
+func (dt *DebugTapWriter) Write(data []byte) (int, error) {
+	dt.lock.Lock()
+	defer dt.lock.Unlock()
+
+	n, err := dt.iowc.Write(data)
+	if dt.Enabled() {
+		_, _ = dt.tap.Write(hexDump(data[:n], dt.offset, err))
+	}
+	dt.offset += int64(n)
+	return n, err
+}
+
+// Unwrap returns the underlying io.WriteCloser, not the tap.
+func (dt *DebugTapWriter) Unwrap() io.WriteCloser { return dt.iowc }
+
+// Close closes the underlying io.WriteCloser. It does not close the tap.
+func (dt *DebugTapWriter) Close() error {
+	return dt.iowc.Close()
+}
+
+// hexDump renders data, which begins at offset in the stream, in the layout of
+// `hexdump -C`, preceded by a header line describing the write.
+func hexDump(data []byte, offset int64, err error) []byte {
+	const hexDigits = "0123456789abcdef"
+
+	buf := []byte(fmt.Sprintf("write %d bytes at offset %d", len(data), offset))
+	if err != nil {
+		buf = append(buf, fmt.Sprintf(": %s", err)...)
+	}
+	buf = append(buf, '\n')
+
+	for i := 0; i < len(data); i += 16 {
+		line := data[i:]
+		if len(line) > 16 {
+			line = line[:16]
+		}
+		buf = append(buf, fmt.Sprintf("%08x ", offset+int64(i))...)
+		for j := 0; j < 16; j++ {
+			if j == 8 {
+				buf = append(buf, ' ')
+			}
+			if j < len(line) {
+				buf = append(buf, ' ', hexDigits[line[j]>>4], hexDigits[line[j]&0x0f])
+			} else {
+				buf = append(buf, "   "...)
+			}
+		}
+		buf = append(buf, "  |"...)
+		for _, b := range line {
+			if b < 32 || b > 126 {
+				b = '.'
+			}
+			buf = append(buf, b)
+		}
+		buf = append(buf, "|\n"...)
+	}
+	return buf
+}
@@ -0,0 +1,56 @@
+package gorill
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestDebugTapWriter(t *testing.T) {
+	payload := new(bytes.Buffer)
+	tap := new(bytes.Buffer)
+	dt := NewDebugTapWriter(NopCloseWriter(payload), tap)
+
+	n, err := dt.Write([]byte(alphabet))
+	ensureError(t, err)
+	if got, want := n, len(alphabet); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	dt.SetEnabled(false)
+	if dt.Enabled() {
+		t.Errorf("GOT: %v; WANT: %v", true, false)
+	}
+	_, err = dt.Write([]byte("hidden"))
+	ensureError(t, err)
+
+	dt.SetEnabled(true)
+	_, err = dt.Write([]byte("\x00\xffok"))
+	ensureError(t, err)
+	ensureError(t, dt.Close())
+
+	if got, want := payload.String(), alphabet+"hidden\x00\xffok"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+
+	want := "write 27 bytes at offset 0\n" +
+		"00000000  61 62 63 64 65 66 67 68  69 6a 6b 6c 6d 6e 6f 70  |abcdefghijklmnop|\n" +
+		"00000010  71 72 73 74 75 76 77 78  79 7a 0a                 |qrstuvwxyz.|\n" +
+		"write 4 bytes at offset 33\n" +
+		"00000021  00 ff 6f 6b                                       |..ok|\n"
+	if got := tap.String(); got != want {
+		t.Errorf("GOT:\n%s\nWANT:\n%s", got, want)
+	}
+}
+
+func TestDebugTapWriterError(t *testing.T) {
+	tap := new(bytes.Buffer)
+	dt := NewDebugTapWriter(&testWriteCloser{}, tap)
+
+	_, err := dt.Write([]byte(alphabet))
+	testErrorType(t, err, io.ErrShortWrite)
+
+	if got, want := tap.String(), "write 0 bytes at offset 0: short write\n"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+}
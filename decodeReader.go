@@ -0,0 +1,147 @@
+//go:build go1.21
+// +build go1.21
+
+package gorill
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// DecodeReaderSetter is any function that modifies a DecodeReader being
+// instantiated.
+type DecodeReaderSetter func(*decodeReaderConfig) error
+
+type decodeReaderConfig struct {
+	lengthPrefix bool
+	maxSize      int
+}
+
+// DecodeLengthPrefix is used to configure a DecodeReader to read records
+// preceded by their length, as a 4 byte big-endian unsigned integer, as
+// written by an EncodeWriter configured with EncodeLengthPrefix. By default
+// records are newline delimited.
+func DecodeLengthPrefix() DecodeReaderSetter {
+	return func(config *decodeReaderConfig) error {
+		config.lengthPrefix = true
+		return nil
+	}
+}
+
+// DecodeMaxRecordSize is used to configure the maximum size of a record a
+// DecodeReader will read. The default is DefaultMaxLineSize.
+func DecodeMaxRecordSize(size int) DecodeReaderSetter {
+	return func(config *decodeReaderConfig) error {
+		if size <= 0 {
+			return fmt.Errorf("max record size must be greater than 0: %d", size)
+		}
+		config.maxSize = size
+		return nil
+	}
+}
+
+// DecodeReader reads records from an underlying io.ReadCloser, and decodes each
+// one into a value of type T with a caller supplied unmarshal function, such as
+// json.Unmarshal, completing a typed streaming pipeline with EncodeWriter.
+// Errors from the wrappers beneath it, such as ErrTimeout from a
+// TimedReadCloser, are returned unchanged.
+//
+// Because each record is decoded independently, stateful stream formats such
+// as gob ought to use their own stream decoder instead.
+type DecodeReader[T any] struct {
+	config    decodeReaderConfig
+	iorc      io.ReadCloser
+	lr        *LinesReader
+	unmarshal func([]byte, interface{}) error
+}
+
+// NewDecodeReader returns a DecodeReader that decodes records read from iorc
+// with unmarshal.
+//
+//   dr, err := gorill.NewDecodeReader[Event](conn, json.Unmarshal)
+//   if err != nil {
+//       return err
+//   }
+//   for {
+//       event, err := dr.Next()
+//       if err == io.EOF {
+//           break
+//       }
+//       if err != nil {
+//           return err
+//       }
+//       handle(event)
+//   }
+func NewDecodeReader[T any](iorc io.ReadCloser, unmarshal func([]byte, interface{}) error, setters ...DecodeReaderSetter) (*DecodeReader[T], error) {
+	dr := &DecodeReader[T]{
+		config:    decodeReaderConfig{maxSize: DefaultMaxLineSize},
+		iorc:      iorc,
+		unmarshal: unmarshal,
+	}
+	for _, setter := range setters {
+		if err := setter(&dr.config); err != nil {
+			return nil, err
+		}
+	}
+	if !dr.config.lengthPrefix {
+		lr, err := NewLinesReader(iorc, MaxLineSize(dr.config.maxSize))
+		if err != nil {
+			return nil, err
+		}
+		dr.lr = lr
+	}
+	return dr, nil
+}
+
+// Next reads and decodes the next record. It returns io.EOF when there are no
+// more records, and io.ErrUnexpectedEOF when the stream ends within a record.
+// Empty lines between newline delimited records are ignored.
+func (dr *DecodeReader[T]) Next() (T, error) {
+	var v T
+	record, err := dr.next()
+	if err != nil {
+		return v, err
+	}
+	err = dr.unmarshal(record, &v)
+	return v, err
+}
+
+func (dr *DecodeReader[T]) next() ([]byte, error) {
+	if dr.lr != nil {
+		for dr.lr.Scan() {
+			if line := dr.lr.Bytes(); len(line) > 0 {
+				return line, nil
+			}
+		}
+		if err := dr.lr.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+
+	var prefix [4]byte
+	if _, err := io.ReadFull(dr.iorc, prefix[:]); err != nil {
+		return nil, err // io.EOF only when no bytes of the prefix were read
+	}
+	size := binary.BigEndian.Uint32(prefix[:])
+	if uint64(size) > uint64(dr.config.maxSize) {
+		return nil, fmt.Errorf("record of %d bytes exceeds maximum record size of %d bytes", size, dr.config.maxSize)
+	}
+	record := make([]byte, size)
+	if _, err := io.ReadFull(dr.iorc, record); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return record, nil
+}
+
+// Unwrap returns the underlying io.ReadCloser.
+func (dr *DecodeReader[T]) Unwrap() io.ReadCloser { return dr.iorc }
+
+// Close closes the underlying io.ReadCloser.
+func (dr *DecodeReader[T]) Close() error {
+	return dr.iorc.Close()
+}
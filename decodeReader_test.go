@@ -0,0 +1,80 @@
+//go:build go1.21
+// +build go1.21
+
+package gorill
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestDecodeReaderLines(t *testing.T) {
+	input := "{\"Name\":\"a\",\"Count\":1}\n\n{\"Name\":\"b\",\"Count\":2}"
+	dr, err := NewDecodeReader[encodeTestEvent](ioutil.NopCloser(strings.NewReader(input)), json.Unmarshal)
+	ensureError(t, err)
+
+	for _, want := range []encodeTestEvent{{Name: "a", Count: 1}, {Name: "b", Count: 2}} {
+		got, err := dr.Next()
+		ensureError(t, err)
+		if got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	}
+	_, err = dr.Next()
+	testErrorType(t, err, io.EOF)
+	ensureError(t, dr.Close())
+}
+
+func TestDecodeReaderLengthPrefix(t *testing.T) {
+	bb := NewNopCloseBuffer()
+	ew, err := NewEncodeWriter[encodeTestEvent](bb, func(w io.Writer) ValueEncoder { return json.NewEncoder(w) }, EncodeLengthPrefix())
+	ensureError(t, err)
+	events := []encodeTestEvent{{Name: "a", Count: 1}, {Name: "b", Count: 2}}
+	for _, event := range events {
+		ensureError(t, ew.WriteValue(event))
+	}
+	encoded := bb.String()
+
+	dr, err := NewDecodeReader[encodeTestEvent](ioutil.NopCloser(strings.NewReader(encoded)), json.Unmarshal, DecodeLengthPrefix())
+	ensureError(t, err)
+	for _, want := range events {
+		got, err := dr.Next()
+		ensureError(t, err)
+		if got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	}
+	_, err = dr.Next()
+	testErrorType(t, err, io.EOF)
+
+	dr, err = NewDecodeReader[encodeTestEvent](ioutil.NopCloser(strings.NewReader(encoded[:len(encoded)-3])), json.Unmarshal, DecodeLengthPrefix())
+	ensureError(t, err)
+	_, err = dr.Next()
+	ensureError(t, err)
+	_, err = dr.Next()
+	testErrorType(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestDecodeReaderLimits(t *testing.T) {
+	_, err := NewDecodeReader[encodeTestEvent](ioutil.NopCloser(strings.NewReader("")), json.Unmarshal, DecodeMaxRecordSize(0))
+	ensureError(t, err, "max record size must be greater than 0")
+
+	dr, err := NewDecodeReader[encodeTestEvent](ioutil.NopCloser(strings.NewReader("\x00\x00\x01\x00")), json.Unmarshal,
+		DecodeLengthPrefix(), DecodeMaxRecordSize(16))
+	ensureError(t, err)
+	_, err = dr.Next()
+	ensureError(t, err, "record of 256 bytes exceeds maximum record size of 16 bytes")
+
+	dr, err = NewDecodeReader[encodeTestEvent](ioutil.NopCloser(strings.NewReader(alphabet)), json.Unmarshal, DecodeMaxRecordSize(16))
+	ensureError(t, err)
+	_, err = dr.Next()
+	testErrorType(t, err, ErrLineTooLong{Line: 1, Max: 16})
+
+	dr, err = NewDecodeReader[encodeTestEvent](ioutil.NopCloser(strings.NewReader("not json\n")), json.Unmarshal)
+	ensureError(t, err)
+	_, err = dr.Next()
+	ensureError(t, err, "invalid character")
+}
@@ -0,0 +1,62 @@
+package gorill
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// ErrUnsupportedCompression is returned when a stream is recognized as being
+// compressed using an algorithm this library cannot decompress.
+type ErrUnsupportedCompression string
+
+// Error returns a string representing the ErrUnsupportedCompression.
+func (e ErrUnsupportedCompression) Error() string {
+	return fmt.Sprintf("unsupported compression: %s", string(e))
+}
+
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	bzip2Magic = []byte("BZh")
+	zstdMagic  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// NewDecompressingReader inspects the initial bytes of ior, and when they
+// identify a gzip or bzip2 compressed stream, returns an io.ReadCloser that
+// decompresses the stream. Otherwise it returns an io.ReadCloser that reads
+// from ior unmodified. When ior is a zstd compressed stream, it returns
+// ErrUnsupportedCompression. Closing the returned io.ReadCloser does not close
+// ior.
+//
+//   fh, err := os.Open("/var/log/messages.1.gz")
+//   if err != nil {
+//       return err
+//   }
+//   defer fh.Close()
+//   r, err := gorill.NewDecompressingReader(fh)
+//   if err != nil {
+//       return err
+//   }
+//   defer r.Close()
+//   lines, err := gorill.CountLinesFromReader(r)
+func NewDecompressingReader(ior io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(ior)
+	head, err := br.Peek(len(zstdMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(head, gzipMagic):
+		return gzip.NewReader(br)
+	case bytes.HasPrefix(head, bzip2Magic):
+		return NopCloseReader(bzip2.NewReader(br)), nil
+	case bytes.HasPrefix(head, zstdMagic):
+		return nil, ErrUnsupportedCompression("zstd")
+	default:
+		return NopCloseReader(br), nil
+	}
+}
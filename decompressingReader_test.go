@@ -0,0 +1,52 @@
+package gorill
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"testing"
+)
+
+func TestDecompressingReader(t *testing.T) {
+	const payload = "one\ntwo\nthree\n"
+
+	t.Run("uncompressed", func(t *testing.T) {
+		r, err := NewDecompressingReader(bytes.NewReader([]byte(payload)))
+		ensureError(t, err)
+		buf, err := ioutil.ReadAll(r)
+		ensureError(t, err)
+		if got, want := string(buf), payload; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+		ensureError(t, r.Close())
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		r, err := NewDecompressingReader(bytes.NewReader(nil))
+		ensureError(t, err)
+		buf, err := ioutil.ReadAll(r)
+		ensureError(t, err)
+		if got, want := len(buf), 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("gzip", func(t *testing.T) {
+		bb := new(bytes.Buffer)
+		gz := gzip.NewWriter(bb)
+		_, err := gz.Write([]byte(payload))
+		ensureError(t, err)
+		ensureError(t, gz.Close())
+
+		lines, err := CountLinesFromReader(bb, LineCountDecompress())
+		ensureError(t, err)
+		if got, want := lines, 3; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("zstd", func(t *testing.T) {
+		_, err := NewDecompressingReader(bytes.NewReader([]byte{0x28, 0xb5, 0x2f, 0xfd, 0}))
+		testErrorType(t, err, ErrUnsupportedCompression(""))
+	})
+}
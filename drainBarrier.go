@@ -0,0 +1,116 @@
+package gorill
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// DrainBarrier is an io.WriteCloser that tracks writes in flight to an
+// underlying io.WriteCloser shared by many go-routines, so that a program
+// shutting down may stop accepting new writes, wait for the writes already
+// started to finish, and then close the sink, without improvising that
+// sequence around a sync.WaitGroup.
+//
+// DrainBarrier does not serialize writes. When the underlying io.WriteCloser
+// is not safe for concurrent use, wrap it with a LockingWriteCloser.
+type DrainBarrier struct {
+	closeErr  error
+	closeOnce sync.Once
+	inflight  int
+	iowc      io.WriteCloser
+	lock      sync.Mutex
+	quiescing bool
+	wg        sync.WaitGroup
+}
+
+// NewDrainBarrier returns a DrainBarrier that writes to iowc.
+//
+//   db := gorill.NewDrainBarrier(gorill.NewLockingWriteCloser(logFile))
+//   for i := 0; i < workers; i++ {
+//       go worker(db)
+//   }
+//   <-shutdown
+//   ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+//   defer cancel()
+//   if err := db.Quiesce(ctx); err != nil {
+//       log.Print(err)
+//   }
+func NewDrainBarrier(iowc io.WriteCloser) *DrainBarrier {
+	return &DrainBarrier{iowc: iowc}
+}
+
+// Inflight returns the number of writes that have started and not yet
+// finished.
+func (db *DrainBarrier) Inflight() int {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	return db.inflight
+}
+
+// Write writes data to the underlying io.WriteCloser, unless the DrainBarrier
+// is quiescing, in which case it returns ErrWriteAfterClose.
+func (db *DrainBarrier) Write(data []byte) (int, error) {
+	db.lock.Lock()
+	if db.quiescing {
+		db.lock.Unlock()
+		return 0, ErrWriteAfterClose{}
+	}
+	db.inflight++
+	db.wg.Add(1)
+	db.lock.Unlock()
+
+	defer func() {
+		db.lock.Lock()
+		db.inflight--
+		db.lock.Unlock()
+		db.wg.Done()
+	}()
+	return db.iowc.Write(data)
+}
+
+// SetWriteDeadline sets the write deadline of the underlying io.WriteCloser,
+// which may be used to bound writes in flight while quiescing.
+func (db *DrainBarrier) SetWriteDeadline(t time.Time) error {
+	return SetWriteDeadline(db.iowc, t)
+}
+
+// Quiesce stops accepting new writes, waits for writes in flight to finish,
+// then closes the underlying io.WriteCloser. When ctx is done before the writes
+// in flight finish, it closes the underlying io.WriteCloser without waiting any
+// longer, and its returned error includes the context error. Only the first
+// call closes the underlying io.WriteCloser; subsequent calls return the same
+// error.
+func (db *DrainBarrier) Quiesce(ctx context.Context) error {
+	db.lock.Lock()
+	db.quiescing = true
+	db.lock.Unlock()
+
+	db.closeOnce.Do(func() {
+		drained := make(chan struct{})
+		go func() {
+			db.wg.Wait()
+			close(drained)
+		}()
+
+		var errors ErrList
+		select {
+		case <-drained:
+		case <-ctx.Done():
+			errors.Append(ctx.Err())
+		}
+		errors.Append(db.iowc.Close())
+		db.closeErr = errors.Err()
+	})
+	return db.closeErr
+}
+
+// Unwrap returns the underlying io.WriteCloser.
+func (db *DrainBarrier) Unwrap() io.WriteCloser { return db.iowc }
+
+// Close is equivalent to Quiesce with a context that is never done.
+func (db *DrainBarrier) Close() error {
+	return db.Quiesce(context.Background())
+}
@@ -0,0 +1,113 @@
+package gorill
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gatedWriteCloser blocks each write until its gate is closed.
+type gatedWriteCloser struct {
+	started chan struct{}
+	gate    chan struct{}
+	closed  bool
+	lock    sync.Mutex
+}
+
+func (g *gatedWriteCloser) Write(data []byte) (int, error) {
+	g.started <- struct{}{}
+	<-g.gate
+	return len(data), nil
+}
+
+func (g *gatedWriteCloser) Close() error {
+	g.lock.Lock()
+	g.closed = true
+	g.lock.Unlock()
+	return nil
+}
+
+func (g *gatedWriteCloser) isClosed() bool {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return g.closed
+}
+
+func TestDrainBarrierWaitsForInflight(t *testing.T) {
+	g := &gatedWriteCloser{started: make(chan struct{}, 1), gate: make(chan struct{})}
+	db := NewDrainBarrier(g)
+
+	done := make(chan error)
+	go func() {
+		_, err := db.Write([]byte(alphabet))
+		done <- err
+	}()
+	<-g.started
+	if got, want := db.Inflight(), 1; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	quiesced := make(chan error)
+	go func() { quiesced <- db.Quiesce(context.Background()) }()
+
+	time.Sleep(10 * time.Millisecond)
+	_, err := db.Write([]byte(alphabet))
+	testErrorType(t, err, ErrWriteAfterClose{})
+	if g.isClosed() {
+		t.Errorf("GOT: %v; WANT: %v", true, false)
+	}
+
+	close(g.gate)
+	ensureError(t, <-done)
+	ensureError(t, <-quiesced)
+	if !g.isClosed() {
+		t.Errorf("GOT: %v; WANT: %v", false, true)
+	}
+	ensureError(t, db.Close())
+}
+
+func TestDrainBarrierDeadline(t *testing.T) {
+	g := &gatedWriteCloser{started: make(chan struct{}, 1), gate: make(chan struct{})}
+	db := NewDrainBarrier(g)
+
+	go func() { _, _ = db.Write([]byte(alphabet)) }()
+	<-g.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := db.Quiesce(ctx)
+	ensureError(t, err, "deadline exceeded")
+	if !g.isClosed() {
+		t.Errorf("GOT: %v; WANT: %v", false, true)
+	}
+	close(g.gate)
+}
+
+func TestDrainBarrierConcurrentWrites(t *testing.T) {
+	bb := NewLockingWriteCloser(NewNopCloseBuffer())
+	db := NewDrainBarrier(bb)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if _, err := db.Write([]byte("x")); err != nil {
+					if !strings.Contains(err.Error(), "closed") {
+						t.Error(err)
+					}
+					return
+				}
+			}
+		}()
+	}
+	time.Sleep(time.Millisecond)
+	ensureError(t, db.Close())
+	wg.Wait()
+	if got, want := db.Inflight(), 0; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
@@ -0,0 +1,106 @@
+//go:build go1.21
+// +build go1.21
+
+package gorill
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+)
+
+// ValueEncoder is implemented by the encoders of the standard library, such as
+// *json.Encoder and *gob.Encoder, and may be implemented by a small adapter for
+// other serialization formats, such as protocol buffers.
+type ValueEncoder interface {
+	Encode(v interface{}) error
+}
+
+// EncodeWriterSetter is any function that modifies an EncodeWriter being
+// instantiated.
+type EncodeWriterSetter func(*encodeWriterConfig) error
+
+type encodeWriterConfig struct {
+	lengthPrefix bool
+}
+
+// EncodeLengthPrefix is used to configure an EncodeWriter to precede each
+// encoded value with its length, as a 4 byte big-endian unsigned integer, for
+// formats that do not delimit their own values.
+func EncodeLengthPrefix() EncodeWriterSetter {
+	return func(config *encodeWriterConfig) error {
+		config.lengthPrefix = true
+		return nil
+	}
+}
+
+// EncodeWriter serializes values of type T with a caller supplied encoder, and
+// writes each one to an underlying io.WriteCloser with a single write, so that
+// typed producers may sit directly on top of a stack of byte stream wrappers.
+type EncodeWriter[T any] struct {
+	buf    bytes.Buffer
+	config encodeWriterConfig
+	enc    ValueEncoder
+	iowc   io.WriteCloser
+	lock   sync.Mutex
+}
+
+// NewEncodeWriter returns an EncodeWriter that writes values to iowc, encoded
+// by the ValueEncoder returned by newEncoder. Because an encoder may be
+// stateful, as a gob encoder is, newEncoder is invoked once.
+//
+//   ew, err := gorill.NewEncodeWriter[Event](conn, func(w io.Writer) gorill.ValueEncoder {
+//       return json.NewEncoder(w)
+//   })
+//   if err != nil {
+//       return err
+//   }
+//   err = ew.WriteValue(Event{Name: "login"})
+func NewEncodeWriter[T any](iowc io.WriteCloser, newEncoder func(io.Writer) ValueEncoder, setters ...EncodeWriterSetter) (*EncodeWriter[T], error) {
+	ew := &EncodeWriter[T]{iowc: iowc}
+	for _, setter := range setters {
+		if err := setter(&ew.config); err != nil {
+			return nil, err
+		}
+	}
+	ew.enc = newEncoder(&ew.buf)
+	return ew, nil
+}
+
+// WriteValue encodes v, and writes it to the underlying io.WriteCloser.
+func (ew *EncodeWriter[T]) WriteValue(v T) error {
+	ew.lock.Lock()
+	defer ew.lock.Unlock()
+
+	ew.buf.Reset()
+	if ew.config.lengthPrefix {
+		ew.buf.Write([]byte{0, 0, 0, 0}) // placeholder for the length
+	}
+	if err := ew.enc.Encode(v); err != nil {
+		return err
+	}
+	buf := ew.buf.Bytes()
+	if ew.config.lengthPrefix {
+		size := len(buf) - 4
+		if uint64(size) > math.MaxUint32 {
+			return fmt.Errorf("encoded value too large for length prefix: %d bytes", size)
+		}
+		binary.BigEndian.PutUint32(buf, uint32(size))
+	}
+	n, err := ew.iowc.Write(buf)
+	if err == nil && n < len(buf) {
+		err = io.ErrShortWrite
+	}
+	return err
+}
+
+// Unwrap returns the underlying io.WriteCloser.
+func (ew *EncodeWriter[T]) Unwrap() io.WriteCloser { return ew.iowc }
+
+// Close closes the underlying io.WriteCloser.
+func (ew *EncodeWriter[T]) Close() error {
+	return ew.iowc.Close()
+}
@@ -0,0 +1,70 @@
+//go:build go1.21
+// +build go1.21
+
+package gorill
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"io"
+	"testing"
+)
+
+type encodeTestEvent struct {
+	Name  string
+	Count int
+}
+
+func TestEncodeWriterJSON(t *testing.T) {
+	bb := NewNopCloseBuffer()
+	ew, err := NewEncodeWriter[encodeTestEvent](bb, func(w io.Writer) ValueEncoder { return json.NewEncoder(w) })
+	ensureError(t, err)
+
+	ensureError(t, ew.WriteValue(encodeTestEvent{Name: "login", Count: 1}))
+	ensureError(t, ew.WriteValue(encodeTestEvent{Name: "logout", Count: 2}))
+	ensureError(t, ew.Close())
+
+	if got, want := bb.String(), "{\"Name\":\"login\",\"Count\":1}\n{\"Name\":\"logout\",\"Count\":2}\n"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+}
+
+func TestEncodeWriterGobLengthPrefix(t *testing.T) {
+	bb := NewNopCloseBuffer()
+	ew, err := NewEncodeWriter[encodeTestEvent](bb, func(w io.Writer) ValueEncoder { return gob.NewEncoder(w) }, EncodeLengthPrefix())
+	ensureError(t, err)
+
+	events := []encodeTestEvent{{Name: "a", Count: 1}, {Name: "b", Count: 2}}
+	for _, event := range events {
+		ensureError(t, ew.WriteValue(event))
+	}
+
+	// Remove the framing, then decode the concatenated payloads.
+	var payload []byte
+	buf := bb.Bytes()
+	for len(buf) > 0 {
+		size := binary.BigEndian.Uint32(buf)
+		payload = append(payload, buf[4:4+size]...)
+		buf = buf[4+size:]
+	}
+	dec := gob.NewDecoder(bytes.NewReader(payload))
+	for _, want := range events {
+		var got encodeTestEvent
+		ensureError(t, dec.Decode(&got))
+		if got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	}
+}
+
+func TestEncodeWriterError(t *testing.T) {
+	ew, err := NewEncodeWriter[encodeTestEvent](&testWriteCloser{}, func(w io.Writer) ValueEncoder { return json.NewEncoder(w) })
+	ensureError(t, err)
+	testErrorType(t, ew.WriteValue(encodeTestEvent{}), io.ErrShortWrite)
+
+	ec, err := NewEncodeWriter[chan int](NewNopCloseBuffer(), func(w io.Writer) ValueEncoder { return json.NewEncoder(w) })
+	ensureError(t, err)
+	ensureError(t, ec.WriteValue(make(chan int)), "unsupported type")
+}
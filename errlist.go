@@ -1,6 +1,56 @@
 package gorill
 
-import "strings"
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrListDetailLimit is the largest number of errors an ErrList lists
+// individually in its error message. A longer list is summarized by grouping
+// identical error messages together with a count, so that the message remains
+// actionable when many members of an aggregate fail the same way.
+const ErrListDetailLimit = 10
+
+// MemberError identifies which member of an aggregate, such as one of the
+// writers of a MultiWriteCloserFanOut, returned an error.
+type MemberError struct {
+	// Index is the position of the member within the aggregate, or -1 when
+	// the error is not associated with a member.
+	Index int
+
+	// Name is the name of the member, when it has one.
+	Name string
+
+	// Err is the error returned by the member.
+	Err error
+}
+
+// Error returns a string representation of a MemberError error instance.
+func (e MemberError) Error() string {
+	if e.Index < 0 {
+		return e.Err.Error()
+	}
+	if e.Name != "" {
+		return fmt.Sprintf("member %d (%s): %s", e.Index, e.Name, e.Err)
+	}
+	return fmt.Sprintf("member %d: %s", e.Index, e.Err)
+}
+
+// Unwrap returns the error returned by the member.
+func (e MemberError) Unwrap() error { return e.Err }
+
+// memberName returns the name of an aggregate member, from its Name or String
+// method when it has one, such as the path of an *os.File.
+func memberName(member interface{}) string {
+	switch m := member.(type) {
+	case interface{ Name() string }:
+		return m.Name()
+	case fmt.Stringer:
+		return m.String()
+	}
+	return ""
+}
 
 // ErrList is a slice of errors, useful when a function must return a single error, but has multiple
 // independent errors to return.
@@ -13,11 +63,36 @@ func (e *ErrList) Append(b error) {
 	}
 }
 
+// AppendMember appends a non-nil error returned by the member of an aggregate at the specified
+// index, identifying the member by its Name or String method when it has one.
+func (e *ErrList) AppendMember(index int, member interface{}, b error) {
+	if b != nil {
+		*e = append(*e, MemberError{Index: index, Name: memberName(member), Err: b})
+	}
+}
+
 // Count returns number of non-nil errors accumulated in ErrList.
 func (e ErrList) Count() int {
 	return len([]error(e))
 }
 
+// Failures returns each error in the list as a MemberError. An error that was not appended with
+// AppendMember has an Index of -1. When built with Go 1.20 or later, errors.Is and errors.As also
+// examine each error in the list; before that, Failures is the way to examine the member errors.
+func (e ErrList) Failures() []MemberError {
+	failures := make([]MemberError, 0, len([]error(e)))
+	for _, err := range []error(e) {
+		switch me := err.(type) {
+		case nil:
+		case MemberError:
+			failures = append(failures, me)
+		default:
+			failures = append(failures, MemberError{Index: -1, Err: err})
+		}
+	}
+	return failures
+}
+
 // Err returns either a list of non-nil error values, or a single error value if the list only
 // contains one error.
 func (e ErrList) Err() error {
@@ -31,20 +106,40 @@ func (e ErrList) Err() error {
 	case 0:
 		return nil
 	case 1:
-		return e[0]
+		return errors[0]
 	default:
 		return ErrList(errors)
 	}
 }
 
 // Error returns the string version of an error list, which is the list of errors, joined by a
-// comma-space byte sequence.
+// comma-space byte sequence. When the list has more than ErrListDetailLimit errors, the errors
+// are instead grouped by message, ignoring which member returned them, and each message is listed
+// once, most frequent first, followed by the number of times it occurred.
 func (e ErrList) Error() string {
-	es := make([]string, 0, len([]error(e)))
-	for i := range []error(e) {
-		if []error(e)[i] != nil {
-			es = append(es, []error(e)[i].Error())
+	failures := e.Failures()
+	if len(failures) <= ErrListDetailLimit {
+		es := make([]string, 0, len(failures))
+		for _, f := range failures {
+			es = append(es, f.Error())
+		}
+		return strings.Join(es, ", ")
+	}
+
+	counts := make(map[string]int)
+	var messages []string
+	for _, f := range failures {
+		message := f.Err.Error()
+		if counts[message] == 0 {
+			messages = append(messages, message)
 		}
+		counts[message]++
+	}
+	sort.SliceStable(messages, func(i, j int) bool { return counts[messages[i]] > counts[messages[j]] })
+
+	es := make([]string, 0, len(messages))
+	for _, message := range messages {
+		es = append(es, fmt.Sprintf("%s (%d times)", message, counts[message]))
 	}
-	return strings.Join(es, ", ")
+	return fmt.Sprintf("%d errors: %s", len(failures), strings.Join(es, ", "))
 }
@@ -0,0 +1,16 @@
+//go:build go1.20
+// +build go1.20
+
+package gorill
+
+// Unwrap returns the non-nil errors in the list, so that errors.Is and errors.As examine each of
+// them, such as to extract the MemberError of a failed writer.
+func (e ErrList) Unwrap() []error {
+	errors := make([]error, 0, len([]error(e)))
+	for _, err := range []error(e) {
+		if err != nil {
+			errors = append(errors, err)
+		}
+	}
+	return errors
+}
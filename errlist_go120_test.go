@@ -0,0 +1,31 @@
+//go:build go1.20
+// +build go1.20
+
+package gorill
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestErrListUnwrap(t *testing.T) {
+	var list ErrList
+	list.Append(io.ErrShortWrite)
+	list.AppendMember(1, nil, io.ErrClosedPipe)
+	err := list.Err()
+
+	if !errors.Is(err, io.ErrShortWrite) {
+		t.Errorf("GOT: %v; WANT: %v", err, io.ErrShortWrite)
+	}
+	if !errors.Is(err, io.ErrClosedPipe) {
+		t.Errorf("GOT: %v; WANT: %v", err, io.ErrClosedPipe)
+	}
+	var member MemberError
+	if !errors.As(err, &member) {
+		t.Fatalf("GOT: %T; WANT: %T", err, member)
+	}
+	if got, want := member.Index, 1; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
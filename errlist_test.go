@@ -0,0 +1,75 @@
+package gorill
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// namedWriteCloser is a NopCloseBuffer with a name, whose Close returns err.
+type namedWriteCloser struct {
+	*NopCloseBuffer
+	err  error
+	name string
+}
+
+func (n *namedWriteCloser) Name() string { return n.name }
+func (n *namedWriteCloser) Close() error { return n.err }
+
+func TestErrListMembers(t *testing.T) {
+	var list ErrList
+	list.Append(io.EOF)
+	list.AppendMember(3, &namedWriteCloser{name: "/var/log/a"}, io.ErrShortWrite)
+	list.AppendMember(4, NewNopCloseBuffer(), io.ErrClosedPipe)
+	list.AppendMember(5, nil, nil)
+
+	if got, want := list.Count(), 3; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := list.Error(), "EOF, member 3 (/var/log/a): short write, member 4: io: read/write on closed pipe"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+
+	failures := list.Failures()
+	if got, want := len(failures), 3; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := failures[0].Index, -1; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := failures[1].Name, "/var/log/a"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	if !errors.Is(failures[2], io.ErrClosedPipe) {
+		t.Errorf("GOT: %v; WANT: %v", failures[2], io.ErrClosedPipe)
+	}
+}
+
+func TestErrListSummary(t *testing.T) {
+	var list ErrList
+	for i := 0; i < 500; i++ {
+		err := io.ErrClosedPipe
+		if i%25 == 0 {
+			err = io.ErrShortWrite
+		}
+		list.AppendMember(i, nil, err)
+	}
+	want := "500 errors: io: read/write on closed pipe (480 times), short write (20 times)"
+	if got := list.Error(); got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+}
+
+func TestMultiWriteCloserFanOutCloseIdentifiesMembers(t *testing.T) {
+	mw := NewMultiWriteCloserFanOut(
+		&namedWriteCloser{NopCloseBuffer: NewNopCloseBuffer(), name: "first", err: fmt.Errorf("cannot flush")},
+		&namedWriteCloser{NopCloseBuffer: NewNopCloseBuffer(), name: "second"})
+
+	err := mw.Close()
+	ensureError(t, err, "(first): cannot flush")
+	if strings.Contains(err.Error(), "second") {
+		t.Errorf("GOT: %v; WANT: only first", err)
+	}
+}
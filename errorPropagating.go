@@ -0,0 +1,138 @@
+package gorill
+
+import (
+	"io"
+	"sync"
+)
+
+// abortState is shared by both ends of an error propagating pipeline.
+type abortState struct {
+	err  error
+	iorc io.ReadCloser
+	iowc io.WriteCloser
+	lock sync.Mutex
+}
+
+// aborted returns the error with which the pipeline was aborted, or nil.
+func (s *abortState) aborted() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.err
+}
+
+// abort records err, then closes both ends of the pipeline to unblock any
+// pending operations. Only the first abort has any effect.
+func (s *abortState) abort(err error) error {
+	if err == nil {
+		err = io.ErrClosedPipe
+	}
+	s.lock.Lock()
+	if s.err != nil {
+		s.lock.Unlock()
+		return nil
+	}
+	s.err = err
+	s.lock.Unlock()
+
+	var errors ErrList
+	errors.Append(s.iowc.Close())
+	errors.Append(s.iorc.Close())
+	return errors.Err()
+}
+
+// NewErrorPropagatingPipe wraps the reading and writing ends of a pipeline,
+// such as the two ends of a pipe with other wrappers from this library layered
+// on either end, so that either end may abort the pipeline with an error. This
+// is the equivalent of io.PipeWriter's CloseWithError method for an arbitrary
+// pipeline. After either end is aborted, pending and subsequent operations on
+// both ends fail with that error.
+//
+//   pr, pw := io.Pipe()
+//   r, w := gorill.NewErrorPropagatingPipe(pr, gorill.NewLockingWriteCloser(pw))
+//   go func() {
+//       if err := produce(w); err != nil {
+//           w.Abort(err) // consumer's Read returns err
+//           return
+//       }
+//       w.Close() // consumer's Read returns io.EOF
+//   }()
+//   return consume(r)
+func NewErrorPropagatingPipe(iorc io.ReadCloser, iowc io.WriteCloser) (*ErrorPropagatingReadCloser, *ErrorPropagatingWriteCloser) {
+	state := &abortState{iorc: iorc, iowc: iowc}
+	return &ErrorPropagatingReadCloser{state: state}, &ErrorPropagatingWriteCloser{state: state}
+}
+
+// ErrorPropagatingReadCloser is the reading end of an error propagating
+// pipeline.
+type ErrorPropagatingReadCloser struct {
+	state *abortState
+}
+
+// Read reads up to len(p) bytes into p. It returns the error with which the
+// pipeline was aborted, if any.
+func (r *ErrorPropagatingReadCloser) Read(p []byte) (int, error) {
+	if err := r.state.aborted(); err != nil {
+		return 0, err
+	}
+	n, err := r.state.iorc.Read(p)
+	if err != nil {
+		if aerr := r.state.aborted(); aerr != nil {
+			err = aerr // read was interrupted by an abort
+		}
+	}
+	return n, err
+}
+
+// Abort causes pending and subsequent operations on both ends of the pipeline
+// to fail with err, or io.ErrClosedPipe when err is nil. It closes both the
+// underlying io.ReadCloser and io.WriteCloser, and returns any errors from
+// doing so.
+func (r *ErrorPropagatingReadCloser) Abort(err error) error {
+	return r.state.abort(err)
+}
+
+// Unwrap returns the io.ReadCloser shared with the paired writer.
+func (r *ErrorPropagatingReadCloser) Unwrap() io.ReadCloser { return r.state.iorc }
+
+// Close closes the underlying io.ReadCloser.
+func (r *ErrorPropagatingReadCloser) Close() error {
+	return r.state.iorc.Close()
+}
+
+// ErrorPropagatingWriteCloser is the writing end of an error propagating
+// pipeline.
+type ErrorPropagatingWriteCloser struct {
+	state *abortState
+}
+
+// Write writes data to the underlying io.WriteCloser. It returns the error
+// with which the pipeline was aborted, if any.
+func (w *ErrorPropagatingWriteCloser) Write(data []byte) (int, error) {
+	if err := w.state.aborted(); err != nil {
+		return 0, err
+	}
+	n, err := w.state.iowc.Write(data)
+	if err != nil {
+		if aerr := w.state.aborted(); aerr != nil {
+			err = aerr // write was interrupted by an abort
+		}
+	}
+	return n, err
+}
+
+// Abort causes pending and subsequent operations on both ends of the pipeline
+// to fail with err, or io.ErrClosedPipe when err is nil. It closes both the
+// underlying io.ReadCloser and io.WriteCloser, and returns any errors from
+// doing so.
+func (w *ErrorPropagatingWriteCloser) Abort(err error) error {
+	return w.state.abort(err)
+}
+
+// Unwrap returns the io.WriteCloser shared with the paired reader.
+func (w *ErrorPropagatingWriteCloser) Unwrap() io.WriteCloser { return w.state.iowc }
+
+// Close closes the underlying io.WriteCloser, after which the reading end
+// returns io.EOF once it has read all data written.
+func (w *ErrorPropagatingWriteCloser) Close() error {
+	return w.state.iowc.Close()
+}
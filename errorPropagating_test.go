@@ -0,0 +1,79 @@
+package gorill
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestErrorPropagatingPipe(t *testing.T) {
+	t.Run("normal close", func(t *testing.T) {
+		pr, pw := io.Pipe()
+		r, w := NewErrorPropagatingPipe(pr, pw)
+		go func() {
+			_, _ = w.Write([]byte(alphabet))
+			_ = w.Close()
+		}()
+		buf, err := ioutil.ReadAll(r)
+		ensureError(t, err)
+		if got, want := string(buf), alphabet; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("writer aborts pending read", func(t *testing.T) {
+		pr, pw := io.Pipe()
+		r, w := NewErrorPropagatingPipe(pr, pw)
+		producerFailed := errors.New("producer failed")
+
+		done := make(chan error, 1)
+		go func() {
+			_, err := r.Read(make([]byte, 8))
+			done <- err
+		}()
+		time.Sleep(10 * time.Millisecond) // let read block
+		ensureError(t, w.Abort(producerFailed))
+
+		select {
+		case err := <-done:
+			testErrorType(t, err, producerFailed)
+		case <-time.After(time.Second):
+			t.Fatal("GOT: read blocked; WANT: aborted")
+		}
+		_, err := w.Write([]byte(alphabet))
+		testErrorType(t, err, producerFailed)
+	})
+
+	t.Run("reader aborts pending write", func(t *testing.T) {
+		pr, pw := io.Pipe()
+		r, w := NewErrorPropagatingPipe(pr, pw)
+		consumerFailed := errors.New("consumer failed")
+
+		done := make(chan error, 1)
+		go func() {
+			_, err := w.Write([]byte(alphabet)) // blocks with nobody reading
+			done <- err
+		}()
+		time.Sleep(10 * time.Millisecond)
+		ensureError(t, r.Abort(consumerFailed))
+
+		select {
+		case err := <-done:
+			testErrorType(t, err, consumerFailed)
+		case <-time.After(time.Second):
+			t.Fatal("GOT: write blocked; WANT: aborted")
+		}
+		_, err := r.Read(make([]byte, 8))
+		testErrorType(t, err, consumerFailed)
+	})
+
+	t.Run("nil error", func(t *testing.T) {
+		r, w := NewErrorPropagatingPipe(io.Pipe())
+		ensureError(t, w.Abort(nil))
+		ensureError(t, w.Abort(errors.New("ignored")))
+		_, err := r.Read(make([]byte, 8))
+		testErrorType(t, err, io.ErrClosedPipe)
+	})
+}
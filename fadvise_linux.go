@@ -0,0 +1,25 @@
+//go:build linux && (amd64 || arm64 || loong64 || mips64 || mips64le || ppc64 || ppc64le || riscv64 || s390x)
+// +build linux
+// +build amd64 arm64 loong64 mips64 mips64le ppc64 ppc64le riscv64 s390x
+
+package gorill
+
+import (
+	"os"
+	"syscall"
+)
+
+const (
+	fadviseSequential = 2 // POSIX_FADV_SEQUENTIAL
+	fadviseDontNeed   = 4 // POSIX_FADV_DONTNEED
+)
+
+// fadviseFile advises the kernel how the specified range of fh will be
+// accessed. A length of 0 means through the end of the file.
+func fadviseFile(fh *os.File, offset, length int64, advice int) error {
+	_, _, errno := syscall.Syscall6(syscall.SYS_FADVISE64, fh.Fd(), uintptr(offset), uintptr(length), uintptr(advice), 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
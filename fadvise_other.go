@@ -0,0 +1,16 @@
+//go:build !linux || !(amd64 || arm64 || loong64 || mips64 || mips64le || ppc64 || ppc64le || riscv64 || s390x)
+// +build !linux !amd64,!arm64,!loong64,!mips64,!mips64le,!ppc64,!ppc64le,!riscv64,!s390x
+
+package gorill
+
+import "os"
+
+const (
+	fadviseSequential = 2
+	fadviseDontNeed   = 4
+)
+
+// fadviseFile does nothing on platforms without posix_fadvise(2) support.
+func fadviseFile(fh *os.File, offset, length int64, advice int) error {
+	return nil
+}
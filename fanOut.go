@@ -0,0 +1,170 @@
+package gorill
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// FanOutSetter is any function that modifies a MultiWriteCloserFanOut being instantiated by
+// NewFanOut.
+type FanOutSetter func(*MultiWriteCloserFanOut) error
+
+// FanOutWriters is used to configure the io.WriteCloser instances a new MultiWriteCloserFanOut
+// initially writes to.
+func FanOutWriters(writers ...io.WriteCloser) FanOutSetter {
+	return func(mwc *MultiWriteCloserFanOut) error {
+		for _, w := range writers {
+			if _, ok := mwc.writerMap[w]; !ok {
+				mwc.writerMap[w] = mwc.newMember(w)
+			}
+		}
+		return nil
+	}
+}
+
+// FanOutSeries is used to configure a new MultiWriteCloserFanOut to write to each writer in turn
+// from the calling go-routine, which avoids go-routine overhead when the writers are fast, such as
+// buffers and local files. See SetConcurrency.
+func FanOutSeries() FanOutSetter {
+	return FanOutConcurrency(1)
+}
+
+// FanOutConcurrency is used to configure the number of go-routines a new MultiWriteCloserFanOut
+// uses to write to its writers concurrently. See SetConcurrency.
+func FanOutConcurrency(limit int) FanOutSetter {
+	return func(mwc *MultiWriteCloserFanOut) error {
+		if limit < 0 {
+			return fmt.Errorf("concurrency must not be negative: %d", limit)
+		}
+		mwc.SetConcurrency(limit)
+		return nil
+	}
+}
+
+// FanOutPolicy is used to configure how a new MultiWriteCloserFanOut treats a writer whose Write
+// returns an error. See SetErrorPolicy.
+func FanOutPolicy(policy FanOutErrorPolicy) FanOutSetter {
+	return func(mwc *MultiWriteCloserFanOut) error {
+		mwc.SetErrorPolicy(policy)
+		return nil
+	}
+}
+
+// FanOutSequencing is used to configure a new MultiWriteCloserFanOut to assign a sequence number to
+// each write. See SetSequencing.
+func FanOutSequencing() FanOutSetter {
+	return func(mwc *MultiWriteCloserFanOut) error {
+		mwc.SetSequencing(true)
+		return nil
+	}
+}
+
+// FanOutIsolatePanics is used to configure a new MultiWriteCloserFanOut to recover panics raised by
+// its writers. See SetIsolatePanics.
+func FanOutIsolatePanics() FanOutSetter {
+	return func(mwc *MultiWriteCloserFanOut) error {
+		mwc.SetIsolatePanics(true)
+		return nil
+	}
+}
+
+// FanOutWriteTimeout is used to configure the time each writer of a new MultiWriteCloserFanOut is
+// allowed to complete a write. See SetWriteTimeout.
+func FanOutWriteTimeout(timeout time.Duration) FanOutSetter {
+	return func(mwc *MultiWriteCloserFanOut) error {
+		if timeout <= 0 {
+			return fmt.Errorf("timeout must be greater than 0: %s", timeout)
+		}
+		mwc.SetWriteTimeout(timeout)
+		return nil
+	}
+}
+
+// FanOutMinimumSuccess is used to configure the number of writers of a new MultiWriteCloserFanOut
+// that must accept each write. See SetMinimumSuccess.
+func FanOutMinimumSuccess(count int) FanOutSetter {
+	return func(mwc *MultiWriteCloserFanOut) error {
+		if count <= 0 {
+			return fmt.Errorf("count must be greater than 0: %d", count)
+		}
+		mwc.SetMinimumSuccess(count)
+		return nil
+	}
+}
+
+// FanOutCoalescing is used to configure a new MultiWriteCloserFanOut to collect small writes into
+// a single write to its writers. See SetCoalescing.
+func FanOutCoalescing(window time.Duration, maxBytes int) FanOutSetter {
+	return func(mwc *MultiWriteCloserFanOut) error {
+		if window <= 0 {
+			return fmt.Errorf("window must be greater than 0: %s", window)
+		}
+		if maxBytes <= 0 {
+			return fmt.Errorf("maxBytes must be greater than 0: %d", maxBytes)
+		}
+		mwc.SetCoalescing(window, maxBytes)
+		return nil
+	}
+}
+
+// FanOutClock is used to configure the clock a new MultiWriteCloserFanOut uses to time writes, write
+// timeouts, health checks, redial backoff, and CloseWithTimeout, and to timestamp its events and
+// collect bytes while coalescing.
+func FanOutClock(clock Clock) FanOutSetter {
+	return func(mwc *MultiWriteCloserFanOut) error {
+		if clock == nil {
+			return fmt.Errorf("clock must not be nil")
+		}
+		mwc.clock = clock
+		mwc.events.clock = clock
+		if c := mwc.coalescer; c != nil {
+			mwc.SetCoalescing(c.window, c.threshold) // re-create it with the clock
+		}
+		return nil
+	}
+}
+
+// NewFanOut returns a MultiWriteCloserFanOut that is go-routine safe, configured by setters, which
+// is the single entry point for every style of fan-out: concurrent or in series, best effort or
+// quorum, and so on. Each setter has a corresponding method to change the configuration after it
+// is created. NewMultiWriteCloserFanOut(writers...) is equivalent to
+// NewFanOut(FanOutWriters(writers...)).
+//
+//   mw, err := gorill.NewFanOut(
+//       gorill.FanOutWriters(primary, replica1, replica2),
+//       gorill.FanOutSeries(),
+//       gorill.FanOutMinimumSuccess(2),
+//   )
+//   if err != nil {
+//       return err
+//   }
+func NewFanOut(setters ...FanOutSetter) (*MultiWriteCloserFanOut, error) {
+	mwc := &MultiWriteCloserFanOut{
+		clock:     systemClock{},
+		dialers:   make(map[*fanOutDialer]struct{}),
+		events:    fanOutEvents{clock: systemClock{}},
+		writerMap: make(map[io.WriteCloser]*fanOutMember),
+	}
+	mwc.redialMin, mwc.redialMax = DefaultRedialBackoff, DefaultMaxRedialBackoff
+	mwc.update()
+	for _, setter := range setters {
+		if err := setter(mwc); err != nil {
+			// Stop any go-routines started by prior setters.
+			mwc.lock.Lock()
+			coalescer := mwc.coalescer
+			mwc.coalescer = nil
+			mwc.halt()
+			mwc.update()
+			mwc.lock.Unlock()
+			if coalescer != nil {
+				coalescer.Close()
+			}
+			return nil, err
+		}
+	}
+	mwc.lock.Lock()
+	mwc.update() // publish the writers added by FanOutWriters
+	mwc.lock.Unlock()
+	return mwc, nil
+}
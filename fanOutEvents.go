@@ -0,0 +1,121 @@
+package gorill
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// DefaultFanOutEventBuffer is the number of events a MultiWriteCloserFanOut buffers for the
+// receiver of its Events channel before it drops events.
+const DefaultFanOutEventBuffer = 64
+
+// FanOutEventKind identifies what happened to a writer of a MultiWriteCloserFanOut.
+type FanOutEventKind int
+
+const (
+	// EventAdd is emitted when a writer is added.
+	EventAdd FanOutEventKind = iota + 1
+
+	// EventRemove is emitted when a writer is removed, either by the caller, or because of an
+	// error, in which case it follows the corresponding EventError.
+	EventRemove
+
+	// EventError is emitted when a write to a writer, including a health check ping, returns an
+	// error, does not return in time, or is abandoned, whether or not the writer is removed.
+	EventError
+
+	// EventClose is emitted when the MultiWriteCloserFanOut closes a writer, either while
+	// removing it because of an error, or while closing.
+	EventClose
+)
+
+// String returns the name of the event kind.
+func (k FanOutEventKind) String() string {
+	switch k {
+	case EventAdd:
+		return "add"
+	case EventRemove:
+		return "remove"
+	case EventError:
+		return "error"
+	case EventClose:
+		return "close"
+	}
+	return "unknown"
+}
+
+// FanOutEvent describes a change to the membership or health of a writer of a
+// MultiWriteCloserFanOut.
+type FanOutEvent struct {
+	// Kind identifies what happened.
+	Kind FanOutEventKind
+
+	// Writer is the writer it happened to.
+	Writer io.WriteCloser
+
+	// Err is the error of an EventError, or returned by Close for an EventClose.
+	Err error
+
+	// Time is when it happened.
+	Time time.Time
+}
+
+// fanOutEvents is the channel of events of a MultiWriteCloserFanOut, created when first requested.
+type fanOutEvents struct {
+	c      chan FanOutEvent
+	clock  Clock // clock provides the time of each event
+	closed bool
+	lock   sync.Mutex
+}
+
+// emit sends an event, unless no channel was requested, or its buffer is full.
+func (fe *fanOutEvents) emit(kind FanOutEventKind, w io.WriteCloser, err error) {
+	fe.lock.Lock()
+	defer fe.lock.Unlock()
+
+	if fe.c == nil || fe.closed {
+		return
+	}
+	select {
+	case fe.c <- FanOutEvent{Kind: kind, Writer: w, Err: err, Time: fe.clock.Now()}:
+	default: // never block a write on a slow receiver
+	}
+}
+
+// close closes the channel, after which no events are emitted.
+func (fe *fanOutEvents) close() {
+	fe.lock.Lock()
+	defer fe.lock.Unlock()
+
+	if !fe.closed {
+		fe.closed = true
+		if fe.c != nil {
+			close(fe.c)
+		}
+	}
+}
+
+// Events returns a channel that receives an event whenever a writer is added, removed, or closed,
+// or a write to it fails, so operators can observe membership churn without polling. Every call
+// returns the same channel, which is closed by Close, and events are only emitted once it has been
+// requested. Events are never allowed to delay writes: when the receiver falls more than
+// DefaultFanOutEventBuffer events behind, further events are dropped until it catches up.
+//
+//   go func() {
+//       for ev := range mw.Events() {
+//           log.Printf("%s %v: %v", ev.Kind, ev.Writer, ev.Err)
+//       }
+//   }()
+func (mwc *MultiWriteCloserFanOut) Events() <-chan FanOutEvent {
+	mwc.events.lock.Lock()
+	defer mwc.events.lock.Unlock()
+
+	if mwc.events.c == nil {
+		mwc.events.c = make(chan FanOutEvent, DefaultFanOutEventBuffer)
+		if mwc.events.closed {
+			close(mwc.events.c)
+		}
+	}
+	return mwc.events.c
+}
@@ -0,0 +1,69 @@
+package gorill
+
+import (
+	"io"
+	"testing"
+)
+
+func TestMultiWriteCloserFanOutEvents(t *testing.T) {
+	bb := NewNopCloseBuffer()
+	tw := &testWriteCloser{}
+	mw := NewMultiWriteCloserFanOut()
+	events := mw.Events()
+	if events != mw.Events() {
+		t.Errorf("GOT: %v; WANT: %v", "different channels", "same channel")
+	}
+
+	mw.Add(bb)
+	mw.Add(tw)
+	_, err := mw.Write([]byte("blob"))
+	ensureError(t, err)
+	ensureError(t, mw.Close())
+
+	type event struct {
+		kind FanOutEventKind
+		w    io.WriteCloser
+	}
+	want := []event{
+		{EventAdd, bb},
+		{EventAdd, tw},
+		{EventError, tw},
+		{EventRemove, tw},
+		{EventClose, tw},
+		{EventClose, bb},
+	}
+	var got []event
+	for ev := range events {
+		got = append(got, event{ev.Kind, ev.Writer})
+		if ev.Kind == EventError {
+			ensureError(t, ev.Err, "short write")
+		}
+		if ev.Time.IsZero() {
+			t.Errorf("GOT: %v; WANT: %v", ev.Time, "time of event")
+		}
+	}
+	if len(got) != len(want) {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("GOT: %v; WANT: %v", got[i], want[i])
+		}
+	}
+
+	// Events requested after Close are closed.
+	mw = NewMultiWriteCloserFanOut()
+	ensureError(t, mw.Close())
+	if _, ok := <-mw.Events(); ok {
+		t.Errorf("GOT: %v; WANT: %v", ok, false)
+	}
+}
+
+func TestFanOutEventKindString(t *testing.T) {
+	if got, want := EventRemove.String(), "remove"; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := FanOutEventKind(0).String(), "unknown"; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
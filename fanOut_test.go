@@ -0,0 +1,182 @@
+package gorill
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+func TestNewFanOut(t *testing.T) {
+	t.Run("invalid setter", func(t *testing.T) {
+		_, err := NewFanOut(FanOutConcurrency(4), FanOutMinimumSuccess(0))
+		ensureError(t, err, "count must be greater than 0")
+		_, err = NewFanOut(FanOutWriteTimeout(0))
+		ensureError(t, err, "timeout must be greater than 0")
+		_, err = NewFanOut(FanOutCoalescing(time.Millisecond, 0))
+		ensureError(t, err, "maxBytes must be greater than 0")
+	})
+
+	t.Run("invalid setter stops go-routines", func(t *testing.T) {
+		bb1, bb2 := NewNopCloseBuffer(), NewNopCloseBuffer()
+		var mwc *MultiWriteCloserFanOut
+		_, err := NewFanOut(
+			FanOutWriters(bb1),
+			FanOutConcurrency(4),
+			FanOutCoalescing(time.Hour, 1024),
+			func(m *MultiWriteCloserFanOut) error {
+				mwc = m
+				m.SetHealthCheck(time.Hour, []byte("ping"))
+				_, err := m.AddFactory(func() (io.WriteCloser, error) { return bb2, nil })
+				return err
+			},
+			FanOutMinimumSuccess(0),
+		)
+		ensureError(t, err, "count must be greater than 0")
+
+		mwc.lock.Lock()
+		defer mwc.lock.Unlock()
+		if mwc.pool != nil || mwc.probeStop != nil || mwc.coalescer != nil {
+			t.Errorf("GOT: %v, %v, %v; WANT: pool, prober, and coalescer stopped", mwc.pool, mwc.probeStop, mwc.coalescer)
+		}
+		if got, want := len(mwc.dialers), 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		for _, member := range mwc.writerMap {
+			member.lock.Lock()
+			halted := member.halted
+			member.lock.Unlock()
+			if !halted {
+				t.Errorf("GOT: %v; WANT: %v", halted, true)
+			}
+		}
+		if got, want := len(mwc.writerMap), 2; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if bb1.IsClosed() || bb2.IsClosed() {
+			t.Errorf("GOT: writers closed; WANT: writers left open")
+		}
+	})
+
+	t.Run("configures fan-out", func(t *testing.T) {
+		bb1, bb2 := NewNopCloseBuffer(), NewNopCloseBuffer()
+		mw, err := NewFanOut(
+			FanOutWriters(bb1, bb2, bb1),
+			FanOutSeries(),
+			FanOutSequencing(),
+			FanOutMinimumSuccess(2),
+		)
+		ensureError(t, err)
+		if got, want := mw.Count(), 2; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		_, err = mw.Write([]byte("blob"))
+		ensureError(t, err)
+		if got, want := mw.Sequence(), uint64(1); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		for _, bb := range []*NopCloseBuffer{bb1, bb2} {
+			if want := "blob"; bb.String() != want {
+				t.Errorf("GOT: %v; WANT: %v", bb.String(), want)
+			}
+		}
+
+		mw.Remove(bb2)
+		_, err = mw.Write([]byte("blob"))
+		ensureError(t, err, "quorum not reached: 1 of 2")
+		ensureError(t, mw.Close())
+	})
+}
+
+// advancingWriteCloser advances a FakeClock during each write, so that the write appears to take
+// that long.
+type advancingWriteCloser struct {
+	*NopCloseBuffer
+	clock *FakeClock
+	d     time.Duration
+}
+
+func (a *advancingWriteCloser) Write(data []byte) (int, error) {
+	a.clock.Advance(a.d)
+	return a.NopCloseBuffer.Write(data)
+}
+
+func TestFanOutClock(t *testing.T) {
+	epoch := time.Unix(0, 0)
+
+	t.Run("nil clock", func(t *testing.T) {
+		_, err := NewFanOut(FanOutClock(nil))
+		ensureError(t, err, "clock must not be nil")
+	})
+
+	t.Run("latency and events", func(t *testing.T) {
+		fc := NewFakeClock(epoch)
+		aw := &advancingWriteCloser{NopCloseBuffer: NewNopCloseBuffer(), clock: fc, d: 5 * time.Millisecond}
+		mw, err := NewFanOut(FanOutClock(fc))
+		ensureError(t, err)
+		events := mw.Events()
+		mw.Add(aw)
+		if ev := <-events; !ev.Time.Equal(epoch) {
+			t.Errorf("GOT: %v; WANT: %v", ev.Time, epoch)
+		}
+		_, err = mw.Write([]byte(alphabet))
+		ensureError(t, err)
+		if got, want := mw.WriterStats()[0].LastLatency, 5*time.Millisecond; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureError(t, mw.Close())
+	})
+
+	t.Run("write timeout", func(t *testing.T) {
+		fc := NewFakeClock(epoch)
+		stuck := &gatedWriteCloser{started: make(chan struct{}, 1), gate: make(chan struct{})}
+		defer close(stuck.gate)
+		mw, err := NewFanOut(FanOutWriters(stuck), FanOutWriteTimeout(time.Hour), FanOutPolicy(KeepAndReport), FanOutClock(fc))
+		ensureError(t, err)
+		written := make(chan error, 1)
+		go func() {
+			_, err := mw.Write([]byte(alphabet))
+			written <- err
+		}()
+		fc.BlockUntil(1)
+		fc.Advance(time.Hour)
+		ensureError(t, <-written, "timeout after 1h0m0s")
+		if got, want := mw.Count(), 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("health check", func(t *testing.T) {
+		fc := NewFakeClock(epoch)
+		lr := new(lockedRecorder)
+		mw, err := NewFanOut(FanOutWriters(lr), FanOutClock(fc))
+		ensureError(t, err)
+		mw.SetHealthCheck(time.Minute, []byte("\n"))
+		fc.BlockUntil(1)
+		fc.Advance(time.Minute)
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			if got, _ := lr.snapshot(); got == "\n" {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("ping not written")
+			}
+			time.Sleep(time.Millisecond)
+		}
+		ensureError(t, mw.Close())
+	})
+
+	t.Run("coalescing configured first", func(t *testing.T) {
+		fc := NewFakeClock(epoch)
+		bb := NewNopCloseBuffer()
+		mw, err := NewFanOut(FanOutWriters(bb), FanOutCoalescing(time.Second, 1024), FanOutClock(fc))
+		ensureError(t, err)
+		_, err = mw.Write([]byte("blob"))
+		ensureError(t, err)
+		fc.Advance(time.Second)
+		if want := "blob"; bb.String() != want {
+			t.Errorf("GOT: %v; WANT: %v", bb.String(), want)
+		}
+		ensureError(t, mw.Close())
+	})
+}
@@ -0,0 +1,179 @@
+package gorill
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// DefaultFetchSectionSize is the default number of bytes in each section
+// fetched by FetchSections.
+const DefaultFetchSectionSize = 1 << 20
+
+// DefaultFetchConcurrency is the default number of sections FetchSections
+// fetches concurrently.
+const DefaultFetchConcurrency = 4
+
+// DefaultFetchRetries is the default number of times FetchSections retries
+// fetching a section after a failed attempt.
+const DefaultFetchRetries = 2
+
+// SectionOpener returns an io.ReadCloser that reads length bytes of a data
+// source starting at offset, for instance by issuing an HTTP request with a
+// Range header.
+type SectionOpener func(offset, length int64) (io.ReadCloser, error)
+
+// ReaderAtSectionOpener returns a SectionOpener that reads sections of ra.
+func ReaderAtSectionOpener(ra io.ReaderAt) SectionOpener {
+	return func(offset, length int64) (io.ReadCloser, error) {
+		return NopCloseReader(io.NewSectionReader(ra, offset, length)), nil
+	}
+}
+
+// FetchSectionsSetter is any function that modifies how FetchSections fetches
+// sections.
+type FetchSectionsSetter func(*fetchSectionsConfig) error
+
+// fetchSectionsConfig holds the configuration used by FetchSections.
+type fetchSectionsConfig struct {
+	concurrency int
+	retries     int
+	sectionSize int64
+}
+
+// FetchConcurrency is used to configure the number of sections FetchSections
+// fetches concurrently. At most this many sections are buffered in memory at
+// once.
+func FetchConcurrency(count int) FetchSectionsSetter {
+	return func(c *fetchSectionsConfig) error {
+		if count <= 0 {
+			return fmt.Errorf("concurrency must be greater than 0: %d", count)
+		}
+		c.concurrency = count
+		return nil
+	}
+}
+
+// FetchRetries is used to configure the number of times FetchSections retries
+// fetching a section after a failed attempt.
+func FetchRetries(count int) FetchSectionsSetter {
+	return func(c *fetchSectionsConfig) error {
+		if count < 0 {
+			return fmt.Errorf("retries must not be negative: %d", count)
+		}
+		c.retries = count
+		return nil
+	}
+}
+
+// FetchSectionSize is used to configure the number of bytes in each section
+// fetched by FetchSections.
+func FetchSectionSize(size int64) FetchSectionsSetter {
+	return func(c *fetchSectionsConfig) error {
+		if size <= 0 {
+			return fmt.Errorf("section size must be greater than 0: %d", size)
+		}
+		c.sectionSize = size
+		return nil
+	}
+}
+
+// fetchResult is the outcome of fetching a single section.
+type fetchResult struct {
+	buf *bytes.Buffer
+	err error
+}
+
+// FetchSections reads size bytes from a data source by concurrently fetching
+// sections of it, and writes the sections in order to iow. Each section is
+// retried when opening or reading it fails, or when it returns fewer bytes
+// than expected. It returns the number of bytes written to iow, and the first
+// error encountered.
+//
+//   fh, err := os.Open("huge.bin")
+//   if err != nil {
+//       return err
+//   }
+//   defer fh.Close()
+//   fi, err := fh.Stat()
+//   if err != nil {
+//       return err
+//   }
+//   _, err = gorill.FetchSections(w, fi.Size(), gorill.ReaderAtSectionOpener(fh), gorill.FetchConcurrency(8))
+func FetchSections(iow io.Writer, size int64, opener SectionOpener, setters ...FetchSectionsSetter) (int64, error) {
+	c := fetchSectionsConfig{
+		concurrency: DefaultFetchConcurrency,
+		retries:     DefaultFetchRetries,
+		sectionSize: DefaultFetchSectionSize,
+	}
+	for _, setter := range setters {
+		if err := setter(&c); err != nil {
+			return 0, err
+		}
+	}
+
+	// Each in flight section delivers its result on its own channel, and the
+	// channels are queued in section order, so sections are written in order
+	// regardless of the order in which they complete.
+	var pending []chan fetchResult
+	var written int64
+	var err error
+
+	wait := func() {
+		result := <-pending[0]
+		pending = pending[1:]
+		if err == nil {
+			if err = result.err; err == nil {
+				var n int64
+				n, err = result.buf.WriteTo(iow)
+				written += n
+			}
+		}
+	}
+
+	for offset := int64(0); offset < size && err == nil; offset += c.sectionSize {
+		length := c.sectionSize
+		if remaining := size - offset; length > remaining {
+			length = remaining
+		}
+		results := make(chan fetchResult, 1)
+		pending = append(pending, results)
+		go func(offset, length int64) {
+			buf, err := c.fetch(opener, offset, length)
+			results <- fetchResult{buf, err}
+		}(offset, length)
+
+		if len(pending) == c.concurrency {
+			wait()
+		}
+	}
+	for len(pending) > 0 {
+		wait() // drain sections in flight, even after an error
+	}
+	return written, err
+}
+
+// fetch reads the specified section, retrying failed attempts.
+func (c fetchSectionsConfig) fetch(opener SectionOpener, offset, length int64) (*bytes.Buffer, error) {
+	buf := bytes.NewBuffer(make([]byte, 0, length))
+	var err error
+	for attempt := 0; attempt <= c.retries; attempt++ {
+		buf.Reset()
+		var iorc io.ReadCloser
+		if iorc, err = opener(offset, length); err != nil {
+			continue
+		}
+		var n int64
+		n, err = buf.ReadFrom(io.LimitReader(iorc, length))
+		if cerr := iorc.Close(); err == nil {
+			err = cerr
+		}
+		if err == nil && n < length {
+			err = fmt.Errorf("section at offset %d returned %d of %d bytes: %s", offset, n, length, io.ErrUnexpectedEOF)
+		}
+		if err == nil {
+			return buf, nil
+		}
+	}
+	return nil, err
+}
@@ -0,0 +1,75 @@
+package gorill
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestFetchSections(t *testing.T) {
+	source := strings.Repeat(alphabet, 100)
+
+	t.Run("invalid section size", func(t *testing.T) {
+		_, err := FetchSections(new(bytes.Buffer), 0, nil, FetchSectionSize(0))
+		ensureError(t, err, "section size must be greater than 0")
+	})
+
+	t.Run("assembles in order", func(t *testing.T) {
+		for _, sectionSize := range []int64{1, 7, 100, 10000} {
+			bb := new(bytes.Buffer)
+			n, err := FetchSections(bb, int64(len(source)), ReaderAtSectionOpener(strings.NewReader(source)), FetchSectionSize(sectionSize), FetchConcurrency(3))
+			ensureError(t, err)
+			if got, want := n, int64(len(source)); got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := bb.String(), source; got != want {
+				t.Errorf("GOT: %q; WANT: %q", got, want)
+			}
+		}
+	})
+
+	t.Run("retries failed sections", func(t *testing.T) {
+		var lock sync.Mutex
+		attempts := make(map[int64]int)
+		opener := func(offset, length int64) (io.ReadCloser, error) {
+			lock.Lock()
+			attempts[offset]++
+			attempt := attempts[offset]
+			lock.Unlock()
+			switch attempt {
+			case 1:
+				return nil, errors.New("transient")
+			case 2:
+				// short section
+				return NopCloseReader(strings.NewReader(source[offset : offset+length-1])), nil
+			}
+			return NopCloseReader(strings.NewReader(source[offset : offset+length])), nil
+		}
+
+		bb := new(bytes.Buffer)
+		_, err := FetchSections(bb, int64(len(source)), opener, FetchSectionSize(100))
+		ensureError(t, err)
+		if got, want := bb.String(), source; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("gives up after retries", func(t *testing.T) {
+		opener := func(offset, length int64) (io.ReadCloser, error) {
+			if offset == 200 {
+				return nil, errors.New("permanent")
+			}
+			return NopCloseReader(strings.NewReader(source[offset : offset+length])), nil
+		}
+
+		bb := new(bytes.Buffer)
+		n, err := FetchSections(bb, int64(len(source)), opener, FetchSectionSize(100), FetchRetries(1))
+		ensureError(t, err, "permanent")
+		if got, want := n, int64(200); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
@@ -0,0 +1,17 @@
+package gorill
+
+import (
+	"os"
+	"syscall"
+)
+
+// preallocateFile reserves size bytes of storage in fh starting at offset,
+// falling back to extending the file when the file system does not support
+// fallocate(2).
+func preallocateFile(fh *os.File, offset, size int64) error {
+	err := syscall.Fallocate(int(fh.Fd()), 0, offset, size)
+	if err == syscall.EOPNOTSUPP || err == syscall.ENOSYS {
+		return fh.Truncate(offset + size)
+	}
+	return err
+}
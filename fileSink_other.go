@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package gorill
+
+import "os"
+
+// preallocateFile extends fh to the size required to hold size bytes starting
+// at offset.
+func preallocateFile(fh *os.File, offset, size int64) error {
+	return fh.Truncate(offset + size)
+}
@@ -0,0 +1,48 @@
+package gorill
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestFileSink(t *testing.T) {
+	t.Run("invalid preallocation", func(t *testing.T) {
+		_, err := NewFileSink(NewNopCloseBuffer(), Preallocate(0))
+		ensureError(t, err, "preallocation size must be greater than 0")
+	})
+
+	t.Run("not a file", func(t *testing.T) {
+		bb := NewNopCloseBuffer()
+		fs, err := NewFileSink(bb, Preallocate(1024), SequentialHint(), DropCacheAfterFlush())
+		ensureError(t, err)
+		_, err = fs.Write([]byte(alphabet))
+		ensureError(t, err)
+		ensureError(t, fs.Flush())
+		ensureError(t, fs.Close())
+		if got, want := bb.String(), alphabet; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("file", func(t *testing.T) {
+		fh, err := ioutil.TempFile("", "gorill")
+		ensureError(t, err)
+		defer os.Remove(fh.Name())
+
+		fs, err := NewFileSink(fh, Preallocate(1<<20), SequentialHint(), DropCacheAfterFlush())
+		ensureError(t, err)
+		_, err = fs.Write([]byte(alphabet))
+		ensureError(t, err)
+		ensureError(t, fs.Flush())
+		_, err = fs.Write([]byte(alphabet))
+		ensureError(t, err)
+		ensureError(t, fs.Close())
+
+		buf, err := ioutil.ReadFile(fh.Name())
+		ensureError(t, err)
+		if got, want := string(buf), alphabet+alphabet; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+}
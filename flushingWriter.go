@@ -0,0 +1,179 @@
+package gorill
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// FlushingWriter is an io.WriteCloser that flushes the underlying io.Writer
+// after writing to it, which is necessary to stream data to HTTP clients, such
+// as Server-Sent Events or a live tail of a log file, because an
+// http.ResponseWriter otherwise buffers its output. The underlying io.Writer is
+// flushed when it implements either http.Flusher, or a Flush method that
+// returns an error, such as bufio.Writer.
+//
+// By default it flushes after every write. When placed beneath a
+// SpooledWriteCloser, which writes to it only when its own buffer is flushed,
+// this results in one flush per spool flush.
+type FlushingWriter struct {
+	eachLine bool
+	flush    func() error
+	iow      io.Writer
+	lock     sync.Mutex
+}
+
+// FlushingWriterSetter is any function that modifies a FlushingWriter being
+// instantiated.
+type FlushingWriterSetter func(*FlushingWriter) error
+
+// FlushEachLine is used to configure a new FlushingWriter to flush only after
+// writes that complete a line, that is, writes that contain a newline.
+func FlushEachLine() FlushingWriterSetter {
+	return func(fw *FlushingWriter) error {
+		fw.eachLine = true
+		return nil
+	}
+}
+
+// NewFlushingWriter returns a FlushingWriter that flushes iow after writing
+// to it.
+func NewFlushingWriter(iow io.Writer, setters ...FlushingWriterSetter) (*FlushingWriter, error) {
+	fw := &FlushingWriter{iow: iow}
+	for _, setter := range setters {
+		if err := setter(fw); err != nil {
+			return nil, err
+		}
+	}
+	switch f := iow.(type) {
+	case http.Flusher:
+		fw.flush = func() error { f.Flush(); return nil }
+	case interface{ Flush() error }:
+		fw.flush = f.Flush
+	default:
+		fw.flush = func() error { return nil }
+	}
+	return fw, nil
+}
+
+// Write writes data to the underlying io.Writer, then flushes it.
+func (fw *FlushingWriter) Write(data []byte) (int, error) {
+	fw.lock.Lock()
+	defer fw.lock.Unlock()
+
+	n, err := fw.iow.Write(data)
+	if err != nil {
+		return n, err
+	}
+	if !fw.eachLine || bytes.IndexByte(data, '\n') >= 0 {
+		err = fw.flush()
+	}
+	return n, err
+}
+
+// Flush flushes the underlying io.Writer.
+func (fw *FlushingWriter) Flush() error {
+	fw.lock.Lock()
+	defer fw.lock.Unlock()
+	return fw.flush()
+}
+
+// SetWriteDeadline sets the write deadline of the underlying io.Writer.
+func (fw *FlushingWriter) SetWriteDeadline(t time.Time) error {
+	return SetWriteDeadline(fw.iow, t)
+}
+
+// Unwrap returns the underlying io.Writer.
+func (fw *FlushingWriter) Unwrap() io.Writer { return fw.iow }
+
+// Close flushes the underlying io.Writer, which is not closed.
+func (fw *FlushingWriter) Close() error {
+	return fw.Flush()
+}
+
+// ResponseWriteCloser adapts an http.ResponseWriter to an io.WriteCloser, whose
+// Close method ends the response. This allows a streaming HTTP endpoint to be
+// added to a MultiWriteCloserFanOut like any other io.WriteCloser. The handler
+// adds it, then waits for it to be done before returning:
+//
+//   func liveTail(w http.ResponseWriter, r *http.Request) {
+//       w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+//       rwc, err := gorill.NewResponseWriteCloser(w, r, gorill.FlushEachLine())
+//       if err != nil {
+//           http.Error(w, err.Error(), http.StatusInternalServerError)
+//           return
+//       }
+//       fanOut.Add(rwc)
+//       <-rwc.Done()
+//       fanOut.Remove(rwc)
+//   }
+type ResponseWriteCloser struct {
+	done   chan struct{}
+	fw     *FlushingWriter
+	halted bool
+	lock   sync.Mutex
+	req    *http.Request
+}
+
+// NewResponseWriteCloser returns a ResponseWriteCloser that writes to w, and
+// flushes w according to setters. It immediately flushes the response headers
+// to the client, so any headers must be set before it is called. It is done
+// when either its Close method is called, or the client of request r goes
+// away.
+func NewResponseWriteCloser(w http.ResponseWriter, r *http.Request, setters ...FlushingWriterSetter) (*ResponseWriteCloser, error) {
+	fw, err := NewFlushingWriter(w, setters...)
+	if err != nil {
+		return nil, err
+	}
+	if err = fw.Flush(); err != nil {
+		return nil, err
+	}
+	rwc := &ResponseWriteCloser{done: make(chan struct{}), fw: fw, req: r}
+	go func() {
+		select {
+		case <-r.Context().Done():
+			rwc.Close()
+		case <-rwc.done:
+		}
+	}()
+	return rwc, nil
+}
+
+// Done returns a channel that is closed when the ResponseWriteCloser is done,
+// after which the handler may return.
+func (rwc *ResponseWriteCloser) Done() <-chan struct{} {
+	return rwc.done
+}
+
+// Write writes data to the response and flushes it. It returns
+// ErrWriteAfterClose once the ResponseWriteCloser is done, so that a
+// MultiWriteCloserFanOut removes it.
+func (rwc *ResponseWriteCloser) Write(data []byte) (int, error) {
+	rwc.lock.Lock()
+	defer rwc.lock.Unlock()
+
+	if rwc.halted {
+		return 0, ErrWriteAfterClose{}
+	}
+	return rwc.fw.Write(data)
+}
+
+// Close flushes the response, and marks the ResponseWriteCloser as done. It
+// does not write to the response after it returns.
+func (rwc *ResponseWriteCloser) Close() error {
+	rwc.lock.Lock()
+	defer rwc.lock.Unlock()
+
+	if rwc.halted {
+		return nil
+	}
+	rwc.halted = true
+	var err error
+	if rwc.req.Context().Err() == nil {
+		err = rwc.fw.Flush()
+	}
+	close(rwc.done)
+	return err
+}
@@ -0,0 +1,124 @@
+package gorill
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// flushCounter counts calls to its Flush method.
+type flushCounter struct {
+	bytes.Buffer
+	flushes int
+}
+
+func (fc *flushCounter) Flush() { fc.flushes++ }
+
+func TestFlushingWriter(t *testing.T) {
+	t.Run("each write", func(t *testing.T) {
+		fc := new(flushCounter)
+		fw, err := NewFlushingWriter(fc)
+		ensureError(t, err)
+		for _, piece := range []string{"abc", "def\n"} {
+			_, err = fw.Write([]byte(piece))
+			ensureError(t, err)
+		}
+		if got, want := fc.flushes, 2; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("each line", func(t *testing.T) {
+		fc := new(flushCounter)
+		fw, err := NewFlushingWriter(fc, FlushEachLine())
+		ensureError(t, err)
+		for _, piece := range []string{"abc", "def\n", "ghi"} {
+			_, err = fw.Write([]byte(piece))
+			ensureError(t, err)
+		}
+		if got, want := fc.flushes, 1; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("bufio", func(t *testing.T) {
+		bb := new(bytes.Buffer)
+		fw, err := NewFlushingWriter(bufio.NewWriter(bb))
+		ensureError(t, err)
+		_, err = fw.Write([]byte(alphabet))
+		ensureError(t, err)
+		if got, want := bb.String(), alphabet; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+}
+
+func TestResponseWriteCloser(t *testing.T) {
+	t.Run("close ends response", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		rwc, err := NewResponseWriteCloser(rec, httptest.NewRequest("GET", "/", nil))
+		ensureError(t, err)
+
+		_, err = rwc.Write([]byte(alphabet))
+		ensureError(t, err)
+		if !rec.Flushed {
+			t.Errorf("GOT: %v; WANT: %v", rec.Flushed, true)
+		}
+		ensureError(t, rwc.Close())
+		<-rwc.Done()
+
+		_, err = rwc.Write([]byte(alphabet))
+		testErrorType(t, err, ErrWriteAfterClose{})
+		if got, want := rec.Body.String(), alphabet; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("client goes away", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		r := httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+		rwc, err := NewResponseWriteCloser(httptest.NewRecorder(), r)
+		ensureError(t, err)
+		cancel()
+		select {
+		case <-rwc.Done():
+		case <-time.After(time.Second):
+			t.Fatal("GOT: not done; WANT: done")
+		}
+	})
+
+	t.Run("fan out streaming", func(t *testing.T) {
+		fanOut := NewMultiWriteCloserFanOut()
+		added := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rwc, err := NewResponseWriteCloser(w, r, FlushEachLine())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			fanOut.Add(rwc)
+			close(added)
+			<-rwc.Done()
+		}))
+		defer server.Close()
+
+		resp, err := http.Get(server.URL)
+		ensureError(t, err)
+		defer resp.Body.Close()
+		<-added
+
+		// The line arrives at the client before the response ends.
+		_, err = fanOut.Write([]byte("hello\n"))
+		ensureError(t, err)
+		line, err := bufio.NewReader(resp.Body).ReadString('\n')
+		ensureError(t, err)
+		if got, want := line, "hello\n"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+		ensureError(t, fanOut.Close())
+	})
+}
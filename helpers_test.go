@@ -28,10 +28,12 @@ func benchmarkWriter(b *testing.B, n int, consumers []io.WriteCloser) {
 				j := rand.Int31n(int32(consumerCount))
 				n, err := consumers[j].Write([]byte(alphabet))
 				if want := len(alphabet); n != want {
-					b.Fatalf("Actual: %#v; Expected: %#v", n, want)
+					b.Errorf("Actual: %#v; Expected: %#v", n, want)
+					break
 				}
 				if err != nil {
-					b.Fatalf("Actual: %#v; Expected: %#v", err, nil)
+					b.Errorf("Actual: %#v; Expected: %#v", err, nil)
+					break
 				}
 			}
 			finished.Done()
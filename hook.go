@@ -0,0 +1,56 @@
+package gorill
+
+// Operation names reported to an OperationHook.
+const (
+	// OpClose is reported by AuditWriter and AuditReader when closed.
+	OpClose = "close"
+
+	// OpBroadcast is reported by MultiWriteCloserFanOut for each write
+	// delivered to all of its writers.
+	OpBroadcast = "broadcast"
+
+	// OpFlush is reported by SpooledWriteCloser for each flush of its
+	// buffer, whether requested, periodic, or while closing.
+	OpFlush = "flush"
+
+	// OpRead is reported by TimedReadCloser and AuditReader for each read.
+	OpRead = "read"
+
+	// OpWrite is reported by AuditWriter for each write.
+	OpWrite = "write"
+)
+
+// OperationHook is invoked by an instrumented wrapper when it starts an
+// operation, and returns a function the wrapper invokes when the operation
+// ends, with the number of bytes involved and any error. It allows tracing
+// stream operations without this library depending on a particular tracing
+// library. For instance, an OpenTelemetry adapter might be:
+//
+//   hook := func(op string) func(int, error) {
+//       _, span := tracer.Start(ctx, "gorill."+op)
+//       return func(n int, err error) {
+//           span.SetAttributes(attribute.Int("bytes", n))
+//           if err != nil {
+//               span.RecordError(err)
+//           }
+//           span.End()
+//       }
+//   }
+//
+// The hook may be invoked concurrently from multiple go-routines, and ought to
+// return quickly, because it is invoked synchronously with the operation.
+type OperationHook func(op string) func(n int, err error)
+
+// startOperation invokes hook when it is not nil, and returns the function to
+// be invoked when the operation ends, which is never nil.
+func startOperation(hook OperationHook, op string) func(int, error) {
+	if hook == nil {
+		return nopEndOperation
+	}
+	if end := hook(op); end != nil {
+		return end
+	}
+	return nopEndOperation
+}
+
+func nopEndOperation(int, error) {}
@@ -0,0 +1,93 @@
+package gorill
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+)
+
+// hookRecorder records operations reported to its hook.
+type hookRecorder struct {
+	lock sync.Mutex
+	ops  []string
+	n    []int
+	errs []error
+}
+
+func (hr *hookRecorder) hook(op string) func(int, error) {
+	return func(n int, err error) {
+		hr.lock.Lock()
+		hr.ops = append(hr.ops, op)
+		hr.n = append(hr.n, n)
+		hr.errs = append(hr.errs, err)
+		hr.lock.Unlock()
+	}
+}
+
+func TestOperationHook(t *testing.T) {
+	t.Run("spooled flush", func(t *testing.T) {
+		hr := new(hookRecorder)
+		w, err := NewSpooledWriteCloser(NewNopCloseBuffer(), Flush(time.Hour), SpoolHook(hr.hook))
+		ensureError(t, err)
+		_, err = w.Write([]byte(alphabet))
+		ensureError(t, err)
+		ensureError(t, w.Flush())
+		ensureError(t, w.Close())
+
+		if got, want := len(hr.ops), 2; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := hr.ops[0], OpFlush; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := hr.n[0], len(alphabet); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := hr.n[1], 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("timed read", func(t *testing.T) {
+		hr := new(hookRecorder)
+		rc, err := NewTimedReadCloserWithSetters(NopCloseReader(bytes.NewBufferString(alphabet)), time.Second, TimedReadHook(hr.hook))
+		ensureError(t, err)
+		_, err = rc.Read(make([]byte, 5))
+		ensureError(t, err)
+		ensureError(t, rc.Close())
+
+		if got, want := len(hr.ops), 1; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := hr.ops[0], OpRead; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := hr.n[0], 5; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("fan out broadcast", func(t *testing.T) {
+		hr := new(hookRecorder)
+		mwc := NewMultiWriteCloserFanOut(NewNopCloseBuffer(), &testWriteCloser{})
+		mwc.SetOperationHook(hr.hook)
+		_, err := mwc.Write([]byte(alphabet))
+		ensureError(t, err)
+
+		if got, want := len(hr.ops), 1; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := hr.ops[0], OpBroadcast; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureError(t, hr.errs[0], "short write")
+	})
+
+	t.Run("nil end function", func(t *testing.T) {
+		w, err := NewSpooledWriteCloser(NewNopCloseBuffer(), SpoolHook(func(string) func(int, error) { return nil }))
+		ensureError(t, err)
+		ensureError(t, w.Flush())
+		ensureError(t, w.Close())
+	})
+}
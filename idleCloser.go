@@ -0,0 +1,164 @@
+package gorill
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// WriteCloserFactory returns a newly opened io.WriteCloser, for instance by
+// opening a file in append mode.
+type WriteCloserFactory func() (io.WriteCloser, error)
+
+// IdleCloser is an io.WriteCloser that closes its underlying io.WriteCloser
+// after a preset period during which no bytes were written to it, and
+// transparently re-opens it using a factory when next written to. This allows a
+// program to maintain a large number of sinks, such as a file per client
+// connected to a MultiWriteCloserFanOut, without exhausting its file
+// descriptors when most clients are idle.
+//
+// The underlying io.WriteCloser is not opened until the first Write.
+type IdleCloser struct {
+	clock     Clock
+	factory   WriteCloserFactory
+	halted    bool
+	idle      time.Duration
+	iowc      io.WriteCloser // iowc is nil while idle
+	lastWrite time.Time
+	lock      sync.Mutex
+	onIdle    func(error)
+	timer     Timer
+}
+
+// IdleCloserSetter is any function that modifies an IdleCloser being
+// instantiated.
+type IdleCloserSetter func(*IdleCloser) error
+
+// OnIdle is used to configure a new IdleCloser to invoke callback each time it
+// closes the underlying io.WriteCloser due to inactivity, passing any error
+// returned by its Close method.
+func OnIdle(callback func(error)) IdleCloserSetter {
+	return func(ic *IdleCloser) error {
+		ic.onIdle = callback
+		return nil
+	}
+}
+
+// IdleClock is used to configure the clock a new IdleCloser uses to measure
+// inactivity.
+func IdleClock(clock Clock) IdleCloserSetter {
+	return func(ic *IdleCloser) error {
+		if clock == nil {
+			return fmt.Errorf("clock must not be nil")
+		}
+		ic.clock = clock
+		return nil
+	}
+}
+
+// NewIdleCloser returns an IdleCloser that uses factory to open its underlying
+// io.WriteCloser, and closes it after idle elapses without a Write.
+//
+//   ic, err := gorill.NewIdleCloser(func() (io.WriteCloser, error) {
+//       return os.OpenFile(pathname, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+//   }, time.Minute)
+//   if err != nil {
+//       return err
+//   }
+//   fanOut.Add(ic)
+func NewIdleCloser(factory WriteCloserFactory, idle time.Duration, setters ...IdleCloserSetter) (*IdleCloser, error) {
+	if idle <= 0 {
+		return nil, fmt.Errorf("idle period must be greater than 0: %s", idle)
+	}
+	ic := &IdleCloser{clock: systemClock{}, factory: factory, idle: idle}
+	for _, setter := range setters {
+		if err := setter(ic); err != nil {
+			return nil, err
+		}
+	}
+	return ic, nil
+}
+
+// IsOpen returns true when the underlying io.WriteCloser is currently open.
+func (ic *IdleCloser) IsOpen() bool {
+	ic.lock.Lock()
+	defer ic.lock.Unlock()
+	return ic.iowc != nil
+}
+
+// Write writes data to the underlying io.WriteCloser, opening it first when it
+// was closed due to inactivity.
+func (ic *IdleCloser) Write(data []byte) (int, error) {
+	ic.lock.Lock()
+	defer ic.lock.Unlock()
+
+	if ic.halted {
+		return 0, ErrWriteAfterClose{}
+	}
+	if ic.iowc == nil {
+		iowc, err := ic.factory()
+		if err != nil {
+			return 0, err
+		}
+		ic.iowc = iowc
+		ic.timer = ic.clock.AfterFunc(ic.idle, ic.expire)
+	}
+	ic.lastWrite = ic.clock.Now()
+	return ic.iowc.Write(data)
+}
+
+// expire closes the underlying io.WriteCloser when it has been idle for the
+// preset period, otherwise it re-arms the timer for the remainder.
+func (ic *IdleCloser) expire() {
+	ic.lock.Lock()
+	if ic.halted || ic.iowc == nil {
+		ic.lock.Unlock()
+		return
+	}
+	if remaining := ic.idle - ic.clock.Now().Sub(ic.lastWrite); remaining > 0 {
+		ic.timer = ic.clock.AfterFunc(remaining, ic.expire)
+		ic.lock.Unlock()
+		return
+	}
+	err := ic.iowc.Close()
+	ic.iowc = nil
+	onIdle := ic.onIdle
+	ic.lock.Unlock()
+
+	if onIdle != nil {
+		onIdle(err)
+	}
+}
+
+// Unwrap returns the underlying io.WriteCloser, or nil while it is closed for
+// being idle.
+func (ic *IdleCloser) Unwrap() io.WriteCloser {
+	ic.lock.Lock()
+	defer ic.lock.Unlock()
+	if ic.iowc == nil {
+		return nil // avoid returning a non-nil interface holding nil
+	}
+	return ic.iowc
+}
+
+// Close closes the underlying io.WriteCloser when it is open. Subsequent writes
+// return ErrWriteAfterClose.
+func (ic *IdleCloser) Close() error {
+	ic.lock.Lock()
+	defer ic.lock.Unlock()
+
+	if ic.halted {
+		return nil
+	}
+	ic.halted = true
+	if ic.timer != nil {
+		ic.timer.Stop()
+	}
+	if ic.iowc == nil {
+		return nil
+	}
+	err := ic.iowc.Close()
+	ic.iowc = nil
+	return err
+}
@@ -0,0 +1,105 @@
+package gorill
+
+import (
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIdleCloser(t *testing.T) {
+	t.Run("invalid idle period", func(t *testing.T) {
+		_, err := NewIdleCloser(nil, 0)
+		ensureError(t, err, "idle period must be greater than 0")
+	})
+
+	t.Run("factory error", func(t *testing.T) {
+		ic, err := NewIdleCloser(func() (io.WriteCloser, error) {
+			return nil, errors.New("cannot open")
+		}, time.Hour)
+		ensureError(t, err)
+		_, err = ic.Write([]byte(alphabet))
+		ensureError(t, err, "cannot open")
+	})
+
+	t.Run("closes when idle and reopens", func(t *testing.T) {
+		var opened []*NopCloseBuffer
+		var idled int32
+		ic, err := NewIdleCloser(func() (io.WriteCloser, error) {
+			bb := NewNopCloseBuffer()
+			opened = append(opened, bb)
+			return bb, nil
+		}, 10*time.Millisecond, OnIdle(func(err error) {
+			ensureError(t, err)
+			atomic.AddInt32(&idled, 1)
+		}))
+		ensureError(t, err)
+
+		if ic.IsOpen() {
+			t.Errorf("GOT: %v; WANT: %v", true, false)
+		}
+		_, err = ic.Write([]byte("first"))
+		ensureError(t, err)
+		if !ic.IsOpen() {
+			t.Errorf("GOT: %v; WANT: %v", false, true)
+		}
+
+		deadline := time.Now().Add(time.Second)
+		for ic.IsOpen() && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		if ic.IsOpen() {
+			t.Fatalf("GOT: %v; WANT: %v", true, false)
+		}
+		if got, want := atomic.LoadInt32(&idled), int32(1); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		_, err = ic.Write([]byte("second"))
+		ensureError(t, err)
+		ensureError(t, ic.Close())
+		ensureError(t, ic.Close())
+
+		if got, want := len(opened), 2; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		for i, want := range []string{"first", "second"} {
+			if got := opened[i].String(); got != want {
+				t.Errorf("GOT: %q; WANT: %q", got, want)
+			}
+			if !opened[i].IsClosed() {
+				t.Errorf("GOT: %v; WANT: %v", false, true)
+			}
+		}
+
+		_, err = ic.Write([]byte("third"))
+		testErrorType(t, err, ErrWriteAfterClose{})
+	})
+}
+
+func TestIdleCloserFakeClock(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	bb := NewNopCloseBuffer()
+	ic, err := NewIdleCloser(func() (io.WriteCloser, error) { return bb, nil }, time.Minute, IdleClock(clock))
+	ensureError(t, err)
+
+	_, err = ic.Write([]byte("first"))
+	ensureError(t, err)
+	clock.Advance(30 * time.Second)
+	_, err = ic.Write([]byte("second")) // postpones closing until 90 seconds
+	ensureError(t, err)
+
+	clock.Advance(45 * time.Second)
+	if !ic.IsOpen() {
+		t.Errorf("GOT: %v; WANT: %v", false, true)
+	}
+	clock.Advance(15 * time.Second)
+	if ic.IsOpen() {
+		t.Errorf("GOT: %v; WANT: %v", true, false)
+	}
+	if !bb.IsClosed() {
+		t.Errorf("GOT: %v; WANT: %v", false, true)
+	}
+	ensureError(t, ic.Close())
+}
@@ -0,0 +1,76 @@
+//go:build go1.23
+// +build go1.23
+
+package gorill
+
+import (
+	"fmt"
+	"io"
+	"iter"
+)
+
+// Lines returns an iterator over the lines read from ior, built on a
+// LinesReader using its default maximum line size. Each line is yielded
+// without its line terminator, along with a nil error. When reading fails,
+// including when a line is too long, the error is yielded with a nil line, and
+// iteration stops. The yielded slice is only valid until the next iteration.
+//
+//   for line, err := range gorill.Lines(fh) {
+//       if err != nil {
+//           return err
+//       }
+//       process(line)
+//   }
+func Lines(ior io.Reader, setters ...LinesReaderSetter) iter.Seq2[[]byte, error] {
+	return func(yield func([]byte, error) bool) {
+		lr, err := NewLinesReader(ior, setters...)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		for lr.Scan() {
+			if !yield(lr.Bytes(), nil) {
+				return
+			}
+		}
+		if err := lr.Err(); err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
+// Chunks returns an iterator over successive chunks of up to size bytes read
+// from ior. Each chunk is yielded along with a nil error. When reading fails
+// with an error other than io.EOF, the error is yielded with a nil chunk, and
+// iteration stops. The yielded slice is only valid until the next iteration.
+// When size is not greater than 0, an error is yielded with a nil chunk.
+//
+//   for chunk, err := range gorill.Chunks(fh, 64*1024) {
+//       if err != nil {
+//           return err
+//       }
+//       hash.Write(chunk)
+//   }
+func Chunks(ior io.Reader, size int) iter.Seq2[[]byte, error] {
+	return func(yield func([]byte, error) bool) {
+		if size <= 0 {
+			yield(nil, fmt.Errorf("chunk size must be greater than 0: %d", size))
+			return
+		}
+		buf := make([]byte, size)
+		for {
+			n, err := io.ReadFull(ior, buf)
+			if n > 0 {
+				if !yield(buf[:n], nil) {
+					return
+				}
+			}
+			if err != nil {
+				if err != io.EOF && err != io.ErrUnexpectedEOF {
+					yield(nil, err)
+				}
+				return
+			}
+		}
+	}
+}
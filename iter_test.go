@@ -0,0 +1,85 @@
+//go:build go1.23
+// +build go1.23
+
+package gorill
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLines(t *testing.T) {
+	t.Run("lines", func(t *testing.T) {
+		var got []string
+		for line, err := range Lines(strings.NewReader("one\ntwo\r\nthree")) {
+			ensureError(t, err)
+			got = append(got, string(line))
+		}
+		if want := []string{"one", "two", "three"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("break", func(t *testing.T) {
+		var got []string
+		for line, err := range Lines(strings.NewReader("one\ntwo\nthree\n")) {
+			ensureError(t, err)
+			got = append(got, string(line))
+			if len(got) == 2 {
+				break
+			}
+		}
+		if want := []string{"one", "two"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("line too long", func(t *testing.T) {
+		var last error
+		for _, err := range Lines(strings.NewReader("one\nthirteen\n"), MaxLineSize(4)) {
+			last = err
+		}
+		testErrorType(t, last, ErrLineTooLong{})
+	})
+}
+
+func TestChunks(t *testing.T) {
+	t.Run("invalid size", func(t *testing.T) {
+		var got []error
+		for chunk, err := range Chunks(strings.NewReader(alphabet), 0) {
+			if chunk != nil {
+				t.Errorf("GOT: %q; WANT: %v", chunk, nil)
+			}
+			got = append(got, err)
+		}
+		if len(got) != 1 {
+			t.Fatalf("GOT: %v; WANT: %v", len(got), 1)
+		}
+		ensureError(t, got[0], "chunk size must be greater than 0: 0")
+	})
+
+	t.Run("chunks", func(t *testing.T) {
+		var got []string
+		for chunk, err := range Chunks(strings.NewReader(alphabet), 10) {
+			ensureError(t, err)
+			got = append(got, string(chunk))
+		}
+		if want := []string{alphabet[:10], alphabet[10:20], alphabet[20:]}; !reflect.DeepEqual(got, want) {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("read error", func(t *testing.T) {
+		boom := errors.New("boom")
+		r := &testReader{tuples: []tuple{{"abc", nil}, {"", boom}}}
+		var got []error
+		for _, err := range Chunks(r, 2) {
+			got = append(got, err)
+		}
+		if want := []error{nil, boom}; !reflect.DeepEqual(got, want) {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
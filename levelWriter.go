@@ -0,0 +1,160 @@
+package gorill
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// LevelParser returns the level token at the start of line, along with the
+// remainder of line that follows the token. It returns false when line does
+// not start with a level token.
+type LevelParser func(line []byte) (token string, rest []byte, ok bool)
+
+// ParseLevelToken is the default LevelParser. It recognizes a leading word
+// consisting only of letters, optionally enclosed in square brackets or
+// followed by a colon, such as "WARN", "[warn]", or "Warning:". The token is
+// returned in upper case.
+func ParseLevelToken(line []byte) (string, []byte, bool) {
+	trimmed := bytes.TrimLeft(line, " \t")
+	end := bytes.IndexAny(trimmed, " \t\r\n")
+	if end < 0 {
+		end = len(trimmed)
+	}
+	word, rest := trimmed[:end], bytes.TrimLeft(trimmed[end:], " \t")
+	if len(word) > 2 && word[0] == '[' && word[len(word)-1] == ']' {
+		word = word[1 : len(word)-1]
+	} else if len(word) > 1 && word[len(word)-1] == ':' {
+		word = word[:len(word)-1]
+	}
+	if len(word) == 0 {
+		return "", line, false
+	}
+	for _, b := range word {
+		if !(b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z') {
+			return "", line, false
+		}
+	}
+	return strings.ToUpper(string(word)), rest, true
+}
+
+// LevelWriter is an io.WriteCloser that parses a level token at the start of
+// each line written to it, and forwards the line to a writer selected by that
+// level. This allows routing the unstructured log output of legacy code or
+// subprocesses, for instance sending warnings and errors to one file, and
+// everything else to another. Lines longer than DefaultMaxLineSize are
+// forwarded in pieces.
+type LevelWriter struct {
+	emit     func(token string, known bool, line, rest []byte) error
+	fallback io.Writer
+	parse    LevelParser
+	pending  []byte
+	routes   map[string]io.Writer
+}
+
+// LevelWriterSetter is any function that modifies a LevelWriter being
+// instantiated.
+type LevelWriterSetter func(*LevelWriter) error
+
+// LevelRoute is used to configure a new LevelWriter to forward lines that start
+// with the specified level token to iow. Tokens are matched without regard to
+// case.
+func LevelRoute(token string, iow io.Writer) LevelWriterSetter {
+	return func(lw *LevelWriter) error {
+		if token == "" {
+			return fmt.Errorf("level token must not be empty")
+		}
+		lw.routes[strings.ToUpper(token)] = iow
+		return nil
+	}
+}
+
+// LevelTokenParser is used to configure a new LevelWriter to use parser to
+// find the level token at the start of each line, rather than ParseLevelToken.
+func LevelTokenParser(parser LevelParser) LevelWriterSetter {
+	return func(lw *LevelWriter) error {
+		if parser == nil {
+			return fmt.Errorf("level parser must not be nil")
+		}
+		lw.parse = parser
+		return nil
+	}
+}
+
+// NewLevelWriter returns a LevelWriter that forwards each line to the writer
+// configured for its level token, or to fallback when the line does not start
+// with a configured level token.
+//
+//   lw, err := gorill.NewLevelWriter(stdoutLog,
+//       gorill.LevelRoute("WARN", errorLog),
+//       gorill.LevelRoute("ERROR", errorLog))
+//   if err != nil {
+//       return err
+//   }
+//   cmd.Stdout = lw
+func NewLevelWriter(fallback io.Writer, setters ...LevelWriterSetter) (*LevelWriter, error) {
+	lw := &LevelWriter{
+		fallback: fallback,
+		parse:    ParseLevelToken,
+		routes:   make(map[string]io.Writer),
+	}
+	for _, setter := range setters {
+		if err := setter(lw); err != nil {
+			return nil, err
+		}
+	}
+	lw.emit = lw.route
+	return lw, nil
+}
+
+// route writes line to the writer configured for token.
+func (lw *LevelWriter) route(token string, known bool, line, _ []byte) error {
+	iow, ok := lw.routes[token]
+	if !known || !ok {
+		iow = lw.fallback
+	}
+	_, err := iow.Write(line)
+	return err
+}
+
+// Write forwards each complete line in data, buffering any final partial line
+// until it is completed by a subsequent write or the LevelWriter is closed.
+func (lw *LevelWriter) Write(data []byte) (int, error) {
+	lw.pending = append(lw.pending, data...)
+	for len(lw.pending) > 0 {
+		end := bytes.IndexByte(lw.pending, '\n') + 1
+		if end == 0 {
+			if len(lw.pending) < DefaultMaxLineSize {
+				break
+			}
+			end = DefaultMaxLineSize
+		}
+		err := lw.forward(lw.pending[:end])
+		lw.pending = lw.pending[end:]
+		if err != nil {
+			return len(data), err
+		}
+	}
+	if len(lw.pending) == 0 {
+		lw.pending = lw.pending[:0:0] // release buffer
+	}
+	return len(data), nil
+}
+
+// forward parses the level token of line, and emits it.
+func (lw *LevelWriter) forward(line []byte) error {
+	token, rest, ok := lw.parse(line)
+	return lw.emit(token, ok, line, rest)
+}
+
+// Close forwards any final line that was not terminated by a newline. It does
+// not close any of the writers to which lines are forwarded.
+func (lw *LevelWriter) Close() error {
+	if len(lw.pending) == 0 {
+		return nil
+	}
+	line := lw.pending
+	lw.pending = nil
+	return lw.forward(line)
+}
@@ -0,0 +1,55 @@
+//go:build go1.21
+// +build go1.21
+
+package gorill
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"time"
+)
+
+// slogLevels maps level tokens to the corresponding slog.Level.
+var slogLevels = map[string]slog.Level{
+	"DEBUG":   slog.LevelDebug,
+	"TRACE":   slog.LevelDebug,
+	"INFO":    slog.LevelInfo,
+	"NOTICE":  slog.LevelInfo,
+	"WARN":    slog.LevelWarn,
+	"WARNING": slog.LevelWarn,
+	"ERR":     slog.LevelError,
+	"ERROR":   slog.LevelError,
+	"FATAL":   slog.LevelError,
+}
+
+// NewSlogLevelWriter returns a LevelWriter that sends each line as a record
+// to handler, with its level determined by the level token at the start of the
+// line, and its message being the remainder of the line. Lines without a
+// recognized level token are logged at slog.LevelInfo with the entire line as
+// the message. Records whose level is not enabled by handler are discarded.
+//
+//   lw, err := gorill.NewSlogLevelWriter(slog.Default().Handler())
+//   if err != nil {
+//       return err
+//   }
+//   cmd.Stderr = lw
+func NewSlogLevelWriter(handler slog.Handler, setters ...LevelWriterSetter) (*LevelWriter, error) {
+	lw, err := NewLevelWriter(nil, setters...)
+	if err != nil {
+		return nil, err
+	}
+	lw.emit = func(token string, known bool, line, rest []byte) error {
+		level, ok := slogLevels[token]
+		if !known || !ok {
+			level, rest = slog.LevelInfo, line
+		}
+		ctx := context.Background()
+		if !handler.Enabled(ctx, level) {
+			return nil
+		}
+		message := string(bytes.TrimRight(rest, "\r\n"))
+		return handler.Handle(ctx, slog.NewRecord(time.Now(), level, message, 0))
+	}
+	return lw, nil
+}
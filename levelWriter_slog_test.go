@@ -0,0 +1,34 @@
+//go:build go1.21
+// +build go1.21
+
+package gorill
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+)
+
+func TestSlogLevelWriter(t *testing.T) {
+	bb := new(bytes.Buffer)
+	handler := slog.NewTextHandler(bb, &slog.HandlerOptions{
+		Level: slog.LevelInfo,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	lw, err := NewSlogLevelWriter(handler)
+	ensureError(t, err)
+
+	_, err = lw.Write([]byte("WARN disk full\nDEBUG hidden\nplain line\n[error] boom"))
+	ensureError(t, err)
+	ensureError(t, lw.Close())
+
+	want := "level=WARN msg=\"disk full\"\nlevel=INFO msg=\"plain line\"\nlevel=ERROR msg=boom\n"
+	if got := bb.String(); got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+}
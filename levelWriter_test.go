@@ -0,0 +1,73 @@
+package gorill
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseLevelToken(t *testing.T) {
+	for _, tc := range []struct {
+		line, token, rest string
+		ok                bool
+	}{
+		{"WARN disk full\n", "WARN", "disk full\n", true},
+		{"[error] boom\n", "ERROR", "boom\n", true},
+		{"  Info: started\n", "INFO", "started\n", true},
+		{"DEBUG\n", "DEBUG", "\n", true},
+		{"2024-01-01 INFO started\n", "", "2024-01-01 INFO started\n", false},
+		{"\n", "", "\n", false},
+	} {
+		token, rest, ok := ParseLevelToken([]byte(tc.line))
+		if token != tc.token || string(rest) != tc.rest || ok != tc.ok {
+			t.Errorf("%q: GOT: %q, %q, %v; WANT: %q, %q, %v", tc.line, token, rest, ok, tc.token, tc.rest, tc.ok)
+		}
+	}
+}
+
+func TestLevelWriter(t *testing.T) {
+	t.Run("invalid token", func(t *testing.T) {
+		_, err := NewLevelWriter(new(bytes.Buffer), LevelRoute("", new(bytes.Buffer)))
+		ensureError(t, err, "level token must not be empty")
+	})
+
+	t.Run("routes lines", func(t *testing.T) {
+		other, errs := new(bytes.Buffer), new(bytes.Buffer)
+		lw, err := NewLevelWriter(other, LevelRoute("warn", errs), LevelRoute("ERROR", errs))
+		ensureError(t, err)
+
+		// Split lines across writes.
+		for _, piece := range []string{"INFO one\nWA", "RN two\n[error] thr", "ee\nno level\nERROR: four"} {
+			n, err := lw.Write([]byte(piece))
+			ensureError(t, err)
+			if got, want := n, len(piece); got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		}
+		if got, want := errs.String(), "WARN two\n[error] three\n"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+		ensureError(t, lw.Close())
+		if got, want := errs.String(), "WARN two\n[error] three\nERROR: four"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+		if got, want := other.String(), "INFO one\nno level\n"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("custom parser", func(t *testing.T) {
+		other, errs := new(bytes.Buffer), new(bytes.Buffer)
+		lw, err := NewLevelWriter(other, LevelRoute("E", errs), LevelTokenParser(func(line []byte) (string, []byte, bool) {
+			if len(line) > 1 && line[1] == ' ' {
+				return string(line[:1]), line[2:], true
+			}
+			return "", line, false
+		}))
+		ensureError(t, err)
+		_, err = lw.Write([]byte("E bad\nI good\n"))
+		ensureError(t, err)
+		if got, want := errs.String(), "E bad\n"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+}
@@ -0,0 +1,61 @@
+package gorill
+
+import (
+	"bytes"
+	"io"
+	"math"
+)
+
+// IndexLinesFromReaderAt returns the byte offset of the start of every line in
+// ra, enabling later random access to a particular line of a large file. A
+// final line that is not terminated by a newline character is included, so the
+// number of offsets returned equals the number of lines counted by
+// CountLinesFromReader using the VisibleLines mode.
+//
+//   offsets, err := gorill.IndexLinesFromReaderAt(fh)
+//   if err != nil {
+//       return err
+//   }
+//   // seek to the start of line 1000, where the first line is line 1
+//   _, err = fh.Seek(offsets[999], io.SeekStart)
+func IndexLinesFromReaderAt(ra io.ReaderAt) ([]int64, error) {
+	var offsets []int64
+	err := IndexLinesFromReaderAtFunc(ra, func(offset int64) error {
+		offsets = append(offsets, offset)
+		return nil
+	})
+	return offsets, err
+}
+
+// IndexLinesFromReaderAtFunc invokes callback with the byte offset of the start
+// of every line in ra, in order, without accumulating the offsets in memory.
+// When callback returns an error, indexing stops and that error is returned.
+func IndexLinesFromReaderAtFunc(ra io.ReaderAt, callback func(offset int64) error) error {
+	var reserved [4096]byte // allocate buffer space on the call stack
+	isLineStart := true
+
+	_, err := scanChunks(io.NewSectionReader(ra, 0, math.MaxInt64), reserved[:], func(chunk []byte, offset int64) error {
+		if isLineStart {
+			if err := callback(offset); err != nil {
+				return err
+			}
+		}
+		var searchOffset int
+		for {
+			index := bytes.IndexByte(chunk[searchOffset:], '\n')
+			if index == -1 {
+				break
+			}
+			searchOffset += index + 1
+			if searchOffset == len(chunk) {
+				break // next line starts in following chunk, if any
+			}
+			if err := callback(offset + int64(searchOffset)); err != nil {
+				return err
+			}
+		}
+		isLineStart = chunk[len(chunk)-1] == '\n'
+		return nil
+	})
+	return err
+}
@@ -0,0 +1,61 @@
+package gorill
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIndexLinesFromReaderAt(t *testing.T) {
+	cases := []struct {
+		input string
+		want  []int64
+	}{
+		{"", nil},
+		{"\n", []int64{0}},
+		{"one", []int64{0}},
+		{"one\n", []int64{0}},
+		{"one\ntwo", []int64{0, 4}},
+		{"one\n\nthree\n", []int64{0, 4, 5}},
+	}
+	for _, c := range cases {
+		got, err := IndexLinesFromReaderAt(strings.NewReader(c.input))
+		ensureError(t, err)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%q: GOT: %v; WANT: %v", c.input, got, c.want)
+		}
+		count, err := CountLinesFromString(c.input)
+		ensureError(t, err)
+		if got, want := len(got), count; got != want {
+			t.Errorf("%q: GOT: %v; WANT: %v", c.input, got, want)
+		}
+	}
+
+	t.Run("lines span chunks", func(t *testing.T) {
+		long := strings.Repeat("x", 4095)
+		got, err := IndexLinesFromReaderAt(strings.NewReader(long + "\n" + long + "\nz"))
+		ensureError(t, err)
+		if want := []int64{0, 4096, 8192}; !reflect.DeepEqual(got, want) {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("callback error stops indexing", func(t *testing.T) {
+		stop := errors.New("stop")
+		var calls int
+		err := IndexLinesFromReaderAtFunc(strings.NewReader("a\nb\nc\n"), func(int64) error {
+			calls++
+			if calls == 2 {
+				return stop
+			}
+			return nil
+		})
+		if got, want := err, stop; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := calls, 2; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
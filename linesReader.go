@@ -0,0 +1,130 @@
+package gorill
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// DefaultMaxLineSize is the default maximum size of a line returned by a
+// LinesReader.
+const DefaultMaxLineSize = bufio.MaxScanTokenSize
+
+// ErrLineTooLong is returned by a LinesReader when a line exceeds its maximum
+// line size.
+type ErrLineTooLong struct {
+	// Line is the number of the line that was too long, where the first line
+	// is line 1.
+	Line int
+
+	// Max is the maximum line size that was exceeded.
+	Max int
+}
+
+// Error returns a string representing the ErrLineTooLong.
+func (e ErrLineTooLong) Error() string {
+	return fmt.Sprintf("line %d exceeds maximum line size of %d bytes", e.Line, e.Max)
+}
+
+// LinesReader iterates through the lines read from an io.Reader, with the same
+// methods as bufio.Scanner. Unlike bufio.Scanner, its maximum line size is
+// configured when it is created, and when a line exceeds it, Err returns
+// ErrLineTooLong identifying which line was too long, rather than
+// bufio.ErrTooLong.
+type LinesReader struct {
+	scanner *bufio.Scanner
+	line    int
+	maxSize int
+}
+
+// LinesReaderSetter is any function that modifies a LinesReader being
+// instantiated.
+type LinesReaderSetter func(*LinesReader) error
+
+// MaxLineSize is used to configure the maximum size of a line, not including
+// its line terminator, that a new LinesReader will return.
+func MaxLineSize(size int) LinesReaderSetter {
+	return func(lr *LinesReader) error {
+		if size <= 0 {
+			return fmt.Errorf("max line size must be greater than 0: %d", size)
+		}
+		lr.maxSize = size
+		return nil
+	}
+}
+
+// NewLinesReader returns a LinesReader that iterates through the lines read
+// from ior. Lines may be terminated by a newline character, or a carriage
+// return and newline sequence, and a final line need not be terminated.
+//
+//   lr, err := gorill.NewLinesReader(fh, gorill.MaxLineSize(1<<20))
+//   if err != nil {
+//       return err
+//   }
+//   for lr.Scan() {
+//       process(lr.Bytes())
+//   }
+//   if err := lr.Err(); err != nil {
+//       return err // might be gorill.ErrLineTooLong
+//   }
+func NewLinesReader(ior io.Reader, setters ...LinesReaderSetter) (*LinesReader, error) {
+	lr := &LinesReader{maxSize: DefaultMaxLineSize}
+	for _, setter := range setters {
+		if err := setter(lr); err != nil {
+			return nil, err
+		}
+	}
+	initial := DefaultBufSize
+	if initial > lr.maxSize+2 {
+		initial = lr.maxSize + 2
+	}
+	lr.scanner = bufio.NewScanner(ior)
+	// Allow room for the line terminator, which is not part of the line.
+	lr.scanner.Buffer(make([]byte, initial), lr.maxSize+2)
+	lr.scanner.Split(lr.split)
+	return lr, nil
+}
+
+// split wraps bufio.ScanLines to enforce the maximum line size regardless of
+// the line terminator.
+func (lr *LinesReader) split(data []byte, atEOF bool) (int, []byte, error) {
+	advance, token, err := bufio.ScanLines(data, atEOF)
+	if err == nil && len(token) > lr.maxSize {
+		return 0, nil, ErrLineTooLong{Line: lr.line + 1, Max: lr.maxSize}
+	}
+	return advance, token, err
+}
+
+// Scan advances the LinesReader to the next line, which will then be available
+// through the Bytes or Text method. It returns false when there are no more
+// lines, either by reaching the end of the input or an error.
+func (lr *LinesReader) Scan() bool {
+	if lr.scanner.Scan() {
+		lr.line++
+		return true
+	}
+	return false
+}
+
+// Bytes returns the most recent line read by Scan, not including its line
+// terminator. The underlying array may be overwritten by a subsequent call to
+// Scan.
+func (lr *LinesReader) Bytes() []byte { return lr.scanner.Bytes() }
+
+// Text returns the most recent line read by Scan as a newly allocated string.
+func (lr *LinesReader) Text() string { return lr.scanner.Text() }
+
+// Line returns the number of the most recent line read by Scan, where the first
+// line is line 1.
+func (lr *LinesReader) Line() int { return lr.line }
+
+// Err returns the first error, other than io.EOF, encountered by the
+// LinesReader.
+func (lr *LinesReader) Err() error {
+	err := lr.scanner.Err()
+	if err == bufio.ErrTooLong {
+		// Line longer than buffer even before split function could reject it.
+		return ErrLineTooLong{Line: lr.line + 1, Max: lr.maxSize}
+	}
+	return err
+}
@@ -0,0 +1,56 @@
+package gorill
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLinesReader(t *testing.T) {
+	t.Run("invalid max line size", func(t *testing.T) {
+		_, err := NewLinesReader(strings.NewReader(""), MaxLineSize(0))
+		ensureError(t, err, "max line size must be greater than 0")
+	})
+
+	t.Run("lines", func(t *testing.T) {
+		lr, err := NewLinesReader(strings.NewReader("one\r\ntwo\n\nfour"))
+		ensureError(t, err)
+		var got []string
+		for lr.Scan() {
+			got = append(got, lr.Text())
+		}
+		ensureError(t, lr.Err())
+		if want := []string{"one", "two", "", "four"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+		if got, want := lr.Line(), 4; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("line at maximum size", func(t *testing.T) {
+		lr, err := NewLinesReader(strings.NewReader("abcd\r\nefgh"), MaxLineSize(4))
+		ensureError(t, err)
+		var got []string
+		for lr.Scan() {
+			got = append(got, lr.Text())
+		}
+		ensureError(t, lr.Err())
+		if want := []string{"abcd", "efgh"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("line too long", func(t *testing.T) {
+		for _, input := range []string{"abc\nabcde\nabc\n", "abc\nabcdefghijklmnop\nabc\n", "abc\nabcde"} {
+			lr, err := NewLinesReader(strings.NewReader(input), MaxLineSize(4))
+			ensureError(t, err)
+			for lr.Scan() {
+			}
+			err = lr.Err()
+			if got, want := err, error(ErrLineTooLong{Line: 2, Max: 4}); got != want {
+				t.Errorf("%q: GOT: %v; WANT: %v", input, got, want)
+			}
+		}
+	})
+}
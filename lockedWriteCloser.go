@@ -3,6 +3,7 @@ package gorill
 import (
 	"io"
 	"sync"
+	"time"
 )
 
 // LockingWriteCloser is an io.WriteCloser that allows only exclusive access to its Write and Close
@@ -37,6 +38,34 @@ func (lwc *LockingWriteCloser) Write(data []byte) (int, error) {
 	return lwc.iowc.Write(data)
 }
 
+// WriteString writes s to the underlying io.WriteCloser, without converting it
+// to a byte slice when the underlying io.WriteCloser implements io.StringWriter.
+func (lwc *LockingWriteCloser) WriteString(s string) (int, error) {
+	lwc.lock.Lock()
+	defer lwc.lock.Unlock()
+	return io.WriteString(lwc.iowc, s)
+}
+
+// ReadFrom reads data from r until io.EOF or an error occurs, writing it to the
+// underlying io.WriteCloser while holding exclusive access. It passes through
+// to the underlying io.WriteCloser so zero-copy optimizations, such as
+// sendfile(2) and splice(2), are preserved when used with io.Copy or Transfer.
+func (lwc *LockingWriteCloser) ReadFrom(r io.Reader) (int64, error) {
+	lwc.lock.Lock()
+	defer lwc.lock.Unlock()
+	return Transfer(lwc.iowc, r)
+}
+
+// SetWriteDeadline sets the write deadline of the underlying io.WriteCloser. It
+// does not wait for exclusive access, so that a deadline may interrupt a Write
+// that is blocked.
+func (lwc *LockingWriteCloser) SetWriteDeadline(t time.Time) error {
+	return SetWriteDeadline(lwc.iowc, t)
+}
+
+// Unwrap returns the underlying io.WriteCloser.
+func (lwc *LockingWriteCloser) Unwrap() io.WriteCloser { return lwc.iowc }
+
 // Close closes the underlying io.WriteCloser.
 func (lwc *LockingWriteCloser) Close() error {
 	lwc.lock.Lock()
@@ -12,3 +12,16 @@ func BenchmarkWriterLockedWriter(b *testing.B) {
 	}
 	benchmarkWriter(b, b.N, consumers)
 }
+
+func TestLockingWriteCloserWriteString(t *testing.T) {
+	bb := NewNopCloseBuffer()
+	lwc := NewLockingWriteCloser(bb)
+	n, err := lwc.WriteString(alphabet)
+	ensureError(t, err)
+	if got, want := n, len(alphabet); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := bb.String(), alphabet; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+}
@@ -0,0 +1,342 @@
+package gorill
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// DefaultMergeMaxPending is the default number of bytes a MergeWriter producer
+// handle will hold while waiting for a frame boundary before it gives up and
+// writes the partial frame to the underlying io.WriteCloser.
+const DefaultMergeMaxPending = 64 * 1024
+
+// FrameBoundary returns the length of the longest prefix of buf that consists
+// only of complete frames. It returns 0 when buf does not contain a complete
+// frame.
+type FrameBoundary func(buf []byte) int
+
+// LineBoundary is a FrameBoundary that treats each newline terminated line as a
+// frame.
+func LineBoundary(buf []byte) int {
+	return bytes.LastIndexByte(buf, '\n') + 1
+}
+
+// MergeWriter interleaves the output of multiple producers into a single
+// underlying io.WriteCloser, but only at frame boundaries, which by default are
+// newline characters. Each producer obtains its own io.WriteCloser by calling
+// Add. Unlike pointing many go-routines at a single LockingWriteCloser, a line
+// written by one producer in several Write calls will never be interrupted by
+// output from another producer.
+type MergeWriter struct {
+	boundary   FrameBoundary
+	halted     bool
+	handles    map[*MergeWriteCloser]struct{}
+	iowc       io.WriteCloser
+	lock       sync.Mutex
+	maxPending int
+
+	// The following fields are only used in fair queuing mode.
+	cond    *sync.Cond
+	err     error               // err is the first error from the underlying io.WriteCloser
+	fairCap int                 // fairCap is the number of bytes each handle may have queued
+	flushed chan struct{}       // flushed is closed when the flusher exits
+	ready   []*MergeWriteCloser // ready lists handles with queued frames, in round-robin order
+}
+
+// MergeWriterSetter is any function that modifies a MergeWriter being
+// instantiated.
+type MergeWriterSetter func(*MergeWriter) error
+
+// MergeBoundary is used to configure a new MergeWriter to interleave producer
+// output at the boundaries identified by the specified function rather than at
+// line boundaries.
+func MergeBoundary(boundary FrameBoundary) MergeWriterSetter {
+	return func(mw *MergeWriter) error {
+		if boundary == nil {
+			return fmt.Errorf("frame boundary function must not be nil")
+		}
+		mw.boundary = boundary
+		return nil
+	}
+}
+
+// MergeMaxPending is used to configure the maximum number of bytes each
+// producer handle will hold while waiting for a frame boundary.
+func MergeMaxPending(size int) MergeWriterSetter {
+	return func(mw *MergeWriter) error {
+		if size <= 0 {
+			return fmt.Errorf("max pending must be greater than 0: %d", size)
+		}
+		mw.maxPending = size
+		return nil
+	}
+}
+
+// MergeFairQueuing is used to configure a new MergeWriter to write frames from
+// its producer handles in round-robin order, one write's worth of frames from
+// each handle per cycle, so that a single chatty producer cannot starve the
+// others. Each handle may have up to maxQueued bytes of complete frames waiting
+// to be written; a handle that exceeds its share blocks until its frames are
+// written. In this mode frames are written by a background go-routine, so an
+// error from the underlying io.WriteCloser is returned by the next Write to
+// any handle, and by Close.
+func MergeFairQueuing(maxQueued int) MergeWriterSetter {
+	return func(mw *MergeWriter) error {
+		if maxQueued <= 0 {
+			return fmt.Errorf("max queued must be greater than 0: %d", maxQueued)
+		}
+		mw.fairCap = maxQueued
+		return nil
+	}
+}
+
+// NewMergeWriter returns a MergeWriter that interleaves the output of its
+// producer handles into the provided io.WriteCloser.
+//
+//   mw, err := gorill.NewMergeWriter(gorill.NopCloseWriter(os.Stdout))
+//   if err != nil {
+//       return err
+//   }
+//   for i := 0; i < 10; i++ {
+//       go func(w io.WriteCloser, i int) {
+//           defer w.Close()
+//           fmt.Fprintf(w, "producer %d: ", i)
+//           fmt.Fprintf(w, "line is never garbled\n")
+//       }(mw.Add(), i)
+//   }
+func NewMergeWriter(iowc io.WriteCloser, setters ...MergeWriterSetter) (*MergeWriter, error) {
+	mw := &MergeWriter{
+		boundary:   LineBoundary,
+		handles:    make(map[*MergeWriteCloser]struct{}),
+		iowc:       iowc,
+		maxPending: DefaultMergeMaxPending,
+	}
+	for _, setter := range setters {
+		if err := setter(mw); err != nil {
+			return nil, err
+		}
+	}
+	if mw.fairCap > 0 {
+		mw.cond = sync.NewCond(&mw.lock)
+		mw.flushed = make(chan struct{})
+		go mw.flush()
+	}
+	return mw, nil
+}
+
+// Add returns a new producer handle whose output will be interleaved with the
+// output of the other producer handles only at frame boundaries.
+func (mw *MergeWriter) Add() *MergeWriteCloser {
+	h := &MergeWriteCloser{mw: mw}
+	mw.lock.Lock()
+	mw.handles[h] = struct{}{}
+	mw.lock.Unlock()
+	return h
+}
+
+// Unwrap returns the io.WriteCloser into which producer output is merged.
+func (mw *MergeWriter) Unwrap() io.WriteCloser { return mw.iowc }
+
+// Close writes any partial frames held by producer handles that have not been
+// closed, then closes the underlying io.WriteCloser. After Close, writes to any
+// producer handle return ErrWriteAfterClose.
+func (mw *MergeWriter) Close() error {
+	mw.lock.Lock()
+	handles := make([]*MergeWriteCloser, 0, len(mw.handles))
+	for h := range mw.handles {
+		handles = append(handles, h)
+	}
+	mw.lock.Unlock()
+
+	var errors ErrList
+	for i, h := range handles {
+		errors.AppendMember(i, h, h.Close())
+	}
+
+	mw.lock.Lock()
+	if mw.halted {
+		mw.lock.Unlock()
+		return errors.Err()
+	}
+	mw.halted = true
+	if mw.cond != nil {
+		// Wake the flusher so it drains the queued frames and exits.
+		mw.cond.Broadcast()
+		mw.lock.Unlock()
+		<-mw.flushed
+		mw.lock.Lock()
+		errors.Append(mw.err)
+	}
+	mw.lock.Unlock()
+
+	errors.Append(mw.iowc.Close())
+	return errors.Err()
+}
+
+// write writes buf to the underlying io.WriteCloser without interruption from
+// other producer handles. In fair queuing mode, it queues buf on behalf of h
+// instead.
+func (mw *MergeWriter) write(h *MergeWriteCloser, buf []byte) error {
+	mw.lock.Lock()
+	defer mw.lock.Unlock()
+
+	if mw.cond != nil {
+		return mw.enqueue(h, buf)
+	}
+	if mw.halted {
+		return ErrWriteAfterClose{}
+	}
+	n, err := mw.iowc.Write(buf)
+	if err == nil && n != len(buf) {
+		err = io.ErrShortWrite
+	}
+	return err
+}
+
+// enqueue appends a copy of buf to the frames queued by h, blocking while h
+// already has its share of bytes queued. The caller must hold mw.lock.
+func (mw *MergeWriter) enqueue(h *MergeWriteCloser, buf []byte) error {
+	for !mw.halted && mw.err == nil && h.queued > 0 && h.queued+len(buf) > mw.fairCap {
+		mw.cond.Wait()
+	}
+	if mw.halted {
+		return ErrWriteAfterClose{}
+	}
+	if mw.err != nil {
+		return mw.err
+	}
+	if len(h.queue) == 0 {
+		mw.ready = append(mw.ready, h)
+	}
+	h.queue = append(h.queue, append([]byte(nil), buf...))
+	h.queued += len(buf)
+	mw.cond.Broadcast()
+	return nil
+}
+
+// flush runs in its own go-routine in fair queuing mode, writing the first
+// queued frames of each ready handle in turn, until the MergeWriter is closed
+// and no frames remain.
+func (mw *MergeWriter) flush() {
+	defer close(mw.flushed)
+
+	mw.lock.Lock()
+	defer mw.lock.Unlock()
+
+	for {
+		for len(mw.ready) == 0 && !mw.halted {
+			mw.cond.Wait()
+		}
+		if len(mw.ready) == 0 {
+			return
+		}
+
+		h := mw.ready[0]
+		mw.ready[0] = nil
+		mw.ready = mw.ready[1:]
+		buf := h.queue[0]
+		h.queue[0] = nil
+		h.queue = h.queue[1:]
+		if len(h.queue) > 0 {
+			mw.ready = append(mw.ready, h) // go to the back of the line
+		}
+		h.queued -= len(buf)
+		mw.cond.Broadcast()
+
+		if mw.err == nil {
+			// Release the lock while writing so producers may continue to
+			// queue frames.
+			mw.lock.Unlock()
+			n, err := mw.iowc.Write(buf)
+			if err == nil && n != len(buf) {
+				err = io.ErrShortWrite
+			}
+			mw.lock.Lock()
+			if err != nil && mw.err == nil {
+				mw.err = err
+				mw.cond.Broadcast()
+			}
+		}
+	}
+}
+
+// MergeWriteCloser is a producer handle for a MergeWriter.
+type MergeWriteCloser struct {
+	halted  bool
+	lock    sync.Mutex
+	mw      *MergeWriter
+	pending []byte
+
+	// queue and queued are protected by mw.lock, and only used in fair
+	// queuing mode.
+	queue  [][]byte
+	queued int
+}
+
+// Write holds data until one or more complete frames are available, then
+// writes the complete frames to the underlying io.WriteCloser in a single
+// operation.
+func (h *MergeWriteCloser) Write(data []byte) (int, error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if h.halted {
+		return 0, ErrWriteAfterClose{}
+	}
+
+	if len(h.pending) == 0 {
+		// Fast path avoids copying complete frames into pending buffer.
+		k := h.mw.boundary(data)
+		if k > 0 {
+			if err := h.mw.write(h, data[:k]); err != nil {
+				return 0, err
+			}
+		}
+		h.pending = append(h.pending, data[k:]...)
+	} else {
+		h.pending = append(h.pending, data...)
+		k := h.mw.boundary(h.pending)
+		if k > 0 {
+			if err := h.mw.write(h, h.pending[:k]); err != nil {
+				h.pending = h.pending[:len(h.pending)-len(data)]
+				return 0, err
+			}
+			h.pending = h.pending[:copy(h.pending, h.pending[k:])]
+		}
+	}
+
+	if len(h.pending) >= h.mw.maxPending {
+		// Frame is too large to hold; emit what has accumulated.
+		if err := h.mw.write(h, h.pending); err != nil {
+			return len(data), err
+		}
+		h.pending = h.pending[:0]
+	}
+	return len(data), nil
+}
+
+// Close writes any partial frame held by the producer handle to the underlying
+// io.WriteCloser, and detaches the handle from its MergeWriter. It does not
+// close the underlying io.WriteCloser.
+func (h *MergeWriteCloser) Close() error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if h.halted {
+		return nil
+	}
+	h.halted = true
+
+	h.mw.lock.Lock()
+	delete(h.mw.handles, h)
+	h.mw.lock.Unlock()
+
+	if len(h.pending) == 0 {
+		return nil
+	}
+	err := h.mw.write(h, h.pending)
+	h.pending = nil
+	return err
+}
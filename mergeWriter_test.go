@@ -0,0 +1,211 @@
+package gorill
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMergeWriter(t *testing.T) {
+	t.Run("partial lines are held until complete", func(t *testing.T) {
+		bb := NewNopCloseBuffer()
+		mw, err := NewMergeWriter(bb)
+		ensureError(t, err)
+
+		first, second := mw.Add(), mw.Add()
+		_, err = first.Write([]byte("one "))
+		ensureError(t, err)
+		_, err = second.Write([]byte("two\n"))
+		ensureError(t, err)
+		_, err = first.Write([]byte("three\nfour"))
+		ensureError(t, err)
+
+		if got, want := bb.String(), "two\none three\n"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+
+		ensureError(t, mw.Close())
+		if got, want := bb.String(), "two\none three\nfour"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+		if got, want := bb.IsClosed(), true; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		_, err = first.Write([]byte("five\n"))
+		testErrorType(t, err, ErrWriteAfterClose{})
+	})
+
+	t.Run("max pending emits partial frame", func(t *testing.T) {
+		bb := NewNopCloseBuffer()
+		mw, err := NewMergeWriter(bb, MergeMaxPending(4))
+		ensureError(t, err)
+
+		h := mw.Add()
+		_, err = h.Write([]byte("abcdef"))
+		ensureError(t, err)
+		if got, want := bb.String(), "abcdef"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("custom boundary", func(t *testing.T) {
+		bb := NewNopCloseBuffer()
+		mw, err := NewMergeWriter(bb, MergeBoundary(func(buf []byte) int {
+			return bytes.LastIndexByte(buf, 0) + 1
+		}))
+		ensureError(t, err)
+
+		h := mw.Add()
+		_, err = h.Write([]byte("a\nb\x00c\n"))
+		ensureError(t, err)
+		if got, want := bb.String(), "a\nb\x00"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("concurrent producers never garble lines", func(t *testing.T) {
+		bb := NewNopCloseBuffer()
+		mw, err := NewMergeWriter(bb)
+		ensureError(t, err)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(h *MergeWriteCloser, i int) {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					fmt.Fprintf(h, "producer %d ", i)
+					fmt.Fprintf(h, "line %d\n", j)
+				}
+				h.Close()
+			}(mw.Add(), i)
+		}
+		wg.Wait()
+
+		lines := strings.Split(strings.TrimSuffix(bb.String(), "\n"), "\n")
+		if got, want := len(lines), 1000; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		for _, line := range lines {
+			var i, j int
+			if _, err := fmt.Sscanf(line, "producer %d line %d", &i, &j); err != nil {
+				t.Errorf("garbled line: %q", line)
+			}
+		}
+	})
+}
+
+// gatedBuffer blocks each Write until release is closed, after announcing the
+// Write on entered.
+type gatedBuffer struct {
+	*NopCloseBuffer
+	entered chan struct{}
+	release chan struct{}
+}
+
+func newGatedBuffer() *gatedBuffer {
+	return &gatedBuffer{
+		NopCloseBuffer: NewNopCloseBuffer(),
+		entered:        make(chan struct{}, 100),
+		release:        make(chan struct{}),
+	}
+}
+
+func (g *gatedBuffer) Write(buf []byte) (int, error) {
+	g.entered <- struct{}{}
+	<-g.release
+	return g.NopCloseBuffer.Write(buf)
+}
+
+func TestMergeWriterFairQueuing(t *testing.T) {
+	t.Run("invalid", func(t *testing.T) {
+		_, err := NewMergeWriter(NewNopCloseBuffer(), MergeFairQueuing(0))
+		ensureError(t, err, "max queued must be greater than 0")
+	})
+
+	t.Run("round-robin", func(t *testing.T) {
+		gw := newGatedBuffer()
+		mw, err := NewMergeWriter(gw, MergeFairQueuing(1024))
+		ensureError(t, err)
+
+		a, b, c := mw.Add(), mw.Add(), mw.Add()
+		_, err = a.Write([]byte("a1\n"))
+		ensureError(t, err)
+		<-gw.entered // flusher is now blocked writing a1
+
+		for _, w := range []struct {
+			h    *MergeWriteCloser
+			data string
+		}{{a, "a2\n"}, {a, "a3\n"}, {a, "a4\n"}, {b, "b1\n"}, {c, "c1\n"}, {b, "b2\n"}} {
+			_, err = w.h.Write([]byte(w.data))
+			ensureError(t, err)
+		}
+		close(gw.release)
+
+		ensureError(t, mw.Close())
+		if got, want := gw.String(), "a1\na2\nb1\nc1\na3\nb2\na4\n"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+		if got, want := gw.IsClosed(), true; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("producer blocks at its cap", func(t *testing.T) {
+		gw := newGatedBuffer()
+		mw, err := NewMergeWriter(gw, MergeFairQueuing(4))
+		ensureError(t, err)
+
+		a, b := mw.Add(), mw.Add()
+		_, err = a.Write([]byte("a1\n"))
+		ensureError(t, err)
+		<-gw.entered
+		_, err = a.Write([]byte("a2\n"))
+		ensureError(t, err)
+
+		done := make(chan error)
+		go func() {
+			_, err := a.Write([]byte("a3\n"))
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			t.Fatalf("GOT: %v; WANT: blocked write", err)
+		case <-time.After(20 * time.Millisecond):
+		}
+
+		// Another producer is not held back by the chatty one.
+		_, err = b.Write([]byte("b1\n"))
+		ensureError(t, err)
+
+		close(gw.release)
+		ensureError(t, <-done)
+		ensureError(t, mw.Close())
+		if got, want := gw.String(), "a1\na2\nb1\na3\n"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("write error", func(t *testing.T) {
+		mw, err := NewMergeWriter(&testWriteCloser{}, MergeFairQueuing(1024))
+		ensureError(t, err)
+
+		h := mw.Add()
+		_, err = h.Write([]byte("one\n"))
+		ensureError(t, err)
+
+		// The error is reported once the flusher has attempted the write.
+		deadline := time.Now().Add(time.Second)
+		for err == nil && time.Now().Before(deadline) {
+			_, err = h.Write([]byte("two\n"))
+			time.Sleep(time.Millisecond)
+		}
+		testErrorType(t, err, io.ErrShortWrite)
+		testErrorType(t, mw.Close(), io.ErrShortWrite)
+	})
+}
@@ -0,0 +1,138 @@
+package gorill
+
+import "sync"
+
+// Message types defined by RFC 6455, and used by popular WebSocket libraries.
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+)
+
+// MessageConn is a message oriented connection, such as a WebSocket. Its method
+// set matches that of *websocket.Conn from github.com/gorilla/websocket. Other
+// libraries may be adapted with a small wrapper, for instance for
+// nhooyr.io/websocket:
+//
+//   type nhooyrConn struct{ c *websocket.Conn }
+//
+//   func (n nhooyrConn) WriteMessage(typ int, data []byte) error {
+//       return n.c.Write(context.Background(), websocket.MessageType(typ), data)
+//   }
+//
+//   func (n nhooyrConn) ReadMessage() (int, []byte, error) {
+//       typ, data, err := n.c.Read(context.Background())
+//       return int(typ), data, err
+//   }
+//
+//   func (n nhooyrConn) Close() error { return n.c.Close(websocket.StatusNormalClosure, "") }
+type MessageConn interface {
+	ReadMessage() (messageType int, data []byte, err error)
+	WriteMessage(messageType int, data []byte) error
+	Close() error
+}
+
+// MessageWriteCloser is an io.WriteCloser that sends each write to a
+// MessageConn as one message, allowing a WebSocket to be added to a
+// MultiWriteCloserFanOut or wrapped by a TimedWriteCloser.
+type MessageWriteCloser struct {
+	halted      bool
+	lock        sync.Mutex
+	mc          MessageConn
+	messageType int
+}
+
+// NewMessageWriteCloser returns a MessageWriteCloser that sends each write to
+// mc as one message of the specified type, such as TextMessage.
+//
+//   conn, err := upgrader.Upgrade(w, r, nil)
+//   if err != nil {
+//       return
+//   }
+//   fanOut.Add(gorill.NewMessageWriteCloser(conn, gorill.TextMessage))
+func NewMessageWriteCloser(mc MessageConn, messageType int) *MessageWriteCloser {
+	return &MessageWriteCloser{mc: mc, messageType: messageType}
+}
+
+// Write sends data as one message. Because most WebSocket connections do not
+// support concurrent writers, writes are serialized.
+func (mw *MessageWriteCloser) Write(data []byte) (int, error) {
+	mw.lock.Lock()
+	defer mw.lock.Unlock()
+
+	if mw.halted {
+		return 0, ErrWriteAfterClose{}
+	}
+	if err := mw.mc.WriteMessage(mw.messageType, data); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// Unwrap returns the underlying MessageConn.
+func (mw *MessageWriteCloser) Unwrap() MessageConn { return mw.mc }
+
+// Close closes the underlying MessageConn.
+func (mw *MessageWriteCloser) Close() error {
+	mw.lock.Lock()
+	defer mw.lock.Unlock()
+
+	if mw.halted {
+		return nil
+	}
+	mw.halted = true
+	return mw.mc.Close()
+}
+
+// MessageReadCloser is an io.ReadCloser that reads the contents of the messages
+// received from a MessageConn as a stream of bytes, allowing a WebSocket to be
+// wrapped by a TimedReadCloser, or copied with io.Copy.
+type MessageReadCloser struct {
+	buf    []byte // buf holds the unread remainder of the current message
+	halted bool
+	lock   sync.Mutex
+	mc     MessageConn
+}
+
+// NewMessageReadCloser returns a MessageReadCloser that reads messages from mc.
+func NewMessageReadCloser(mc MessageConn) *MessageReadCloser {
+	return &MessageReadCloser{mc: mc}
+}
+
+// Read reads up to len(p) bytes from the current message. When the current
+// message has been read completely, it receives the next message. A single
+// Read never returns bytes from more than one message.
+func (mr *MessageReadCloser) Read(p []byte) (int, error) {
+	mr.lock.Lock()
+	defer mr.lock.Unlock()
+
+	if mr.halted {
+		return 0, ErrReadAfterClose{}
+	}
+	for len(mr.buf) == 0 {
+		_, data, err := mr.mc.ReadMessage()
+		if err != nil {
+			return 0, err
+		}
+		mr.buf = data
+	}
+	n := copy(p, mr.buf)
+	mr.buf = mr.buf[n:]
+	return n, nil
+}
+
+// Unwrap returns the underlying MessageConn.
+func (mr *MessageReadCloser) Unwrap() MessageConn { return mr.mc }
+
+// Close closes the underlying MessageConn. When a MessageReadCloser and a
+// MessageWriteCloser share a MessageConn, closing either closes the connection.
+func (mr *MessageReadCloser) Close() error {
+	mr.lock.Lock()
+	defer mr.lock.Unlock()
+
+	if mr.halted {
+		return nil
+	}
+	mr.halted = true
+	mr.buf = nil
+	return mr.mc.Close()
+}
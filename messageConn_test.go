@@ -0,0 +1,90 @@
+package gorill
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+// fakeMessageConn is a MessageConn that records messages written to it, and
+// returns queued messages when read.
+type fakeMessageConn struct {
+	closed   bool
+	incoming [][]byte
+	types    []int
+	written  [][]byte
+}
+
+func (f *fakeMessageConn) ReadMessage() (int, []byte, error) {
+	if len(f.incoming) == 0 {
+		return 0, nil, io.EOF
+	}
+	data := f.incoming[0]
+	f.incoming = f.incoming[1:]
+	return BinaryMessage, data, nil
+}
+
+func (f *fakeMessageConn) WriteMessage(messageType int, data []byte) error {
+	if f.closed {
+		return errors.New("connection closed")
+	}
+	f.types = append(f.types, messageType)
+	f.written = append(f.written, append([]byte(nil), data...))
+	return nil
+}
+
+func (f *fakeMessageConn) Close() error { f.closed = true; return nil }
+
+func TestMessageWriteCloser(t *testing.T) {
+	fc := new(fakeMessageConn)
+	mw := NewMessageWriteCloser(fc, TextMessage)
+
+	for _, message := range []string{"one", "two"} {
+		n, err := mw.Write([]byte(message))
+		ensureError(t, err)
+		if got, want := n, len(message); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	}
+	if got, want := len(fc.written), 2; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := string(fc.written[1]), "two"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	if got, want := fc.types[0], TextMessage; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	// Usable beneath other wrappers.
+	tw := NewTimedWriteCloser(mw, time.Second)
+	_, err := tw.Write([]byte("three"))
+	ensureError(t, err)
+	ensureError(t, tw.Close())
+	if !fc.closed {
+		t.Errorf("GOT: %v; WANT: %v", fc.closed, true)
+	}
+	_, err = mw.Write([]byte("four"))
+	testErrorType(t, err, ErrWriteAfterClose{})
+}
+
+func TestMessageReadCloser(t *testing.T) {
+	fc := &fakeMessageConn{incoming: [][]byte{[]byte("abc"), nil, []byte("defgh")}}
+	mr := NewMessageReadCloser(fc)
+
+	buf := make([]byte, 4)
+	n, err := mr.Read(buf)
+	ensureError(t, err)
+	ensureBuffer(t, buf, n, "abc") // does not span messages
+
+	rest, err := ioutil.ReadAll(mr)
+	ensureError(t, err)
+	if got, want := string(rest), "defgh"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	ensureError(t, mr.Close())
+	_, err = mr.Read(buf)
+	testErrorType(t, err, ErrReadAfterClose{})
+}
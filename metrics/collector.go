@@ -0,0 +1,178 @@
+// Package metrics publishes the statistics reported by gorill wrappers that
+// implement gorill.StatsReporter, via expvar, the Prometheus text exposition
+// format, and a shim for the Prometheus client library, so observability of
+// many wrappers comes from one integration point.
+package metrics
+
+import (
+	"bufio"
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/karrick/gorill"
+)
+
+// Collector gathers statistics from a set of named gorill.StatsReporter
+// instances.
+type Collector struct {
+	lock      sync.RWMutex
+	reporters map[string]gorill.StatsReporter
+}
+
+// NewCollector returns a Collector without any registered reporters.
+//
+//   spooler, err := gorill.NewSpooledWriteCloser(fh)
+//   if err != nil {
+//       return err
+//   }
+//   collector := metrics.NewCollector()
+//   collector.Register("log_spool", spooler)
+//   collector.Publish("gorill") // expvar
+//   http.Handle("/metrics", collector.Handler("gorill")) // Prometheus
+func NewCollector() *Collector {
+	return &Collector{reporters: make(map[string]gorill.StatsReporter)}
+}
+
+// Register adds reporter to the Collector under the specified name. It returns
+// an error when another reporter is already registered with the same name.
+func (c *Collector) Register(name string, reporter gorill.StatsReporter) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if _, ok := c.reporters[name]; ok {
+		return fmt.Errorf("cannot register duplicate name: %q", name)
+	}
+	c.reporters[name] = reporter
+	return nil
+}
+
+// Unregister removes the reporter registered under the specified name.
+func (c *Collector) Unregister(name string) {
+	c.lock.Lock()
+	delete(c.reporters, name)
+	c.lock.Unlock()
+}
+
+// Snapshot returns the statistics of every registered reporter, keyed by the
+// name under which the reporter was registered.
+func (c *Collector) Snapshot() map[string]map[string]int64 {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	snapshot := make(map[string]map[string]int64, len(c.reporters))
+	for name, reporter := range c.reporters {
+		snapshot[name] = reporter.Stats()
+	}
+	return snapshot
+}
+
+// Publish publishes the statistics of the registered reporters as an expvar
+// variable with the specified name, whose value is re-computed each time it is
+// read. Like expvar.Publish, it panics when the name is already in use.
+func (c *Collector) Publish(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} { return c.Snapshot() }))
+}
+
+// Sample is one statistic of a registered reporter, in the form expected by a
+// Prometheus metric: Name is the metric family, and Source is the value of its
+// source label.
+type Sample struct {
+	Name   string
+	Source string
+	Value  int64
+}
+
+// Samples returns the statistics of the registered reporters, sorted by metric
+// family and then by source. Each statistic becomes a metric family named by
+// joining prefix and the statistic name with an underscore, and the name under
+// which the reporter was registered becomes its source label.
+func (c *Collector) Samples(prefix string) []Sample {
+	var samples []Sample
+	for source, stats := range c.Snapshot() {
+		for stat, value := range stats {
+			samples = append(samples, Sample{Name: sanitize(prefix + "_" + stat), Source: source, Value: value})
+		}
+	}
+	sort.Slice(samples, func(i, j int) bool {
+		if samples[i].Name != samples[j].Name {
+			return samples[i].Name < samples[j].Name
+		}
+		return samples[i].Source < samples[j].Source
+	})
+	return samples
+}
+
+// Collect sends the statistics of the registered reporters to ch, as returned
+// by Samples. It has the shape of the Collect method of a prometheus.Collector,
+// so that a program using the Prometheus client library may register the
+// statistics without this package depending on that library.
+//
+//   type gorillCollector struct{ c *metrics.Collector }
+//
+//   func (gorillCollector) Describe(chan<- *prometheus.Desc) {} // unchecked
+//
+//   func (g gorillCollector) Collect(ch chan<- prometheus.Metric) {
+//       samples := make(chan metrics.Sample)
+//       go func() {
+//           g.c.Collect("gorill", samples)
+//           close(samples)
+//       }()
+//       for s := range samples {
+//           desc := prometheus.NewDesc(s.Name, "gorill statistic", []string{"source"}, nil)
+//           ch <- prometheus.MustNewConstMetric(desc, prometheus.UntypedValue, float64(s.Value), s.Source)
+//       }
+//   }
+//
+//   prometheus.MustRegister(gorillCollector{collector})
+func (c *Collector) Collect(prefix string, ch chan<- Sample) {
+	for _, sample := range c.Samples(prefix) {
+		ch <- sample
+	}
+}
+
+// WritePrometheus writes the statistics of the registered reporters to w using
+// the Prometheus text exposition format, with the metric families and labels
+// described by Samples.
+func (c *Collector) WritePrometheus(w io.Writer, prefix string) error {
+	bw := bufio.NewWriter(w)
+	var family string
+	for _, sample := range c.Samples(prefix) {
+		// Samples are grouped by metric family, as required by the exposition
+		// format.
+		if sample.Name != family {
+			family = sample.Name
+			fmt.Fprintf(bw, "# TYPE %s untyped\n", family)
+		}
+		fmt.Fprintf(bw, "%s{source=\"%s\"} %d\n", family, labelEscaper.Replace(sample.Source), sample.Value)
+	}
+	return bw.Flush()
+}
+
+// Handler returns an http.Handler that serves the statistics of the registered
+// reporters using the Prometheus text exposition format, suitable for being
+// scraped by a Prometheus server.
+func (c *Collector) Handler(prefix string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		_ = c.WritePrometheus(w, prefix) // client went away
+	})
+}
+
+// labelEscaper escapes a label value as required by the Prometheus text
+// exposition format, which only escapes backslash, double-quote, and line feed.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// sanitize replaces characters not permitted in Prometheus metric names.
+func sanitize(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == ':' {
+			return r
+		}
+		return '_'
+	}, name)
+}
@@ -0,0 +1,130 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/karrick/gorill"
+)
+
+type fixedReporter map[string]int64
+
+func (r fixedReporter) Stats() map[string]int64 { return r }
+
+func TestCollectorRegister(t *testing.T) {
+	c := NewCollector()
+	if err := c.Register("a", fixedReporter{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Register("a", fixedReporter{}); err == nil || !strings.Contains(err.Error(), "duplicate") {
+		t.Errorf("GOT: %v; WANT: %v", err, "duplicate")
+	}
+	c.Unregister("a")
+	if got, want := len(c.Snapshot()), 0; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
+
+func TestCollectorWritePrometheus(t *testing.T) {
+	c := NewCollector()
+	_ = c.Register("second", fixedReporter{"bytes": 2, "writes": 1})
+	_ = c.Register("first", fixedReporter{"bytes": 13, "buffer-size": 4096})
+
+	bb := new(bytes.Buffer)
+	if err := c.WritePrometheus(bb, "gorill"); err != nil {
+		t.Fatal(err)
+	}
+	want := `# TYPE gorill_buffer_size untyped
+gorill_buffer_size{source="first"} 4096
+# TYPE gorill_bytes untyped
+gorill_bytes{source="first"} 13
+gorill_bytes{source="second"} 2
+# TYPE gorill_writes untyped
+gorill_writes{source="second"} 1
+`
+	if got := bb.String(); got != want {
+		t.Errorf("GOT:\n%s\nWANT:\n%s", got, want)
+	}
+
+	rec := httptest.NewRecorder()
+	c.Handler("gorill").ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if got := rec.Body.String(); got != want {
+		t.Errorf("GOT:\n%s\nWANT:\n%s", got, want)
+	}
+}
+
+func TestCollectorWritePrometheusEscapesLabels(t *testing.T) {
+	c := NewCollector()
+	_ = c.Register("a\\b\"c\nd\te", fixedReporter{"bytes": 1})
+
+	bb := new(bytes.Buffer)
+	if err := c.WritePrometheus(bb, "gorill"); err != nil {
+		t.Fatal(err)
+	}
+	want := "# TYPE gorill_bytes untyped\ngorill_bytes{source=\"a\\\\b\\\"c\\nd\te\"} 1\n"
+	if got := bb.String(); got != want {
+		t.Errorf("GOT:\n%s\nWANT:\n%s", got, want)
+	}
+}
+
+func TestCollectorCollect(t *testing.T) {
+	c := NewCollector()
+	_ = c.Register("second", fixedReporter{"bytes": 2})
+	_ = c.Register("first", fixedReporter{"bytes": 13, "writes": 1})
+
+	ch := make(chan Sample)
+	go func() {
+		c.Collect("gorill", ch)
+		close(ch)
+	}()
+	var got []Sample
+	for sample := range ch {
+		got = append(got, sample)
+	}
+	want := []Sample{
+		{Name: "gorill_bytes", Source: "first", Value: 13},
+		{Name: "gorill_bytes", Source: "second", Value: 2},
+		{Name: "gorill_writes", Source: "first", Value: 1},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("GOT: %v; WANT: %v", got[i], want[i])
+		}
+	}
+}
+
+// publishCount makes the expvar name of each run of TestCollectorPublish
+// unique, because expvar.Publish panics when a name is reused.
+var publishCount int64
+
+func TestCollectorPublish(t *testing.T) {
+	fanOut := gorill.NewMultiWriteCloserFanOut(gorill.NewNopCloseBuffer())
+	if _, err := fanOut.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewCollector()
+	_ = c.Register("fan_out", fanOut)
+	name := fmt.Sprintf("gorill_test_%d", atomic.AddInt64(&publishCount, 1))
+	c.Publish(name)
+
+	var got map[string]map[string]int64
+	if err := json.Unmarshal([]byte(expvar.Get(name).String()), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := got["fan_out"]["bytes"], int64(5); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := got["fan_out"]["writers"], int64(1); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
@@ -0,0 +1,152 @@
+package gorill
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// MmapReaderAt provides io.ReaderAt and io.ReadSeekCloser semantics for a
+// file whose contents are mapped into memory. Reading from the mapping does not
+// require a system call, which makes it an efficient backing for random access
+// to huge, read-mostly files, such as when building a line index with
+// IndexLinesFromReaderAt, or when reading lines in reverse with
+// ReverseLineReader.
+//
+// On platforms that do not support mmap(2), the file contents are read into
+// memory instead.
+//
+// ReadAt may be called concurrently. Read and Seek share a file offset, and
+// must not be called concurrently with each other.
+type MmapReaderAt struct {
+	data   []byte
+	halted bool
+	lock   sync.RWMutex
+	off    int64 // off is the offset used by Read and Seek
+}
+
+// OpenMmapReaderAt maps the contents of the specified file into memory, and
+// returns a MmapReaderAt that reads from the mapping. The file is closed before
+// this function returns, but the mapping remains valid until the MmapReaderAt
+// is closed.
+//
+//   mr, err := gorill.OpenMmapReaderAt("huge.log")
+//   if err != nil {
+//       return err
+//   }
+//   defer mr.Close()
+//   offsets, err := gorill.IndexLinesFromReaderAt(mr)
+func OpenMmapReaderAt(pathname string) (*MmapReaderAt, error) {
+	fh, err := os.Open(pathname)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	return NewMmapReaderAt(fh)
+}
+
+// NewMmapReaderAt maps the contents of fh into memory, and returns a
+// MmapReaderAt that reads from the mapping. The caller may close fh once this
+// function returns.
+func NewMmapReaderAt(fh *os.File) (*MmapReaderAt, error) {
+	fi, err := fh.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := fi.Size()
+	if int64(int(size)) != size {
+		return nil, fmt.Errorf("file too large to map into memory: %d", size)
+	}
+	mr := new(MmapReaderAt)
+	if size > 0 {
+		if mr.data, err = mmapFile(fh, int(size)); err != nil {
+			return nil, err
+		}
+	}
+	return mr, nil
+}
+
+// Len returns the number of bytes of the mapped file.
+func (mr *MmapReaderAt) Len() int {
+	mr.lock.RLock()
+	defer mr.lock.RUnlock()
+	return len(mr.data)
+}
+
+// ReadAt reads len(p) bytes from the mapped file starting at offset off. It
+// returns io.EOF when fewer than len(p) bytes were read because the end of the
+// file was reached.
+func (mr *MmapReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	mr.lock.RLock()
+	defer mr.lock.RUnlock()
+
+	if mr.halted {
+		return 0, ErrReadAfterClose{}
+	}
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if off >= int64(len(mr.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, mr.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Read reads up to len(p) bytes from the mapped file into p, starting at the
+// current offset.
+func (mr *MmapReaderAt) Read(p []byte) (int, error) {
+	n, err := mr.ReadAt(p, mr.off)
+	mr.off += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+// Seek sets the offset for the next Read, interpreted according to whence.
+func (mr *MmapReaderAt) Seek(offset int64, whence int) (int64, error) {
+	mr.lock.RLock()
+	defer mr.lock.RUnlock()
+
+	if mr.halted {
+		return 0, ErrReadAfterClose{}
+	}
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += mr.off
+	case io.SeekEnd:
+		offset += int64(len(mr.data))
+	default:
+		return 0, fmt.Errorf("invalid whence: %d", whence)
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	mr.off = offset
+	return offset, nil
+}
+
+// Close releases the memory mapping. Byte slices previously obtained from the
+// MmapReaderAt remain valid, because all methods copy bytes out of the mapping.
+func (mr *MmapReaderAt) Close() error {
+	mr.lock.Lock()
+	defer mr.lock.Unlock()
+
+	if mr.halted {
+		return nil
+	}
+	mr.halted = true
+	data := mr.data
+	mr.data = nil
+	if data == nil {
+		return nil
+	}
+	return munmapFile(data)
+}
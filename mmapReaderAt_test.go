@@ -0,0 +1,87 @@
+package gorill
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestMmapReaderAt(t *testing.T) {
+	tempFile := func(t *testing.T, contents string) string {
+		fh, err := ioutil.TempFile("", "gorill")
+		ensureError(t, err)
+		_, err = fh.WriteString(contents)
+		ensureError(t, err)
+		ensureError(t, fh.Close())
+		return fh.Name()
+	}
+
+	t.Run("empty file", func(t *testing.T) {
+		pathname := tempFile(t, "")
+		defer os.Remove(pathname)
+
+		mr, err := OpenMmapReaderAt(pathname)
+		ensureError(t, err)
+		if got, want := mr.Len(), 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		_, err = mr.ReadAt(make([]byte, 1), 0)
+		testErrorType(t, err, io.EOF)
+		ensureError(t, mr.Close())
+	})
+
+	t.Run("reads", func(t *testing.T) {
+		pathname := tempFile(t, alphabet)
+		defer os.Remove(pathname)
+
+		mr, err := OpenMmapReaderAt(pathname)
+		ensureError(t, err)
+
+		buf := make([]byte, 5)
+		n, err := mr.ReadAt(buf, 10)
+		ensureError(t, err)
+		ensureBuffer(t, buf, n, "klmno")
+
+		n, err = mr.ReadAt(buf, 23)
+		testErrorType(t, err, io.EOF)
+		ensureBuffer(t, buf, n, "xyz\n")
+
+		_, err = mr.Seek(-4, io.SeekEnd)
+		ensureError(t, err)
+		rest, err := ioutil.ReadAll(mr)
+		ensureError(t, err)
+		if got, want := string(rest), "xyz\n"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+
+		_, err = mr.Seek(0, io.SeekStart)
+		ensureError(t, err)
+		all, err := ioutil.ReadAll(mr)
+		ensureError(t, err)
+		if !bytes.Equal(all, []byte(alphabet)) {
+			t.Errorf("GOT: %q; WANT: %q", all, alphabet)
+		}
+
+		ensureError(t, mr.Close())
+		ensureError(t, mr.Close())
+		_, err = mr.ReadAt(buf, 0)
+		testErrorType(t, err, ErrReadAfterClose{})
+	})
+
+	t.Run("line index", func(t *testing.T) {
+		pathname := tempFile(t, "one\ntwo\nthree\n")
+		defer os.Remove(pathname)
+
+		mr, err := OpenMmapReaderAt(pathname)
+		ensureError(t, err)
+		defer mr.Close()
+
+		offsets, err := IndexLinesFromReaderAt(mr)
+		ensureError(t, err)
+		if got, want := len(offsets), 3; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
@@ -0,0 +1,23 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package gorill
+
+import (
+	"io"
+	"os"
+)
+
+// mmapFile reads size bytes of fh into memory on platforms without mmap(2).
+func mmapFile(fh *os.File, size int) ([]byte, error) {
+	data := make([]byte, size)
+	if _, err := io.ReadFull(io.NewSectionReader(fh, 0, int64(size)), data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// munmapFile does nothing on platforms without mmap(2).
+func munmapFile(data []byte) error {
+	return nil
+}
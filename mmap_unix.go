@@ -0,0 +1,19 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package gorill
+
+import (
+	"os"
+	"syscall"
+)
+
+// mmapFile maps size bytes of fh into memory for reading.
+func mmapFile(fh *os.File, size int) ([]byte, error) {
+	return syscall.Mmap(int(fh.Fd()), 0, size, syscall.PROT_READ, syscall.MAP_SHARED)
+}
+
+// munmapFile releases a mapping obtained from mmapFile.
+func munmapFile(data []byte) error {
+	return syscall.Munmap(data)
+}
@@ -0,0 +1,96 @@
+package gorill
+
+import (
+	"io"
+	"sync"
+)
+
+// MultiCloser is an io.Closer that closes a list of io.Closer instances, such as the files,
+// connections, and writers that make up a larger resource, aggregating their errors.
+type MultiCloser struct {
+	closers []io.Closer
+	lock    sync.Mutex
+}
+
+// NewMultiCloser returns a MultiCloser that is go-routine safe, and that closes the specified
+// io.Closer instances.
+//
+//   mc := gorill.NewMultiCloser()
+//   fh, err := os.Open(path)
+//   if err != nil {
+//       return err
+//   }
+//   mc.Add(fh)
+//   conn, err := net.Dial("tcp", address)
+//   if err != nil {
+//       mc.Close()
+//       return err
+//   }
+//   mc.Add(conn)
+//   defer mc.Close() // closes conn, then fh
+func NewMultiCloser(closers ...io.Closer) *MultiCloser {
+	mc := &MultiCloser{}
+	for _, c := range closers {
+		mc.Add(c)
+	}
+	return mc
+}
+
+// Add adds an io.Closer to the list of io.Closer instances to be closed, unless it is already in
+// the list. It returns the number of io.Closer instances in the list.
+func (mc *MultiCloser) Add(c io.Closer) int {
+	mc.lock.Lock()
+	defer mc.lock.Unlock()
+
+	if mc.index(c) < 0 {
+		mc.closers = append(mc.closers, c)
+	}
+	return len(mc.closers)
+}
+
+// Remove removes an io.Closer from the list of io.Closer instances to be closed, without closing
+// it. It returns the number of io.Closer instances in the list.
+func (mc *MultiCloser) Remove(c io.Closer) int {
+	mc.lock.Lock()
+	defer mc.lock.Unlock()
+
+	if i := mc.index(c); i >= 0 {
+		mc.closers = append(mc.closers[:i], mc.closers[i+1:]...)
+	}
+	return len(mc.closers)
+}
+
+// index returns the position of c in the list, or -1 when it is not in the list. The caller must
+// hold the lock.
+func (mc *MultiCloser) index(c io.Closer) int {
+	for i, other := range mc.closers {
+		if other == c {
+			return i
+		}
+	}
+	return -1
+}
+
+// Count returns the number of io.Closer instances in the list.
+func (mc *MultiCloser) Count() int {
+	mc.lock.Lock()
+	defer mc.lock.Unlock()
+
+	return len(mc.closers)
+}
+
+// Close closes every io.Closer in the list, in the reverse order they were added, like deferred
+// calls, and empties the list. When io.Closer instances return errors, the returned ErrList holds a
+// MemberError identifying each one that failed by its position in the list.
+func (mc *MultiCloser) Close() error {
+	mc.lock.Lock()
+	closers := mc.closers
+	mc.closers = nil
+	mc.lock.Unlock()
+
+	var errors ErrList
+	for i := len(closers) - 1; i >= 0; i-- {
+		errors.AppendMember(i, closers[i], closers[i].Close())
+	}
+	return errors.Err()
+}
@@ -0,0 +1,72 @@
+package gorill
+
+import (
+	"errors"
+	"testing"
+)
+
+// closeRecorder is an io.Closer that records the order in which it was closed.
+type closeRecorder struct {
+	err   error
+	name  string
+	order *[]string
+}
+
+func (cr *closeRecorder) Close() error {
+	*cr.order = append(*cr.order, cr.name)
+	return cr.err
+}
+
+func (cr *closeRecorder) String() string { return cr.name }
+
+func TestMultiCloser(t *testing.T) {
+	t.Run("closes in reverse order", func(t *testing.T) {
+		var order []string
+		first := &closeRecorder{name: "first", order: &order}
+		second := &closeRecorder{name: "second", order: &order}
+		mc := NewMultiCloser(first, second)
+		if got, want := mc.Add(first), 2; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		ensureError(t, mc.Close())
+		if got, want := len(order), 2; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		if order[0] != "second" || order[1] != "first" {
+			t.Errorf("GOT: %v; WANT: %v", order, []string{"second", "first"})
+		}
+		if got, want := mc.Count(), 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureError(t, mc.Close())
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		var order []string
+		first := &closeRecorder{name: "first", order: &order}
+		second := &closeRecorder{name: "second", order: &order}
+		mc := NewMultiCloser(first, second)
+		if got, want := mc.Remove(first), 1; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureError(t, mc.Close())
+		if got, want := len(order), 1; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("aggregates errors", func(t *testing.T) {
+		var order []string
+		mc := NewMultiCloser(
+			&closeRecorder{name: "first", order: &order, err: errors.New("first failed")},
+			&closeRecorder{name: "second", order: &order},
+			&closeRecorder{name: "third", order: &order, err: errors.New("third failed")},
+		)
+		err := mc.Close()
+		ensureError(t, err, "member 2 (third): third failed", "member 0 (first): first failed")
+		if got, want := len(order), 3; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
@@ -73,6 +73,9 @@ func (fanin *MultiWriteCloserFanIn) Write(data []byte) (int, error) {
 	return written, err
 }
 
+// Unwrap returns the underlying io.WriteCloser.
+func (fanin *MultiWriteCloserFanIn) Unwrap() io.WriteCloser { return fanin.iowc }
+
 // Close marks the MultiWriteCloserFanIn as finished.  The last Close method invoked for a group of
 // MultiWriteCloserFanIn instances will trigger a close of the underlying io.WriteCloser.
 func (fanin *MultiWriteCloserFanIn) Close() error {
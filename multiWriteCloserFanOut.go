@@ -1,18 +1,166 @@
 package gorill
 
 import (
+	"context"
+	"fmt"
 	"io"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// SequencedWriteCloser is an io.WriteCloser that receives the sequence number of each write
+// broadcast by a MultiWriteCloserFanOut with sequencing enabled.
+type SequencedWriteCloser interface {
+	io.WriteCloser
+
+	// JoinSequence is invoked when the writer is added to a sequencing
+	// MultiWriteCloserFanOut, with the sequence number of the first write it
+	// will receive.
+	JoinSequence(next uint64)
+
+	// WriteSequenced is invoked instead of Write for each write, with the
+	// sequence number assigned to it.
+	WriteSequenced(seq uint64, data []byte) (int, error)
+}
+
 // MultiWriteCloserFanOut is a structure that allows additions to and removals from the list of
 // io.WriteCloser objects that will be written to.
+//
+// Writes use an immutable snapshot of the list of writers and the configuration, which additions,
+// removals, and configuration changes replace, so concurrent writes do not contend for a lock, and
+// writes in progress continue with the snapshot they started with.
 type MultiWriteCloserFanOut struct {
-	lock        sync.RWMutex
-	writerMap   map[io.WriteCloser]struct{}
-	writerSlice []io.WriteCloser
+	// Statistics are accessed atomically, and kept first for 64-bit alignment.
+	statBytes   int64
+	statEvicted int64
+	statWrites  int64
+	sequence    uint64 // sequence is the number assigned to the latest broadcast
+
+	fanOutConfig
+	added     uint64                     // added is the number of writers ever added, which orders them
+	clock     Clock                      // clock times writes, health checks, and redials
+	closeLock sync.RWMutex               // closeLock is held for reading by broadcasts, so Close waits for them
+	dialers   map[*fanOutDialer]struct{} // dialers holds the writers added with AddFactory
+	events    fanOutEvents               // events delivers the events requested by Events
+	lock      sync.Mutex                 // lock serializes changes to the writers and the configuration
+	seqLock   sync.Mutex                 // seqLock serializes additions with broadcasts while sequencing or catching up
+	snapshot  atomic.Value               // snapshot holds the *fanOutSnapshot used by writes
+	writerMap map[io.WriteCloser]*fanOutMember
+}
+
+// fanOutConfig is the configuration of a MultiWriteCloserFanOut.
+type fanOutConfig struct {
+	catchUp     *tailBuffer
+	coalescer   *CoalescingWriter // coalescer collects small writes, when coalescing is enabled
+	concurrency int
+	hook        OperationHook
+	isolate     bool
+	leaveOpen   bool // leaveOpen is set when evicted writers are not closed
+	onError     func(io.WriteCloser, error)
+	onRemove    func(io.WriteCloser, error)
+	policy      FanOutErrorPolicy
+	pool        *fanOutPool   // pool is the fixed worker pool, when concurrency is greater than 1
+	probeStop   chan struct{} // probeStop is closed to stop the health check prober
+	quorum      int           // quorum is the number of writers that must accept each write
+	redialMin   time.Duration // redialMin is the delay before the first attempt to re-create a writer
+	redialMax   time.Duration // redialMax is the longest delay between attempts to re-create a writer
+	sequenced   bool
+	timeout     time.Duration // timeout is the time allowed for each writer to complete a write
+}
+
+// fanOutSnapshot is an immutable copy of the writers and the configuration of a
+// MultiWriteCloserFanOut, replaced whenever either changes, so that writes need no lock.
+type fanOutSnapshot struct {
+	fanOutConfig
+	members []*fanOutMember // members holds the state of each writer in writers
+	writers []io.WriteCloser
+}
+
+// fanOutMember is the state of a writer of a MultiWriteCloserFanOut: its statistics, and the
+// long-lived go-routine that writes to it, so that writes do not spawn a go-routine per writer.
+type fanOutMember struct {
+	counters *writerCounters
+	halted   bool
+	jobs     chan func()
+	lock     sync.Mutex   // lock serializes dispatching jobs with halting the go-routine
+	order    uint64       // order is the position of the writer in the order writers were added
+	pingLock sync.RWMutex // pingLock is held for writing by pings, and for reading by writes
+}
+
+// newMember returns the state of a new writer, and starts its go-routine. The caller must hold the
+// lock.
+func (mwc *MultiWriteCloserFanOut) newMember(w io.WriteCloser) *fanOutMember {
+	mwc.added++
+	m := &fanOutMember{
+		counters: &writerCounters{stats: WriterStats{Writer: w}},
+		jobs:     make(chan func(), 1), // queue one job while finishing the previous one
+		order:    mwc.added,
+	}
+	go func() {
+		for job := range m.jobs {
+			job()
+		}
+	}()
+	return m
+}
+
+// dispatch runs job on the writer's go-routine, or on a new go-routine when it is busy with a
+// write that has not returned, or halted because the writer was removed.
+func (m *fanOutMember) dispatch(job func()) {
+	m.lock.Lock()
+	if !m.halted {
+		select {
+		case m.jobs <- job:
+			m.lock.Unlock()
+			return
+		default:
+		}
+	}
+	m.lock.Unlock()
+	go job()
+}
+
+// halt stops the writer's go-routine once it has run the jobs already dispatched to it.
+func (m *fanOutMember) halt() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if !m.halted {
+		m.halted = true
+		close(m.jobs)
+	}
+}
+
+// fanOutPool is a fixed pool of worker go-routines shared by all writes.
+type fanOutPool struct {
+	jobs chan func()
+	stop chan struct{} // stop is closed to stop the workers
 }
 
+// fanOutSink is the io.WriteCloser into which the coalescer of a MultiWriteCloserFanOut writes the
+// bytes it collected.
+type fanOutSink struct {
+	mwc *MultiWriteCloserFanOut
+}
+
+// Write broadcasts data to the writers. Errors are not returned, because they would stop the
+// coalescer; they are handled by the error policy and reported to the callbacks instead.
+func (s fanOutSink) Write(data []byte) (int, error) {
+	return s.WriteContext(context.Background(), data)
+}
+
+// WriteContext broadcasts data to the writers like Write, but stops waiting for writers that have
+// not returned by the time ctx is done, like MultiWriteCloserFanOut.WriteContext.
+func (s fanOutSink) WriteContext(ctx context.Context, data []byte) (int, error) {
+	s.mwc.broadcast(ctx, fanOutPayload{b: data}, nil)
+	return len(data), nil
+}
+
+// Close does nothing, because the writers are closed by the MultiWriteCloserFanOut.
+func (fanOutSink) Close() error { return nil }
+
 // NewMultiWriteCloserFanOut returns a MultiWriteCloserFanOut that is go-routine safe.
 //
 //   bb1 = gorill.NewNopCloseBuffer()
@@ -32,25 +180,48 @@ type MultiWriteCloserFanOut struct {
 //   	t.Errorf("Actual: %#v; Expected: %#v", bb2.String(), want)
 //   }
 func NewMultiWriteCloserFanOut(writers ...io.WriteCloser) *MultiWriteCloserFanOut {
-	mwc := &MultiWriteCloserFanOut{writerMap: make(map[io.WriteCloser]struct{})}
-	for _, w := range writers {
-		mwc.writerMap[w] = struct{}{}
-	}
-	mwc.update()
+	mwc, _ := NewFanOut(FanOutWriters(writers...)) // FanOutWriters never returns an error
 	return mwc
 }
 
-// update makes the slice reflect contents of the altered map
+// drop removes a writer from the map, and stops its go-routine. The caller must hold the lock, and
+// invoke update.
+func (mwc *MultiWriteCloserFanOut) drop(w io.WriteCloser) {
+	if member, ok := mwc.writerMap[w]; ok {
+		member.halt()
+		delete(mwc.writerMap, w)
+		mwc.events.emit(EventRemove, w, nil)
+	}
+}
+
+// update publishes a snapshot that reflects the contents of the altered map and configuration, with
+// the writers in the order they were added. The caller must hold the lock.
 func (mwc *MultiWriteCloserFanOut) update() {
-	mwc.writerSlice = make([]io.WriteCloser, 0, len(mwc.writerMap))
-	for iow := range mwc.writerMap {
-		mwc.writerSlice = append(mwc.writerSlice, iow)
+	snap := &fanOutSnapshot{
+		fanOutConfig: mwc.fanOutConfig,
+		members:      make([]*fanOutMember, 0, len(mwc.writerMap)),
+		writers:      make([]io.WriteCloser, 0, len(mwc.writerMap)),
+	}
+	for _, member := range mwc.writerMap {
+		snap.members = append(snap.members, member)
 	}
+	sort.Slice(snap.members, func(i, j int) bool { return snap.members[i].order < snap.members[j].order })
+	for _, member := range snap.members {
+		snap.writers = append(snap.writers, member.counters.stats.Writer)
+	}
+	mwc.snapshot.Store(snap)
+}
+
+// load returns the current snapshot.
+func (mwc *MultiWriteCloserFanOut) load() *fanOutSnapshot {
+	return mwc.snapshot.Load().(*fanOutSnapshot)
 }
 
 // Add adds an io.WriteCloser to the list of writers to be written to whenever this
 // MultiWriteCloserFanOut is written to.  It returns the number of io.WriteCloser instances attached
-// to the MultiWriteCloserFanOut instance.
+// to the MultiWriteCloserFanOut instance. When catch up is enabled, the retained recent bytes are
+// written to the new writer before it is added, and a writer that returns an error is closed
+// rather than added.
 //
 //   bb1 = gorill.NewNopCloseBuffer()
 //   mw = gorill.NewMultiWriteCloserFanOut(bb1)
@@ -59,20 +230,200 @@ func (mwc *MultiWriteCloserFanOut) update() {
 func (mwc *MultiWriteCloserFanOut) Add(w io.WriteCloser) int {
 	mwc.lock.Lock()
 	defer mwc.lock.Unlock()
+	mwc.seqLock.Lock()
+	defer mwc.seqLock.Unlock()
+
+	if mwc.admit(w) {
+		mwc.update()
+	}
+	return len(mwc.writerMap)
+}
+
+// AddWriter adds an io.Writer that must never be closed, such as os.Stdout, like Add, after
+// wrapping it with NopCloseWriter, so that neither Close nor the removal of a writer that returns
+// an error closes it. Because wrapping the same io.Writer again yields an equal value, it can be
+// removed with Remove(gorill.NopCloseWriter(w)).
+//
+//   mw = gorill.NewMultiWriteCloserFanOut(logFile)
+//   mw.AddWriter(os.Stderr)
+func (mwc *MultiWriteCloserFanOut) AddWriter(w io.Writer) int {
+	return mwc.Add(NopCloseWriter(w))
+}
 
-	mwc.writerMap[w] = struct{}{}
+// admit adds a writer to the map, after writing the catch up bytes to it, and returns true when it
+// was added. The caller must hold both locks, and invoke update.
+func (mwc *MultiWriteCloserFanOut) admit(w io.WriteCloser) bool {
+	if _, ok := mwc.writerMap[w]; ok {
+		return false
+	}
+	if mwc.catchUp != nil {
+		if recent := mwc.catchUp.Bytes(); len(recent) > 0 {
+			if err := mwc.catchUpMember(w, recent); err != nil {
+				atomic.AddInt64(&mwc.statEvicted, 1)
+				mwc.events.emit(EventError, w, err)
+				return false
+			}
+		}
+	}
+	if sw, ok := w.(SequencedWriteCloser); ok && mwc.sequenced {
+		sw.JoinSequence(atomic.LoadUint64(&mwc.sequence) + 1)
+	}
+	mwc.writerMap[w] = mwc.newMember(w)
+	mwc.events.emit(EventAdd, w, nil)
+	return true
+}
+
+// catchUpMember writes the catch up bytes to a writer being added, honoring the panic isolation
+// setting and the write timeout like a broadcast does, and closes the writer when that write fails,
+// once it returns. The caller must hold both locks.
+func (mwc *MultiWriteCloserFanOut) catchUpMember(w io.WriteCloser, recent []byte) error {
+	isolate, leaveOpen := mwc.isolate, mwc.leaveOpen
+	result := make(chan error, 1)
+	write := func() {
+		n, err := writeFanOutMember(w, isolate, 0, fanOutPayload{b: recent})
+		if err == nil && n < len(recent) {
+			err = io.ErrShortWrite
+		}
+		result <- err
+	}
+	var err error
+	if mwc.timeout <= 0 {
+		write()
+		err = <-result
+	} else {
+		go write()
+		timer := mwc.clock.NewTimer(mwc.timeout)
+		select {
+		case err = <-result:
+			timer.Stop()
+		case <-timer.C():
+			go func() {
+				<-result
+				mwc.closeMember(w, isolate, leaveOpen)
+			}()
+			return ErrTimeout(mwc.timeout)
+		}
+	}
+	if err != nil {
+		mwc.closeMember(w, isolate, leaveOpen)
+	}
+	return err
+}
+
+// ReplaceAll atomically replaces the list of writers with writers, so that every write is delivered
+// either to the previous list or to the new one, such as when reloading configuration. Writers in
+// both lists keep receiving writes without interruption, and writers only in the new list are added
+// like Add does. It returns the writers that were removed, without closing them.
+//
+//   removed := mw.ReplaceAll(sinks...)
+//   for _, w := range removed {
+//       w.Close()
+//   }
+func (mwc *MultiWriteCloserFanOut) ReplaceAll(writers ...io.WriteCloser) []io.WriteCloser {
+	mwc.lock.Lock()
+	defer mwc.lock.Unlock()
+	mwc.seqLock.Lock()
+	defer mwc.seqLock.Unlock()
+
+	keep := make(map[io.WriteCloser]struct{}, len(writers))
+	for _, w := range writers {
+		keep[w] = struct{}{}
+	}
+	var removed []io.WriteCloser
+	for w := range mwc.writerMap {
+		if _, ok := keep[w]; !ok {
+			mwc.drop(w)
+			mwc.forgetDialer(w)
+			removed = append(removed, w)
+		}
+	}
+	for _, w := range writers {
+		mwc.admit(w)
+	}
 	mwc.update()
-	return len(mwc.writerSlice)
+	return removed
 }
 
-// Close will close the underlying io.WriteCloser, and releases resources.
+// Close will close the underlying io.WriteCloser, and releases resources. When writers return
+// errors, the returned ErrList holds a MemberError identifying each writer that failed. Close waits
+// for writes in progress to return before closing the writers, and writes started while Close is in
+// progress wait for it to finish. Bytes collected while coalescing are written
+// before the writers are closed, and writers that buffer data, which have a Flush method returning
+// an error, such as SpooledWriteCloser and FileSink, are flushed before being closed. The writers
+// are closed in turn; see CloseWithTimeout to bound the time spent closing them.
 func (mwc *MultiWriteCloserFanOut) Close() error {
+	return mwc.CloseWithTimeout(0)
+}
+
+// CloseWithTimeout releases resources like Close, but closes the writers concurrently, and returns
+// once they have all closed or timeout elapses, whichever comes first, so that one writer whose
+// Close hangs, such as a network connection to an unresponsive peer, cannot block shutdown. The
+// returned ErrList holds a MemberError identifying each writer that returned an error, and each
+// writer that had not finished closing in time, with ErrTimeout. A timeout that is not greater
+// than 0 closes the writers in turn, without a time limit. The timeout does not include waiting for
+// writes in progress to return; see SetWriteTimeout to bound those.
+//
+//   if err := mw.CloseWithTimeout(5 * time.Second); err != nil {
+//       log.Printf("cannot close writers: %s", err)
+//   }
+func (mwc *MultiWriteCloserFanOut) CloseWithTimeout(timeout time.Duration) error {
+	mwc.lock.Lock()
+	coalescer := mwc.coalescer
+	mwc.coalescer = nil
+	mwc.update()
+	mwc.lock.Unlock()
+	if coalescer != nil {
+		// Flushing broadcasts, which may acquire the lock to evict writers.
+		coalescer.Close()
+	}
+
+	mwc.closeLock.Lock()
+	defer mwc.closeLock.Unlock()
 	mwc.lock.Lock()
 	defer mwc.lock.Unlock()
 
+	mwc.halt()
+	mwc.update()
+	writers := mwc.load().writers
 	var errors ErrList
-	for _, iowc := range mwc.writerSlice {
-		errors.Append(iowc.Close())
+	if timeout <= 0 {
+		for i, iowc := range writers {
+			err := flushAndClose(iowc)
+			mwc.events.emit(EventClose, iowc, err)
+			errors.AppendMember(i, iowc, err)
+		}
+		mwc.events.close()
+		return errors.Err()
+	}
+
+	type closeResult struct {
+		i   int
+		err error
+	}
+	results := make(chan closeResult, len(writers)) // stragglers never block
+	for i, iowc := range writers {
+		go func(i int, iowc io.WriteCloser) {
+			results <- closeResult{i, flushAndClose(iowc)}
+		}(i, iowc)
+	}
+	timer := mwc.clock.NewTimer(timeout)
+	defer timer.Stop()
+	defer mwc.events.close()
+	closed := make([]bool, len(writers))
+	for remaining := len(writers); remaining > 0; remaining-- {
+		select {
+		case r := <-results:
+			closed[r.i] = true
+			mwc.events.emit(EventClose, writers[r.i], r.err)
+			errors.AppendMember(r.i, writers[r.i], r.err)
+		case <-timer.C():
+			for i, iowc := range writers {
+				if !closed[i] {
+					errors.AppendMember(i, iowc, ErrTimeout(timeout))
+				}
+			}
+			return errors.Err()
+		}
 	}
 	return errors.Err()
 }
@@ -85,10 +436,36 @@ func (mwc *MultiWriteCloserFanOut) Close() error {
 //   mw.Add(gorill.NewNopCloseBuffer())
 //   count = mw.Count() // returns 2
 func (mwc *MultiWriteCloserFanOut) Count() int {
-	mwc.lock.RLock()
-	defer mwc.lock.RUnlock()
+	return len(mwc.load().writers)
+}
+
+// Writers returns a copy of the list of io.WriteCloser instances attached to the
+// MultiWriteCloserFanOut instance, in the order they were added, such as for monitoring which
+// destinations remain after writers that returned errors were removed.
+//
+//   for _, w := range mw.Writers() {
+//       log.Printf("writing to %v", w)
+//   }
+func (mwc *MultiWriteCloserFanOut) Writers() []io.WriteCloser {
+	return append([]io.WriteCloser(nil), mwc.load().writers...)
+}
 
-	return len(mwc.writerSlice)
+// WriterStats returns the statistics of each io.WriteCloser attached to the
+// MultiWriteCloserFanOut instance, in the order they were added. The statistics
+// of a writer are discarded when it is removed.
+//
+//   for _, ws := range mw.WriterStats() {
+//       if ws.LastErr != nil {
+//           log.Printf("%v: %d errors, most recently: %s", ws.Writer, ws.Errors, ws.LastErr)
+//       }
+//   }
+func (mwc *MultiWriteCloserFanOut) WriterStats() []WriterStats {
+	snap := mwc.load()
+	stats := make([]WriterStats, len(snap.members))
+	for i, member := range snap.members {
+		stats[i] = member.counters.snapshot()
+	}
+	return stats
 }
 
 // IsEmpty returns true if and only if there are no writers in the list of writers to be written to.
@@ -98,10 +475,7 @@ func (mwc *MultiWriteCloserFanOut) Count() int {
 //   mw.Add(gorill.NewNopCloseBuffer())
 //   mw.IsEmpty() // returns false
 func (mwc *MultiWriteCloserFanOut) IsEmpty() bool {
-	mwc.lock.RLock()
-	defer mwc.lock.RUnlock()
-
-	return len(mwc.writerSlice) == 0
+	return len(mwc.load().writers) == 0
 }
 
 // Remove removes an io.WriteCloser from the list of writers to be written to whenever this
@@ -116,13 +490,15 @@ func (mwc *MultiWriteCloserFanOut) Remove(w io.WriteCloser) int {
 	mwc.lock.Lock()
 	defer mwc.lock.Unlock()
 
-	delete(mwc.writerMap, w)
+	mwc.drop(w)
+	mwc.forgetDialer(w)
 	mwc.update()
-	return len(mwc.writerSlice)
+	return len(mwc.writerMap)
 }
 
-// Write writes the data to all the writers in the MultiWriteCloserFanOut.  It removes and invokes Close
-// method for all io.WriteClosers that returns an error when written to.
+// Write writes the data to all the writers in the MultiWriteCloserFanOut.  By default it removes and
+// invokes Close method for all io.WriteClosers that returns an error when written to; see
+// SetErrorPolicy for alternatives.
 //
 //   bb1 = gorill.NewNopCloseBuffer()
 //   bb2 = gorill.NewNopCloseBuffer()
@@ -135,36 +511,1083 @@ func (mwc *MultiWriteCloserFanOut) Remove(w io.WriteCloser) int {
 //   	t.Errorf("Actual: %#v; Expected: %#v", err, nil)
 //   }
 func (mwc *MultiWriteCloserFanOut) Write(data []byte) (int, error) {
-	mwc.lock.RLock()
-	defer mwc.lock.RUnlock()
+	for {
+		coalescer := mwc.load().coalescer
+		if coalescer == nil {
+			return mwc.WriteContext(context.Background(), data)
+		}
+		n, err := coalescer.Write(data)
+		if _, ok := err.(ErrWriteAfterClose); ok && mwc.load().coalescer != coalescer {
+			continue // coalescing was reconfigured while writing
+		}
+		return n, err
+	}
+}
+
+// Flush immediately broadcasts any bytes collected while coalescing.
+func (mwc *MultiWriteCloserFanOut) Flush() error {
+	return mwc.flush(context.Background())
+}
+
+// flush broadcasts any bytes collected while coalescing like Flush, but stops waiting for writers
+// that have not returned by the time ctx is done.
+func (mwc *MultiWriteCloserFanOut) flush(ctx context.Context) error {
+	if coalescer := mwc.load().coalescer; coalescer != nil {
+		if err := coalescer.flushContext(ctx); err != nil {
+			if _, ok := err.(ErrWriteAfterClose); !ok {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Per-write states of each io.WriteCloser during a broadcast.
+const (
+	fanOutPending   = iota // not yet claimed by a worker
+	fanOutWriting          // claimed by a worker, and being written
+	fanOutDone             // write has returned
+	fanOutAbandoned        // write had not returned when the context was done
+)
+
+// WriteContext writes data to all of the io.WriteCloser instances like Write,
+// but returns ctx.Err() as soon as ctx is done, rather than waiting for a stuck
+// io.WriteCloser to complete its write. Any io.WriteCloser whose write has not
+// returned by then is removed from the fan-out, and closed once its write
+// returns. When the concurrency limit prevents some io.WriteCloser instances
+// from starting their writes before ctx is done, they remain in the fan-out
+// but do not receive data. When coalescing, any bytes collected before data
+// are broadcast first, and ctx bounds that broadcast too.
+//
+//   ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+//   defer cancel()
+//   if _, err := mw.WriteContext(ctx, data); err != nil {
+//       log.Printf("abandoned slow writers: %s", err)
+//   }
+func (mwc *MultiWriteCloserFanOut) WriteContext(ctx context.Context, data []byte) (int, error) {
+	mwc.flush(ctx)
+	n, _, err := mwc.broadcast(ctx, fanOutPayload{b: data}, nil)
+	return n, err
+}
+
+// WriteString writes s to all of the io.WriteCloser instances like Write, passing it to each
+// io.WriteCloser that implements io.StringWriter without converting it to a byte slice. It is
+// converted once for the remaining writers.
+//
+//   mw.WriteString("request handled\n")
+func (mwc *MultiWriteCloserFanOut) WriteString(s string) (int, error) {
+	if mwc.load().coalescer != nil {
+		return mwc.Write([]byte(s))
+	}
+	n, _, err := mwc.broadcast(context.Background(), fanOutPayload{s: s, isString: true}, nil)
+	return n, err
+}
+
+// ReadFrom reads data from r until io.EOF or an error occurs, writing each chunk read to all of the
+// io.WriteCloser instances like Write, using a pooled buffer rather than requiring the caller to
+// stage the entire payload in memory. It returns the number of bytes read, and any error other than
+// io.EOF encountered while reading, or the first error returned by Write.
+//
+//   _, err := io.Copy(mw, resp.Body)
+func (mwc *MultiWriteCloserFanOut) ReadFrom(r io.Reader) (int64, error) {
+	bp := transferBuffers.Get().(*[]byte)
+	defer transferBuffers.Put(bp)
+	buf := *bp
+
+	var total int64
+	for {
+		nr, rerr := r.Read(buf)
+		if nr > 0 {
+			total += int64(nr)
+			if _, err := mwc.Write(buf[:nr]); err != nil {
+				return total, err
+			}
+		}
+		if rerr == io.EOF {
+			return total, nil
+		}
+		if rerr != nil {
+			return total, rerr
+		}
+	}
+}
+
+// WriteReport writes data to all of the io.WriteCloser instances like Write, but also returns an
+// ErrList holding a MemberError for each writer that failed, and why, regardless of the error
+// policy. The ErrList is empty when every writer succeeded.
+//
+//   _, failures := mw.WriteReport(data)
+//   for _, f := range failures.Failures() {
+//       log.Printf("writer %d (%s) failed: %s", f.Index, f.Name, f.Err)
+//   }
+func (mwc *MultiWriteCloserFanOut) WriteReport(data []byte) (int, ErrList) {
+	mwc.Flush()
+	n, errs, _ := mwc.broadcast(context.Background(), fanOutPayload{b: data}, nil)
+	return n, errs
+}
+
+// FanOutMemberOutcome describes what happened to a single writer during WriteSeriesReport.
+type FanOutMemberOutcome struct {
+	Writer io.WriteCloser
+	N      int   // N is the number of bytes the writer accepted
+	Err    error // Err is the error returned by the writer, or nil when it accepted all the data
+}
+
+// WriteSeries writes data to all of the io.WriteCloser instances like Write, but to each in turn,
+// in the order they were added, regardless of the concurrency limit, so that callers that need a
+// deterministic sequential fan-out, such as writing to a primary before its replicas, get one.
+//
+//   mw = gorill.NewMultiWriteCloserFanOut(primary)
+//   mw.Add(replica)
+//   _, err := mw.WriteSeries(record) // writes to primary, then replica
+func (mwc *MultiWriteCloserFanOut) WriteSeries(data []byte) (int, error) {
+	n, _, err := mwc.WriteSeriesReport(data)
+	return n, err
+}
+
+// WriteSeriesReport writes data to all of the io.WriteCloser instances like WriteSeries, but also
+// returns the outcome of each writer, in the order they were written to, so that callers can act on
+// which writers succeeded and which failed, and why.
+//
+//   _, outcomes, _ := mw.WriteSeriesReport(record)
+//   for _, o := range outcomes {
+//       if o.Err != nil {
+//           log.Printf("%v accepted %d bytes: %s", o.Writer, o.N, o.Err)
+//       }
+//   }
+func (mwc *MultiWriteCloserFanOut) WriteSeriesReport(data []byte) (int, []FanOutMemberOutcome, error) {
+	mwc.Flush()
+	var outcomes []FanOutMemberOutcome
+	n, _, err := mwc.broadcast(context.Background(), fanOutPayload{b: data}, &outcomes)
+	return n, outcomes, err
+}
+
+// broadcast writes data to all of the io.WriteCloser instances, returning the number of bytes
+// reported to the caller, the errors returned by individual writers, and the error reported to the
+// caller under the error policy. When outcomes is not nil, the writers are written to in turn, in
+// the order they were added, and outcomes is set to the outcome of each.
+func (mwc *MultiWriteCloserFanOut) broadcast(ctx context.Context, data fanOutPayload, outcomes *[]FanOutMemberOutcome) (int, ErrList, error) {
+	// Callbacks run after any lock is released, so they may add or remove writers.
+	var failed, removed []fanOutFailure
+	var onError, onRemove func(io.WriteCloser, error)
+	defer func() {
+		if onError != nil {
+			for _, f := range failed {
+				onError(f.w, f.err)
+			}
+		}
+		for _, f := range removed {
+			onRemove(f.w, f.err)
+		}
+	}()
+	mwc.closeLock.RLock()
+	defer mwc.closeLock.RUnlock() // before the callbacks, which may invoke Close
 
 	// NOTE: the complexity of wait group and go routines does not
-	// solve the slow writer problem, but it helps
-	var lock sync.Mutex
+	// solve the slow writer problem, but it helps; AsyncMultiWriteCloser
+	// solves it by giving each writer its own queue
+	var seq uint64
+	var locked bool // locked is set while holding seqLock
+	snap := mwc.load()
+	if snap.sequenced || snap.catchUp != nil {
+		// Exclude Add, so a writer being added either receives the catch up
+		// bytes that include this write, or receives this write, and while
+		// sequencing, deliver one write at a time.
+		mwc.seqLock.Lock()
+		locked = true
+		snap = mwc.load()
+		if snap.sequenced {
+			seq = atomic.AddUint64(&mwc.sequence, 1)
+		}
+	}
+
+	writers, members := snap.writers, snap.members
+	if data.isString && data.b == nil && (snap.catchUp != nil || !allStringWriters(writers, seq > 0)) {
+		data.b = []byte(data.s) // convert once for all writers that need a byte slice
+	}
+	if snap.catchUp != nil {
+		snap.catchUp.Write(data.b)
+	}
+	if locked && !snap.sequenced {
+		mwc.seqLock.Unlock()
+		locked = false
+	}
+
+	isolate := snap.isolate
+	leaveOpen := snap.leaveOpen
+	policy := snap.policy
+	timeout := snap.timeout
+	onError, onRemove = snap.onError, snap.onRemove
+	if timeout > 0 && !data.isString {
+		// A hung write may still be reading data after this method returns.
+		data.b = append([]byte(nil), data.b...)
+	}
+
+	// deliver writes data to a single writer, retrying as permitted by the error policy, and
+	// records the outcome in the writer's counters.
+	deliver := func(w io.WriteCloser, member *fanOutMember) (int, error) {
+		member.pingLock.RLock()
+		defer member.pingLock.RUnlock()
+		started := mwc.clock.Now()
+		var written int
+		for attempt := 0; ; attempt++ {
+			n, err := writeFanOutMember(w, isolate, seq, data.from(written))
+			written += n
+			if err == nil && written != data.len() {
+				err = io.ErrShortWrite
+			}
+			if err == nil || attempt == policy.retries {
+				member.counters.record(written, err, mwc.clock.Now().Sub(started))
+				return written, err
+			}
+		}
+	}
+
+	end := startOperation(snap.hook, OpBroadcast)
+	var lock sync.Mutex // lock protects the following variables
+	var wg sync.WaitGroup
+	var errored, hung []io.WriteCloser
+	var errs ErrList
+	var cancelled bool
+	var next int // next is the index of the next writer to be claimed by a worker
+	states := make([]int, len(writers))
+	workers := len(writers)
+	if outcomes != nil {
+		*outcomes = make([]FanOutMemberOutcome, len(writers))
+		if workers > 1 {
+			workers = 1
+		}
+	} else if snap.concurrency > 0 && snap.concurrency < workers {
+		workers = snap.concurrency
+	}
+	// write writes to the writer at index i, after the caller has claimed it.
+	write := func(i int) {
+		w := writers[i]
+		var n int
+		var err error
+		var timedOut bool
+		if timeout > 0 {
+			type delivery struct {
+				n   int
+				err error
+			}
+			result := make(chan delivery, 1)
+			go func() {
+				n, err := deliver(w, members[i])
+				result <- delivery{n, err}
+			}()
+			timer := mwc.clock.NewTimer(timeout)
+			select {
+			case d := <-result:
+				n, err = d.n, d.err
+				timer.Stop()
+			case <-timer.C():
+				err, timedOut = ErrTimeout(timeout), true
+				go func() {
+					<-result
+					mwc.closeMember(w, isolate, leaveOpen)
+				}()
+			}
+		} else {
+			n, err = deliver(w, members[i])
+		}
+
+		lock.Lock()
+		if outcomes != nil {
+			(*outcomes)[i] = FanOutMemberOutcome{Writer: w, N: n, Err: err}
+		}
+		abandoned := states[i] == fanOutAbandoned
+		states[i] = fanOutDone
+		if err != nil && !abandoned {
+			evict := timedOut || !policy.keep
+			if timedOut {
+				hung = append(hung, w)
+			} else if evict {
+				errored = append(errored, w)
+			}
+			if onError != nil || onRemove != nil {
+				failed = append(failed, fanOutFailure{w, err, evict})
+			}
+			errs.AppendMember(i, w, err)
+			mwc.events.emit(EventError, w, err)
+		}
+		lock.Unlock()
+
+		if abandoned && !timedOut {
+			mwc.closeMember(w, isolate, leaveOpen)
+		}
+	}
+	worker := func() {
+		defer wg.Done()
+		for {
+			lock.Lock()
+			if cancelled || next >= len(writers) {
+				lock.Unlock()
+				return
+			}
+			i := next
+			next++
+			states[i] = fanOutWriting
+			lock.Unlock()
+			write(i)
+		}
+	}
+	wg.Add(workers)
+	if workers == 1 && ctx.Done() == nil {
+		worker() // avoid the cost of a go-routine when writing serially
+	} else if pool := snap.pool; pool != nil {
+	dispatch:
+		for i := 0; i < workers; i++ {
+			select {
+			case pool.jobs <- worker:
+			case <-pool.stop:
+				go worker() // the pool was stopped by a concurrent change
+			case <-ctx.Done():
+				wg.Add(i - workers) // these workers were never dispatched
+				break dispatch
+			}
+		}
+	} else if snap.concurrency == 0 {
+		for i, member := range members {
+			i := i
+			member.dispatch(func() {
+				defer wg.Done()
+				lock.Lock()
+				if cancelled {
+					lock.Unlock()
+					return
+				}
+				states[i] = fanOutWriting
+				lock.Unlock()
+				write(i)
+			})
+		}
+	} else {
+		for i := 0; i < workers; i++ {
+			go worker()
+		}
+	}
+
+	var abandoned []io.WriteCloser
+	if done := ctx.Done(); done == nil {
+		wg.Wait()
+	} else {
+		finished := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(finished)
+		}()
+		select {
+		case <-finished:
+		case <-done:
+			lock.Lock()
+			cancelled = true
+			for i, state := range states {
+				if state == fanOutWriting {
+					states[i] = fanOutAbandoned
+					abandoned = append(abandoned, writers[i])
+				}
+			}
+			lock.Unlock()
+		}
+	}
+
+	if locked {
+		mwc.seqLock.Unlock() // before taking the lock, which Add takes first
+	}
+
+	lock.Lock()
+	err := errs.Err()
+	if cancelled {
+		err = ctx.Err()
+	}
+	end(data.len(), err)
+	atomic.AddInt64(&mwc.statWrites, 1)
+	atomic.AddInt64(&mwc.statBytes, int64(data.len()))
+	if evicted := len(errored) + len(hung) + len(abandoned); evicted > 0 {
+		atomic.AddInt64(&mwc.statEvicted, int64(evicted))
+		mwc.lock.Lock()
+		for _, w := range errored {
+			if _, ok := mwc.writerMap[w]; ok {
+				mwc.drop(w)
+				mwc.closeMember(w, isolate, leaveOpen)
+				mwc.redial(w)
+			}
+		}
+		for _, w := range hung {
+			mwc.drop(w) // closed once its write returns
+			mwc.redial(w)
+		}
+		for _, w := range abandoned {
+			mwc.events.emit(EventError, w, ctx.Err())
+			mwc.drop(w)
+			mwc.redial(w)
+		}
+		mwc.update()
+		mwc.lock.Unlock()
+		if onRemove != nil {
+			for _, f := range failed {
+				if f.evicted {
+					removed = append(removed, f)
+				}
+			}
+			for _, w := range abandoned {
+				removed = append(removed, fanOutFailure{w, ctx.Err(), true})
+			}
+		}
+	}
+	lock.Unlock()
+
+	if cancelled {
+		return 0, errs, ctx.Err()
+	}
+	if succeeded := len(writers) - errs.Count(); succeeded < snap.quorum {
+		return data.len(), errs, ErrQuorum{Required: snap.quorum, Succeeded: succeeded, Errs: errs}
+	}
+	if policy.keep {
+		return data.len(), errs, errs.Err()
+	}
+	return data.len(), errs, nil
+}
+
+// fanOutFailure is an io.WriteCloser reported to a callback, with its error.
+type fanOutFailure struct {
+	w       io.WriteCloser
+	err     error
+	evicted bool // evicted is set when the writer was removed from the fan-out
+}
+
+// fanOutPayload is the data broadcast by a write, held as a string when written with WriteString,
+// along with its conversion to a byte slice when any writer requires one.
+type fanOutPayload struct {
+	b        []byte
+	s        string
+	isString bool
+}
+
+func (p fanOutPayload) len() int {
+	if p.isString {
+		return len(p.s)
+	}
+	return len(p.b)
+}
+
+// from returns the portion of the payload starting at offset.
+func (p fanOutPayload) from(offset int) fanOutPayload {
+	if p.isString {
+		p.s = p.s[offset:]
+		if p.b != nil {
+			p.b = p.b[offset:]
+		}
+		return p
+	}
+	p.b = p.b[offset:]
+	return p
+}
+
+// allStringWriters returns true when every writer accepts a string payload without conversion.
+func allStringWriters(writers []io.WriteCloser, sequenced bool) bool {
+	for _, w := range writers {
+		if _, ok := w.(io.StringWriter); !ok {
+			return false
+		}
+		if _, ok := w.(SequencedWriteCloser); ok && sequenced {
+			return false
+		}
+	}
+	return true
+}
+
+// flushAndClose flushes w when it has a Flush method, then closes it, and returns the errors from
+// both.
+func flushAndClose(w io.WriteCloser) error {
+	var errors ErrList
+	if f, ok := w.(interface{ Flush() error }); ok {
+		errors.Append(f.Flush())
+	}
+	errors.Append(w.Close())
+	return errors.Err()
+}
+
+// closeMember closes an io.WriteCloser evicted from the fan-out, unless evicted writers are left
+// open.
+func (mwc *MultiWriteCloserFanOut) closeMember(w io.WriteCloser, isolate, leaveOpen bool) {
+	if leaveOpen {
+		return
+	}
+	var err error
+	if isolate {
+		err = NewSafeWriteCloser(w).Close()
+	} else {
+		err = w.Close() // BUG might cause bug when client tries to later Close ???
+	}
+	mwc.events.emit(EventClose, w, err)
+}
+
+// writeFanOutMember writes data to a single writer, honoring the panic isolation
+// setting, and when seq is not 0, the sequence number assigned to the write.
+func writeFanOutMember(w io.WriteCloser, isolate bool, seq uint64, data fanOutPayload) (n int, err error) {
+	if isolate {
+		defer func() {
+			if r := recover(); r != nil {
+				n, err = 0, ErrPanic{Value: r}
+			}
+		}()
+	}
+	if sw, ok := w.(SequencedWriteCloser); ok && seq > 0 {
+		return sw.WriteSequenced(seq, data.b)
+	}
+	if data.isString {
+		if sw, ok := w.(io.StringWriter); ok {
+			return sw.WriteString(data.s)
+		}
+	}
+	return w.Write(data.b)
+}
+
+// Sequence returns the sequence number assigned to the most recent write while sequencing is
+// enabled, or 0 when no write has been sequenced.
+func (mwc *MultiWriteCloserFanOut) Sequence() uint64 {
+	return atomic.LoadUint64(&mwc.sequence)
+}
+
+// SetSequencing determines whether each write is assigned a monotonically increasing sequence
+// number, starting at 1. While sequencing is enabled, writes are delivered one at a time, so every
+// writer receives them in sequence order, and writers that implement SequencedWriteCloser receive
+// each sequence number along with the data, allowing them to detect gaps and duplicates.
+func (mwc *MultiWriteCloserFanOut) SetSequencing(enabled bool) {
+	mwc.lock.Lock()
+	defer mwc.lock.Unlock()
+	defer mwc.update()
+
+	mwc.sequenced = enabled
+}
+
+// SetCatchUp causes the MultiWriteCloserFanOut to retain the most recent bytes written to it, and to
+// write them to each newly added writer before it receives any subsequent writes, so that a live
+// tail subscriber starts with some context rather than in the middle of a line. Up to maxBytes
+// bytes are retained when maxBytes is positive, and up to maxLines complete lines, plus any partial
+// final line, are retained when maxLines is positive. When both are positive, the smaller amount is
+// retained. When neither is positive, catch up is disabled. The catch up write honors
+// SetIsolatePanics and SetWriteTimeout like a broadcast, and a writer whose catch up write fails or
+// is short is not added, and is closed like an evicted writer.
+func (mwc *MultiWriteCloserFanOut) SetCatchUp(maxBytes, maxLines int) {
+	mwc.lock.Lock()
+	defer mwc.lock.Unlock()
+	defer mwc.update()
+
+	if maxBytes <= 0 && maxLines <= 0 {
+		mwc.catchUp = nil
+		return
+	}
+	mwc.catchUp = &tailBuffer{maxBytes: maxBytes, maxLines: maxLines}
+}
+
+// SetConcurrency limits the number of go-routines that write to the writers concurrently. The
+// default, 0, writes to every writer concurrently from a long-lived go-routine dedicated to it,
+// which runs until the writer is removed or until Close, and minimizes the latency added by slow
+// writers. A write finding that go-routine still busy with a previous write uses a new go-routine.
+// A limit of 1 writes to each writer in turn from the calling go-routine, which avoids go-routine
+// overhead when the writers are fast, such as buffers and local files. A greater limit starts a
+// fixed pool of that many worker go-routines, shared by all writes, which runs until Close or until
+// the limit is changed.
+func (mwc *MultiWriteCloserFanOut) SetConcurrency(limit int) {
+	mwc.lock.Lock()
+	defer mwc.lock.Unlock()
+	defer mwc.update()
+
+	if limit < 0 {
+		limit = 0
+	}
+	mwc.concurrency = limit
+	mwc.stopPool()
+	if limit > 1 {
+		pool := &fanOutPool{jobs: make(chan func()), stop: make(chan struct{})}
+		for i := 0; i < limit; i++ {
+			go func() {
+				for {
+					select {
+					case job := <-pool.jobs:
+						job()
+					case <-pool.stop:
+						return
+					}
+				}
+			}()
+		}
+		mwc.pool = pool
+	}
+}
+
+// halt stops the go-routines of the MultiWriteCloserFanOut: the worker pool, the health check
+// prober, the dialers, and the go-routine of each writer. It does not close the writers. The
+// caller must hold the lock, and invoke update.
+func (mwc *MultiWriteCloserFanOut) halt() {
+	mwc.stopPool()
+	mwc.stopProber()
+	for d := range mwc.dialers {
+		close(d.stop)
+		delete(mwc.dialers, d)
+	}
+	for _, member := range mwc.writerMap {
+		member.halt()
+	}
+}
+
+// stopPool stops the worker pool, if any, once its running jobs finish. Writes that still use the
+// pool start their own go-routines instead. The caller must hold the lock.
+func (mwc *MultiWriteCloserFanOut) stopPool() {
+	if mwc.pool != nil {
+		close(mwc.pool.stop)
+		mwc.pool = nil
+	}
+}
+
+// SetMinimumSuccess causes each write to return ErrQuorum unless at least count writers accepted
+// all of its data, such as when fanning out to replicated sinks where best effort is not
+// acceptable. Writers that fail are otherwise treated according to the error policy. Because
+// collected bytes are broadcast after Write returns while coalescing, the quorum is not enforced on
+// them. A count that is not greater than 0, the default, requires no writers to succeed.
+//
+//   mw.SetMinimumSuccess(2)
+//   if _, err := mw.Write(record); err != nil {
+//       return err // fewer than two replicas have the record
+//   }
+func (mwc *MultiWriteCloserFanOut) SetMinimumSuccess(count int) {
+	mwc.lock.Lock()
+	defer mwc.lock.Unlock()
+	defer mwc.update()
+
+	if count < 0 {
+		count = 0
+	}
+	mwc.quorum = count
+}
+
+// ErrQuorum is returned by a MultiWriteCloserFanOut write when fewer writers accepted the data than
+// required by SetMinimumSuccess.
+type ErrQuorum struct {
+	// Required is the number of writers that must accept each write.
+	Required int
+
+	// Succeeded is the number of writers that accepted the write.
+	Succeeded int
+
+	// Errs holds a MemberError for each writer that failed.
+	Errs ErrList
+}
+
+// Error returns a string representation of a ErrQuorum error instance.
+func (e ErrQuorum) Error() string {
+	if len(e.Errs) == 0 {
+		return fmt.Sprintf("quorum not reached: %d of %d required writers succeeded", e.Succeeded, e.Required)
+	}
+	return fmt.Sprintf("quorum not reached: %d of %d required writers succeeded: %s", e.Succeeded, e.Required, e.Errs)
+}
+
+// SetHealthCheck starts a background go-routine that writes ping to each writer every interval,
+// so that dead destinations, such as network connections closed by their peer, are detected
+// between writes rather than by the next write. A nil ping performs a zero-byte write. A writer
+// whose ping returns an error is removed from the fan-out regardless of the error policy, closed
+// unless disabled by SetCloseEvicted, and reported to the callbacks registered with SetOnError and
+// SetOnRemove. Pings are written to each writer from the go-routine that writes to it, and never
+// overlap a write to that writer, so ping need only be harmless to each destination, such as an
+// empty line or a protocol keep-alive message. The prober runs until Close
+// or until health checks are changed. An interval that is not greater than 0 stops health checks.
+//
+//   mw.SetHealthCheck(30*time.Second, nil)
+func (mwc *MultiWriteCloserFanOut) SetHealthCheck(interval time.Duration, ping []byte) {
+	mwc.lock.Lock()
+	defer mwc.lock.Unlock()
+	defer mwc.update()
+
+	mwc.stopProber()
+	if interval > 0 {
+		stop := make(chan struct{})
+		ping = append([]byte(nil), ping...)
+		go func() {
+			ticker := mwc.clock.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C():
+					mwc.probe(ping, stop)
+				case <-stop:
+					return
+				}
+			}
+		}()
+		mwc.probeStop = stop
+	}
+}
+
+// stopProber stops the health check prober, if any. The caller must hold the lock, and invoke
+// update.
+func (mwc *MultiWriteCloserFanOut) stopProber() {
+	if mwc.probeStop != nil {
+		close(mwc.probeStop)
+		mwc.probeStop = nil
+	}
+}
+
+// probe writes ping to each writer, and removes the writers that fail, unless the prober was
+// stopped.
+func (mwc *MultiWriteCloserFanOut) probe(ping []byte, stop chan struct{}) {
+	mwc.closeLock.RLock()
+	select {
+	case <-stop:
+		mwc.closeLock.RUnlock()
+		return // stopped, possibly by Close, while waiting for the lock
+	default:
+	}
+
+	snap := mwc.load()
+	var lock sync.Mutex // lock protects failed
+	var failed []fanOutFailure
 	var wg sync.WaitGroup
-	var errored []io.WriteCloser
-	wg.Add(len(mwc.writerSlice))
-	for _, sw := range mwc.writerSlice {
-		go func(w io.WriteCloser) {
-			n, err := w.Write(data)
-			if n != len(data) {
+	wg.Add(len(snap.writers))
+	for i, w := range snap.writers {
+		w, member := w, snap.members[i]
+		member.dispatch(func() {
+			defer wg.Done()
+			member.pingLock.Lock()
+			defer member.pingLock.Unlock()
+			started := mwc.clock.Now()
+			n, err := writeFanOutMember(w, snap.isolate, 0, fanOutPayload{b: ping})
+			if err == nil && n != len(ping) {
 				err = io.ErrShortWrite
 			}
+			member.counters.record(n, err, mwc.clock.Now().Sub(started))
 			if err != nil {
+				mwc.events.emit(EventError, w, err)
 				lock.Lock()
-				errored = append(errored, w)
+				failed = append(failed, fanOutFailure{w, err, true})
 				lock.Unlock()
 			}
-			wg.Done()
-		}(sw)
+		})
 	}
 	wg.Wait()
-	if len(errored) > 0 {
-		for _, w := range errored {
-			delete(mwc.writerMap, w)
-			w.Close() // BUG might cause bug when client tries to later Close ???
+	if len(failed) == 0 {
+		mwc.closeLock.RUnlock()
+		return
+	}
+
+	atomic.AddInt64(&mwc.statEvicted, int64(len(failed)))
+	mwc.lock.Lock()
+	for _, f := range failed {
+		if _, ok := mwc.writerMap[f.w]; ok {
+			mwc.drop(f.w)
+			mwc.closeMember(f.w, snap.isolate, snap.leaveOpen)
+			mwc.redial(f.w)
 		}
+	}
+	mwc.update()
+	mwc.lock.Unlock()
+	mwc.closeLock.RUnlock()
+
+	// Callbacks run after the locks are released, so they may add or remove writers, or Close.
+	for _, f := range failed {
+		if snap.onError != nil {
+			snap.onError(f.w, f.err)
+		}
+		if snap.onRemove != nil {
+			snap.onRemove(f.w, f.err)
+		}
+	}
+}
+
+// FanOutErrorPolicy determines how a MultiWriteCloserFanOut treats an io.WriteCloser whose Write
+// returns an error.
+type FanOutErrorPolicy struct {
+	keep    bool // keep the writer in the fan-out and report its error from Write
+	retries int  // retries is the number of times a failed write is retried
+}
+
+// RemoveOnError is the default FanOutErrorPolicy. An io.WriteCloser whose Write returns an error is
+// removed from the fan-out and closed, and Write does not return the error.
+var RemoveOnError = FanOutErrorPolicy{}
+
+// KeepAndReport is a FanOutErrorPolicy that keeps an io.WriteCloser whose Write returns an error in
+// the fan-out, and returns the error from Write, as an ErrList identifying each failed writer.
+var KeepAndReport = FanOutErrorPolicy{keep: true}
+
+// RetryN returns a FanOutErrorPolicy that retries a failed write to an io.WriteCloser up to count
+// times, writing any bytes not yet written, before removing it from the fan-out and closing it like
+// RemoveOnError.
+func RetryN(count int) FanOutErrorPolicy {
+	if count < 0 {
+		count = 0
+	}
+	return FanOutErrorPolicy{retries: count}
+}
+
+// SetErrorPolicy determines how subsequent writes treat an io.WriteCloser whose Write returns an
+// error. The default, RemoveOnError, removes and closes the writer at its first error, so a
+// transient error permanently evicts it.
+func (mwc *MultiWriteCloserFanOut) SetErrorPolicy(policy FanOutErrorPolicy) {
+	mwc.lock.Lock()
+	defer mwc.lock.Unlock()
+	defer mwc.update()
+
+	mwc.policy = policy
+}
+
+// SetOnError causes callback to be invoked with each io.WriteCloser whose Write returns an error,
+// and that error, after any retries permitted by the error policy. It is invoked after the write
+// completes, from the go-routine that called Write, and may add or remove writers.
+func (mwc *MultiWriteCloserFanOut) SetOnError(callback func(io.WriteCloser, error)) {
+	mwc.lock.Lock()
+	defer mwc.lock.Unlock()
+	defer mwc.update()
+
+	mwc.onError = callback
+}
+
+// SetOnRemove causes callback to be invoked with each io.WriteCloser removed from the fan-out
+// during a write, either because its Write returned an error or did not return within the write
+// timeout, or because WriteContext abandoned it, along with the reason. It is invoked after the
+// writer has been removed, so a callback may re-dial and add a replacement.
+//
+//   mw.SetOnRemove(func(w io.WriteCloser, err error) {
+//       log.Printf("removed writer: %s", err)
+//       if conn, err := net.Dial("tcp", address); err == nil {
+//           mw.Add(conn)
+//       }
+//   })
+func (mwc *MultiWriteCloserFanOut) SetOnRemove(callback func(io.WriteCloser, error)) {
+	mwc.lock.Lock()
+	defer mwc.lock.Unlock()
+	defer mwc.update()
+
+	mwc.onRemove = callback
+}
+
+// SetCloseEvicted determines whether an io.WriteCloser removed from the fan-out because its Write
+// returned an error, timed out, or was abandoned, is closed. It is by default. Disable it when the
+// caller retains ownership of its writers, such as to reconnect and add them again, typically from
+// a callback registered with SetOnRemove.
+func (mwc *MultiWriteCloserFanOut) SetCloseEvicted(enabled bool) {
+	mwc.lock.Lock()
+	defer mwc.lock.Unlock()
+	defer mwc.update()
+
+	mwc.leaveOpen = !enabled
+}
+
+// SetIsolatePanics determines whether a panic raised by a writer's Write or Close method is
+// recovered. When enabled, a writer that panics is treated like one that returns an error: it is
+// removed and closed, and the panic is reported as an ErrPanic to the operation hook, so that one
+// misbehaving writer cannot crash the program.
+func (mwc *MultiWriteCloserFanOut) SetIsolatePanics(enabled bool) {
+	mwc.lock.Lock()
+	defer mwc.lock.Unlock()
+	defer mwc.update()
+
+	mwc.isolate = enabled
+}
+
+// SetOperationHook causes each subsequent write to be reported to hook as an OpBroadcast
+// operation. The error reported is the aggregate of the errors returned by writers that were
+// removed because of them. A nil hook disables reporting.
+func (mwc *MultiWriteCloserFanOut) SetOperationHook(hook OperationHook) {
+	mwc.lock.Lock()
+	defer mwc.lock.Unlock()
+	defer mwc.update()
+
+	mwc.hook = hook
+}
+
+// SetWriteTimeout limits the time each writer is allowed to complete a write. A writer whose write
+// has not returned within timeout is treated as having failed with ErrTimeout: it is removed from
+// the fan-out regardless of the error policy, and closed once its write eventually returns, so that
+// one hung writer cannot stall every subsequent write. While a timeout is set, each write copies
+// data, because a hung writer may still read it after Write returns. A timeout that is not greater
+// than 0 removes the limit.
+//
+//   mw.SetWriteTimeout(5 * time.Second)
+func (mwc *MultiWriteCloserFanOut) SetWriteTimeout(timeout time.Duration) {
+	mwc.lock.Lock()
+	defer mwc.lock.Unlock()
+	defer mwc.update()
+
+	if timeout < 0 {
+		timeout = 0
+	}
+	mwc.timeout = timeout
+}
+
+// Stats returns the number of writers, the number of writes and bytes written,
+// and the number of writers removed because they returned an error.
+func (mwc *MultiWriteCloserFanOut) Stats() map[string]int64 {
+	return map[string]int64{
+		"bytes":   atomic.LoadInt64(&mwc.statBytes),
+		"evicted": atomic.LoadInt64(&mwc.statEvicted),
+		"writers": int64(mwc.Count()),
+		"writes":  atomic.LoadInt64(&mwc.statWrites),
+	}
+}
+
+// SetCoalescing causes small writes to be collected for up to window, or until maxBytes bytes have
+// been collected, and broadcast to the writers as a single write, which reduces the number of
+// system calls made on behalf of chatty producers, such as loggers that write each line
+// separately, at the cost of the added latency. Writes of at least maxBytes bytes are broadcast
+// immediately, and WriteContext and WriteReport broadcast any collected bytes before their own, to
+// preserve order. Because collected bytes are broadcast after Write returns, errors writing them
+// are not returned to the caller, but are handled by the error policy and reported to the callbacks
+// registered with SetOnError and SetOnRemove. A window or maxBytes that is not greater than 0
+// disables coalescing, after broadcasting any collected bytes.
+//
+//   mw.SetCoalescing(time.Millisecond, 4096)
+func (mwc *MultiWriteCloserFanOut) SetCoalescing(window time.Duration, maxBytes int) {
+	mwc.lock.Lock()
+	previous := mwc.coalescer
+	mwc.coalescer = nil
+	if window > 0 && maxBytes > 0 {
+		// Arguments were validated above, so the constructor cannot fail.
+		mwc.coalescer, _ = NewCoalescingWriter(fanOutSink{mwc}, CoalesceWindow(window), CoalesceThreshold(maxBytes), CoalesceClock(mwc.clock))
+	}
+	mwc.update()
+	mwc.lock.Unlock()
+
+	if previous != nil {
+		// Flushing broadcasts, which may acquire the lock to evict writers.
+		previous.Close()
+	}
+}
+
+// DefaultRedialBackoff is the default delay before a MultiWriteCloserFanOut first attempts to
+// re-create a writer added with AddFactory after it was removed.
+const DefaultRedialBackoff = 100 * time.Millisecond
+
+// DefaultMaxRedialBackoff is the default longest delay between attempts of a
+// MultiWriteCloserFanOut to re-create a writer added with AddFactory.
+const DefaultMaxRedialBackoff = 30 * time.Second
+
+// fanOutDialer re-creates a writer added with AddFactory after it is removed because of an error.
+type fanOutDialer struct {
+	dial func() (io.WriteCloser, error)
+	stop chan struct{}  // stop is closed when the writer is removed by the caller, or by Close
+	w    io.WriteCloser // w is the current writer, or nil while it is being re-created
+}
+
+// AddFactory adds the io.WriteCloser returned by dial to the list of writers like Add does, and
+// whenever that writer is removed from the fan-out because its Write returned an error, did not
+// return in time, or failed a health check, invokes dial again from a background go-routine to
+// re-create it and add it back, waiting between failed attempts with exponential backoff as
+// configured by SetRedialBackoff. Writes made while the writer is being re-created are not
+// delivered to it. Removing the writer with Remove or ReplaceAll, or invoking Close, stops it from
+// being re-created. It returns the number of io.WriteCloser instances attached to the
+// MultiWriteCloserFanOut instance, and the error returned by the first invocation of dial, in
+// which case nothing is added.
+//
+//   _, err := mw.AddFactory(func() (io.WriteCloser, error) {
+//       return net.Dial("tcp", address)
+//   })
+func (mwc *MultiWriteCloserFanOut) AddFactory(dial func() (io.WriteCloser, error)) (int, error) {
+	w, err := dial()
+	if err != nil {
+		return mwc.Count(), err
+	}
+
+	mwc.lock.Lock()
+	defer mwc.lock.Unlock()
+	mwc.seqLock.Lock()
+	defer mwc.seqLock.Unlock()
+
+	d := &fanOutDialer{dial: dial, stop: make(chan struct{})}
+	mwc.dialers[d] = struct{}{}
+	if _, ok := mwc.writerMap[w]; ok || mwc.admit(w) {
+		d.w = w
 		mwc.update()
+	} else {
+		go mwc.redialLoop(d, mwc.redialMin, mwc.redialMax)
+	}
+	return len(mwc.writerMap), nil
+}
+
+// SetRedialBackoff determines how long the MultiWriteCloserFanOut waits before attempting to
+// re-create a writer added with AddFactory, starting at initial, and doubling after each failed
+// attempt up to maximum. Values that are not greater than 0 select DefaultRedialBackoff and
+// DefaultMaxRedialBackoff respectively.
+func (mwc *MultiWriteCloserFanOut) SetRedialBackoff(initial, maximum time.Duration) {
+	mwc.lock.Lock()
+	defer mwc.lock.Unlock()
+	defer mwc.update()
+
+	if initial <= 0 {
+		initial = DefaultRedialBackoff
+	}
+	if maximum <= 0 {
+		maximum = DefaultMaxRedialBackoff
+	}
+	if maximum < initial {
+		maximum = initial
+	}
+	mwc.redialMin, mwc.redialMax = initial, maximum
+}
+
+// redial starts re-creating w after it was removed because of an error, when it was added with
+// AddFactory. The caller must hold the lock.
+func (mwc *MultiWriteCloserFanOut) redial(w io.WriteCloser) {
+	for d := range mwc.dialers {
+		if d.w == w {
+			d.w = nil
+			go mwc.redialLoop(d, mwc.redialMin, mwc.redialMax)
+			return
+		}
+	}
+}
+
+// forgetDialer stops re-creating w, when it was added with AddFactory. The caller must hold the
+// lock.
+func (mwc *MultiWriteCloserFanOut) forgetDialer(w io.WriteCloser) {
+	for d := range mwc.dialers {
+		if d.w == w {
+			close(d.stop)
+			delete(mwc.dialers, d)
+			return
+		}
+	}
+}
+
+// redialLoop invokes the dialer until it returns a writer that is added, or until it is stopped.
+func (mwc *MultiWriteCloserFanOut) redialLoop(d *fanOutDialer, backoff, maximum time.Duration) {
+	for {
+		timer := mwc.clock.NewTimer(backoff)
+		select {
+		case <-timer.C():
+		case <-d.stop:
+			timer.Stop()
+			return
+		}
+		if backoff *= 2; backoff > maximum {
+			backoff = maximum
+		}
+
+		w, err := d.dial()
+		if err != nil {
+			continue
+		}
+		mwc.lock.Lock()
+		mwc.seqLock.Lock()
+		var added, stopped bool
+		select {
+		case <-d.stop:
+			stopped = true
+		default:
+			if _, ok := mwc.writerMap[w]; ok || mwc.admit(w) {
+				added, d.w = true, w
+				mwc.update()
+			}
+		}
+		mwc.seqLock.Unlock()
+		mwc.lock.Unlock()
+		if stopped {
+			w.Close() // the writer was never added
+			return
+		}
+		if added {
+			return
+		}
 	}
-	return len(data), nil
 }
@@ -2,8 +2,16 @@ package gorill
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"testing/iotest"
 	"time"
 )
 
@@ -202,6 +210,31 @@ func TestMultiWriteCloserFanOutWriteErrorRemovesBadWriteCloser(t *testing.T) {
 	mw.Close()
 }
 
+func TestMultiWriteCloserFanOutWriters(t *testing.T) {
+	buf := NewNopCloseBuffer()
+	ew := &testWriteCloser{}
+	mw := NewMultiWriteCloserFanOut(buf, ew)
+	if got, want := len(mw.Writers()), 2; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	_, err := mw.Write([]byte(alphabet))
+	ensureError(t, err)
+	writers := mw.Writers()
+	if got, want := len(writers), 1; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := writers[0], io.WriteCloser(buf); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	// The returned slice is a copy.
+	writers[0] = ew
+	if got, want := mw.Writers()[0], io.WriteCloser(buf); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
+
 const writersCount = 1000
 const data = "zzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzz"
 
@@ -210,12 +243,15 @@ func BenchmarkWriterMultiWriteCloserFanOutWrite(b *testing.B) {
 	for i := 0; i < writersCount; i++ {
 		mw.Add(NewNopCloseBuffer())
 	}
-	n, err := mw.Write([]byte(data))
-	if n != len(data) {
-		b.Errorf("Actual: %#v; Expected: %#v", n, 4)
-	}
-	if err != nil {
-		b.Errorf("Actual: %#v; Expected: %#v", err, nil)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		n, err := mw.Write([]byte(data))
+		if n != len(data) {
+			b.Errorf("Actual: %#v; Expected: %#v", n, 4)
+		}
+		if err != nil {
+			b.Errorf("Actual: %#v; Expected: %#v", err, nil)
+		}
 	}
 	mw.Close()
 }
@@ -234,3 +270,1403 @@ func BenchmarkWriterMultiWriteCloserFanOutWriteSlow(b *testing.B) {
 	}
 	mw.Close()
 }
+
+// sequenceRecorder records the sequence numbers it receives.
+type sequenceRecorder struct {
+	*NopCloseBuffer
+	joined uint64
+	seqs   []uint64
+}
+
+func (sr *sequenceRecorder) JoinSequence(next uint64) { sr.joined = next }
+
+func (sr *sequenceRecorder) WriteSequenced(seq uint64, data []byte) (int, error) {
+	sr.seqs = append(sr.seqs, seq)
+	return sr.Write(data)
+}
+
+func TestMultiWriteCloserFanOutSequencing(t *testing.T) {
+	plain := NewNopCloseBuffer()
+	early := &sequenceRecorder{NopCloseBuffer: NewNopCloseBuffer()}
+	mw := NewMultiWriteCloserFanOut(plain)
+	mw.SetSequencing(true)
+	mw.Add(early)
+
+	for i := 0; i < 3; i++ {
+		_, err := mw.Write([]byte("x"))
+		ensureError(t, err)
+	}
+	if got, want := mw.Sequence(), uint64(3); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	late := &sequenceRecorder{NopCloseBuffer: NewNopCloseBuffer()}
+	mw.Add(late)
+	if got, want := late.joined, uint64(4); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	_, err := mw.Write([]byte("y"))
+	ensureError(t, err)
+
+	if got, want := fmt.Sprint(early.seqs), "[1 2 3 4]"; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := early.joined, uint64(1); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := fmt.Sprint(late.seqs), "[4]"; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := plain.String(), "xxxy"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	ensureError(t, mw.Close())
+}
+
+func TestMultiWriteCloserFanOutCatchUpLines(t *testing.T) {
+	mw := NewMultiWriteCloserFanOut(NewNopCloseBuffer())
+	mw.SetCatchUp(0, 2)
+
+	_, err := mw.Write([]byte("one\ntwo\nthree\nfo"))
+	ensureError(t, err)
+
+	late := NewNopCloseBuffer()
+	mw.Add(late)
+	_, err = mw.Write([]byte("ur\n"))
+	ensureError(t, err)
+
+	if got, want := late.String(), "two\nthree\nfour\n"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	ensureError(t, mw.Close())
+}
+
+func TestMultiWriteCloserFanOutCatchUpBytes(t *testing.T) {
+	mw := NewMultiWriteCloserFanOut()
+	mw.SetCatchUp(5, 0)
+
+	for i := 0; i < 3; i++ {
+		_, err := mw.Write([]byte(alphabet))
+		ensureError(t, err)
+	}
+
+	late := NewNopCloseBuffer()
+	if got, want := mw.Add(late), 1; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := late.String(), "wxyz\n"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+
+	failing := &testWriteCloser{}
+	if got, want := mw.Add(failing), 1; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if !failing.IsClosed() {
+		t.Errorf("GOT: %v; WANT: %v", false, true)
+	}
+	ensureError(t, mw.Close())
+}
+
+// undercountingWriteCloser reports writing one byte fewer than it was given,
+// without an error.
+type undercountingWriteCloser struct{ *NopCloseBuffer }
+
+func (u undercountingWriteCloser) Write(data []byte) (int, error) {
+	n, err := u.NopCloseBuffer.Write(data)
+	return n - 1, err
+}
+
+func TestMultiWriteCloserFanOutCatchUpFailures(t *testing.T) {
+	t.Run("panic", func(t *testing.T) {
+		mw := NewMultiWriteCloserFanOut()
+		mw.SetCatchUp(64, 0)
+		mw.SetIsolatePanics(true)
+		_, err := mw.Write([]byte(alphabet))
+		ensureError(t, err)
+
+		if got, want := mw.Add(panickingWriteCloser{}), 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := mw.Stats()["evicted"], int64(1); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureError(t, mw.Close())
+	})
+
+	t.Run("short write", func(t *testing.T) {
+		mw := NewMultiWriteCloserFanOut()
+		mw.SetCatchUp(64, 0)
+		_, err := mw.Write([]byte(alphabet))
+		ensureError(t, err)
+
+		short := undercountingWriteCloser{NewNopCloseBuffer()}
+		if got, want := mw.Add(short), 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if !short.IsClosed() {
+			t.Errorf("GOT: %v; WANT: %v", false, true)
+		}
+		ensureError(t, mw.Close())
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		clock := NewFakeClock(time.Unix(0, 0))
+		mw, err := NewFanOut(FanOutClock(clock))
+		ensureError(t, err)
+		mw.SetCatchUp(64, 0)
+		mw.SetWriteTimeout(time.Second)
+		_, err = mw.Write([]byte(alphabet))
+		ensureError(t, err)
+
+		stuck := &gatedWriteCloser{started: make(chan struct{}, 1), gate: make(chan struct{})}
+		added := make(chan int, 1)
+		go func() { added <- mw.Add(stuck) }()
+		<-stuck.started
+		clock.BlockUntil(1) // the write timeout
+		clock.Advance(time.Second)
+		if got, want := <-added, 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		// Broadcasts are no longer stalled by the hung writer.
+		bb := NewNopCloseBuffer()
+		mw.Add(bb)
+		_, err = mw.Write([]byte("more"))
+		ensureError(t, err)
+		if got, want := bb.String(), alphabet+"more"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+
+		// The hung writer is closed once its write returns.
+		close(stuck.gate)
+		deadline := time.Now().Add(5 * time.Second)
+		for !stuck.isClosed() {
+			if time.Now().After(deadline) {
+				t.Fatal("GOT: open; WANT: closed")
+			}
+			time.Sleep(time.Millisecond)
+		}
+		ensureError(t, mw.Close())
+	})
+}
+
+func TestMultiWriteCloserFanOutConcurrency(t *testing.T) {
+	for _, limit := range []int{0, 1, 3} {
+		var buffers []*NopCloseBuffer
+		mw := NewMultiWriteCloserFanOut()
+		for i := 0; i < 10; i++ {
+			bb := NewNopCloseBuffer()
+			buffers = append(buffers, bb)
+			mw.Add(bb)
+		}
+		failing := &testWriteCloser{}
+		mw.Add(failing)
+		mw.SetConcurrency(limit)
+
+		_, err := mw.Write([]byte(alphabet))
+		ensureError(t, err)
+		for _, bb := range buffers {
+			if got, want := bb.String(), alphabet; got != want {
+				t.Errorf("concurrency %d: GOT: %q; WANT: %q", limit, got, want)
+			}
+		}
+		if got, want := mw.Count(), 10; got != want {
+			t.Errorf("concurrency %d: GOT: %v; WANT: %v", limit, got, want)
+		}
+		ensureError(t, mw.Close())
+	}
+}
+
+func TestMultiWriteCloserFanOutWriteContext(t *testing.T) {
+	t.Run("completes", func(t *testing.T) {
+		bb := NewNopCloseBuffer()
+		mw := NewMultiWriteCloserFanOut(bb)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		n, err := mw.WriteContext(ctx, []byte(alphabet))
+		ensureError(t, err)
+		if got, want := n, len(alphabet); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := bb.String(), alphabet; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("abandons stuck writer", func(t *testing.T) {
+		bb := NewNopCloseBuffer()
+		stuck := &gatedWriteCloser{started: make(chan struct{}, 1), gate: make(chan struct{})}
+		mw := NewMultiWriteCloserFanOut(bb, stuck)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		n, err := mw.WriteContext(ctx, []byte(alphabet))
+		if err != context.DeadlineExceeded {
+			t.Errorf("GOT: %v; WANT: %v", err, context.DeadlineExceeded)
+		}
+		if got, want := n, 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := bb.String(), alphabet; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+		if got, want := mw.Count(), 1; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := stuck.isClosed(), false; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		// Once its write returns, the abandoned writer is closed.
+		close(stuck.gate)
+		deadline := time.Now().Add(time.Second)
+		for !stuck.isClosed() && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		if got, want := stuck.isClosed(), true; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		// Later writes are not held up by the abandoned writer.
+		_, err = mw.Write([]byte("more"))
+		ensureError(t, err)
+		if got, want := bb.String(), alphabet+"more"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+}
+
+// concurrencyProbe is an io.WriteCloser that records the greatest number of
+// writes in progress at once across all probes sharing its counters.
+type concurrencyProbe struct {
+	*NopCloseBuffer
+	counters *probeCounters
+}
+
+type probeCounters struct {
+	lock    sync.Mutex
+	current int
+	max     int
+}
+
+func (p *concurrencyProbe) Write(data []byte) (int, error) {
+	p.counters.lock.Lock()
+	p.counters.current++
+	if p.counters.current > p.counters.max {
+		p.counters.max = p.counters.current
+	}
+	p.counters.lock.Unlock()
+
+	time.Sleep(100 * time.Microsecond)
+
+	p.counters.lock.Lock()
+	p.counters.current--
+	p.counters.lock.Unlock()
+	return p.NopCloseBuffer.Write(data)
+}
+
+func TestMultiWriteCloserFanOutWorkerPool(t *testing.T) {
+	t.Run("bounds concurrency", func(t *testing.T) {
+		counters := new(probeCounters)
+		var probes []*concurrencyProbe
+		mw := NewMultiWriteCloserFanOut()
+		for i := 0; i < 1000; i++ {
+			p := &concurrencyProbe{NopCloseBuffer: NewNopCloseBuffer(), counters: counters}
+			probes = append(probes, p)
+			mw.Add(p)
+		}
+		mw.SetConcurrency(8)
+
+		for i := 0; i < 4; i++ {
+			_, err := mw.Write([]byte("x"))
+			ensureError(t, err)
+		}
+
+		if got, limit := counters.max, 8; got > limit {
+			t.Errorf("GOT: %v; WANT: at most %v", got, limit)
+		}
+		for _, p := range probes {
+			if got, want := p.String(), "xxxx"; got != want {
+				t.Fatalf("GOT: %q; WANT: %q", got, want)
+			}
+		}
+		ensureError(t, mw.Close())
+	})
+
+	t.Run("cancelled while workers are busy", func(t *testing.T) {
+		stuck1 := &gatedWriteCloser{started: make(chan struct{}, 1), gate: make(chan struct{})}
+		stuck2 := &gatedWriteCloser{started: make(chan struct{}, 1), gate: make(chan struct{})}
+		bb := NewNopCloseBuffer()
+		mw := NewMultiWriteCloserFanOut(stuck1, stuck2, bb)
+		mw.SetConcurrency(2)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		_, err := mw.WriteContext(ctx, []byte(alphabet))
+		if err != context.DeadlineExceeded {
+			t.Errorf("GOT: %v; WANT: %v", err, context.DeadlineExceeded)
+		}
+		close(stuck1.gate)
+		close(stuck2.gate)
+
+		// Both stuck writers were claimed by the two workers and abandoned.
+		if got, want := mw.Count(), 1; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		_, err = mw.Write([]byte(alphabet))
+		ensureError(t, err)
+		if got, want := bb.String(), alphabet; !strings.HasSuffix(got, want) {
+			t.Errorf("GOT: %q; WANT: suffix %q", got, want)
+		}
+		ensureError(t, mw.Close())
+	})
+}
+
+// flakyWriteCloser fails its first failures writes, then writes to its buffer.
+type flakyWriteCloser struct {
+	*NopCloseBuffer
+	failures int
+}
+
+func (f *flakyWriteCloser) Write(data []byte) (int, error) {
+	if f.failures > 0 {
+		f.failures--
+		return 0, errors.New("transient failure")
+	}
+	return f.NopCloseBuffer.Write(data)
+}
+
+func TestMultiWriteCloserFanOutErrorPolicy(t *testing.T) {
+	t.Run("remove on error", func(t *testing.T) {
+		flaky := &flakyWriteCloser{NopCloseBuffer: NewNopCloseBuffer(), failures: 1}
+		mw := NewMultiWriteCloserFanOut(flaky)
+
+		_, err := mw.Write([]byte(alphabet))
+		ensureError(t, err)
+		if got, want := mw.Count(), 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := flaky.IsClosed(), true; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("keep and report", func(t *testing.T) {
+		bb := NewNopCloseBuffer()
+		flaky := &flakyWriteCloser{NopCloseBuffer: NewNopCloseBuffer(), failures: 1}
+		mw := NewMultiWriteCloserFanOut(bb, flaky)
+		mw.SetErrorPolicy(KeepAndReport)
+
+		n, err := mw.Write([]byte("one"))
+		ensureError(t, err, "transient failure")
+		if got, want := n, 3; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := mw.Count(), 2; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := flaky.IsClosed(), false; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		_, err = mw.Write([]byte("two"))
+		ensureError(t, err)
+		if got, want := bb.String(), "onetwo"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+		if got, want := flaky.String(), "two"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("retry succeeds", func(t *testing.T) {
+		flaky := &flakyWriteCloser{NopCloseBuffer: NewNopCloseBuffer(), failures: 2}
+		mw := NewMultiWriteCloserFanOut(flaky)
+		mw.SetErrorPolicy(RetryN(2))
+
+		_, err := mw.Write([]byte(alphabet))
+		ensureError(t, err)
+		if got, want := mw.Count(), 1; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := flaky.String(), alphabet; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("retries exhausted", func(t *testing.T) {
+		flaky := &flakyWriteCloser{NopCloseBuffer: NewNopCloseBuffer(), failures: 2}
+		mw := NewMultiWriteCloserFanOut(flaky)
+		mw.SetErrorPolicy(RetryN(1))
+
+		_, err := mw.Write([]byte(alphabet))
+		ensureError(t, err)
+		if got, want := mw.Count(), 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := flaky.IsClosed(), true; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
+
+func TestMultiWriteCloserFanOutCallbacks(t *testing.T) {
+	t.Run("removed on error", func(t *testing.T) {
+		failing := &testWriteCloser{}
+		mw := NewMultiWriteCloserFanOut(NewNopCloseBuffer(), failing)
+
+		var errored, removed []io.WriteCloser
+		mw.SetOnError(func(w io.WriteCloser, err error) {
+			testErrorType(t, err, io.ErrShortWrite)
+			errored = append(errored, w)
+		})
+		mw.SetOnRemove(func(w io.WriteCloser, err error) {
+			testErrorType(t, err, io.ErrShortWrite)
+			removed = append(removed, w)
+			mw.Add(NewNopCloseBuffer()) // replacing the writer must not deadlock
+		})
+
+		_, err := mw.Write([]byte(alphabet))
+		ensureError(t, err)
+		if got, want := len(errored), 1; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := errored[0], io.WriteCloser(failing); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := len(removed), 1; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := removed[0], io.WriteCloser(failing); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := mw.Count(), 2; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("kept writers are not removed", func(t *testing.T) {
+		mw := NewMultiWriteCloserFanOut(&testWriteCloser{})
+		mw.SetErrorPolicy(KeepAndReport)
+
+		var errored, removed int
+		mw.SetOnError(func(io.WriteCloser, error) { errored++ })
+		mw.SetOnRemove(func(io.WriteCloser, error) { removed++ })
+
+		_, err := mw.Write([]byte(alphabet))
+		ensureError(t, err, "short write")
+		if got, want := errored, 1; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := removed, 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("abandoned", func(t *testing.T) {
+		stuck := &gatedWriteCloser{started: make(chan struct{}, 1), gate: make(chan struct{})}
+		defer close(stuck.gate)
+		mw := NewMultiWriteCloserFanOut(stuck)
+
+		var removed []error
+		mw.SetOnRemove(func(w io.WriteCloser, err error) { removed = append(removed, err) })
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err := mw.WriteContext(ctx, []byte(alphabet))
+		if err != context.DeadlineExceeded {
+			t.Errorf("GOT: %v; WANT: %v", err, context.DeadlineExceeded)
+		}
+		if got, want := len(removed), 1; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := removed[0], context.DeadlineExceeded; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
+
+func TestMultiWriteCloserFanOutWriteTimeout(t *testing.T) {
+	t.Run("evicts hung writer", func(t *testing.T) {
+		bb := NewNopCloseBuffer()
+		stuck := &gatedWriteCloser{started: make(chan struct{}, 1), gate: make(chan struct{})}
+		mw := NewMultiWriteCloserFanOut(bb, stuck)
+		mw.SetWriteTimeout(10 * time.Millisecond)
+
+		var removed []error
+		mw.SetOnRemove(func(w io.WriteCloser, err error) { removed = append(removed, err) })
+
+		n, err := mw.Write([]byte(alphabet))
+		ensureError(t, err)
+		if got, want := n, len(alphabet); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := mw.Count(), 1; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := len(removed), 1; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		testErrorType(t, removed[0], ErrTimeout(0))
+		if got, want := stuck.isClosed(), false; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		// Once its write returns, the hung writer is closed.
+		close(stuck.gate)
+		deadline := time.Now().Add(time.Second)
+		for !stuck.isClosed() && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		if got, want := stuck.isClosed(), true; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := bb.String(), alphabet; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("evicted despite keep and report", func(t *testing.T) {
+		stuck := &gatedWriteCloser{started: make(chan struct{}, 1), gate: make(chan struct{})}
+		defer close(stuck.gate)
+		mw := NewMultiWriteCloserFanOut(stuck)
+		mw.SetErrorPolicy(KeepAndReport)
+		mw.SetWriteTimeout(10 * time.Millisecond)
+
+		_, err := mw.Write([]byte(alphabet))
+		ensureError(t, err, "timeout")
+		if got, want := mw.Count(), 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("fast writers unaffected", func(t *testing.T) {
+		bb := NewNopCloseBuffer()
+		mw := NewMultiWriteCloserFanOut(bb)
+		mw.SetWriteTimeout(time.Second)
+		_, err := mw.Write([]byte(alphabet))
+		ensureError(t, err)
+		if got, want := mw.Count(), 1; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := bb.String(), alphabet; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+}
+
+func TestMultiWriteCloserFanOutWriteReport(t *testing.T) {
+	bb := NewNopCloseBuffer()
+	bad := &testWriteCloser{}
+	mw := NewMultiWriteCloserFanOut(bb, bad)
+
+	n, errs := mw.WriteReport([]byte(alphabet))
+	if got, want := n, len(alphabet); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	failures := errs.Failures()
+	if got, want := len(failures), 1; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := failures[0].Err, io.ErrShortWrite; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := mw.Count(), 1; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	n, errs = mw.WriteReport([]byte(alphabet))
+	if got, want := n, len(alphabet); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := errs.Count(), 0; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := bb.String(), alphabet+alphabet; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+}
+
+// stringCounter is an io.WriteCloser that counts the calls to its WriteString method.
+type stringCounter struct {
+	*NopCloseBuffer
+	calls int
+}
+
+func (sc *stringCounter) WriteString(s string) (int, error) {
+	sc.calls++
+	return sc.NopCloseBuffer.WriteString(s)
+}
+
+func TestMultiWriteCloserFanOutWriteString(t *testing.T) {
+	sc := &stringCounter{NopCloseBuffer: NewNopCloseBuffer()}
+	bb := NewNopCloseBuffer()
+	bytesOnly := struct{ io.WriteCloser }{bb} // hides WriteString
+	mw := NewMultiWriteCloserFanOut(sc, bytesOnly)
+
+	n, err := mw.WriteString(alphabet)
+	ensureError(t, err)
+	if got, want := n, len(alphabet); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := sc.calls, 1; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := sc.String(), alphabet; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	if got, want := bb.String(), alphabet; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+}
+
+func TestMultiWriteCloserFanOutReadFrom(t *testing.T) {
+	t.Run("copies every chunk", func(t *testing.T) {
+		bb1, bb2 := NewNopCloseBuffer(), NewNopCloseBuffer()
+		mw := NewMultiWriteCloserFanOut(bb1, bb2)
+		payload := strings.Repeat(alphabet, 5000) // larger than the pooled buffer
+
+		n, err := mw.ReadFrom(strings.NewReader(payload))
+		ensureError(t, err)
+		if got, want := n, int64(len(payload)); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		for _, bb := range []*NopCloseBuffer{bb1, bb2} {
+			if got, want := bb.String(), payload; got != want {
+				t.Errorf("GOT: %d bytes; WANT: %d bytes", len(got), len(want))
+			}
+		}
+	})
+
+	t.Run("read error", func(t *testing.T) {
+		bb := NewNopCloseBuffer()
+		mw := NewMultiWriteCloserFanOut(bb)
+		r := iotest.TimeoutReader(strings.NewReader(alphabet)) // fails its second read
+
+		n, err := mw.ReadFrom(r)
+		ensureError(t, err, "timeout")
+		if got, want := n, int64(len(alphabet)); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := bb.String(), alphabet; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("write error", func(t *testing.T) {
+		mw := NewMultiWriteCloserFanOut(&testWriteCloser{})
+		mw.SetErrorPolicy(KeepAndReport)
+		_, err := mw.ReadFrom(strings.NewReader(alphabet))
+		ensureError(t, err, "short write")
+	})
+}
+
+func TestMultiWriteCloserFanOutWriterStats(t *testing.T) {
+	bb := NewNopCloseBuffer()
+	bad := &testWriteCloser{}
+	mw := NewMultiWriteCloserFanOut(bb, bad)
+	mw.SetErrorPolicy(KeepAndReport)
+
+	for i := 0; i < 2; i++ {
+		_, _ = mw.Write([]byte(alphabet))
+	}
+	stats := mw.WriterStats()
+	if got, want := len(stats), 2; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	for _, ws := range stats {
+		switch ws.Writer {
+		case bb:
+			if got, want := ws.Bytes, int64(2*len(alphabet)); got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := ws.Errors, int64(0); got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		case bad:
+			if got, want := ws.Errors, int64(2); got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := ws.LastErr, io.ErrShortWrite; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		default:
+			t.Errorf("GOT: %v; WANT: a known writer", ws.Writer)
+		}
+	}
+
+	mw.Remove(bad)
+	if got, want := len(mw.WriterStats()), 1; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
+
+func TestMultiWriteCloserFanOutReplaceAll(t *testing.T) {
+	bb1, bb2, bb3 := NewNopCloseBuffer(), NewNopCloseBuffer(), NewNopCloseBuffer()
+	mw := NewMultiWriteCloserFanOut(bb1, bb2)
+	_, err := mw.Write([]byte("a"))
+	ensureError(t, err)
+
+	removed := mw.ReplaceAll(bb2, bb3, bb3)
+	if got, want := len(removed), 1; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := removed[0], io.WriteCloser(bb1); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if bb1.IsClosed() {
+		t.Errorf("GOT: %v; WANT: %v", true, false)
+	}
+	if got, want := mw.Count(), 2; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	_, err = mw.Write([]byte("b"))
+	ensureError(t, err)
+	for _, tc := range []struct {
+		bb   *NopCloseBuffer
+		want string
+	}{{bb1, "a"}, {bb2, "ab"}, {bb3, "b"}} {
+		if got := tc.bb.String(); got != tc.want {
+			t.Errorf("GOT: %q; WANT: %q", got, tc.want)
+		}
+	}
+
+	if got, want := len(mw.ReplaceAll()), 2; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if !mw.IsEmpty() {
+		t.Errorf("GOT: %v; WANT: %v", false, true)
+	}
+}
+
+func TestMultiWriteCloserFanOutCloseEvicted(t *testing.T) {
+	bad := &testWriteCloser{}
+	mw := NewMultiWriteCloserFanOut(bad)
+	mw.SetCloseEvicted(false)
+
+	_, err := mw.Write([]byte(alphabet))
+	ensureError(t, err)
+	if got, want := mw.Count(), 0; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if bad.IsClosed() {
+		t.Errorf("GOT: %v; WANT: %v", true, false)
+	}
+}
+
+func TestMultiWriteCloserFanOutConcurrentMembership(t *testing.T) {
+	mw := NewMultiWriteCloserFanOut(&testWriteCloser{})
+	mw.SetCatchUp(64, 0)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_, _ = mw.Write([]byte(alphabet))
+			}
+		}()
+	}
+	for j := 0; j < 100; j++ {
+		w := new(lockedRecorder) // receives concurrent writes
+		mw.Add(w)
+		if j%2 == 0 {
+			mw.Remove(w)
+		}
+		mw.Add(&testWriteCloser{}) // evicted by a concurrent write
+	}
+	wg.Wait()
+
+	if got, want := mw.Count(), 50; got < want {
+		t.Errorf("GOT: %v; WANT: at least %v", got, want)
+	}
+	ensureError(t, mw.Close())
+}
+
+func TestMultiWriteCloserFanOutCoalescing(t *testing.T) {
+	t.Run("merges small writes", func(t *testing.T) {
+		lr := new(lockedRecorder)
+		mw := NewMultiWriteCloserFanOut(lr)
+		mw.SetCoalescing(time.Hour, 16)
+		for _, piece := range []string{"abc", "def", "ghi"} {
+			n, err := mw.Write([]byte(piece))
+			ensureError(t, err)
+			if want := len(piece); n != want {
+				t.Errorf("GOT: %v; WANT: %v", n, want)
+			}
+		}
+		if _, sizes := lr.snapshot(); len(sizes) != 0 {
+			t.Errorf("GOT: %v; WANT: %v", sizes, nil)
+		}
+		ensureError(t, mw.Flush())
+		got, sizes := lr.snapshot()
+		if want := "abcdefghi"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if want := []int{9}; !reflect.DeepEqual(sizes, want) {
+			t.Errorf("GOT: %v; WANT: %v", sizes, want)
+		}
+	})
+
+	t.Run("broadcasts at max bytes", func(t *testing.T) {
+		lr := new(lockedRecorder)
+		mw := NewMultiWriteCloserFanOut(lr)
+		mw.SetCoalescing(time.Hour, 4)
+		for _, piece := range []string{"ab", "cd", "efghij", "k"} {
+			_, err := mw.WriteString(piece)
+			ensureError(t, err)
+		}
+		got, sizes := lr.snapshot()
+		if want := "abcdefghij"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if want := []int{4, 6}; !reflect.DeepEqual(sizes, want) {
+			t.Errorf("GOT: %v; WANT: %v", sizes, want)
+		}
+	})
+
+	t.Run("write context preserves order", func(t *testing.T) {
+		lr := new(lockedRecorder)
+		mw := NewMultiWriteCloserFanOut(lr)
+		mw.SetCoalescing(time.Hour, 16)
+		_, err := mw.Write([]byte("abc"))
+		ensureError(t, err)
+		_, err = mw.WriteContext(context.Background(), []byte("def"))
+		ensureError(t, err)
+		got, sizes := lr.snapshot()
+		if want := "abcdef"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if want := []int{3, 3}; !reflect.DeepEqual(sizes, want) {
+			t.Errorf("GOT: %v; WANT: %v", sizes, want)
+		}
+	})
+
+	t.Run("write context bounds broadcast of collected bytes", func(t *testing.T) {
+		stuck := &gatedWriteCloser{started: make(chan struct{}, 1), gate: make(chan struct{})}
+		defer close(stuck.gate)
+		mw := NewMultiWriteCloserFanOut(stuck)
+		mw.SetCoalescing(time.Hour, 16)
+		_, err := mw.Write([]byte("abc"))
+		ensureError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			_, _ = mw.WriteContext(ctx, []byte("def"))
+			close(done)
+		}()
+		<-stuck.started // broadcasting the collected bytes
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("GOT: WriteContext blocked; WANT: WriteContext to return when ctx is done")
+		}
+		if got, want := mw.Count(), 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("disabling broadcasts collected bytes", func(t *testing.T) {
+		lr := new(lockedRecorder)
+		mw := NewMultiWriteCloserFanOut(lr)
+		mw.SetCoalescing(time.Hour, 16)
+		_, err := mw.Write([]byte("abc"))
+		ensureError(t, err)
+		mw.SetCoalescing(0, 0)
+		_, err = mw.Write([]byte("def"))
+		ensureError(t, err)
+		got, sizes := lr.snapshot()
+		if want := "abcdef"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if want := []int{3, 3}; !reflect.DeepEqual(sizes, want) {
+			t.Errorf("GOT: %v; WANT: %v", sizes, want)
+		}
+	})
+
+	t.Run("close broadcasts collected bytes", func(t *testing.T) {
+		bb := NewNopCloseBuffer()
+		mw := NewMultiWriteCloserFanOut(bb)
+		mw.SetCoalescing(time.Hour, 16)
+		_, err := mw.Write([]byte("abc"))
+		ensureError(t, err)
+		ensureError(t, mw.Close())
+		if want := "abc"; bb.String() != want {
+			t.Errorf("GOT: %v; WANT: %v", bb.String(), want)
+		}
+		if !bb.IsClosed() {
+			t.Errorf("GOT: %v; WANT: %v", false, true)
+		}
+	})
+
+	t.Run("failed writer removed after window", func(t *testing.T) {
+		tw := &testWriteCloser{}
+		mw := NewMultiWriteCloserFanOut(tw)
+		removed := make(chan error, 1)
+		mw.SetOnRemove(func(_ io.WriteCloser, err error) { removed <- err })
+		mw.SetCoalescing(time.Millisecond, 16)
+		_, err := mw.Write([]byte("abc"))
+		ensureError(t, err)
+		select {
+		case err = <-removed:
+			ensureError(t, err, "short write")
+		case <-time.After(5 * time.Second):
+			t.Fatal("writer not removed")
+		}
+		if got, want := mw.Count(), 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
+
+func TestMultiWriteCloserFanOutHealthCheck(t *testing.T) {
+	t.Run("removes failed writer", func(t *testing.T) {
+		healthy := new(lockedRecorder)
+		dead := &testWriteCloser{}
+		mw := NewMultiWriteCloserFanOut(healthy, dead)
+		defer mw.Close()
+		removed := make(chan io.WriteCloser, 1)
+		mw.SetOnRemove(func(w io.WriteCloser, err error) {
+			ensureError(t, err, "short write")
+			removed <- w
+		})
+		mw.SetHealthCheck(time.Millisecond, nil)
+		select {
+		case w := <-removed:
+			if w != dead {
+				t.Errorf("GOT: %v; WANT: %v", w, dead)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("writer not removed")
+		}
+		if !dead.IsClosed() {
+			t.Errorf("GOT: %v; WANT: %v", false, true)
+		}
+		if got, want := mw.Count(), 1; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, _ := healthy.snapshot(); got != "" {
+			t.Errorf("GOT: %v; WANT: %v", got, "")
+		}
+	})
+
+	t.Run("writes ping", func(t *testing.T) {
+		lr := new(lockedRecorder)
+		mw := NewMultiWriteCloserFanOut(lr)
+		mw.SetHealthCheck(time.Millisecond, []byte("\n"))
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			if got, _ := lr.snapshot(); strings.HasPrefix(got, "\n\n") {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("ping not written")
+			}
+			time.Sleep(time.Millisecond)
+		}
+		mw.SetHealthCheck(0, nil)
+		if got, want := mw.Count(), 1; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureError(t, mw.Close())
+	})
+
+	t.Run("pings do not overlap writes", func(t *testing.T) {
+		ow := new(overlapDetector)
+		mw := NewMultiWriteCloserFanOut(ow)
+		mw.SetHealthCheck(time.Millisecond, []byte("\n"))
+		for i := 0; i < 100; i++ {
+			_, err := mw.Write([]byte(alphabet))
+			ensureError(t, err)
+		}
+		ensureError(t, mw.Close())
+		if got := atomic.LoadInt32(&ow.overlapped); got != 0 {
+			t.Errorf("GOT: %v; WANT: %v", got, 0)
+		}
+	})
+}
+
+// overlapDetector records whether its Write method is ever invoked while another invocation is in
+// progress.
+type overlapDetector struct {
+	active     int32
+	overlapped int32
+}
+
+func (o *overlapDetector) Write(data []byte) (int, error) {
+	if atomic.AddInt32(&o.active, 1) > 1 {
+		atomic.StoreInt32(&o.overlapped, 1)
+	}
+	time.Sleep(100 * time.Microsecond)
+	atomic.AddInt32(&o.active, -1)
+	return len(data), nil
+}
+
+func (o *overlapDetector) Close() error { return nil }
+
+func TestMultiWriteCloserFanOutAddFactory(t *testing.T) {
+	t.Run("dial error", func(t *testing.T) {
+		mw := NewMultiWriteCloserFanOut()
+		n, err := mw.AddFactory(func() (io.WriteCloser, error) { return nil, errors.New("refused") })
+		ensureError(t, err, "refused")
+		if want := 0; n != want {
+			t.Errorf("GOT: %v; WANT: %v", n, want)
+		}
+	})
+
+	t.Run("re-creates failed writer", func(t *testing.T) {
+		lr := new(lockedRecorder)
+		dead := &testWriteCloser{}
+		var lock sync.Mutex // lock protects dials
+		var dials int
+		mw := NewMultiWriteCloserFanOut()
+		defer mw.Close()
+		mw.SetRedialBackoff(time.Millisecond, 4*time.Millisecond)
+		_, err := mw.AddFactory(func() (io.WriteCloser, error) {
+			lock.Lock()
+			defer lock.Unlock()
+			dials++
+			switch dials {
+			case 1:
+				return dead, nil
+			case 2:
+				return nil, errors.New("refused")
+			default:
+				return lr, nil
+			}
+		})
+		ensureError(t, err)
+
+		_, err = mw.Write([]byte("lost"))
+		ensureError(t, err)
+		if !dead.IsClosed() {
+			t.Errorf("GOT: %v; WANT: %v", false, true)
+		}
+
+		deadline := time.Now().Add(5 * time.Second)
+		for mw.Count() == 0 {
+			if time.Now().After(deadline) {
+				t.Fatal("writer not re-created")
+			}
+			time.Sleep(time.Millisecond)
+		}
+		_, err = mw.Write([]byte("found"))
+		ensureError(t, err)
+		if got, _ := lr.snapshot(); got != "found" {
+			t.Errorf("GOT: %v; WANT: %v", got, "found")
+		}
+		lock.Lock()
+		if want := 3; dials != want {
+			t.Errorf("GOT: %v; WANT: %v", dials, want)
+		}
+		lock.Unlock()
+	})
+
+	t.Run("remove stops re-creating", func(t *testing.T) {
+		bb := NewNopCloseBuffer()
+		var dials int
+		mw := NewMultiWriteCloserFanOut()
+		_, err := mw.AddFactory(func() (io.WriteCloser, error) {
+			dials++
+			return bb, nil
+		})
+		ensureError(t, err)
+		if got, want := mw.Remove(bb), 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := len(mw.dialers), 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if want := 1; dials != want {
+			t.Errorf("GOT: %v; WANT: %v", dials, want)
+		}
+	})
+}
+
+func TestMultiWriteCloserFanOutMinimumSuccess(t *testing.T) {
+	t.Run("quorum reached", func(t *testing.T) {
+		bb1, bb2 := NewNopCloseBuffer(), NewNopCloseBuffer()
+		mw := NewMultiWriteCloserFanOut(bb1, bb2, &testWriteCloser{})
+		mw.SetMinimumSuccess(2)
+		n, err := mw.Write([]byte("blob"))
+		ensureError(t, err)
+		if want := 4; n != want {
+			t.Errorf("GOT: %v; WANT: %v", n, want)
+		}
+		if got, want := mw.Count(), 2; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("quorum not reached", func(t *testing.T) {
+		bb := NewNopCloseBuffer()
+		mw := NewMultiWriteCloserFanOut(bb, &testWriteCloser{})
+		mw.SetMinimumSuccess(2)
+		_, err := mw.Write([]byte("blob"))
+		ensureError(t, err, "quorum not reached: 1 of 2", "short write")
+		quorum, ok := err.(ErrQuorum)
+		if !ok {
+			t.Fatalf("GOT: %T; WANT: %T", err, quorum)
+		}
+		if got, want := quorum.Errs.Count(), 1; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if want := "blob"; bb.String() != want {
+			t.Errorf("GOT: %v; WANT: %v", bb.String(), want)
+		}
+
+		_, err = mw.Write([]byte("more"))
+		ensureError(t, err, "quorum not reached: 1 of 2 required writers succeeded")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		mw := NewMultiWriteCloserFanOut()
+		mw.SetMinimumSuccess(1)
+		_, err := mw.Write([]byte("blob"))
+		ensureError(t, err, "quorum not reached: 0 of 1")
+		mw.SetMinimumSuccess(0)
+		_, err = mw.Write([]byte("blob"))
+		ensureError(t, err)
+	})
+}
+
+func TestMultiWriteCloserFanOutAddWriter(t *testing.T) {
+	bb1, bb2 := NewNopCloseBuffer(), NewNopCloseBuffer()
+	mw := NewMultiWriteCloserFanOut(bb1)
+	if got, want := mw.AddWriter(bb2), 2; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	_, err := mw.Write([]byte("blob"))
+	ensureError(t, err)
+	if want := "blob"; bb2.String() != want {
+		t.Errorf("GOT: %v; WANT: %v", bb2.String(), want)
+	}
+	ensureError(t, mw.Close())
+	if !bb1.IsClosed() {
+		t.Errorf("GOT: %v; WANT: %v", false, true)
+	}
+	if bb2.IsClosed() {
+		t.Errorf("GOT: %v; WANT: %v", true, false)
+	}
+	if got, want := mw.Remove(NopCloseWriter(bb2)), 1; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
+
+func TestMultiWriteCloserFanOutMemberGoroutines(t *testing.T) {
+	lr1, lr2 := new(lockedRecorder), new(lockedRecorder)
+	mw := NewMultiWriteCloserFanOut(lr1, lr2)
+	member := mw.writerMap[lr1]
+
+	var wg sync.WaitGroup
+	wg.Add(4)
+	for i := 0; i < 4; i++ {
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_, err := mw.Write([]byte("x"))
+				ensureError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+	for _, lr := range []*lockedRecorder{lr1, lr2} {
+		if got, _ := lr.snapshot(); len(got) != 400 {
+			t.Errorf("GOT: %v; WANT: %v", len(got), 400)
+		}
+	}
+
+	mw.Remove(lr1)
+	if !member.halted {
+		t.Errorf("GOT: %v; WANT: %v", false, true)
+	}
+	ensureError(t, mw.Close())
+	if !mw.writerMap[lr2].halted {
+		t.Errorf("GOT: %v; WANT: %v", false, true)
+	}
+
+	// Writes after Close do not rely on the halted go-routines.
+	_, err := mw.Write([]byte("y"))
+	ensureError(t, err)
+	if got, _ := lr2.snapshot(); len(got) != 401 {
+		t.Errorf("GOT: %v; WANT: %v", len(got), 401)
+	}
+}
+
+// hangingCloser blocks each Close until its gate is closed.
+type hangingCloser struct {
+	*NopCloseBuffer
+	gate chan struct{}
+}
+
+func (h *hangingCloser) Close() error {
+	<-h.gate
+	return errors.New("closed late")
+}
+
+func TestMultiWriteCloserFanOutCloseWithTimeout(t *testing.T) {
+	gate := make(chan struct{})
+	defer close(gate)
+	bb := NewNopCloseBuffer()
+	hung1 := &hangingCloser{NopCloseBuffer: NewNopCloseBuffer(), gate: gate}
+	hung2 := &hangingCloser{NopCloseBuffer: NewNopCloseBuffer(), gate: gate}
+	fc := NewFakeClock(time.Now())
+	mw, err := NewFanOut(FanOutWriters(bb, hung1, hung2), FanOutClock(fc))
+	ensureError(t, err)
+	writers := mw.Writers()
+	events := mw.Events()
+
+	errs := make(chan error, 1)
+	go func() { errs <- mw.CloseWithTimeout(time.Hour) }()
+
+	// Advance the clock only after Close has collected the result from bb, so the
+	// timeout reports exactly the hung writers.
+	for ev := range events {
+		if ev.Kind == EventClose && ev.Writer == io.WriteCloser(bb) {
+			break
+		}
+	}
+	fc.BlockUntil(1)
+	fc.Advance(time.Hour)
+
+	err = <-errs
+	ensureError(t, err, "timeout after 1h0m0s")
+	el, ok := err.(ErrList)
+	if !ok {
+		t.Fatalf("GOT: %T; WANT: %T", err, el)
+	}
+	failures := el.Failures()
+	if got, want := len(failures), 2; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	for _, failure := range failures {
+		if w := writers[failure.Index]; w != io.WriteCloser(hung1) && w != io.WriteCloser(hung2) {
+			t.Errorf("GOT: %v; WANT: %v", failure.Index, "index of a hung writer")
+		}
+		if got, want := failure.Err, ErrTimeout(time.Hour); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	}
+	if !bb.IsClosed() {
+		t.Errorf("GOT: %v; WANT: %v", false, true)
+	}
+}
+
+func TestMultiWriteCloserFanOutCloseWaitsForWrites(t *testing.T) {
+	stuck := &gatedWriteCloser{started: make(chan struct{}, 1), gate: make(chan struct{})}
+	mw := NewMultiWriteCloserFanOut(stuck)
+
+	written := make(chan error, 1)
+	go func() {
+		_, err := mw.Write([]byte(alphabet))
+		written <- err
+	}()
+	<-stuck.started
+
+	closed := make(chan error, 1)
+	go func() { closed <- mw.Close() }()
+	time.Sleep(10 * time.Millisecond) // give Close the opportunity to close the writer too early
+	if stuck.isClosed() {
+		t.Errorf("GOT: %v; WANT: %v", true, false)
+	}
+	select {
+	case err := <-closed:
+		t.Fatalf("GOT: %v; WANT: Close to wait for the write", err)
+	default:
+	}
+
+	close(stuck.gate)
+	ensureError(t, <-written)
+	ensureError(t, <-closed)
+	if !stuck.isClosed() {
+		t.Errorf("GOT: %v; WANT: %v", false, true)
+	}
+}
+
+// orderRecorder appends its name to a shared log for each write.
+type orderRecorder struct {
+	name string
+	log  *[]string
+}
+
+func (o *orderRecorder) Write(data []byte) (int, error) {
+	*o.log = append(*o.log, o.name)
+	return len(data), nil
+}
+
+func (o *orderRecorder) Close() error { return nil }
+
+func TestMultiWriteCloserFanOutWriteSeries(t *testing.T) {
+	var log []string
+	first := &orderRecorder{name: "first", log: &log}
+	second := &orderRecorder{name: "second", log: &log}
+	third := &orderRecorder{name: "third", log: &log}
+	bad := &testWriteCloser{}
+	mw := NewMultiWriteCloserFanOut(first)
+	mw.Add(bad)
+	mw.Add(second)
+	mw.Add(third)
+	mw.SetErrorPolicy(KeepAndReport)
+
+	n, outcomes, err := mw.WriteSeriesReport([]byte("blob"))
+	ensureError(t, err, "short write")
+	if want := 4; n != want {
+		t.Errorf("GOT: %v; WANT: %v", n, want)
+	}
+	if want := []string{"first", "second", "third"}; !reflect.DeepEqual(log, want) {
+		t.Errorf("GOT: %v; WANT: %v", log, want)
+	}
+	want := []FanOutMemberOutcome{
+		{Writer: first, N: 4},
+		{Writer: bad, N: 0, Err: io.ErrShortWrite},
+		{Writer: second, N: 4},
+		{Writer: third, N: 4},
+	}
+	if !reflect.DeepEqual(outcomes, want) {
+		t.Errorf("GOT: %v; WANT: %v", outcomes, want)
+	}
+
+	mw.Remove(bad)
+	log = nil
+	_, err = mw.WriteSeries([]byte("blob"))
+	ensureError(t, err)
+	if want := []string{"first", "second", "third"}; !reflect.DeepEqual(log, want) {
+		t.Errorf("GOT: %v; WANT: %v", log, want)
+	}
+	if got, want := mw.Writers(), []io.WriteCloser{first, second, third}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
+
+// flushRecorder records the order in which it is flushed and closed.
+type flushRecorder struct {
+	calls    []string
+	flushErr error
+}
+
+func (f *flushRecorder) Write(data []byte) (int, error) { return len(data), nil }
+
+func (f *flushRecorder) Flush() error {
+	f.calls = append(f.calls, "flush")
+	return f.flushErr
+}
+
+func (f *flushRecorder) Close() error {
+	f.calls = append(f.calls, "close")
+	return errors.New("close failed")
+}
+
+func TestMultiWriteCloserFanOutCloseFlushes(t *testing.T) {
+	fr := &flushRecorder{flushErr: errors.New("flush failed")}
+	mw := NewMultiWriteCloserFanOut(fr, NewNopCloseBuffer())
+	ensureError(t, mw.Close(), "flush failed", "close failed")
+	if want := []string{"flush", "close"}; !reflect.DeepEqual(fr.calls, want) {
+		t.Errorf("GOT: %v; WANT: %v", fr.calls, want)
+	}
+}
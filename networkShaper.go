@@ -0,0 +1,471 @@
+package gorill
+
+import (
+	"container/heap"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ShapeProfile describes the simulated network conditions for one direction of
+// a NetworkShaper.
+type ShapeProfile struct {
+	// Bandwidth is the number of bytes per second that may be transferred.
+	// Zero means unlimited.
+	Bandwidth int64
+
+	// Delay is the propagation delay added to every transfer.
+	Delay time.Duration
+
+	// Jitter is the maximum random variation added to or subtracted from the
+	// delay of each transfer.
+	Jitter time.Duration
+
+	// Reorder is the probability, from 0 to 1, that a transfer is held back
+	// for an additional Delay, so that it arrives after transfers sent after
+	// it. It is only honored in message mode, because reordering the chunks
+	// of a byte stream would corrupt it.
+	Reorder float64
+}
+
+// NetworkShaper is an io.ReadWriteCloser that simulates the bandwidth,
+// propagation delay, jitter, and reordering of a wide area network in both
+// directions of an underlying io.ReadWriteCloser, for soak testing code built
+// on top of it.
+//
+// A Write blocks only for as long as it takes to transfer its bytes at the
+// configured bandwidth, and the bytes arrive at the underlying
+// io.ReadWriteCloser after the propagation delay. Likewise, bytes read from the
+// underlying io.ReadWriteCloser become available to Read after the time it
+// takes to transfer them plus the propagation delay.
+type NetworkShaper struct {
+	clock       Clock
+	messageMode bool
+	readLink    *shapedLink
+	rng         *rand.Rand
+	rngLock     sync.Mutex
+	rwc         io.ReadWriteCloser
+	writeLink   *shapedLink
+
+	readLock sync.Mutex
+	readCond *sync.Cond
+	readBuf  [][]byte // readBuf holds delivered chunks not yet read
+	readErr  error    // readErr is returned after readBuf is drained
+
+	closeOnce sync.Once
+	closeErr  error
+	closed    int32 // closed is accessed atomically
+}
+
+// NetworkShaperSetter is any function that modifies a NetworkShaper being
+// instantiated.
+type NetworkShaperSetter func(*NetworkShaper) error
+
+// ShapeReads is used to configure the conditions simulated for bytes read from
+// the underlying io.ReadWriteCloser.
+func ShapeReads(profile ShapeProfile) NetworkShaperSetter {
+	return func(ns *NetworkShaper) error {
+		if err := profile.validate(); err != nil {
+			return err
+		}
+		ns.readLink.profile = profile
+		return nil
+	}
+}
+
+// ShapeWrites is used to configure the conditions simulated for bytes written
+// to the underlying io.ReadWriteCloser.
+func ShapeWrites(profile ShapeProfile) NetworkShaperSetter {
+	return func(ns *NetworkShaper) error {
+		if err := profile.validate(); err != nil {
+			return err
+		}
+		ns.writeLink.profile = profile
+		return nil
+	}
+}
+
+// ShapeSeed is used to configure the seed of the random number generator used
+// for jitter and reordering, making a simulation repeatable.
+func ShapeSeed(seed int64) NetworkShaperSetter {
+	return func(ns *NetworkShaper) error {
+		ns.rng = rand.New(rand.NewSource(seed))
+		return nil
+	}
+}
+
+// ShapeClock is used to configure the Clock that times transfers and
+// propagation delays. It is intended for tests, which may provide a FakeClock.
+func ShapeClock(clock Clock) NetworkShaperSetter {
+	return func(ns *NetworkShaper) error {
+		if clock == nil {
+			return fmt.Errorf("clock must not be nil")
+		}
+		ns.clock = clock
+		return nil
+	}
+}
+
+// ShapeMessageMode is used to configure a NetworkShaper to treat each Write,
+// and each Read from the underlying io.ReadWriteCloser, as a discrete message.
+// Messages are delivered whole, and may be delivered out of order when jitter
+// or reordering is configured. A Read never returns bytes from more than one
+// message.
+func ShapeMessageMode() NetworkShaperSetter {
+	return func(ns *NetworkShaper) error {
+		ns.messageMode = true
+		return nil
+	}
+}
+
+func (p ShapeProfile) validate() error {
+	if p.Bandwidth < 0 {
+		return fmt.Errorf("bandwidth must not be negative: %d", p.Bandwidth)
+	}
+	if p.Delay < 0 || p.Jitter < 0 {
+		return fmt.Errorf("delay and jitter must not be negative: %s, %s", p.Delay, p.Jitter)
+	}
+	if p.Reorder < 0 || p.Reorder > 1 {
+		return fmt.Errorf("reorder probability must be between 0 and 1: %g", p.Reorder)
+	}
+	return nil
+}
+
+// NewNetworkShaper returns a NetworkShaper that simulates the configured
+// network conditions for rwc.
+//
+//   client, server, err := gorill.NewBufferedPipe(gorill.DefaultBufferedPipeSize)
+//   if err != nil {
+//       return err
+//   }
+//   wan := gorill.ShapeProfile{Bandwidth: 1 << 20, Delay: 40 * time.Millisecond, Jitter: 5 * time.Millisecond}
+//   shaped, err := gorill.NewNetworkShaper(client, gorill.ShapeReads(wan), gorill.ShapeWrites(wan))
+func NewNetworkShaper(rwc io.ReadWriteCloser, setters ...NetworkShaperSetter) (*NetworkShaper, error) {
+	ns := &NetworkShaper{
+		clock:     systemClock{},
+		readLink:  newShapedLink(),
+		rng:       rand.New(rand.NewSource(time.Now().UnixNano())),
+		rwc:       rwc,
+		writeLink: newShapedLink(),
+	}
+	ns.readCond = sync.NewCond(&ns.readLock)
+	for _, setter := range setters {
+		if err := setter(ns); err != nil {
+			return nil, err
+		}
+	}
+	ns.readLink.ns, ns.writeLink.ns = ns, ns
+
+	go ns.writeLink.deliver(func(p *shapedPacket) {
+		if p.err != nil {
+			return
+		}
+		n, err := ns.rwc.Write(p.data)
+		if err == nil && n < len(p.data) {
+			err = io.ErrShortWrite
+		}
+		if err != nil {
+			ns.writeLink.fail(err)
+		}
+	})
+	go ns.readLink.deliver(func(p *shapedPacket) {
+		ns.readLock.Lock()
+		if p.err != nil {
+			if ns.readErr == nil {
+				ns.readErr = p.err
+			}
+		} else {
+			ns.readBuf = append(ns.readBuf, p.data)
+		}
+		ns.readCond.Broadcast()
+		ns.readLock.Unlock()
+	})
+	go ns.pump()
+	return ns, nil
+}
+
+// jitter returns a random duration between -max and +max.
+func (ns *NetworkShaper) jitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	ns.rngLock.Lock()
+	defer ns.rngLock.Unlock()
+	return time.Duration(ns.rng.Int63n(int64(2*max)+1)) - max
+}
+
+// chance returns true with the specified probability.
+func (ns *NetworkShaper) chance(probability float64) bool {
+	if probability <= 0 {
+		return false
+	}
+	ns.rngLock.Lock()
+	defer ns.rngLock.Unlock()
+	return ns.rng.Float64() < probability
+}
+
+// pump reads from the underlying io.ReadWriteCloser, and sends what it reads
+// through the read link.
+func (ns *NetworkShaper) pump() {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := ns.rwc.Read(buf)
+		if n > 0 {
+			ns.readLink.send(append([]byte(nil), buf[:n]...))
+		}
+		if err != nil {
+			ns.readLink.sendError(err)
+			return
+		}
+	}
+}
+
+// Read reads up to len(p) bytes that have arrived from the underlying
+// io.ReadWriteCloser.
+func (ns *NetworkShaper) Read(p []byte) (int, error) {
+	ns.readLock.Lock()
+	defer ns.readLock.Unlock()
+
+	for len(ns.readBuf) == 0 && ns.readErr == nil {
+		ns.readCond.Wait()
+	}
+	if len(ns.readBuf) == 0 {
+		return 0, ns.readErr
+	}
+	n := copy(p, ns.readBuf[0])
+	if n == len(ns.readBuf[0]) || ns.messageMode {
+		// In message mode, the remainder of a message too large for p is
+		// discarded, as with a datagram socket.
+		ns.readBuf = ns.readBuf[1:]
+	} else {
+		ns.readBuf[0] = ns.readBuf[0][n:]
+	}
+	return n, nil
+}
+
+// Write blocks for the time it takes to transfer data at the configured
+// bandwidth, then returns while data propagates to the underlying
+// io.ReadWriteCloser. An error writing to the underlying io.ReadWriteCloser is
+// returned by a subsequent Write or Close.
+func (ns *NetworkShaper) Write(data []byte) (int, error) {
+	if atomic.LoadInt32(&ns.closed) == 1 {
+		return 0, ErrWriteAfterClose{}
+	}
+	if err := ns.writeLink.failed(); err != nil {
+		return 0, err
+	}
+	if !ns.writeLink.send(append([]byte(nil), data...)) {
+		return 0, ErrWriteAfterClose{} // Close raced past the check above
+	}
+	return len(data), nil
+}
+
+// Unwrap returns the shaped io.ReadWriteCloser.
+func (ns *NetworkShaper) Unwrap() io.ReadWriteCloser { return ns.rwc }
+
+// Close waits for bytes already written to arrive at the underlying
+// io.ReadWriteCloser, then closes it.
+func (ns *NetworkShaper) Close() error {
+	ns.closeOnce.Do(func() {
+		atomic.StoreInt32(&ns.closed, 1)
+		ns.writeLink.drain()
+		var errors ErrList
+		errors.Append(ns.writeLink.failed())
+		errors.Append(ns.rwc.Close())
+		ns.closeErr = errors.Err()
+
+		ns.readLock.Lock()
+		if ns.readErr == nil {
+			ns.readErr = ErrReadAfterClose{}
+		}
+		ns.readCond.Broadcast()
+		ns.readLock.Unlock()
+	})
+	return ns.closeErr
+}
+
+// shapedPacket is a chunk of data, or a terminal error, in transit.
+type shapedPacket struct {
+	data      []byte
+	deliverAt time.Time
+	err       error
+	seq       uint64
+}
+
+// packetQueue is a heap of packets ordered by delivery time.
+type packetQueue []*shapedPacket
+
+func (q packetQueue) Len() int { return len(q) }
+func (q packetQueue) Less(i, j int) bool {
+	if q[i].deliverAt.Equal(q[j].deliverAt) {
+		return q[i].seq < q[j].seq
+	}
+	return q[i].deliverAt.Before(q[j].deliverAt)
+}
+func (q packetQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *packetQueue) Push(x interface{}) { *q = append(*q, x.(*shapedPacket)) }
+func (q *packetQueue) Pop() interface{} {
+	old := *q
+	p := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return p
+}
+
+// shapedLink simulates one direction of a network path.
+type shapedLink struct {
+	closed       bool // closed is set once the terminal packet is queued, under sendLock
+	cond         *sync.Cond
+	err          error // err is the sticky error from delivering a packet
+	inFlight     int
+	lastDelivery time.Time // lastDelivery is the latest delivery time of any packet
+	lock         sync.Mutex
+	nextFree     time.Time // nextFree is when the link finishes its current transfer
+	ns           *NetworkShaper
+	profile      ShapeProfile
+	queue        packetQueue
+	seq          uint64
+	sendLock     sync.Mutex // sendLock serializes senders, which share bandwidth
+	wake         chan struct{}
+}
+
+func newShapedLink() *shapedLink {
+	l := &shapedLink{wake: make(chan struct{}, 1)}
+	l.cond = sync.NewCond(&l.lock)
+	return l
+}
+
+// send blocks for the time it takes to transfer data at the configured
+// bandwidth, then queues data for delivery after the propagation delay. It
+// returns false without queuing data when the terminal packet has already been
+// queued, because a packet queued after it would never be delivered.
+func (l *shapedLink) send(data []byte) bool {
+	l.sendLock.Lock()
+	defer l.sendLock.Unlock()
+
+	if l.closed {
+		return false
+	}
+	now := l.ns.clock.Now()
+	start := l.nextFree
+	if start.Before(now) {
+		start = now
+	}
+	end := start
+	if l.profile.Bandwidth > 0 {
+		end = start.Add(time.Duration(int64(len(data)) * int64(time.Second) / l.profile.Bandwidth))
+	}
+	l.nextFree = end
+	l.ns.clock.Sleep(end.Sub(now))
+
+	deliverAt := end.Add(l.profile.Delay + l.ns.jitter(l.profile.Jitter))
+	if deliverAt.Before(end) {
+		deliverAt = end
+	}
+	if l.ns.messageMode {
+		if l.ns.chance(l.profile.Reorder) {
+			deliverAt = deliverAt.Add(l.profile.Delay)
+		}
+	} else if deliverAt.Before(l.lastDelivery) {
+		deliverAt = l.lastDelivery // preserve the order of a byte stream
+	}
+	if deliverAt.After(l.lastDelivery) {
+		l.lastDelivery = deliverAt
+	}
+	l.enqueue(&shapedPacket{data: data, deliverAt: deliverAt})
+	return true
+}
+
+// sendError queues a terminal error for delivery after all data sent.
+func (l *shapedLink) sendError(err error) {
+	l.sendLock.Lock()
+	defer l.sendLock.Unlock()
+
+	l.closed = true
+	l.enqueue(&shapedPacket{deliverAt: l.lastDelivery, err: err})
+}
+
+func (l *shapedLink) enqueue(p *shapedPacket) {
+	l.lock.Lock()
+	l.seq++
+	p.seq = l.seq
+	heap.Push(&l.queue, p)
+	l.inFlight++
+	l.lock.Unlock()
+
+	select {
+	case l.wake <- struct{}{}:
+	default:
+	}
+}
+
+// deliver invokes callback with each packet when its delivery time arrives. A
+// packet carrying an error is the final packet.
+func (l *shapedLink) deliver(callback func(*shapedPacket)) {
+	clock := l.ns.clock
+	timer := clock.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		l.lock.Lock()
+		var wait time.Duration = -1
+		var p *shapedPacket
+		if len(l.queue) > 0 {
+			if wait = l.queue[0].deliverAt.Sub(clock.Now()); wait <= 0 {
+				p = heap.Pop(&l.queue).(*shapedPacket)
+			}
+		}
+		l.lock.Unlock()
+
+		if p != nil {
+			callback(p)
+			l.lock.Lock()
+			l.inFlight--
+			l.cond.Broadcast()
+			l.lock.Unlock()
+			if p.err != nil {
+				return
+			}
+			continue
+		}
+		if wait < 0 {
+			<-l.wake
+			continue
+		}
+		timer.Reset(wait)
+		select {
+		case <-l.wake:
+			if !timer.Stop() {
+				<-timer.C()
+			}
+		case <-timer.C():
+		}
+	}
+}
+
+// drain sends a terminal packet, and waits for all packets to be delivered.
+func (l *shapedLink) drain() {
+	l.sendError(io.EOF)
+	l.lock.Lock()
+	for l.inFlight > 0 {
+		l.cond.Wait()
+	}
+	l.lock.Unlock()
+}
+
+// fail records the error from delivering a packet.
+func (l *shapedLink) fail(err error) {
+	l.lock.Lock()
+	if l.err == nil {
+		l.err = err
+	}
+	l.lock.Unlock()
+}
+
+// failed returns the error from delivering a packet, if any.
+func (l *shapedLink) failed() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.err
+}
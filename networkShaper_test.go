@@ -0,0 +1,226 @@
+package gorill
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestNetworkShaperInvalidProfile(t *testing.T) {
+	client, _, err := NewBufferedPipe(DefaultBufferedPipeSize)
+	ensureError(t, err)
+	_, err = NewNetworkShaper(client, ShapeWrites(ShapeProfile{Bandwidth: -1}))
+	ensureError(t, err, "bandwidth must not be negative")
+
+	_, err = NewNetworkShaper(client, ShapeReads(ShapeProfile{Reorder: 1.5}))
+	ensureError(t, err, "reorder probability")
+}
+
+func TestNetworkShaperWriteDelay(t *testing.T) {
+	client, server, err := NewBufferedPipe(DefaultBufferedPipeSize)
+	ensureError(t, err)
+	ns, err := NewNetworkShaper(client, ShapeWrites(ShapeProfile{Delay: 50 * time.Millisecond}))
+	ensureError(t, err)
+
+	start := time.Now()
+	n, err := ns.Write([]byte(alphabet))
+	ensureError(t, err)
+	if got, want := n, len(alphabet); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if elapsed := time.Since(start); elapsed >= 50*time.Millisecond {
+		t.Errorf("GOT: %v; WANT: Write to return before propagation delay", elapsed)
+	}
+
+	buf := make([]byte, 64)
+	n, err = server.Read(buf)
+	ensureError(t, err)
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("GOT: %v; WANT: at least %v", elapsed, 50*time.Millisecond)
+	}
+	ensureBuffer(t, buf, n, alphabet)
+	ensureError(t, ns.Close())
+}
+
+func TestNetworkShaperClock(t *testing.T) {
+	client, server, err := NewBufferedPipe(DefaultBufferedPipeSize)
+	ensureError(t, err)
+	_, err = NewNetworkShaper(client, ShapeClock(nil))
+	ensureError(t, err, "clock must not be nil")
+
+	fc := NewFakeClock(time.Now())
+	ns, err := NewNetworkShaper(client, ShapeClock(fc), ShapeWrites(ShapeProfile{Delay: time.Hour}))
+	ensureError(t, err)
+	_, err = ns.Write([]byte(alphabet))
+	ensureError(t, err)
+
+	type result struct {
+		n   int
+		err error
+	}
+	buf := make([]byte, 64)
+	results := make(chan result, 1)
+	go func() {
+		n, err := server.Read(buf)
+		results <- result{n, err}
+	}()
+
+	select {
+	case r := <-results:
+		t.Fatalf("GOT: %v; WANT: data delayed until the clock advances", r.n)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	// The delivery go-routine may not yet have rescheduled its timer, so
+	// advance the clock until the data arrives.
+	for {
+		fc.Advance(time.Hour)
+		select {
+		case r := <-results:
+			ensureError(t, r.err)
+			ensureBuffer(t, buf, r.n, alphabet)
+			ensureError(t, ns.Close())
+			return
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func TestNetworkShaperBandwidth(t *testing.T) {
+	client, server, err := NewBufferedPipe(DefaultBufferedPipeSize)
+	ensureError(t, err)
+	ns, err := NewNetworkShaper(client, ShapeWrites(ShapeProfile{Bandwidth: 1000}))
+	ensureError(t, err)
+
+	start := time.Now()
+	_, err = ns.Write(make([]byte, 100)) // 100 bytes at 1000 bytes per second
+	ensureError(t, err)
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("GOT: %v; WANT: at least %v", elapsed, 100*time.Millisecond)
+	}
+	ensureError(t, ns.Close())
+
+	got, err := ioutil.ReadAll(server)
+	ensureError(t, err)
+	if len(got) != 100 {
+		t.Errorf("GOT: %v; WANT: %v", len(got), 100)
+	}
+}
+
+func TestNetworkShaperStreamPreservesOrder(t *testing.T) {
+	client, server, err := NewBufferedPipe(DefaultBufferedPipeSize)
+	ensureError(t, err)
+	profile := ShapeProfile{Delay: 5 * time.Millisecond, Jitter: 5 * time.Millisecond, Reorder: 1}
+	ns, err := NewNetworkShaper(client, ShapeWrites(profile), ShapeSeed(42))
+	ensureError(t, err)
+
+	var want bytes.Buffer
+	for i := 0; i < 20; i++ {
+		chunk := alphabet[i : i+1]
+		want.WriteString(chunk)
+		_, err = ns.Write([]byte(chunk))
+		ensureError(t, err)
+	}
+	ensureError(t, ns.Close())
+
+	got, err := ioutil.ReadAll(server)
+	ensureError(t, err)
+	if string(got) != want.String() {
+		t.Errorf("GOT: %q; WANT: %q", got, want.String())
+	}
+}
+
+func TestNetworkShaperMessageModeReorders(t *testing.T) {
+	client, server, err := NewBufferedPipe(DefaultBufferedPipeSize)
+	ensureError(t, err)
+	profile := ShapeProfile{Delay: 20 * time.Millisecond, Reorder: 0.5}
+	ns, err := NewNetworkShaper(server, ShapeReads(profile), ShapeMessageMode(), ShapeSeed(1))
+	ensureError(t, err)
+
+	const count = 20
+	go func() {
+		for i := 0; i < count; i++ {
+			_, _ = client.Write([]byte{byte('a' + i)})
+			time.Sleep(2 * time.Millisecond) // keep messages distinct on the wire
+		}
+		_ = client.Close()
+	}()
+
+	var got []byte
+	buf := make([]byte, 8)
+	for {
+		n, err := ns.Read(buf)
+		if err == io.EOF {
+			break
+		}
+		ensureError(t, err)
+		got = append(got, buf[:n]...)
+	}
+	if len(got) != count {
+		t.Fatalf("GOT: %v; WANT: %v", len(got), count)
+	}
+	var inOrder = true
+	for i := range got {
+		if got[i] != byte('a'+i) {
+			inOrder = false
+		}
+	}
+	if inOrder {
+		t.Errorf("GOT: %q; WANT: messages delivered out of order", got)
+	}
+	ensureError(t, ns.Close())
+}
+
+func TestNetworkShaperWriteAfterClose(t *testing.T) {
+	client, _, err := NewBufferedPipe(DefaultBufferedPipeSize)
+	ensureError(t, err)
+	ns, err := NewNetworkShaper(client)
+	ensureError(t, err)
+	ensureError(t, ns.Close())
+
+	_, err = ns.Write([]byte(alphabet))
+	testErrorType(t, err, ErrWriteAfterClose{})
+}
+
+func TestNetworkShaperWriteRacingClose(t *testing.T) {
+	client, server, err := NewBufferedPipe(DefaultBufferedPipeSize)
+	ensureError(t, err)
+	go io.Copy(ioutil.Discard, server)
+	ns, err := NewNetworkShaper(client)
+	ensureError(t, err)
+	ensureError(t, ns.Close())
+
+	// A Write that checked closed before Close must not queue a packet
+	// behind the terminal packet.
+	if got, want := ns.writeLink.send([]byte(alphabet)), false; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	for i := 0; i < 50; i++ {
+		client, server, err := NewBufferedPipe(DefaultBufferedPipeSize)
+		ensureError(t, err)
+		go io.Copy(ioutil.Discard, server)
+		ns, err := NewNetworkShaper(client)
+		ensureError(t, err)
+
+		for j := 0; j < 4; j++ {
+			go func() {
+				for {
+					if _, err := ns.Write([]byte(alphabet)); err != nil {
+						return
+					}
+				}
+			}()
+		}
+		closed := make(chan error, 1)
+		go func() { closed <- ns.Close() }()
+		select {
+		case err = <-closed:
+			ensureError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("GOT: Close blocked; WANT: Close to return")
+		}
+	}
+}
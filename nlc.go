@@ -2,33 +2,309 @@ package gorill
 
 import (
 	"bytes"
+	"fmt"
 	"io"
+	"os"
+	"sort"
+	"strings"
 )
 
 // NewlineCounter counts the number of lines from the io.Reader, returning the
 // same number of lines read regardless of whether the final Read terminated in
-// a newline character.
+// a newline character. It is equivalent to calling CountLinesFromReader with
+// the VisibleLines mode.
 func NewlineCounter(ior io.Reader) (int, error) {
-	var newlines, total, n int
-	var isNotFinalNewline bool
+	var reserved [4096]byte // allocate buffer space on the call stack
+	return countLines(ior, reserved[:], VisibleLines)
+}
+
+// CountMode determines the rule used to count lines.
+type CountMode int
+
+const (
+	// VisibleLines counts the lines a text editor would display: every newline
+	// character terminates a line, and any bytes following the final newline
+	// character form one additional line. An empty stream has no lines, while
+	// a stream consisting of a single newline character has one empty line.
+	VisibleLines CountMode = iota
+
+	// POSIXLines counts only the number of newline characters, the way the wc
+	// program does, consistent with the POSIX definition of a line as a
+	// sequence of characters terminated by a newline character.
+	POSIXLines
+)
+
+// String returns the name of the count mode.
+func (m CountMode) String() string {
+	switch m {
+	case VisibleLines:
+		return "visible"
+	case POSIXLines:
+		return "POSIX"
+	default:
+		return "unknown"
+	}
+}
+
+// LineCountSetter is any function that modifies how lines are counted by
+// CountLinesFromReader and its related functions.
+type LineCountSetter func(*lineCountConfig) error
+
+// lineCountConfig holds the configuration used when counting lines.
+type lineCountConfig struct {
+	bufSize    int
+	decompress bool
+	mode       CountMode
+}
+
+// LineCountBufSize is used to configure the size of the buffer used to read
+// from the source when counting lines.
+func LineCountBufSize(size int) LineCountSetter {
+	return func(c *lineCountConfig) error {
+		if size <= 0 {
+			return fmt.Errorf("buffer size must be greater than 0: %d", size)
+		}
+		c.bufSize = size
+		return nil
+	}
+}
+
+// LineCountDecompress is used to configure counting lines of the decompressed
+// contents of a gzip or bzip2 compressed stream. Streams that are not
+// compressed are counted as is. See NewDecompressingReader.
+//
+//   lines, err := gorill.CountLinesFromFile("/var/log/messages.1.gz", gorill.LineCountDecompress())
+func LineCountDecompress() LineCountSetter {
+	return func(c *lineCountConfig) error {
+		c.decompress = true
+		return nil
+	}
+}
+
+// LineCountMode is used to configure the rule used to count lines. The default
+// mode is VisibleLines.
+func LineCountMode(mode CountMode) LineCountSetter {
+	return func(c *lineCountConfig) error {
+		switch mode {
+		case VisibleLines, POSIXLines:
+		default:
+			return fmt.Errorf("invalid count mode: %d", mode)
+		}
+		c.mode = mode
+		return nil
+	}
+}
+
+// CountFinalFragment is used to configure whether a final line that is not
+// terminated by a newline character is counted. Text editors count it, and it
+// is counted by default. The wc program does not count it. Passing true is
+// equivalent to the VisibleLines mode, and passing false is equivalent to the
+// POSIXLines mode.
+func CountFinalFragment(count bool) LineCountSetter {
+	if count {
+		return LineCountMode(VisibleLines)
+	}
+	return LineCountMode(POSIXLines)
+}
+
+// CountLinesFromReader returns the number of lines read from ior, according to
+// the configured CountMode. Every newline character terminates a line, and in
+// the default VisibleLines mode, any bytes following the final newline
+// character are counted as an additional line.
+//
+//   lines, err := gorill.CountLinesFromReader(ior, gorill.CountFinalFragment(false))
+func CountLinesFromReader(ior io.Reader, setters ...LineCountSetter) (int, error) {
+	config, err := newLineCountConfig(setters)
+	if err != nil {
+		return 0, err
+	}
+	return config.count(ior, make([]byte, config.bufSize))
+}
+
+// CountLinesFromReaderBuffer returns the number of lines read from ior, using
+// the caller provided buf as scratch space rather than allocating a buffer.
+// This allows a program counting lines in many large files to use a single
+// large buffer. The LineCountBufSize setter is ignored by this function.
+//
+//   buf := make([]byte, 1<<20)
+//   for _, fh := range files {
+//       lines, err := gorill.CountLinesFromReaderBuffer(fh, buf)
+//       // ...
+//   }
+func CountLinesFromReaderBuffer(ior io.Reader, buf []byte, setters ...LineCountSetter) (int, error) {
+	if len(buf) == 0 {
+		return 0, fmt.Errorf("buffer must not be empty")
+	}
+	config, err := newLineCountConfig(setters)
+	if err != nil {
+		return 0, err
+	}
+	return config.count(ior, buf)
+}
+
+// newLineCountConfig returns the line count configuration after applying the
+// setters.
+func newLineCountConfig(setters []LineCountSetter) (lineCountConfig, error) {
+	config := lineCountConfig{bufSize: DefaultBufSize, mode: VisibleLines}
+	for _, setter := range setters {
+		if err := setter(&config); err != nil {
+			return config, err
+		}
+	}
+	return config, nil
+}
+
+// count returns the number of lines read from ior according to the
+// configuration, using buf as scratch space.
+func (c lineCountConfig) count(ior io.Reader, buf []byte) (int, error) {
+	if !c.decompress {
+		return countLines(ior, buf, c.mode)
+	}
+	rc, err := NewDecompressingReader(ior)
+	if err != nil {
+		return 0, err
+	}
+	lines, err := countLines(rc, buf, c.mode)
+	if cerr := rc.Close(); err == nil {
+		err = cerr
+	}
+	return lines, err
+}
+
+// countLines returns the number of lines read from ior, using buf as scratch
+// space. It scans each byte exactly once.
+func countLines(ior io.Reader, buf []byte, mode CountMode) (int, error) {
+	var newlines int
+	isFinalFragment, err := scanChunks(ior, buf, func(chunk []byte, _ int64) error {
+		newlines += bytes.Count(chunk, []byte{'\n'})
+		return nil
+	})
+	if isFinalFragment && mode == VisibleLines {
+		newlines++
+	}
+	return newlines, err
+}
+
+// scanChunks is the scanning core shared by the line counting and line
+// indexing functions. It reads ior into buf until end of stream, invoking
+// callback with each chunk read along with the offset of the chunk from the
+// start of the stream. It returns true when the final byte read was not a
+// newline character, and any error other than io.EOF returned while reading
+// from ior, or returned by callback.
+func scanChunks(ior io.Reader, buf []byte, callback func(chunk []byte, offset int64) error) (bool, error) {
+	var isFinalFragment bool
+	var offset int64
+
+	for {
+		n, err := ior.Read(buf)
+		if n > 0 {
+			if cerr := callback(buf[:n], offset); cerr != nil {
+				return false, cerr
+			}
+			isFinalFragment = buf[n-1] != '\n'
+			offset += int64(n)
+		}
+		if err != nil {
+			if err == io.EOF {
+				err = nil // io.EOF is expected at end of stream
+			}
+			return isFinalFragment, err
+		}
+	}
+}
+
+// CountLinesFromFile returns the number of lines in the file having the
+// specified pathname.
+func CountLinesFromFile(pathname string, setters ...LineCountSetter) (int, error) {
+	fh, err := os.Open(pathname)
+	if err != nil {
+		return 0, err
+	}
+	lines, err := CountLinesFromReader(fh, setters...)
+	if cerr := fh.Close(); err == nil {
+		err = cerr
+	}
+	return lines, err
+}
+
+// CountLinesFromString returns the number of lines in s.
+func CountLinesFromString(s string, setters ...LineCountSetter) (int, error) {
+	return CountLinesFromReader(strings.NewReader(s), setters...)
+}
+
+// LineWidthHistogram accumulates the distribution of line widths observed by
+// MaxLineWidthFromReader.
+type LineWidthHistogram struct {
+	// Bounds holds the inclusive upper bound of each bucket, in ascending
+	// order.
+	Bounds []int
+
+	// Counts holds the number of lines whose width fell into each bucket. It
+	// has one more element than Bounds, with the final element counting the
+	// lines wider than the final bound.
+	Counts []int
+}
+
+// NewLineWidthHistogram returns a LineWidthHistogram with buckets having the
+// specified inclusive upper bounds, which must be in strictly ascending order.
+//
+//   h, err := gorill.NewLineWidthHistogram(40, 80, 120)
+//   if err != nil {
+//       return err
+//   }
+//   max, err := gorill.MaxLineWidthFromReader(fh, h)
+//   // h.Counts[3] holds number of lines wider than 120 bytes
+func NewLineWidthHistogram(bounds ...int) (*LineWidthHistogram, error) {
+	for i := 1; i < len(bounds); i++ {
+		if bounds[i] <= bounds[i-1] {
+			return nil, fmt.Errorf("bounds must be in ascending order: %d <= %d", bounds[i], bounds[i-1])
+		}
+	}
+	return &LineWidthHistogram{
+		Bounds: append([]int(nil), bounds...),
+		Counts: make([]int, len(bounds)+1),
+	}, nil
+}
+
+// record adds a line of the specified width to the histogram.
+func (h *LineWidthHistogram) record(width int) {
+	h.Counts[sort.SearchInts(h.Bounds, width)]++
+}
+
+// MaxLineWidthFromReader returns the width in bytes of the widest line read
+// from ior, not including the newline character. When h is not nil, the width
+// of every line is also recorded in h. It reads ior in a single pass, and does
+// not allocate memory for each line. A final line that is not terminated by a
+// newline character is included.
+func MaxLineWidthFromReader(ior io.Reader, h *LineWidthHistogram) (int, error) {
+	var max, width int
 	var err error
 	var reserved [4096]byte // allocate buffer space on the call stack
 	buf := reserved[:]      // create slice using pre-allocated array from reserved
 
+	endLine := func() {
+		if width > max {
+			max = width
+		}
+		if h != nil {
+			h.record(width)
+		}
+		width = 0
+	}
+
 	for {
+		var n int
 		n, err = ior.Read(buf)
-		if n > 0 {
-			total += n
-			isNotFinalNewline = buf[n-1] != '\n'
-			var searchOffset int
-			for {
-				index := bytes.IndexRune(buf[searchOffset:n], '\n')
-				if index == -1 {
-					break // done counting newlines from this chunk
-				}
-				newlines++                // count this newline
-				searchOffset += index + 1 // start next search following this newline
+		chunk := buf[:n]
+		for len(chunk) > 0 {
+			index := bytes.IndexByte(chunk, '\n')
+			if index == -1 {
+				width += len(chunk)
+				break
 			}
+			width += index
+			endLine()
+			chunk = chunk[index+1:]
 		}
 		if err != nil {
 			if err == io.EOF {
@@ -37,13 +313,8 @@ func NewlineCounter(ior io.Reader) (int, error) {
 			break // do not try to read more if error
 		}
 	}
-
-	// Return the same number of lines read regardless of whether the final read
-	// terminated in a newline character.
-	if isNotFinalNewline {
-		newlines++
-	} else if total == 1 {
-		newlines--
+	if width > 0 {
+		endLine() // final line not terminated by newline
 	}
-	return newlines, err
+	return max, err
 }
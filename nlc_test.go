@@ -1,6 +1,10 @@
 package gorill
 
 import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
 	"strings"
 	"testing"
 )
@@ -39,9 +43,10 @@ func TestNewlineCounter(t *testing.T) {
 
 	t.Run("with newline", func(t *testing.T) {
 		t.Run("empty", func(t *testing.T) {
+			// A single newline character terminates one empty line.
 			c, err := NewlineCounter(strings.NewReader("\n"))
 			ensureError(t, err)
-			if got, want := c, 0; got != want {
+			if got, want := c, 1; got != want {
 				t.Errorf("GOT: %v; WANT: %v", got, want)
 			}
 		})
@@ -68,3 +73,206 @@ func TestNewlineCounter(t *testing.T) {
 		})
 	})
 }
+
+func TestMaxLineWidthFromReader(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		max, err := MaxLineWidthFromReader(strings.NewReader(""), nil)
+		ensureError(t, err)
+		if got, want := max, 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+	t.Run("final line sans newline", func(t *testing.T) {
+		max, err := MaxLineWidthFromReader(strings.NewReader("one\nthree\nfourteen"), nil)
+		ensureError(t, err)
+		if got, want := max, 8; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+	t.Run("line spans reads", func(t *testing.T) {
+		long := strings.Repeat("x", 10000)
+		max, err := MaxLineWidthFromReader(strings.NewReader("one\n"+long+"\ntwo\n"), nil)
+		ensureError(t, err)
+		if got, want := max, len(long); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+	t.Run("histogram", func(t *testing.T) {
+		_, err := NewLineWidthHistogram(4, 2)
+		ensureError(t, err, "ascending")
+
+		h, err := NewLineWidthHistogram(0, 3, 5)
+		ensureError(t, err)
+		max, err := MaxLineWidthFromReader(strings.NewReader("\none\ntwo\nthree\nfourteen\n"), h)
+		ensureError(t, err)
+		if got, want := max, 8; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		for i, want := range []int{1, 2, 1, 1} {
+			if got := h.Counts[i]; got != want {
+				t.Errorf("bucket %d: GOT: %v; WANT: %v", i, got, want)
+			}
+		}
+	})
+}
+
+func TestCountLinesFromString(t *testing.T) {
+	t.Run("invalid buffer size", func(t *testing.T) {
+		_, err := CountLinesFromString("", LineCountBufSize(0))
+		ensureError(t, err, "buffer size must be greater than 0")
+	})
+
+	cases := []struct {
+		input    string
+		fragment int
+		sans     int
+	}{
+		{"", 0, 0},
+		{"one", 1, 0},
+		{"one\n", 1, 1},
+		{"one\ntwo", 2, 1},
+		{"one\ntwo\n", 2, 2},
+		{"\n", 1, 1},
+		{"\n\n", 2, 2},
+	}
+	for _, c := range cases {
+		got, err := CountLinesFromString(c.input, LineCountBufSize(2))
+		ensureError(t, err)
+		if want := c.fragment; got != want {
+			t.Errorf("%q: GOT: %v; WANT: %v", c.input, got, want)
+		}
+		got, err = CountLinesFromString(c.input, CountFinalFragment(false))
+		ensureError(t, err)
+		if want := c.sans; got != want {
+			t.Errorf("%q sans final fragment: GOT: %v; WANT: %v", c.input, got, want)
+		}
+	}
+}
+
+func TestCountLinesFromFile(t *testing.T) {
+	fh, err := ioutil.TempFile("", "gorill")
+	ensureError(t, err)
+	defer os.Remove(fh.Name())
+	_, err = fh.WriteString("one\ntwo\nthree")
+	ensureError(t, err)
+	ensureError(t, fh.Close())
+
+	lines, err := CountLinesFromFile(fh.Name())
+	ensureError(t, err)
+	if got, want := lines, 3; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	_, err = CountLinesFromFile(fh.Name() + ".missing")
+	ensureError(t, err, "no such file")
+}
+
+func TestCountMode(t *testing.T) {
+	t.Run("invalid", func(t *testing.T) {
+		_, err := CountLinesFromString("", LineCountMode(CountMode(42)))
+		ensureError(t, err, "invalid count mode")
+	})
+
+	cases := []struct {
+		name    string
+		input   string
+		visible int
+		posix   int
+	}{
+		{"empty", "", 0, 0},
+		{"single newline", "\n", 1, 1},
+		{"two newlines", "\n\n", 2, 2},
+		{"fragment", "one", 1, 0},
+		{"terminated line", "one\n", 1, 1},
+		{"terminated line plus fragment", "one\ntwo", 2, 1},
+		{"trailing blank line", "one\n\n", 2, 2},
+		{"leading blank line", "\none", 2, 1},
+		{"carriage return is not newline", "one\r", 1, 0},
+		{"crlf", "one\r\ntwo\r\n", 2, 2},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// Small buffer sizes ensure lines span multiple reads.
+			for _, size := range []int{1, 2, 3, DefaultBufSize} {
+				got, err := CountLinesFromString(c.input, LineCountBufSize(size), LineCountMode(VisibleLines))
+				ensureError(t, err)
+				if want := c.visible; got != want {
+					t.Errorf("visible; buffer size %d; GOT: %v; WANT: %v", size, got, want)
+				}
+				got, err = CountLinesFromString(c.input, LineCountBufSize(size), LineCountMode(POSIXLines))
+				ensureError(t, err)
+				if want := c.posix; got != want {
+					t.Errorf("POSIX; buffer size %d; GOT: %v; WANT: %v", size, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCountLinesFromReaderBuffer(t *testing.T) {
+	_, err := CountLinesFromReaderBuffer(strings.NewReader(""), nil)
+	ensureError(t, err, "buffer must not be empty")
+
+	lines, err := CountLinesFromReaderBuffer(strings.NewReader("one\ntwo\nthree"), make([]byte, 2), LineCountMode(POSIXLines))
+	ensureError(t, err)
+	if got, want := lines, 2; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
+
+// newlineCounterIndexRune is the original implementation of NewlineCounter,
+// which repeatedly searched for the next newline, kept for benchmark
+// comparison.
+func newlineCounterIndexRune(ior io.Reader) (int, error) {
+	var newlines, n int
+	var err error
+	var reserved [4096]byte
+	buf := reserved[:]
+
+	for {
+		n, err = ior.Read(buf)
+		if n > 0 {
+			var searchOffset int
+			for {
+				index := bytes.IndexRune(buf[searchOffset:n], '\n')
+				if index == -1 {
+					break
+				}
+				newlines++
+				searchOffset += index + 1
+			}
+		}
+		if err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			break
+		}
+	}
+	return newlines, err
+}
+
+const countLinesBenchmarkSize = 64 << 20
+
+func BenchmarkCountLines(b *testing.B) {
+	b.Run("IndexRune 4KiB", func(b *testing.B) {
+		b.SetBytes(countLinesBenchmarkSize)
+		for i := 0; i < b.N; i++ {
+			_, _ = newlineCounterIndexRune(PatternReader(countLinesBenchmarkSize, []byte(alphabet)))
+		}
+	})
+	b.Run("Count 4KiB", func(b *testing.B) {
+		b.SetBytes(countLinesBenchmarkSize)
+		for i := 0; i < b.N; i++ {
+			_, _ = NewlineCounter(PatternReader(countLinesBenchmarkSize, []byte(alphabet)))
+		}
+	})
+	b.Run("Count 1MiB", func(b *testing.B) {
+		buf := make([]byte, 1<<20)
+		b.SetBytes(countLinesBenchmarkSize)
+		for i := 0; i < b.N; i++ {
+			_, _ = CountLinesFromReaderBuffer(PatternReader(countLinesBenchmarkSize, []byte(alphabet)), buf)
+		}
+	})
+}
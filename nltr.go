@@ -41,3 +41,6 @@ func (r *LineTerminatedReader) Read(p []byte) (int, error) {
 	p[n] = '\n'
 	return n + 1, err
 }
+
+// Unwrap returns the underlying io.Reader.
+func (r *LineTerminatedReader) Unwrap() io.Reader { return r.R }
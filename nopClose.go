@@ -3,6 +3,7 @@ package gorill
 import (
 	"bytes"
 	"io"
+	"time"
 )
 
 // NewNopCloseBuffer returns a structure that wraps bytes.Buffer with a no-op Close method.  It can
@@ -51,6 +52,13 @@ type nopCloseReader struct{ io.Reader }
 
 func (nopCloseReader) Close() error { return nil }
 
+// WriteTo writes all data from the wrapped io.Reader to w, preserving any
+// zero-copy optimization the wrapped io.Reader and w support.
+func (r nopCloseReader) WriteTo(w io.Writer) (int64, error) { return Transfer(w, r.Reader) }
+
+// Unwrap returns the wrapped io.Reader.
+func (r nopCloseReader) Unwrap() io.Reader { return r.Reader }
+
 // NopCloseWriter returns a structure that implements io.WriteCloser, but provides a no-op Close
 // method.  It is useful when you have an io.Writer that you must pass to a method that requires an
 // io.WriteCloser.  It is the counter-part to ioutil.NopCloser, but for io.Writer.
@@ -61,4 +69,14 @@ func NopCloseWriter(iow io.Writer) io.WriteCloser { return nopCloseWriter{iow} }
 
 func (nopCloseWriter) Close() error { return nil }
 
+// ReadFrom reads all data from r into the wrapped io.Writer, preserving any
+// zero-copy optimization r and the wrapped io.Writer support.
+func (w nopCloseWriter) ReadFrom(r io.Reader) (int64, error) { return Transfer(w.Writer, r) }
+
+// SetWriteDeadline sets the write deadline of the wrapped io.Writer.
+func (w nopCloseWriter) SetWriteDeadline(t time.Time) error { return SetWriteDeadline(w.Writer, t) }
+
+// Unwrap returns the wrapped io.Writer.
+func (w nopCloseWriter) Unwrap() io.Writer { return w.Writer }
+
 type nopCloseWriter struct{ io.Writer }
@@ -0,0 +1,56 @@
+package gorill
+
+import (
+	"fmt"
+	"io"
+)
+
+// PatternReader returns an io.Reader that produces exactly size bytes, made up
+// of pattern repeated as many times as necessary, then returns io.EOF. It does
+// not allocate while being read from, making it suitable as a deterministic
+// data source for tests and benchmarks. When pattern is empty, and size is
+// greater than 0, Read returns an error.
+//
+//   r := gorill.PatternReader(10, []byte("abc"))
+//   buf, _ := ioutil.ReadAll(r)
+//   // buf == []byte("abcabcabca")
+func PatternReader(size int64, pattern []byte) io.Reader {
+	return &patternReader{pattern: pattern, remaining: size}
+}
+
+// InfiniteReader returns an io.Reader that produces pattern repeated forever,
+// and never returns an error, unless pattern is empty. It does not allocate
+// while being read from.
+//
+//   r := io.LimitReader(gorill.InfiniteReader([]byte("abc")), 1<<20)
+func InfiniteReader(pattern []byte) io.Reader {
+	return &patternReader{pattern: pattern, remaining: -1}
+}
+
+type patternReader struct {
+	pattern   []byte
+	off       int   // off is the index into pattern of the next byte to produce
+	remaining int64 // remaining is negative for an infinite reader
+}
+
+func (r *patternReader) Read(p []byte) (int, error) {
+	if r.remaining == 0 {
+		return 0, io.EOF
+	}
+	if len(r.pattern) == 0 {
+		return 0, fmt.Errorf("pattern must not be empty")
+	}
+	if r.remaining > 0 && int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	var n int
+	for n < len(p) {
+		nc := copy(p[n:], r.pattern[r.off:])
+		n += nc
+		r.off = (r.off + nc) % len(r.pattern)
+	}
+	if r.remaining > 0 {
+		r.remaining -= int64(n)
+	}
+	return n, nil
+}
@@ -0,0 +1,63 @@
+package gorill
+
+import (
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func TestPatternReader(t *testing.T) {
+	t.Run("empty pattern", func(t *testing.T) {
+		_, err := PatternReader(10, nil).Read(make([]byte, 4))
+		ensureError(t, err, "pattern must not be empty")
+		_, err = InfiniteReader(nil).Read(make([]byte, 4))
+		ensureError(t, err, "pattern must not be empty")
+	})
+
+	t.Run("size zero", func(t *testing.T) {
+		buf, err := ioutil.ReadAll(PatternReader(0, []byte(alphabet)))
+		ensureError(t, err)
+		if got, want := len(buf), 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("pattern spans reads", func(t *testing.T) {
+		r := PatternReader(10, []byte("abc"))
+		buf := make([]byte, 4)
+
+		n, err := r.Read(buf)
+		ensureError(t, err)
+		ensureBuffer(t, buf, n, "abca")
+
+		n, err = r.Read(buf)
+		ensureError(t, err)
+		ensureBuffer(t, buf, n, "bcab")
+
+		n, err = r.Read(buf)
+		ensureError(t, err)
+		ensureBuffer(t, buf, n, "ca")
+
+		n, err = r.Read(buf)
+		ensureError(t, err, "EOF")
+		ensureBuffer(t, buf, n, "")
+	})
+}
+
+func TestInfiniteReader(t *testing.T) {
+	buf, err := ioutil.ReadAll(io.LimitReader(InfiniteReader([]byte(alphabet)), 3*int64(len(alphabet))))
+	ensureError(t, err)
+	if got, want := string(buf), alphabet+alphabet+alphabet; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+}
+
+func BenchmarkPatternReader(b *testing.B) {
+	buf := make([]byte, bufSize)
+	r := InfiniteReader([]byte(alphabet))
+	b.SetBytes(int64(len(buf)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _ = r.Read(buf)
+	}
+}
@@ -0,0 +1,133 @@
+package gorill
+
+import (
+	"fmt"
+	"io"
+)
+
+// PeekReader is an io.ReadCloser that allows looking ahead in any underlying
+// io.ReadCloser by an arbitrary number of bytes, discarding bytes, and pushing
+// bytes back onto the stream, while preserving the ability to close the
+// underlying io.ReadCloser. Unlike bufio.Reader, its lookahead is not limited
+// by a buffer size, and it may be placed at any layer of a stack of readers.
+type PeekReader struct {
+	buf  []byte // buf holds bytes already read from iorc but not yet returned
+	err  error  // err is the error from iorc, returned after buf is drained
+	iorc io.ReadCloser
+}
+
+// NewPeekReader returns a PeekReader that reads from iorc.
+//
+//   pr := gorill.NewPeekReader(conn)
+//   header, err := pr.Peek(4)
+//   if err != nil {
+//       return err
+//   }
+//   if string(header) == "PING" {
+//       _, err = pr.Discard(4)
+//   }
+func NewPeekReader(iorc io.ReadCloser) *PeekReader {
+	return &PeekReader{iorc: iorc}
+}
+
+// fill reads from the underlying io.ReadCloser until at least n bytes are
+// buffered or it returns an error.
+func (pr *PeekReader) fill(n int) {
+	if len(pr.buf) >= n || pr.err != nil {
+		return
+	}
+	if cap(pr.buf) < n {
+		buf := make([]byte, len(pr.buf), n)
+		copy(buf, pr.buf)
+		pr.buf = buf
+	}
+	for len(pr.buf) < n && pr.err == nil {
+		nr, err := pr.iorc.Read(pr.buf[len(pr.buf):n])
+		pr.buf = pr.buf[:len(pr.buf)+nr]
+		pr.err = err
+	}
+}
+
+// Peek returns the next n bytes without consuming them. When fewer than n bytes
+// are available, it returns the bytes available along with the error that
+// prevented reading more. The returned slice is only valid until the next call
+// to a method of the PeekReader, and must not be modified.
+func (pr *PeekReader) Peek(n int) ([]byte, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("cannot peek negative byte count: %d", n)
+	}
+	pr.fill(n)
+	if len(pr.buf) < n {
+		return pr.buf, pr.err
+	}
+	return pr.buf[:n], nil
+}
+
+// Discard skips the next n bytes, returning the number of bytes discarded.
+// When fewer than n bytes are discarded, it also returns the error that
+// prevented discarding more.
+func (pr *PeekReader) Discard(n int) (int, error) {
+	if n < 0 {
+		return 0, fmt.Errorf("cannot discard negative byte count: %d", n)
+	}
+	var discarded int
+	for discarded < n {
+		if len(pr.buf) == 0 {
+			if pr.err != nil {
+				return discarded, pr.err
+			}
+			want := n - discarded
+			if want > DefaultBufSize {
+				want = DefaultBufSize
+			}
+			pr.fill(want)
+			continue
+		}
+		count := n - discarded
+		if count > len(pr.buf) {
+			count = len(pr.buf)
+		}
+		pr.buf = pr.buf[count:]
+		discarded += count
+	}
+	return discarded, nil
+}
+
+// UnreadBytes pushes p back onto the stream, so that it is returned by the next
+// call to Read or Peek, before any bytes previously buffered.
+func (pr *PeekReader) UnreadBytes(p []byte) {
+	buf := make([]byte, len(p)+len(pr.buf))
+	copy(buf, p)
+	copy(buf[len(p):], pr.buf)
+	pr.buf = buf
+}
+
+// Buffered returns the number of bytes read from the underlying io.ReadCloser
+// that have not yet been consumed.
+func (pr *PeekReader) Buffered() int {
+	return len(pr.buf)
+}
+
+// Read reads buffered bytes into p, or when there are none, reads directly from
+// the underlying io.ReadCloser.
+func (pr *PeekReader) Read(p []byte) (int, error) {
+	if len(pr.buf) > 0 {
+		n := copy(p, pr.buf)
+		pr.buf = pr.buf[n:]
+		return n, nil
+	}
+	if pr.err != nil {
+		err := pr.err
+		pr.err = nil // only report a source error once, as the source would
+		return 0, err
+	}
+	return pr.iorc.Read(p)
+}
+
+// Unwrap returns the underlying io.ReadCloser.
+func (pr *PeekReader) Unwrap() io.ReadCloser { return pr.iorc }
+
+// Close closes the underlying io.ReadCloser.
+func (pr *PeekReader) Close() error {
+	return pr.iorc.Close()
+}
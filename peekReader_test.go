@@ -0,0 +1,93 @@
+package gorill
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestPeekReaderPeek(t *testing.T) {
+	pr := NewPeekReader(ioutil.NopCloser(iotest.OneByteReader(strings.NewReader(alphabet))))
+
+	buf, err := pr.Peek(5)
+	ensureError(t, err)
+	if got, want := string(buf), "abcde"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	if got, want := pr.Buffered(), 5; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	buf, err = pr.Peek(len(alphabet) + 10)
+	if err != io.EOF {
+		t.Errorf("GOT: %v; WANT: %v", err, io.EOF)
+	}
+	if got, want := string(buf), alphabet; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+
+	all, err := ioutil.ReadAll(pr)
+	ensureError(t, err)
+	if got, want := string(all), alphabet; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	ensureError(t, pr.Close())
+}
+
+func TestPeekReaderDiscard(t *testing.T) {
+	pr := NewPeekReader(ioutil.NopCloser(strings.NewReader(alphabet)))
+
+	_, err := pr.Peek(3)
+	ensureError(t, err)
+
+	n, err := pr.Discard(10)
+	ensureError(t, err)
+	if got, want := n, 10; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	buf := make([]byte, 3)
+	n, err = pr.Read(buf)
+	ensureError(t, err)
+	ensureBuffer(t, buf, n, "klm")
+
+	n, err = pr.Discard(100)
+	if err != io.EOF {
+		t.Errorf("GOT: %v; WANT: %v", err, io.EOF)
+	}
+	if got, want := n, len(alphabet)-13; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	_, err = pr.Discard(-1)
+	ensureError(t, err, "cannot discard negative byte count")
+	_, err = pr.Peek(-1)
+	ensureError(t, err, "cannot peek negative byte count")
+}
+
+func TestPeekReaderUnreadBytes(t *testing.T) {
+	pr := NewPeekReader(ioutil.NopCloser(strings.NewReader(alphabet)))
+
+	buf := make([]byte, 4)
+	n, err := pr.Read(buf)
+	ensureError(t, err)
+	ensureBuffer(t, buf, n, "abcd")
+
+	_, err = pr.Peek(2)
+	ensureError(t, err)
+	pr.UnreadBytes([]byte("XYcd"))
+
+	head, err := pr.Peek(6)
+	ensureError(t, err)
+	if got, want := string(head), "XYcdef"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+
+	all, err := ioutil.ReadAll(pr)
+	ensureError(t, err)
+	if got, want := string(all), "XY"+alphabet[2:]; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+}
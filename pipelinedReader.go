@@ -0,0 +1,189 @@
+package gorill
+
+import (
+	"fmt"
+	"io"
+)
+
+// DefaultPipelineDepth is the default number of read-ahead requests a
+// PipelinedReader keeps outstanding.
+const DefaultPipelineDepth = 4
+
+// DefaultPipelineChunkSize is the default number of bytes each read-ahead
+// request of a PipelinedReader asks for.
+const DefaultPipelineChunkSize = 256 * 1024
+
+// PipelinedReader is an io.ReadCloser that reads sequentially from an
+// io.ReaderAt while keeping several range reads outstanding ahead of the
+// consumer. When the latency of each read dominates, as it does for network
+// object storage, overlapping the requests this way yields a throughput that
+// the synchronous readers cannot achieve. Completions are reordered so the
+// bytes are always returned in sequence.
+type PipelinedReader struct {
+	ra        io.ReaderAt
+	size      int64 // size is the number of bytes to read, or -1 when unknown
+	depth     int
+	chunkSize int
+	next      int64            // next is the offset of the next read-ahead request
+	pending   []*pipelineChunk // pending read-ahead requests, in offset order
+	current   *pipelineChunk
+	err       error
+}
+
+// pipelineChunk is a single read-ahead request, and when done is closed, its
+// result.
+type pipelineChunk struct {
+	done chan struct{}
+	buf  []byte
+	err  error
+}
+
+// PipelinedReaderSetter is any function that modifies a PipelinedReader being
+// instantiated.
+type PipelinedReaderSetter func(*PipelinedReader) error
+
+// PipelineDepth is used to configure the number of read-ahead requests a
+// PipelinedReader keeps outstanding. At most this many chunks are buffered in
+// memory at once, in addition to the chunk being consumed.
+func PipelineDepth(depth int) PipelinedReaderSetter {
+	return func(pr *PipelinedReader) error {
+		if depth <= 0 {
+			return fmt.Errorf("pipeline depth must be greater than 0: %d", depth)
+		}
+		pr.depth = depth
+		return nil
+	}
+}
+
+// PipelineChunkSize is used to configure the number of bytes each read-ahead
+// request of a PipelinedReader asks for.
+func PipelineChunkSize(size int) PipelinedReaderSetter {
+	return func(pr *PipelinedReader) error {
+		if size <= 0 {
+			return fmt.Errorf("pipeline chunk size must be greater than 0: %d", size)
+		}
+		pr.chunkSize = size
+		return nil
+	}
+}
+
+// NewPipelinedReader returns a PipelinedReader that reads size bytes from ra,
+// starting at offset 0. When size is negative, it reads until ra returns
+// io.EOF.
+//
+//   pr, err := gorill.NewPipelinedReader(object, object.Size(),
+//       gorill.PipelineDepth(8), gorill.PipelineChunkSize(1<<20))
+//   if err != nil {
+//       return err
+//   }
+//   defer pr.Close()
+//   _, err = io.Copy(dst, pr)
+func NewPipelinedReader(ra io.ReaderAt, size int64, setters ...PipelinedReaderSetter) (*PipelinedReader, error) {
+	if size < 0 {
+		size = -1
+	}
+	pr := &PipelinedReader{
+		ra:        ra,
+		size:      size,
+		depth:     DefaultPipelineDepth,
+		chunkSize: DefaultPipelineChunkSize,
+	}
+	for _, setter := range setters {
+		if err := setter(pr); err != nil {
+			return nil, err
+		}
+	}
+	pr.fill()
+	return pr, nil
+}
+
+// fill issues read-ahead requests until depth are outstanding or the end of
+// the source has been requested.
+func (pr *PipelinedReader) fill() {
+	for len(pr.pending) < pr.depth && pr.err == nil {
+		length := int64(pr.chunkSize)
+		if pr.size >= 0 {
+			if remaining := pr.size - pr.next; remaining <= 0 {
+				return
+			} else if remaining < length {
+				length = remaining
+			}
+		}
+		chunk := &pipelineChunk{done: make(chan struct{}), buf: make([]byte, length)}
+		go func(offset int64) {
+			n, err := pr.ra.ReadAt(chunk.buf, offset)
+			chunk.buf, chunk.err = chunk.buf[:n], err
+			close(chunk.done)
+		}(pr.next)
+		pr.next += length
+		pr.pending = append(pr.pending, chunk)
+	}
+}
+
+// Read reads up to len(p) bytes into p, waiting for the oldest outstanding
+// read-ahead request when no completed bytes remain.
+func (pr *PipelinedReader) Read(p []byte) (int, error) {
+	var n int
+	for n < len(p) {
+		if pr.current == nil || len(pr.current.buf) == 0 {
+			if pr.err != nil {
+				break
+			}
+			if len(pr.pending) == 0 {
+				pr.err = io.EOF
+				break
+			}
+			if n > 0 {
+				// Return what is ready rather than blocking for more.
+				select {
+				case <-pr.pending[0].done:
+				default:
+					return n, nil
+				}
+			}
+			pr.current = pr.pending[0]
+			<-pr.current.done
+			pr.pending[0] = nil
+			pr.pending = pr.pending[1:]
+			if err := pr.current.err; err != nil {
+				// A short read ends the stream once its bytes are consumed.
+				// Requests beyond it remain pending only so Close can wait.
+				if err == io.EOF && pr.size >= 0 {
+					if len(pr.current.buf) == cap(pr.current.buf) {
+						err = nil // a complete read may report io.EOF at the end
+					} else {
+						err = io.ErrUnexpectedEOF
+					}
+				}
+				pr.err = err
+			}
+			pr.fill()
+		}
+		nc := copy(p[n:], pr.current.buf)
+		pr.current.buf = pr.current.buf[nc:]
+		n += nc
+	}
+	if n > 0 {
+		return n, nil
+	}
+	return 0, pr.err
+}
+
+// Unwrap returns the underlying io.ReaderAt.
+func (pr *PipelinedReader) Unwrap() io.ReaderAt { return pr.ra }
+
+// Close waits for outstanding read-ahead requests to complete, then closes the
+// underlying io.ReaderAt when it is also an io.Closer. Read returns
+// ErrReadAfterClose after Close.
+func (pr *PipelinedReader) Close() error {
+	for _, chunk := range pr.pending {
+		<-chunk.done
+	}
+	pr.pending = nil
+	pr.current = nil
+	pr.err = ErrReadAfterClose{}
+	if c, ok := pr.ra.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package gorill
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// slowReaderAt delays each ReadAt, and records the greatest number of reads
+// outstanding at once.
+type slowReaderAt struct {
+	ra          io.ReaderAt
+	delay       time.Duration
+	lock        sync.Mutex
+	inflight    int
+	maxInflight int
+	closed      bool
+}
+
+func (s *slowReaderAt) ReadAt(p []byte, offset int64) (int, error) {
+	s.lock.Lock()
+	s.inflight++
+	if s.inflight > s.maxInflight {
+		s.maxInflight = s.inflight
+	}
+	s.lock.Unlock()
+
+	time.Sleep(s.delay)
+
+	s.lock.Lock()
+	s.inflight--
+	s.lock.Unlock()
+	return s.ra.ReadAt(p, offset)
+}
+
+func (s *slowReaderAt) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestPipelinedReaderInvalidSetters(t *testing.T) {
+	_, err := NewPipelinedReader(strings.NewReader(alphabet), -1, PipelineDepth(0))
+	ensureError(t, err, "depth must be greater than 0")
+
+	_, err = NewPipelinedReader(strings.NewReader(alphabet), -1, PipelineChunkSize(0))
+	ensureError(t, err, "chunk size must be greater than 0")
+}
+
+func TestPipelinedReaderKnownSize(t *testing.T) {
+	for _, chunkSize := range []int{1, 4, 26, 27, 100} {
+		pr, err := NewPipelinedReader(strings.NewReader(alphabet), int64(len(alphabet)), PipelineChunkSize(chunkSize))
+		ensureError(t, err)
+
+		all, err := ioutil.ReadAll(pr)
+		ensureError(t, err)
+		if got, want := string(all), alphabet; got != want {
+			t.Errorf("chunk size %d: GOT: %q; WANT: %q", chunkSize, got, want)
+		}
+		ensureError(t, pr.Close())
+	}
+}
+
+func TestPipelinedReaderUnknownSize(t *testing.T) {
+	for _, chunkSize := range []int{1, 4, 27, 100} {
+		pr, err := NewPipelinedReader(strings.NewReader(alphabet), -1, PipelineChunkSize(chunkSize))
+		ensureError(t, err)
+
+		all, err := ioutil.ReadAll(pr)
+		ensureError(t, err)
+		if got, want := string(all), alphabet; got != want {
+			t.Errorf("chunk size %d: GOT: %q; WANT: %q", chunkSize, got, want)
+		}
+		ensureError(t, pr.Close())
+	}
+}
+
+func TestPipelinedReaderPartialSize(t *testing.T) {
+	pr, err := NewPipelinedReader(strings.NewReader(alphabet), 5, PipelineChunkSize(2))
+	ensureError(t, err)
+
+	all, err := ioutil.ReadAll(pr)
+	ensureError(t, err)
+	if got, want := string(all), "abcde"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+}
+
+func TestPipelinedReaderTruncatedSource(t *testing.T) {
+	pr, err := NewPipelinedReader(strings.NewReader(alphabet), 100, PipelineChunkSize(10))
+	ensureError(t, err)
+
+	all, err := ioutil.ReadAll(pr)
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("GOT: %v; WANT: %v", err, io.ErrUnexpectedEOF)
+	}
+	if got, want := string(all), alphabet; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	ensureError(t, pr.Close())
+}
+
+func TestPipelinedReaderError(t *testing.T) {
+	pr, err := NewPipelinedReader(&errorReaderAt{err: errors.New("fetch failed")}, 100)
+	ensureError(t, err)
+
+	_, err = ioutil.ReadAll(pr)
+	ensureError(t, err, "fetch failed")
+}
+
+type errorReaderAt struct{ err error }
+
+func (e *errorReaderAt) ReadAt(p []byte, offset int64) (int, error) { return 0, e.err }
+
+func TestPipelinedReaderOverlapsRequests(t *testing.T) {
+	source := &slowReaderAt{ra: strings.NewReader(strings.Repeat(alphabet, 10)), delay: 10 * time.Millisecond}
+
+	pr, err := NewPipelinedReader(source, int64(10*len(alphabet)), PipelineDepth(4), PipelineChunkSize(len(alphabet)))
+	ensureError(t, err)
+
+	start := time.Now()
+	all, err := ioutil.ReadAll(pr)
+	ensureError(t, err)
+	if got, want := string(all), strings.Repeat(alphabet, 10); got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	// Ten sequential requests take 100ms; four at a time take about 30ms.
+	if elapsed := time.Since(start); elapsed >= 90*time.Millisecond {
+		t.Errorf("GOT: %v; WANT: less than %v", elapsed, 90*time.Millisecond)
+	}
+	if got, want := source.maxInflight, 4; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	ensureError(t, pr.Close())
+	if got, want := source.closed, true; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	_, err = pr.Read(make([]byte, 1))
+	testErrorType(t, err, ErrReadAfterClose{})
+}
@@ -0,0 +1,203 @@
+package gorill
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// QuotaPolicy determines what a QuotaWriter does with bytes that would exceed
+// its budget.
+type QuotaPolicy int
+
+const (
+	// QuotaError causes a QuotaWriter to write only the bytes that fit within
+	// its budget, and to return ErrOverQuota for the remainder.
+	QuotaError QuotaPolicy = iota
+
+	// QuotaDrop causes a QuotaWriter to write only the bytes that fit within
+	// its budget, and to silently discard the remainder.
+	QuotaDrop
+)
+
+// ErrOverQuota is returned by a QuotaWriter when a write would exceed its byte
+// budget.
+type ErrOverQuota int64
+
+// Error returns a string representation of a ErrOverQuota error instance.
+func (e ErrOverQuota) Error() string {
+	return fmt.Sprintf("over quota of %d bytes", int64(e))
+}
+
+// QuotaWriter is an io.WriteCloser that limits the number of bytes written to
+// the underlying io.WriteCloser to a budget, such as when capping the log
+// volume of each tenant of a multi-tenant service. It invokes a callback as
+// usage crosses each of its configured thresholds.
+type QuotaWriter struct {
+	budget     int64
+	callback   func(percent int, used, budget int64)
+	iowc       io.WriteCloser
+	lock       sync.Mutex
+	next       int // next is the index of the next threshold to be crossed
+	policy     QuotaPolicy
+	thresholds []int
+	used       int64
+}
+
+// QuotaWriterSetter is any function that modifies a QuotaWriter being
+// instantiated.
+type QuotaWriterSetter func(*QuotaWriter) error
+
+// QuotaCallback is used to configure the function a QuotaWriter invokes the
+// first time its usage reaches each threshold. The callback is invoked with the
+// threshold percentage, the number of bytes used, and the budget.
+func QuotaCallback(callback func(percent int, used, budget int64)) QuotaWriterSetter {
+	return func(qw *QuotaWriter) error {
+		qw.callback = callback
+		return nil
+	}
+}
+
+// QuotaOverPolicy is used to configure what a QuotaWriter does with bytes that
+// would exceed its budget. The default policy is QuotaError.
+func QuotaOverPolicy(policy QuotaPolicy) QuotaWriterSetter {
+	return func(qw *QuotaWriter) error {
+		switch policy {
+		case QuotaError, QuotaDrop:
+		default:
+			return fmt.Errorf("invalid quota policy: %d", policy)
+		}
+		qw.policy = policy
+		return nil
+	}
+}
+
+// QuotaThresholds is used to configure the usage percentages at which a
+// QuotaWriter invokes its callback. The default thresholds are 50, 90, and 100
+// percent.
+func QuotaThresholds(percents ...int) QuotaWriterSetter {
+	return func(qw *QuotaWriter) error {
+		for _, percent := range percents {
+			if percent <= 0 || percent > 100 {
+				return fmt.Errorf("threshold must be between 1 and 100: %d", percent)
+			}
+		}
+		thresholds := append([]int(nil), percents...)
+		sort.Ints(thresholds)
+		qw.thresholds = thresholds
+		return nil
+	}
+}
+
+// NewQuotaWriter returns a QuotaWriter that writes at most budget bytes to
+// iowc.
+//
+//   qw, err := gorill.NewQuotaWriter(tenantLog, 10<<20,
+//       gorill.QuotaOverPolicy(gorill.QuotaDrop),
+//       gorill.QuotaCallback(func(percent int, used, budget int64) {
+//           log.Printf("tenant %s used %d%% of log quota", tenant, percent)
+//       }))
+func NewQuotaWriter(iowc io.WriteCloser, budget int64, setters ...QuotaWriterSetter) (*QuotaWriter, error) {
+	if budget <= 0 {
+		return nil, fmt.Errorf("budget must be greater than 0: %d", budget)
+	}
+	qw := &QuotaWriter{
+		budget:     budget,
+		iowc:       iowc,
+		thresholds: []int{50, 90, 100},
+	}
+	for _, setter := range setters {
+		if err := setter(qw); err != nil {
+			return nil, err
+		}
+	}
+	return qw, nil
+}
+
+// Write writes as many bytes of data as fit within the remaining budget to the
+// underlying io.WriteCloser. When data does not fit, the configured QuotaPolicy
+// determines whether ErrOverQuota is returned or the remainder is discarded.
+func (qw *QuotaWriter) Write(data []byte) (int, error) {
+	qw.lock.Lock()
+
+	var n int
+	var err error
+	allowed := len(data)
+	if remaining := qw.budget - qw.used; int64(allowed) > remaining {
+		allowed = int(remaining)
+	}
+	if allowed > 0 {
+		n, err = qw.iowc.Write(data[:allowed])
+		qw.used += int64(n)
+	}
+	crossed := qw.crossed()
+	used := qw.used
+	qw.lock.Unlock()
+
+	if qw.callback != nil {
+		for _, percent := range crossed {
+			qw.callback(percent, used, qw.budget)
+		}
+	}
+
+	if err != nil || allowed == len(data) {
+		return n, err
+	}
+	if qw.policy == QuotaDrop {
+		return len(data), nil
+	}
+	return n, ErrOverQuota(qw.budget)
+}
+
+// SetWriteDeadline sets the write deadline of the underlying io.WriteCloser.
+func (qw *QuotaWriter) SetWriteDeadline(t time.Time) error {
+	return SetWriteDeadline(qw.iowc, t)
+}
+
+// crossed returns the thresholds newly reached by the usage. Caller must hold
+// the lock.
+func (qw *QuotaWriter) crossed() []int {
+	var crossed []int
+	for qw.next < len(qw.thresholds) && qw.used*100 >= int64(qw.thresholds[qw.next])*qw.budget {
+		crossed = append(crossed, qw.thresholds[qw.next])
+		qw.next++
+	}
+	return crossed
+}
+
+// Unwrap returns the underlying io.WriteCloser.
+func (qw *QuotaWriter) Unwrap() io.WriteCloser { return qw.iowc }
+
+// Close closes the underlying io.WriteCloser.
+func (qw *QuotaWriter) Close() error {
+	return qw.iowc.Close()
+}
+
+// Remaining returns the number of bytes that may still be written before the
+// budget is exhausted.
+func (qw *QuotaWriter) Remaining() int64 {
+	qw.lock.Lock()
+	defer qw.lock.Unlock()
+
+	return qw.budget - qw.used
+}
+
+// Reset restores the full budget, and re-arms all thresholds, such as at the
+// start of a new accounting period.
+func (qw *QuotaWriter) Reset() {
+	qw.lock.Lock()
+	qw.used = 0
+	qw.next = 0
+	qw.lock.Unlock()
+}
+
+// Used returns the number of bytes written to the underlying io.WriteCloser
+// since the QuotaWriter was created or last Reset.
+func (qw *QuotaWriter) Used() int64 {
+	qw.lock.Lock()
+	defer qw.lock.Unlock()
+
+	return qw.used
+}
@@ -0,0 +1,98 @@
+package gorill
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestQuotaWriterInvalidSettings(t *testing.T) {
+	_, err := NewQuotaWriter(NopCloseWriter(new(bytes.Buffer)), 0)
+	ensureError(t, err, "budget must be greater than 0")
+
+	_, err = NewQuotaWriter(NopCloseWriter(new(bytes.Buffer)), 10, QuotaThresholds(0))
+	ensureError(t, err, "threshold must be between 1 and 100")
+
+	_, err = NewQuotaWriter(NopCloseWriter(new(bytes.Buffer)), 10, QuotaOverPolicy(QuotaPolicy(42)))
+	ensureError(t, err, "invalid quota policy")
+}
+
+func TestQuotaWriterError(t *testing.T) {
+	bb := new(bytes.Buffer)
+	qw, err := NewQuotaWriter(NopCloseWriter(bb), 30)
+	ensureError(t, err)
+
+	n, err := qw.Write([]byte(alphabet))
+	ensureError(t, err)
+	if got, want := n, 27; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	n, err = qw.Write([]byte(alphabet))
+	testErrorType(t, err, ErrOverQuota(30))
+	if got, want := n, 3; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := bb.String(), alphabet+"abc"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	if got, want := qw.Remaining(), int64(0); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	ensureError(t, qw.Close())
+}
+
+func TestQuotaWriterDrop(t *testing.T) {
+	bb := new(bytes.Buffer)
+	qw, err := NewQuotaWriter(NopCloseWriter(bb), 30, QuotaOverPolicy(QuotaDrop))
+	ensureError(t, err)
+
+	for i := 0; i < 3; i++ {
+		n, err := qw.Write([]byte(alphabet))
+		ensureError(t, err)
+		if got, want := n, len(alphabet); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	}
+	if got, want := bb.String(), alphabet+"abc"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+}
+
+func TestQuotaWriterThresholds(t *testing.T) {
+	var crossed []int
+	qw, err := NewQuotaWriter(NopCloseWriter(new(bytes.Buffer)), 100,
+		QuotaOverPolicy(QuotaDrop),
+		QuotaCallback(func(percent int, used, budget int64) {
+			crossed = append(crossed, percent)
+		}))
+	ensureError(t, err)
+
+	_, _ = qw.Write(make([]byte, 49))
+	if got, want := len(crossed), 0; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	_, _ = qw.Write(make([]byte, 45))
+	_, _ = qw.Write(make([]byte, 45))
+	_, _ = qw.Write(make([]byte, 45))
+	ensureIntSlicesMatch(t, crossed, []int{50, 90, 100})
+
+	qw.Reset()
+	crossed = nil
+	if got, want := qw.Used(), int64(0); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	_, _ = qw.Write(make([]byte, 60))
+	ensureIntSlicesMatch(t, crossed, []int{50})
+}
+
+func ensureIntSlicesMatch(tb testing.TB, got, want []int) {
+	tb.Helper()
+	if len(got) != len(want) {
+		tb.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			tb.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	}
+}
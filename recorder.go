@@ -0,0 +1,206 @@
+package gorill
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+)
+
+// recordingMagic identifies a capture written by a Recorder.
+const recordingMagic = "gorill-rec1\n"
+
+// Recorder is an io.WriteCloser that captures each chunk written to it, along
+// with the time elapsed since the previous chunk, to an underlying
+// io.WriteCloser, using a compact format that a Replayer reproduces. Capture
+// the chunks read from a stream by wrapping it with io.TeeReader.
+//
+// Each chunk is stored as the uvarint number of nanoseconds since the previous
+// chunk, or since the Recorder was created for the first chunk, followed by the
+// uvarint length of the chunk, followed by the chunk itself.
+type Recorder struct {
+	iowc io.WriteCloser
+	last time.Time
+	now  func() time.Time
+}
+
+// RecorderSetter is any function that modifies a Recorder being instantiated.
+type RecorderSetter func(*Recorder) error
+
+// RecordClock is used to configure the clock a new Recorder reads to measure
+// the time between chunks.
+func RecordClock(clock Clock) RecorderSetter {
+	return func(r *Recorder) error {
+		if clock == nil {
+			return fmt.Errorf("clock must not be nil")
+		}
+		r.now = clock.Now
+		return nil
+	}
+}
+
+// NewRecorder returns a Recorder that writes a capture to iowc.
+//
+//   capture, err := os.Create("traffic.rec")
+//   if err != nil {
+//       return err
+//   }
+//   rec, err := gorill.NewRecorder(capture)
+//   if err != nil {
+//       return err
+//   }
+//   defer rec.Close()
+//   _, err = io.Copy(ioutil.Discard, io.TeeReader(conn, rec))
+func NewRecorder(iowc io.WriteCloser, setters ...RecorderSetter) (*Recorder, error) {
+	r := &Recorder{iowc: iowc, now: time.Now}
+	for _, setter := range setters {
+		if err := setter(r); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := io.WriteString(iowc, recordingMagic); err != nil {
+		return nil, err
+	}
+	r.last = r.now()
+	return r, nil
+}
+
+// Write records data as a single chunk.
+func (r *Recorder) Write(data []byte) (int, error) {
+	now := r.now()
+	gap := now.Sub(r.last)
+	if gap < 0 {
+		gap = 0
+	}
+	r.last = now
+
+	buf := make([]byte, 2*binary.MaxVarintLen64, 2*binary.MaxVarintLen64+len(data))
+	n := binary.PutUvarint(buf, uint64(gap))
+	n += binary.PutUvarint(buf[n:], uint64(len(data)))
+	buf = append(buf[:n], data...)
+
+	nw, err := r.iowc.Write(buf)
+	if nw -= n; nw < 0 {
+		nw = 0
+	}
+	if err == nil && nw < len(data) {
+		err = io.ErrShortWrite
+	}
+	return nw, err
+}
+
+// Unwrap returns the io.WriteCloser receiving the recording.
+func (r *Recorder) Unwrap() io.WriteCloser { return r.iowc }
+
+// Close closes the underlying io.WriteCloser.
+func (r *Recorder) Close() error {
+	return r.iowc.Close()
+}
+
+// Replayer is an io.ReadCloser that reproduces a stream captured by a
+// Recorder, waiting before returning each chunk for the time that elapsed
+// before it was recorded, so that the shape of production traffic may be
+// replayed through other wrappers in tests and benchmarks.
+type Replayer struct {
+	br      *bufio.Reader
+	iorc    io.ReadCloser
+	pending []byte
+	scale   float64
+	sleep   func(time.Duration)
+}
+
+// ReplayerSetter is any function that modifies a Replayer being instantiated.
+type ReplayerSetter func(*Replayer) error
+
+// ReplayScale is used to configure the factor by which a Replayer multiplies
+// the recorded time between chunks. A factor of 0.5 replays a capture twice as
+// fast as it was recorded, and a factor of 0 replays it without any delay.
+func ReplayScale(factor float64) ReplayerSetter {
+	return func(r *Replayer) error {
+		if factor < 0 {
+			return fmt.Errorf("scale must not be negative: %g", factor)
+		}
+		r.scale = factor
+		return nil
+	}
+}
+
+// ReplayClock is used to configure the clock a new Replayer sleeps on to
+// reproduce the recorded time between chunks.
+func ReplayClock(clock Clock) ReplayerSetter {
+	return func(r *Replayer) error {
+		if clock == nil {
+			return fmt.Errorf("clock must not be nil")
+		}
+		r.sleep = clock.Sleep
+		return nil
+	}
+}
+
+// NewReplayer returns a Replayer that reproduces the capture read from iorc.
+//
+//   capture, err := os.Open("traffic.rec")
+//   if err != nil {
+//       return err
+//   }
+//   rep, err := gorill.NewReplayer(capture, gorill.ReplayScale(0.1))
+//   if err != nil {
+//       return err
+//   }
+//   defer rep.Close()
+func NewReplayer(iorc io.ReadCloser, setters ...ReplayerSetter) (*Replayer, error) {
+	r := &Replayer{br: bufio.NewReader(iorc), iorc: iorc, scale: 1, sleep: time.Sleep}
+	for _, setter := range setters {
+		if err := setter(r); err != nil {
+			return nil, err
+		}
+	}
+	magic := make([]byte, len(recordingMagic))
+	if _, err := io.ReadFull(r.br, magic); err != nil || string(magic) != recordingMagic {
+		return nil, fmt.Errorf("cannot find capture header")
+	}
+	return r, nil
+}
+
+// Read waits until the next chunk is due, then reads it into buf. It never
+// returns bytes from more than one chunk, and only waits before the first read
+// of each chunk.
+func (r *Replayer) Read(buf []byte) (int, error) {
+	for len(r.pending) == 0 {
+		gap, err := binary.ReadUvarint(r.br)
+		if err != nil {
+			return 0, err // io.EOF at the end of the capture
+		}
+		size, err := binary.ReadUvarint(r.br)
+		if err != nil {
+			return 0, truncatedCapture(err)
+		}
+		chunk := make([]byte, size)
+		if _, err = io.ReadFull(r.br, chunk); err != nil {
+			return 0, truncatedCapture(err)
+		}
+		if d := time.Duration(float64(gap) * r.scale); d > 0 {
+			r.sleep(d)
+		}
+		r.pending = chunk
+	}
+	n := copy(buf, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// Unwrap returns the io.ReadCloser providing the recording.
+func (r *Replayer) Unwrap() io.ReadCloser { return r.iorc }
+
+// Close closes the underlying io.ReadCloser.
+func (r *Replayer) Close() error {
+	return r.iorc.Close()
+}
+
+func truncatedCapture(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
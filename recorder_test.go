@@ -0,0 +1,100 @@
+package gorill
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func recordChunks(tb testing.TB, chunks []string, gaps []time.Duration) []byte {
+	tb.Helper()
+	bb := new(bytes.Buffer)
+	rec, err := NewRecorder(NopCloseWriter(bb))
+	ensureError(tb, err)
+
+	now := rec.last
+	rec.now = func() time.Time { return now }
+	for i, chunk := range chunks {
+		now = now.Add(gaps[i])
+		n, err := rec.Write([]byte(chunk))
+		ensureError(tb, err)
+		if got, want := n, len(chunk); got != want {
+			tb.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	}
+	ensureError(tb, rec.Close())
+	return bb.Bytes()
+}
+
+func TestRecorderReplayer(t *testing.T) {
+	capture := recordChunks(t, []string{"abc", "defg", alphabet}, []time.Duration{10 * time.Millisecond, 0, 30 * time.Millisecond})
+
+	rep, err := NewReplayer(ioutil.NopCloser(bytes.NewReader(capture)), ReplayScale(0.5))
+	ensureError(t, err)
+	var slept []time.Duration
+	rep.sleep = func(d time.Duration) { slept = append(slept, d) }
+
+	buf := make([]byte, 2)
+	var chunks []string
+	var chunk []byte
+	for {
+		n, err := rep.Read(buf)
+		chunk = append(chunk, buf[:n]...)
+		if len(rep.pending) == 0 && len(chunk) > 0 {
+			chunks = append(chunks, string(chunk))
+			chunk = nil
+		}
+		if err == io.EOF {
+			break
+		}
+		ensureError(t, err)
+	}
+	ensureError(t, rep.Close())
+
+	wantChunks := []string{"abc", "defg", alphabet}
+	if len(chunks) != len(wantChunks) {
+		t.Fatalf("GOT: %q; WANT: %q", chunks, wantChunks)
+	}
+	for i := range chunks {
+		if chunks[i] != wantChunks[i] {
+			t.Errorf("GOT: %q; WANT: %q", chunks[i], wantChunks[i])
+		}
+	}
+	wantSlept := []time.Duration{5 * time.Millisecond, 15 * time.Millisecond}
+	if len(slept) != len(wantSlept) || slept[0] != wantSlept[0] || slept[1] != wantSlept[1] {
+		t.Errorf("GOT: %v; WANT: %v", slept, wantSlept)
+	}
+}
+
+func TestReplayerRealTime(t *testing.T) {
+	capture := recordChunks(t, []string{"abc"}, []time.Duration{20 * time.Millisecond})
+
+	rep, err := NewReplayer(ioutil.NopCloser(bytes.NewReader(capture)))
+	ensureError(t, err)
+
+	start := time.Now()
+	buf, err := ioutil.ReadAll(rep)
+	ensureError(t, err)
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("GOT: %v; WANT: at least %v", elapsed, 20*time.Millisecond)
+	}
+	if got, want := string(buf), "abc"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+}
+
+func TestReplayerInvalid(t *testing.T) {
+	_, err := NewReplayer(ioutil.NopCloser(bytes.NewReader([]byte("not a capture"))))
+	ensureError(t, err, "cannot find capture header")
+
+	_, err = NewReplayer(ioutil.NopCloser(bytes.NewReader(nil)), ReplayScale(-1))
+	ensureError(t, err, "scale must not be negative")
+
+	capture := recordChunks(t, []string{alphabet}, []time.Duration{0})
+	rep, err := NewReplayer(ioutil.NopCloser(bytes.NewReader(capture[:len(capture)-5])))
+	ensureError(t, err)
+	_, err = ioutil.ReadAll(rep)
+	testErrorType(t, err, io.ErrUnexpectedEOF)
+}
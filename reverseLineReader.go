@@ -0,0 +1,114 @@
+package gorill
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// ReverseLineReader reads lines from an io.ReaderAt starting with the final
+// line and ending with the first line, reading the data in chunks from the end
+// towards the beginning. This allows a program to find the final few lines of
+// interest in a very large file without scanning it from the beginning.
+type ReverseLineReader struct {
+	ra        io.ReaderAt
+	chunkSize int
+	lo        int64  // lo is the offset of the first byte loaded into data
+	data      []byte // data holds the loaded bytes not yet returned as lines
+	started   bool
+	done      bool
+}
+
+// NewReverseLineReader returns a ReverseLineReader that reads the size bytes
+// of ra in chunks of chunkSize bytes, from the end towards the beginning.
+//
+//   fi, err := fh.Stat()
+//   if err != nil {
+//       return err
+//   }
+//   rlr, err := gorill.NewReverseLineReader(fh, fi.Size(), 64*1024)
+//   if err != nil {
+//       return err
+//   }
+//   for {
+//       line, err := rlr.Line()
+//       if err == io.EOF {
+//           break
+//       }
+//       if err != nil {
+//           return err
+//       }
+//       // ...
+//   }
+func NewReverseLineReader(ra io.ReaderAt, size int64, chunkSize int) (*ReverseLineReader, error) {
+	if size < 0 {
+		return nil, fmt.Errorf("size must be greater than or equal to 0: %d", size)
+	}
+	if chunkSize <= 0 {
+		return nil, fmt.Errorf("chunk size must be greater than 0: %d", chunkSize)
+	}
+	return &ReverseLineReader{ra: ra, chunkSize: chunkSize, lo: size}, nil
+}
+
+// Line returns the previous line, not including its terminating newline
+// character. A final line that is not terminated by a newline character is
+// returned first. After the first line has been returned, it returns io.EOF.
+// The returned slice is only valid until the next call to Line.
+func (r *ReverseLineReader) Line() ([]byte, error) {
+	if r.done {
+		return nil, io.EOF
+	}
+	if !r.started {
+		r.started = true
+		if r.lo == 0 {
+			r.done = true
+			return nil, io.EOF // empty stream has no lines
+		}
+		if err := r.load(); err != nil {
+			return nil, err
+		}
+		// Newline terminating final line does not start another line.
+		if l := len(r.data); l > 0 && r.data[l-1] == '\n' {
+			r.data = r.data[:l-1]
+		}
+	}
+	for {
+		if index := bytes.LastIndexByte(r.data, '\n'); index >= 0 {
+			line := r.data[index+1:]
+			r.data = r.data[:index]
+			return line, nil
+		}
+		if r.lo == 0 {
+			r.done = true
+			return r.data, nil
+		}
+		if err := r.load(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// load reads the chunk preceding the loaded data, and prepends it to data.
+func (r *ReverseLineReader) load() error {
+	size := int64(r.chunkSize)
+	if size > r.lo {
+		size = r.lo
+	}
+	lo := r.lo - size
+
+	chunk := make([]byte, int(size)+len(r.data))
+	n, err := r.ra.ReadAt(chunk[:size], lo)
+	if int64(n) < size {
+		if err == nil || err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	copy(chunk[size:], r.data)
+	r.data = chunk
+	r.lo = lo
+	return nil
+}
+
+// Unwrap returns the underlying io.ReaderAt.
+func (r *ReverseLineReader) Unwrap() io.ReaderAt { return r.ra }
@@ -0,0 +1,53 @@
+package gorill
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReverseLineReader(t *testing.T) {
+	t.Run("invalid chunk size", func(t *testing.T) {
+		_, err := NewReverseLineReader(strings.NewReader(""), 0, 0)
+		ensureError(t, err, "chunk size must be greater than 0")
+	})
+
+	cases := []struct {
+		input string
+		want  []string
+	}{
+		{"", nil},
+		{"\n", []string{""}},
+		{"one", []string{"one"}},
+		{"one\n", []string{"one"}},
+		{"one\ntwo", []string{"two", "one"}},
+		{"one\n\nthree\n", []string{"three", "", "one"}},
+		{"\n\n", []string{"", ""}},
+	}
+	for _, c := range cases {
+		for _, chunkSize := range []int{1, 2, 3, DefaultBufSize} {
+			r, err := NewReverseLineReader(strings.NewReader(c.input), int64(len(c.input)), chunkSize)
+			ensureError(t, err)
+			var got []string
+			for {
+				line, err := r.Line()
+				if err == io.EOF {
+					break
+				}
+				ensureError(t, err)
+				got = append(got, string(line))
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("%q; chunk size %d: GOT: %q; WANT: %q", c.input, chunkSize, got, c.want)
+			}
+		}
+	}
+
+	t.Run("size larger than source", func(t *testing.T) {
+		r, err := NewReverseLineReader(strings.NewReader("abc"), 10, 4)
+		ensureError(t, err)
+		_, err = r.Line()
+		ensureError(t, err, "unexpected EOF")
+	})
+}
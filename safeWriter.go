@@ -0,0 +1,68 @@
+package gorill
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// ErrPanic is returned by a SafeWriteCloser when the io.WriteCloser it wraps
+// panics.
+type ErrPanic struct {
+	Value interface{} // Value is the value recovered from the panic
+}
+
+// Error returns a string representation of a ErrPanic error instance.
+func (e ErrPanic) Error() string {
+	return fmt.Sprintf("recovered panic: %v", e.Value)
+}
+
+// SafeWriteCloser is an io.WriteCloser that recovers from panics raised by the
+// Write and Close methods of an untrusted io.WriteCloser, such as a subscriber
+// implementation provided by a plugin, and returns them as ErrPanic errors.
+type SafeWriteCloser struct {
+	iowc io.WriteCloser
+}
+
+// NewSafeWriteCloser returns a SafeWriteCloser that isolates panics raised by
+// iowc.
+//
+//   fanOut.Add(gorill.NewSafeWriteCloser(subscriber))
+func NewSafeWriteCloser(iowc io.WriteCloser) *SafeWriteCloser {
+	return &SafeWriteCloser{iowc: iowc}
+}
+
+// Write writes data to the underlying io.WriteCloser, returning ErrPanic if it
+// panics.
+func (swc *SafeWriteCloser) Write(data []byte) (n int, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			n, err = 0, ErrPanic{Value: r}
+		}
+	}()
+	return swc.iowc.Write(data)
+}
+
+// SetWriteDeadline sets the write deadline of the underlying io.WriteCloser,
+// returning ErrPanic if it panics.
+func (swc *SafeWriteCloser) SetWriteDeadline(t time.Time) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = ErrPanic{Value: r}
+		}
+	}()
+	return SetWriteDeadline(swc.iowc, t)
+}
+
+// Unwrap returns the underlying io.WriteCloser.
+func (swc *SafeWriteCloser) Unwrap() io.WriteCloser { return swc.iowc }
+
+// Close closes the underlying io.WriteCloser, returning ErrPanic if it panics.
+func (swc *SafeWriteCloser) Close() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = ErrPanic{Value: r}
+		}
+	}()
+	return swc.iowc.Close()
+}
@@ -0,0 +1,68 @@
+package gorill
+
+import (
+	"strings"
+	"testing"
+)
+
+// panickingWriteCloser panics when written to or closed.
+type panickingWriteCloser struct{}
+
+func (panickingWriteCloser) Write([]byte) (int, error) { panic("write boom") }
+func (panickingWriteCloser) Close() error              { panic("close boom") }
+
+func TestSafeWriteCloser(t *testing.T) {
+	swc := NewSafeWriteCloser(panickingWriteCloser{})
+
+	n, err := swc.Write([]byte(alphabet))
+	if got, want := n, 0; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	testErrorType(t, err, ErrPanic{Value: "write boom"})
+
+	err = swc.Close()
+	testErrorType(t, err, ErrPanic{Value: "close boom"})
+	ensureError(t, err, "recovered panic: close boom")
+}
+
+func TestSafeWriteCloserPassThrough(t *testing.T) {
+	bb := NewNopCloseBuffer()
+	swc := NewSafeWriteCloser(bb)
+
+	n, err := swc.Write([]byte(alphabet))
+	ensureError(t, err)
+	if got, want := n, len(alphabet); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	ensureError(t, swc.Close())
+	if got, want := bb.String(), alphabet; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+}
+
+func TestMultiWriteCloserFanOutIsolatePanics(t *testing.T) {
+	bb := NewNopCloseBuffer()
+	mw := NewMultiWriteCloserFanOut(bb, panickingWriteCloser{})
+	mw.SetIsolatePanics(true)
+
+	var reported error
+	mw.SetOperationHook(func(op string) func(int, error) {
+		return func(_ int, err error) { reported = err }
+	})
+
+	n, err := mw.Write([]byte(alphabet))
+	ensureError(t, err)
+	if got, want := n, len(alphabet); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if reported == nil || !strings.Contains(reported.Error(), "recovered panic: write boom") {
+		t.Errorf("GOT: %v; WANT: %v", reported, "recovered panic: write boom")
+	}
+	if got, want := mw.Count(), 1; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := bb.String(), alphabet; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	ensureError(t, mw.Close())
+}
@@ -0,0 +1,200 @@
+package gorill
+
+import (
+	"container/list"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// SinkPool limits the number of io.WriteCloser sinks that are concurrently
+// open. Each sink is opened using its factory when first written to. When
+// opening a sink would exceed the maximum, the least recently written sink is
+// closed first, and re-opened using its factory when next written to. A
+// factory that opens a file ought therefore to open it in append mode.
+type SinkPool struct {
+	halted bool
+	lock   sync.Mutex
+	lru    *list.List // lru holds open sinks, most recently written first
+	max    int
+}
+
+// NewSinkPool returns a SinkPool that allows at most max sinks to be open
+// concurrently.
+//
+//   pool, err := gorill.NewSinkPool(256)
+//   if err != nil {
+//       return err
+//   }
+//   defer pool.Close()
+//   sink := pool.Sink(func() (io.WriteCloser, error) {
+//       return os.OpenFile(pathname, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+//   })
+func NewSinkPool(max int) (*SinkPool, error) {
+	if max <= 0 {
+		return nil, fmt.Errorf("maximum open sinks must be greater than 0: %d", max)
+	}
+	return &SinkPool{lru: list.New(), max: max}, nil
+}
+
+// Sink returns a PooledSink that uses factory to open its underlying
+// io.WriteCloser. It does not open the sink.
+func (p *SinkPool) Sink(factory WriteCloserFactory) *PooledSink {
+	return &PooledSink{factory: factory, pool: p}
+}
+
+// Open returns the number of sinks currently open.
+func (p *SinkPool) Open() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.lru.Len()
+}
+
+// Close closes all open sinks. Subsequent writes to any sink from the pool
+// return ErrWriteAfterClose.
+func (p *SinkPool) Close() error {
+	p.lock.Lock()
+	p.halted = true
+	sinks := p.drain(p.lru.Len())
+	p.lock.Unlock()
+
+	var errors ErrList
+	for i, s := range sinks {
+		errors.AppendMember(i, s, s.evict())
+	}
+	return errors.Err()
+}
+
+// drain removes up to count of the least recently written sinks from the list
+// of open sinks, and returns them. Caller must hold the lock.
+func (p *SinkPool) drain(count int) []*PooledSink {
+	var sinks []*PooledSink
+	for ; count > 0; count-- {
+		back := p.lru.Back()
+		if back == nil {
+			break
+		}
+		s := p.lru.Remove(back).(*PooledSink)
+		s.elem = nil
+		sinks = append(sinks, s)
+	}
+	return sinks
+}
+
+// reserve claims a slot for s, returning the sinks that must be closed before s
+// may be opened.
+func (p *SinkPool) reserve(s *PooledSink) ([]*PooledSink, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.halted {
+		return nil, ErrWriteAfterClose{}
+	}
+	victims := p.drain(p.lru.Len() + 1 - p.max)
+	s.elem = p.lru.PushFront(s)
+	return victims, nil
+}
+
+// release gives up the slot held by s, if any.
+func (p *SinkPool) release(s *PooledSink) {
+	p.lock.Lock()
+	if s.elem != nil {
+		p.lru.Remove(s.elem)
+		s.elem = nil
+	}
+	p.lock.Unlock()
+}
+
+// touch marks s as the most recently written sink.
+func (p *SinkPool) touch(s *PooledSink) {
+	p.lock.Lock()
+	if s.elem != nil {
+		p.lru.MoveToFront(s.elem)
+	}
+	p.lock.Unlock()
+}
+
+// PooledSink is an io.WriteCloser whose underlying io.WriteCloser is opened and
+// closed on demand by its SinkPool.
+type PooledSink struct {
+	elem    *list.Element // elem is protected by the pool lock
+	factory WriteCloserFactory
+	halted  bool
+	iowc    io.WriteCloser
+	lock    sync.Mutex
+	pool    *SinkPool
+}
+
+// Write writes data to the underlying io.WriteCloser, opening it first when
+// necessary.
+func (s *PooledSink) Write(data []byte) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.halted {
+		return 0, ErrWriteAfterClose{}
+	}
+	if s.iowc == nil {
+		victims, err := s.pool.reserve(s)
+		if err != nil {
+			return 0, err
+		}
+		// Close the victims before opening this sink, so the number of open
+		// sinks never exceeds the maximum. Victims were removed from the pool
+		// while its lock was held, so this sink is never one of them.
+		for _, victim := range victims {
+			_ = victim.evict() // no caller to which the error may be returned
+		}
+		if s.iowc, err = s.factory(); err != nil {
+			s.iowc = nil
+			s.pool.release(s)
+			return 0, err
+		}
+	} else {
+		s.pool.touch(s)
+	}
+	return s.iowc.Write(data)
+}
+
+// evict closes the underlying io.WriteCloser without closing the PooledSink.
+func (s *PooledSink) evict() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.iowc == nil {
+		return nil
+	}
+	err := s.iowc.Close()
+	s.iowc = nil
+	return err
+}
+
+// Unwrap returns the pooled io.WriteCloser, or nil while it is closed, either
+// because it has not been written to since it was evicted, or after Close.
+func (s *PooledSink) Unwrap() io.WriteCloser {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.iowc == nil {
+		return nil // avoid returning a non-nil interface holding nil
+	}
+	return s.iowc
+}
+
+// Close closes the underlying io.WriteCloser when it is open, and releases its
+// slot in the SinkPool.
+func (s *PooledSink) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.halted {
+		return nil
+	}
+	s.halted = true
+	s.pool.release(s)
+	if s.iowc == nil {
+		return nil
+	}
+	err := s.iowc.Close()
+	s.iowc = nil
+	return err
+}
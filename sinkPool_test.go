@@ -0,0 +1,169 @@
+package gorill
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+)
+
+// appendFiles simulates files opened in append mode, tracking how many are
+// concurrently open.
+type appendFiles struct {
+	lock    sync.Mutex
+	files   map[string]*bytes.Buffer
+	open    int
+	maxOpen int
+	opens   int
+}
+
+func (af *appendFiles) factory(name string) WriteCloserFactory {
+	return func() (io.WriteCloser, error) {
+		af.lock.Lock()
+		defer af.lock.Unlock()
+		af.open++
+		af.opens++
+		if af.open > af.maxOpen {
+			af.maxOpen = af.open
+		}
+		bb, ok := af.files[name]
+		if !ok {
+			bb = new(bytes.Buffer)
+			af.files[name] = bb
+		}
+		return &appendFile{af: af, bb: bb}, nil
+	}
+}
+
+type appendFile struct {
+	af *appendFiles
+	bb *bytes.Buffer
+}
+
+func (f *appendFile) Write(data []byte) (int, error) {
+	f.af.lock.Lock()
+	defer f.af.lock.Unlock()
+	return f.bb.Write(data)
+}
+
+func (f *appendFile) Close() error {
+	f.af.lock.Lock()
+	defer f.af.lock.Unlock()
+	f.af.open--
+	return nil
+}
+
+func TestSinkPool(t *testing.T) {
+	t.Run("invalid maximum", func(t *testing.T) {
+		_, err := NewSinkPool(0)
+		ensureError(t, err, "maximum open sinks must be greater than 0")
+	})
+
+	t.Run("evicts least recently written", func(t *testing.T) {
+		af := &appendFiles{files: make(map[string]*bytes.Buffer)}
+		pool, err := NewSinkPool(2)
+		ensureError(t, err)
+
+		a, b, c := pool.Sink(af.factory("a")), pool.Sink(af.factory("b")), pool.Sink(af.factory("c"))
+		for _, step := range []struct {
+			sink *PooledSink
+			data string
+		}{{a, "a1"}, {b, "b1"}, {a, "a2"}, {c, "c1"}, {a, "a3"}, {b, "b2"}} {
+			_, err = step.sink.Write([]byte(step.data))
+			ensureError(t, err)
+		}
+		// b was evicted by c, then c was evicted by b.
+		if got, want := af.opens, 4; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := pool.Open(), 2; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		ensureError(t, c.Close())
+		ensureError(t, pool.Close())
+		if got, want := af.open, 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		for name, want := range map[string]string{"a": "a1a2a3", "b": "b1b2", "c": "c1"} {
+			if got := af.files[name].String(); got != want {
+				t.Errorf("%s: GOT: %q; WANT: %q", name, got, want)
+			}
+		}
+
+		_, err = a.Write([]byte("a4"))
+		testErrorType(t, err, ErrWriteAfterClose{})
+	})
+
+	t.Run("unwrap", func(t *testing.T) {
+		af := &appendFiles{files: make(map[string]*bytes.Buffer)}
+		pool, err := NewSinkPool(1)
+		ensureError(t, err)
+
+		a, b := pool.Sink(af.factory("a")), pool.Sink(af.factory("b"))
+		if got := a.Unwrap(); got != nil {
+			t.Errorf("GOT: %v; WANT: %v", got, nil)
+		}
+		_, err = a.Write([]byte("a1"))
+		ensureError(t, err)
+		if got := a.Unwrap(); got == nil {
+			t.Errorf("GOT: %v; WANT: open sink", got)
+		}
+
+		// Unwrap races neither with b evicting a, nor with a reopening.
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				Unwrap(a)
+			}
+		}()
+		for i := 0; i < 10; i++ {
+			_, err = b.Write([]byte("b"))
+			ensureError(t, err)
+			_, err = a.Write([]byte("a"))
+			ensureError(t, err)
+		}
+		wg.Wait()
+
+		ensureError(t, a.Close())
+		if got := a.Unwrap(); got != nil {
+			t.Errorf("GOT: %v; WANT: %v", got, nil)
+		}
+		ensureError(t, pool.Close())
+	})
+
+	t.Run("concurrent writers", func(t *testing.T) {
+		af := &appendFiles{files: make(map[string]*bytes.Buffer)}
+		pool, err := NewSinkPool(3)
+		ensureError(t, err)
+
+		const sinkCount, writes = 10, 100
+		var wg sync.WaitGroup
+		wg.Add(sinkCount)
+		for i := 0; i < sinkCount; i++ {
+			go func(s *PooledSink) {
+				defer wg.Done()
+				for j := 0; j < writes; j++ {
+					if _, err := s.Write([]byte("x")); err != nil {
+						t.Error(err)
+						return
+					}
+				}
+			}(pool.Sink(af.factory(fmt.Sprintf("sink%d", i))))
+		}
+		wg.Wait()
+		ensureError(t, pool.Close())
+
+		if af.maxOpen > 3 {
+			t.Errorf("GOT: %v; WANT: <= %v", af.maxOpen, 3)
+		}
+		for name, bb := range af.files {
+			if got, want := bb.Len(), writes; got != want {
+				t.Errorf("%s: GOT: %v; WANT: %v", name, got, want)
+			}
+		}
+	})
+}
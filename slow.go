@@ -15,16 +15,23 @@ import (
 //   n, err := sr.Read(buf) // this call takes at least 10 seconds to return
 //   // n == 7, err == nil
 func SlowReader(r io.Reader, d time.Duration) io.Reader {
-	return &slowReader{Reader: r, duration: d}
+	return &slowReader{Reader: r, clock: systemClock{}, duration: d}
+}
+
+// SlowReaderWithClock returns a structure like SlowReader does, but which sleeps on clock, allowing
+// tests to control the delay with a FakeClock.
+func SlowReaderWithClock(r io.Reader, d time.Duration, clock Clock) io.Reader {
+	return &slowReader{Reader: r, clock: clock, duration: d}
 }
 
 func (s *slowReader) Read(data []byte) (int, error) {
-	time.Sleep(s.duration)
+	s.clock.Sleep(s.duration)
 	return s.Reader.Read(data)
 }
 
 type slowReader struct {
 	io.Reader
+	clock    Clock
 	duration time.Duration
 }
 
@@ -37,15 +44,22 @@ type slowReader struct {
 //   n, err := sw.Write([]byte("example")) // this call takes at least 10 seconds to return
 //   // n == 7, err == nil
 func SlowWriter(w io.Writer, d time.Duration) io.Writer {
-	return &slowWriter{Writer: w, duration: d}
+	return &slowWriter{Writer: w, clock: systemClock{}, duration: d}
+}
+
+// SlowWriterWithClock returns a structure like SlowWriter does, but which sleeps on clock, allowing
+// tests to control the delay with a FakeClock.
+func SlowWriterWithClock(w io.Writer, d time.Duration, clock Clock) io.Writer {
+	return &slowWriter{Writer: w, clock: clock, duration: d}
 }
 
 func (s *slowWriter) Write(data []byte) (int, error) {
-	time.Sleep(s.duration)
+	s.clock.Sleep(s.duration)
 	return s.Writer.Write(data)
 }
 
 type slowWriter struct {
 	io.Writer
+	clock    Clock
 	duration time.Duration
 }
@@ -0,0 +1,83 @@
+package gorill
+
+import (
+	"io"
+	"net/http"
+)
+
+// DefaultSniffLen is the number of bytes a SniffReader holds for inspection
+// when a non-positive length is requested. It is the number of bytes
+// http.DetectContentType considers.
+const DefaultSniffLen = 512
+
+// SniffReader is an io.ReadCloser that reads the first bytes of a stream
+// ahead of time, so they may be inspected before deciding how to process the
+// stream, without consuming them. Reading from a SniffReader returns the entire
+// stream, starting with the inspected bytes.
+type SniffReader struct {
+	head []byte
+	herr error // herr is the error from reading the head, returned after it
+	iorc io.ReadCloser
+	off  int
+}
+
+// NewSniffReader reads up to n bytes from iorc, and returns a SniffReader that
+// allows them to be inspected. When n is not positive, DefaultSniffLen bytes
+// are read.
+//
+// Like NewEscrowReader, it does not return an error during instantiation. Any
+// error encountered reading the first bytes is returned by Read after those
+// bytes have been read.
+//
+//   func ingest(w http.ResponseWriter, r *http.Request) {
+//       sr := gorill.NewSniffReader(r.Body, 0)
+//       switch sr.ContentType() {
+//       case "application/x-gzip":
+//           // ...
+//       }
+//   }
+func NewSniffReader(iorc io.ReadCloser, n int) *SniffReader {
+	if n <= 0 {
+		n = DefaultSniffLen
+	}
+	head := make([]byte, n)
+	nr, err := io.ReadFull(iorc, head)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF // the stream is shorter than the requested length
+	}
+	return &SniffReader{head: head[:nr], herr: err, iorc: iorc}
+}
+
+// ContentType returns the MIME type of the stream, as determined by
+// http.DetectContentType from the inspected bytes.
+func (sr *SniffReader) ContentType() string {
+	return http.DetectContentType(sr.head)
+}
+
+// Peek returns the inspected bytes at the start of the stream. The returned
+// slice is only valid until the next call to Read, and must not be modified.
+func (sr *SniffReader) Peek() []byte {
+	return sr.head
+}
+
+// Read reads from the inspected bytes not yet read, then from the remainder of
+// the stream.
+func (sr *SniffReader) Read(buf []byte) (int, error) {
+	if sr.off < len(sr.head) {
+		n := copy(buf, sr.head[sr.off:])
+		sr.off += n
+		return n, nil
+	}
+	if sr.herr != nil {
+		return 0, sr.herr
+	}
+	return sr.iorc.Read(buf)
+}
+
+// Unwrap returns the underlying io.ReadCloser.
+func (sr *SniffReader) Unwrap() io.ReadCloser { return sr.iorc }
+
+// Close closes the underlying io.ReadCloser.
+func (sr *SniffReader) Close() error {
+	return sr.iorc.Close()
+}
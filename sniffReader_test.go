@@ -0,0 +1,53 @@
+package gorill
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestSniffReaderPeek(t *testing.T) {
+	sr := NewSniffReader(ioutil.NopCloser(strings.NewReader(alphabet)), 5)
+
+	if got, want := string(sr.Peek()), "abcde"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	if got, want := sr.ContentType(), "text/plain; charset=utf-8"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+
+	buf, err := ioutil.ReadAll(sr)
+	ensureError(t, err)
+	if got, want := string(buf), alphabet; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	ensureError(t, sr.Close())
+}
+
+func TestSniffReaderShortStream(t *testing.T) {
+	sr := NewSniffReader(ioutil.NopCloser(bytes.NewReader([]byte("\x1f\x8b\x08"))), 0)
+
+	if got, want := sr.ContentType(), "application/x-gzip"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	buf, err := ioutil.ReadAll(sr)
+	ensureError(t, err)
+	if got, want := string(buf), "\x1f\x8b\x08"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+}
+
+func TestSniffReaderReadError(t *testing.T) {
+	sr := NewSniffReader(ioutil.NopCloser(iotest.TimeoutReader(strings.NewReader("abc"))), 5)
+
+	if got, want := string(sr.Peek()), "abc"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	buf, err := ioutil.ReadAll(sr)
+	testErrorType(t, err, iotest.ErrTimeout)
+	if got, want := string(buf), "abc"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+}
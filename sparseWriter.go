@@ -0,0 +1,202 @@
+package gorill
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+// DefaultSparseBlockSize is the default size of the blocks a SparseWriter
+// inspects for zero bytes.
+const DefaultSparseBlockSize = 4096
+
+// SparseWriter is an io.WriteCloser for *os.File sinks that seeks over blocks
+// consisting entirely of zero bytes rather than writing them, creating holes in
+// the file on file systems that support sparse files. This can drastically
+// reduce the disk space used when streaming virtual machine images or database
+// snapshots, which tend to have long runs of zero bytes.
+type SparseWriter struct {
+	blockSize int
+	fh        *os.File
+	offset    int64 // offset is the logical position in the file
+	size      int64 // size is the length of the file, including bytes it held before
+	zeros     []byte
+}
+
+// SparseWriterSetter is any function that modifies a SparseWriter being
+// instantiated.
+type SparseWriterSetter func(*SparseWriter) error
+
+// SparseBlockSize is used to configure the size of the blocks a new
+// SparseWriter inspects for zero bytes. Runs of zero bytes shorter than the
+// block size are always written. It ought to be a multiple of the file system
+// block size.
+func SparseBlockSize(size int) SparseWriterSetter {
+	return func(sw *SparseWriter) error {
+		if size <= 0 {
+			return fmt.Errorf("block size must be greater than 0: %d", size)
+		}
+		sw.blockSize = size
+		return nil
+	}
+}
+
+// NewSparseWriter returns a SparseWriter that writes to fh, starting at the
+// current offset of fh. The file must not have been opened in append mode. Like
+// writing to fh directly, it overwrites bytes the file already holds without
+// truncating the file, so only blocks written past the end of the file become
+// holes, and blocks of zero bytes over existing bytes are written as zeros.
+//
+//   fh, err := os.Create("disk.img")
+//   if err != nil {
+//       return err
+//   }
+//   sw, err := gorill.NewSparseWriter(fh)
+//   if err != nil {
+//       return err
+//   }
+//   if _, err = io.Copy(sw, image); err != nil {
+//       sw.Close()
+//       return err
+//   }
+//   return sw.Close() // sets file size when stream ends with a hole
+func NewSparseWriter(fh *os.File, setters ...SparseWriterSetter) (*SparseWriter, error) {
+	sw := &SparseWriter{blockSize: DefaultSparseBlockSize, fh: fh}
+	for _, setter := range setters {
+		if err := setter(sw); err != nil {
+			return nil, err
+		}
+	}
+	offset, err := fh.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := fh.Stat()
+	if err != nil {
+		return nil, err
+	}
+	sw.offset, sw.size = offset, fi.Size()
+	if sw.size < offset {
+		sw.size = offset
+	}
+	sw.zeros = make([]byte, sw.blockSize)
+	return sw, nil
+}
+
+// Write writes data to the file, seeking over each block of data that consists
+// entirely of zero bytes.
+func (sw *SparseWriter) Write(data []byte) (int, error) {
+	var written int
+	for written < len(data) {
+		// Find the extent of the run of blocks that are either all zero or not.
+		end := written
+		isHole := sw.isZeroBlock(data[end:])
+		for end < len(data) && sw.isZeroBlock(data[end:]) == isHole {
+			end += sw.blockSize
+			if end > len(data) {
+				end = len(data)
+			}
+		}
+		run := int64(end - written)
+		if isHole {
+			// Seeking over bytes the file already holds would leave their
+			// previous contents in place of the zeros.
+			if existing := sw.size - sw.offset; existing > 0 {
+				if existing > run {
+					existing = run
+				}
+				n, err := sw.writeZeros(existing)
+				if err != nil {
+					return written + int(n), err
+				}
+				run -= n
+			}
+			if run > 0 {
+				if _, err := sw.fh.Seek(run, io.SeekCurrent); err != nil {
+					return end - int(run), err
+				}
+				sw.offset += run
+			}
+		} else {
+			n, err := sw.fh.Write(data[written:end])
+			sw.offset += int64(n)
+			if sw.offset > sw.size {
+				sw.size = sw.offset
+			}
+			if err != nil {
+				return written + n, err
+			}
+		}
+		written = end
+	}
+	return written, nil
+}
+
+// writeZeros writes n zero bytes to the file.
+func (sw *SparseWriter) writeZeros(n int64) (int64, error) {
+	var written int64
+	for written < n {
+		chunk := n - written
+		if chunk > int64(len(sw.zeros)) {
+			chunk = int64(len(sw.zeros))
+		}
+		nw, err := sw.fh.Write(sw.zeros[:chunk])
+		written += int64(nw)
+		sw.offset += int64(nw)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// isZeroBlock returns true when the first block of data is a complete block of
+// zero bytes.
+func (sw *SparseWriter) isZeroBlock(data []byte) bool {
+	return len(data) >= sw.blockSize && bytes.Equal(data[:sw.blockSize], sw.zeros)
+}
+
+// Flush extends the file to its logical size when the most recently written
+// blocks were skipped past the end of the file, without closing the file. It
+// never shortens the file.
+func (sw *SparseWriter) Flush() error {
+	if sw.offset <= sw.size {
+		return nil
+	}
+	if err := sw.fh.Truncate(sw.offset); err != nil {
+		return err
+	}
+	sw.size = sw.offset
+	return nil
+}
+
+// Unwrap returns the underlying file.
+func (sw *SparseWriter) Unwrap() *os.File { return sw.fh }
+
+// Close flushes the SparseWriter, then closes the file.
+func (sw *SparseWriter) Close() error {
+	var errors ErrList
+	errors.Append(sw.Flush())
+	errors.Append(sw.fh.Close())
+	return errors.Err()
+}
+
+// CopySparse copies from src to dst until either io.EOF is reached on src or an
+// error occurs, seeking over blocks of zero bytes rather than writing them. It
+// returns the number of bytes copied, including those seeked over. It does not
+// close dst.
+//
+//   n, err := gorill.CopySparse(dst, src)
+func CopySparse(dst *os.File, src io.Reader) (int64, error) {
+	sw, err := NewSparseWriter(dst)
+	if err != nil {
+		return 0, err
+	}
+	buf := make([]byte, 32*DefaultSparseBlockSize)
+	n, err := io.CopyBuffer(struct{ io.Writer }{sw}, src, buf)
+	if ferr := sw.Flush(); err == nil {
+		err = ferr
+	}
+	return n, err
+}
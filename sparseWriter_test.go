@@ -0,0 +1,98 @@
+package gorill
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestSparseWriter(t *testing.T) {
+	const block = DefaultSparseBlockSize
+
+	payload := make([]byte, 5*block)
+	copy(payload, "head")
+	copy(payload[3*block:], "middle")
+	// final block remains all zero bytes
+
+	t.Run("invalid block size", func(t *testing.T) {
+		_, err := NewSparseWriter(os.Stdout, SparseBlockSize(0))
+		ensureError(t, err, "block size must be greater than 0")
+	})
+
+	t.Run("writer", func(t *testing.T) {
+		fh, err := ioutil.TempFile("", "gorill")
+		ensureError(t, err)
+		defer os.Remove(fh.Name())
+
+		sw, err := NewSparseWriter(fh)
+		ensureError(t, err)
+		// Write in pieces that do not align with blocks.
+		for _, piece := range [][]byte{payload[:100], payload[100 : 2*block+7], payload[2*block+7:]} {
+			n, err := sw.Write(piece)
+			ensureError(t, err)
+			if got, want := n, len(piece); got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		}
+		ensureError(t, sw.Close())
+
+		buf, err := ioutil.ReadFile(fh.Name())
+		ensureError(t, err)
+		if !bytes.Equal(buf, payload) {
+			t.Errorf("GOT: %v bytes; WANT: %v bytes", len(buf), len(payload))
+		}
+	})
+
+	t.Run("overwrite", func(t *testing.T) {
+		for _, blocks := range []int{2, 6} {
+			fh, err := ioutil.TempFile("", "gorill")
+			ensureError(t, err)
+			defer os.Remove(fh.Name())
+
+			existing := bytes.Repeat([]byte{0xff}, blocks*block)
+			_, err = fh.Write(existing)
+			ensureError(t, err)
+			_, err = fh.Seek(0, io.SeekStart)
+			ensureError(t, err)
+
+			sw, err := NewSparseWriter(fh)
+			ensureError(t, err)
+			_, err = sw.Write(payload)
+			ensureError(t, err)
+			ensureError(t, sw.Close())
+
+			// Zero blocks replace existing bytes, and bytes past the end
+			// of payload remain.
+			want := append([]byte(nil), payload...)
+			if len(existing) > len(want) {
+				want = append(want, existing[len(want):]...)
+			}
+			buf, err := ioutil.ReadFile(fh.Name())
+			ensureError(t, err)
+			if !bytes.Equal(buf, want) {
+				t.Errorf("%d blocks: GOT: %v bytes; WANT: %v bytes", blocks, len(buf), len(want))
+			}
+		}
+	})
+
+	t.Run("copy", func(t *testing.T) {
+		fh, err := ioutil.TempFile("", "gorill")
+		ensureError(t, err)
+		defer os.Remove(fh.Name())
+		defer fh.Close()
+
+		n, err := CopySparse(fh, bytes.NewReader(payload))
+		ensureError(t, err)
+		if got, want := n, int64(len(payload)); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		buf, err := ioutil.ReadFile(fh.Name())
+		ensureError(t, err)
+		if !bytes.Equal(buf, payload) {
+			t.Errorf("GOT: %v bytes; WANT: %v bytes", len(buf), len(payload))
+		}
+	})
+}
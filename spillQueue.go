@@ -0,0 +1,191 @@
+package gorill
+
+import (
+	"io"
+	"sync"
+)
+
+// spillQueue is a first-in, first-out queue of bytes written to an io.Writer by a background
+// go-routine. It holds up to a memory cap of queued bytes in memory, and spills the bytes beyond it
+// to a TempSpool that holds them in a temporary file, so that a slow io.Writer neither blocks the
+// producer nor grows the heap without bound. The TempSpool is closed, removing its temporary file,
+// once it has been drained.
+type spillQueue struct {
+	cond     sync.Cond
+	dir      string
+	done     chan struct{} // done is closed when the drain go-routine exits
+	draining bool          // draining is set while bytes removed from the queue are being written
+	err      error         // err is the sticky error from writing to iow or to the spool
+	halted   bool
+	iow      io.Writer
+	lock     sync.Mutex
+	mem      []byte // mem holds the queued bytes that precede any spilled bytes
+	memCap   int
+	readOff  int64      // readOff is the offset in spool of the next spilled byte to be written
+	spool    *TempSpool // spool holds the spilled bytes, which follow those in mem
+}
+
+// newSpillQueue returns a spillQueue that writes to iow, and starts its drain go-routine.
+func newSpillQueue(iow io.Writer, memCap int, dir string) *spillQueue {
+	q := &spillQueue{
+		dir:    dir,
+		done:   make(chan struct{}),
+		iow:    iow,
+		memCap: memCap,
+	}
+	q.cond.L = &q.lock
+	go q.drain()
+	return q
+}
+
+// Write queues data, spilling it to the spool when it does not fit within the memory cap, or when
+// bytes spilled earlier have yet to be written.
+func (q *spillQueue) Write(data []byte) (int, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.halted {
+		return 0, ErrWriteAfterClose{}
+	}
+	if q.err != nil {
+		return 0, q.err
+	}
+	if q.spool == nil && len(q.mem)+len(data) <= q.memCap {
+		q.mem = append(q.mem, data...)
+		q.cond.Broadcast()
+		return len(data), nil
+	}
+	if q.spool == nil {
+		// A threshold of 0 writes every spilled byte to the temporary file.
+		spool, err := NewTempSpool(TempSpoolThreshold(0), TempSpoolDir(q.dir))
+		if err != nil {
+			return 0, err
+		}
+		q.spool = spool
+	}
+	n, err := q.spool.Write(data)
+	if err != nil {
+		q.err = err
+	}
+	q.cond.Broadcast()
+	return n, err
+}
+
+// drain writes the queued bytes to the io.Writer, until the queue is closed and empty, or an error
+// occurs.
+func (q *spillQueue) drain() {
+	defer close(q.done)
+	bp := transferBuffers.Get().(*[]byte)
+	defer transferBuffers.Put(bp)
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	for {
+		for q.err == nil && !q.halted && len(q.mem) == 0 && q.readOff == q.spilled() {
+			q.cond.Wait()
+		}
+		if q.err != nil || (len(q.mem) == 0 && q.readOff == q.spilled()) {
+			return
+		}
+
+		// Bytes in memory precede spilled bytes. Spilled bytes do not change once written, so
+		// they may be read without the lock.
+		var chunk []byte
+		spilled := len(q.mem) == 0
+		if spilled {
+			chunk = *bp
+			if remaining := q.spilled() - q.readOff; remaining < int64(len(chunk)) {
+				chunk = chunk[:remaining]
+			}
+		} else {
+			chunk, q.mem = q.mem, nil
+		}
+		spool, off := q.spool, q.readOff
+		q.draining = true
+		q.lock.Unlock()
+
+		var err error
+		if spilled {
+			var n int
+			n, err = spool.ReadAt(chunk, off)
+			if err == io.EOF && n == len(chunk) {
+				err = nil
+			}
+			chunk = chunk[:n]
+		}
+		if err == nil {
+			var n int
+			n, err = q.iow.Write(chunk)
+			if err == nil && n < len(chunk) {
+				err = io.ErrShortWrite
+			}
+		}
+
+		q.lock.Lock()
+		q.draining = false
+		if err != nil {
+			q.err = err
+		} else if spilled {
+			q.readOff += int64(len(chunk))
+			if q.readOff == q.spilled() {
+				// Everything spilled has been written, so subsequent bytes may be held in
+				// memory again.
+				q.err = q.closeSpool()
+			}
+		}
+		q.cond.Broadcast()
+	}
+}
+
+// spilled returns the number of bytes written to the spool. The caller must hold the lock.
+func (q *spillQueue) spilled() int64 {
+	if q.spool == nil {
+		return 0
+	}
+	return q.spool.Size()
+}
+
+// closeSpool closes the spool, which removes its temporary file. The caller must hold the lock.
+func (q *spillQueue) closeSpool() error {
+	if q.spool == nil {
+		return nil
+	}
+	err := q.spool.Close()
+	q.spool = nil
+	q.readOff = 0
+	return err
+}
+
+// wait blocks until every byte queued has been written, or an error occurs, and returns that error.
+func (q *spillQueue) wait() error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	for q.err == nil && (q.draining || len(q.mem) > 0 || q.readOff < q.spilled()) {
+		q.cond.Wait()
+	}
+	return q.err
+}
+
+// Close waits for every byte queued to be written, then stops the drain go-routine, and closes the
+// spool. It returns the error that stopped the bytes from being written, if any.
+func (q *spillQueue) Close() error {
+	q.lock.Lock()
+	if q.halted {
+		q.lock.Unlock()
+		return nil
+	}
+	q.halted = true
+	q.cond.Broadcast()
+	q.lock.Unlock()
+
+	<-q.done
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	var errors ErrList
+	errors.Append(q.err)
+	errors.Append(q.closeSpool())
+	return errors.Err()
+}
@@ -0,0 +1,138 @@
+package gorill
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// SplitReader divides a stream into successive parts separated by a delimiter
+// byte sequence, such as the boundary of a multipart body, and exposes each
+// part as its own io.ReadCloser. A delimiter is found even when it spans
+// multiple reads from the underlying io.ReadCloser.
+//
+// Like bufio.Scanner, an empty stream has no parts, and a delimiter at the end
+// of the stream does not begin an additional empty part.
+type SplitReader struct {
+	current *SplitPart
+	delim   []byte
+	pr      *PeekReader
+}
+
+// NewSplitReader returns a SplitReader that divides the stream read from iorc
+// into parts separated by delim.
+//
+//   sr, err := gorill.NewSplitReader(body, []byte("\r\n--boundary\r\n"))
+//   if err != nil {
+//       return err
+//   }
+//   for {
+//       part, err := sr.Next()
+//       if err == io.EOF {
+//           break
+//       }
+//       if err != nil {
+//           return err
+//       }
+//       if err = process(part); err != nil {
+//           return err
+//       }
+//   }
+func NewSplitReader(iorc io.ReadCloser, delim []byte) (*SplitReader, error) {
+	if len(delim) == 0 {
+		return nil, fmt.Errorf("delimiter must not be empty")
+	}
+	return &SplitReader{delim: append([]byte(nil), delim...), pr: NewPeekReader(iorc)}, nil
+}
+
+// Next discards whatever remains unread of the current part, and returns the
+// next part. It returns io.EOF when there are no more parts.
+func (sr *SplitReader) Next() (*SplitPart, error) {
+	if sr.current != nil {
+		if err := sr.current.Close(); err != nil {
+			return nil, err
+		}
+		sr.current = nil
+	}
+	head, err := sr.pr.Peek(1)
+	if len(head) == 0 {
+		if err == nil {
+			err = io.EOF
+		}
+		return nil, err
+	}
+	sr.current = &SplitPart{sr: sr}
+	return sr.current, nil
+}
+
+// Unwrap returns the PeekReader from which parts are split.
+func (sr *SplitReader) Unwrap() io.ReadCloser { return sr.pr }
+
+// Close closes the underlying io.ReadCloser.
+func (sr *SplitReader) Close() error {
+	return sr.pr.Close()
+}
+
+// SplitPart is an io.ReadCloser that reads one part of the stream divided by a
+// SplitReader. It is only valid until the next call to the SplitReader's Next
+// method.
+type SplitPart struct {
+	done bool
+	sr   *SplitReader
+}
+
+// Read reads bytes of the part into p, and returns io.EOF at the end of the
+// part. The delimiter that ends the part is not returned.
+func (sp *SplitPart) Read(p []byte) (int, error) {
+	if sp.done || sp.sr.current != sp {
+		return 0, io.EOF
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	delim := sp.sr.delim
+	head, err := sp.sr.pr.Peek(len(p) + len(delim))
+
+	if i := bytes.Index(head, delim); i >= 0 {
+		n := copy(p, head[:i])
+		if n == i {
+			_, _ = sp.sr.pr.Discard(n + len(delim))
+			sp.done = true
+		} else {
+			_, _ = sp.sr.pr.Discard(n)
+		}
+		return n, nil
+	}
+
+	if len(head) == 0 {
+		sp.done = true
+		if err == nil || err == io.EOF {
+			return 0, io.EOF
+		}
+		return 0, err
+	}
+
+	safe := len(head)
+	if err == nil {
+		// The final bytes might be the start of a delimiter that spans past
+		// what was peeked.
+		safe -= len(delim) - 1
+	}
+	if safe > len(p) {
+		safe = len(p)
+	}
+	n := copy(p, head[:safe])
+	_, _ = sp.sr.pr.Discard(n)
+	return n, nil
+}
+
+// Close discards whatever remains unread of the part. It does not close the
+// underlying io.ReadCloser.
+func (sp *SplitPart) Close() error {
+	if sp.done || sp.sr.current != sp {
+		return nil
+	}
+	_, err := io.Copy(ioutil.Discard, sp)
+	return err
+}
@@ -0,0 +1,92 @@
+package gorill
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func splitParts(tb testing.TB, input, delim string, readAll bool) []string {
+	tb.Helper()
+	sr, err := NewSplitReader(ioutil.NopCloser(iotest.OneByteReader(strings.NewReader(input))), []byte(delim))
+	ensureError(tb, err)
+
+	var parts []string
+	for {
+		part, err := sr.Next()
+		if err == io.EOF {
+			break
+		}
+		ensureError(tb, err)
+		if !readAll {
+			parts = append(parts, "")
+			continue
+		}
+		buf, err := ioutil.ReadAll(iotest.HalfReader(part))
+		ensureError(tb, err)
+		parts = append(parts, string(buf))
+	}
+	ensureError(tb, sr.Close())
+	return parts
+}
+
+func TestSplitReader(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		if got := splitParts(t, "", "--", true); len(got) != 0 {
+			t.Errorf("GOT: %q; WANT: none", got)
+		}
+	})
+	t.Run("no delimiter", func(t *testing.T) {
+		ensureStringSlicesMatch(t, splitParts(t, alphabet, "--", true), []string{alphabet})
+	})
+	t.Run("spanning delimiters", func(t *testing.T) {
+		got := splitParts(t, "one\r\n--b\r\ntwo\r\n--b\r\n\r\n--b\r\nthree-\r\n--", "\r\n--b\r\n", true)
+		want := []string{"one", "two", "", "three-\r\n--"}
+		if len(got) != len(want) {
+			t.Fatalf("GOT: %q; WANT: %q", got, want)
+		}
+		for i := range got {
+			if got[i] != want[i] {
+				t.Errorf("GOT: %q; WANT: %q", got[i], want[i])
+			}
+		}
+	})
+	t.Run("trailing delimiter", func(t *testing.T) {
+		got := splitParts(t, "a,b,", ",", true)
+		if len(got) != 2 || got[0] != "a" || got[1] != "b" {
+			t.Errorf("GOT: %q; WANT: %q", got, []string{"a", "b"})
+		}
+	})
+	t.Run("skip unread parts", func(t *testing.T) {
+		if got, want := len(splitParts(t, "aaa,bbb,ccc", ",", false)), 3; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
+
+func TestSplitReaderStalePart(t *testing.T) {
+	sr, err := NewSplitReader(ioutil.NopCloser(strings.NewReader("abc|def")), []byte("|"))
+	ensureError(t, err)
+
+	first, err := sr.Next()
+	ensureError(t, err)
+	second, err := sr.Next()
+	ensureError(t, err)
+
+	n, err := first.Read(make([]byte, 4))
+	if n != 0 || err != io.EOF {
+		t.Errorf("GOT: %v, %v; WANT: 0, EOF", n, err)
+	}
+	buf, err := ioutil.ReadAll(second)
+	ensureError(t, err)
+	if got, want := string(buf), "def"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+}
+
+func TestSplitReaderEmptyDelimiter(t *testing.T) {
+	_, err := NewSplitReader(ioutil.NopCloser(strings.NewReader(alphabet)), nil)
+	ensureError(t, err, "delimiter must not be empty")
+}
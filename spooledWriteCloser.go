@@ -4,7 +4,9 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -15,16 +17,36 @@ const DefaultBufSize = 4096
 const DefaultFlushPeriod = 15 * time.Second
 
 // SpooledWriteCloser spools bytes written to it through a bufio.Writer, periodically flushing data
-// written to underlying io.WriteCloser.
+// written to underlying io.WriteCloser. When the underlying io.WriteCloser is a net.Conn, written
+// payloads are instead queued individually and flushed using a single vectored write. It is safe
+// for concurrent use by multiple go-routines: each write blocks until the go-routine that owns the
+// buffer has accepted it, and receives its own result.
 type SpooledWriteCloser struct {
+	// Statistics are accessed atomically, and kept first for 64-bit alignment.
+	statBytes   int64
+	statFlushes int64
+	statWrites  int64
+	statBufSize int64
+
+	autoLatency time.Duration // autoLatency is the target flush duration
+	autoMax     int           // autoMax is the maximum buffer size when auto-tuning
+	autoMin     int           // autoMin is the minimum buffer size when auto-tuning
 	bufSize     int
-	bw          *bufio.Writer
+	bw          flushWriter
+	clock       Clock
+	drainBytes  int64         // drainBytes is the number of bytes written to iowc this period
+	drainTime   time.Duration // drainTime is the time spent writing drainBytes to iowc
+	flushBytes  int           // flushBytes is the number of buffered bytes that triggers a flush
 	flushPeriod time.Duration
 	halted      bool
+	hook        OperationHook
 	iowc        io.WriteCloser
 	jobs        chan *rillJob
 	jobsDone    sync.WaitGroup
 	lock        sync.RWMutex
+	spill       *spillQueue // spill holds flushed bytes not yet written, when spilling to disk
+	spillCap    int
+	spillDir    string
 }
 
 // SpooledWriteCloserSetter is any function that modifies a SpooledWriteCloser being instantiated.
@@ -41,6 +63,20 @@ func Flush(periodicity time.Duration) SpooledWriteCloserSetter {
 	}
 }
 
+// FlushBytes is used to configure a new SpooledWriteCloser to also flush whenever at least size
+// bytes have accumulated since the previous flush, rather than only periodically, so that bursts
+// of writes do not sit in memory until the next periodic flush. The buffer is flushed before the
+// write that filled it returns.
+func FlushBytes(size int) SpooledWriteCloserSetter {
+	return func(sw *SpooledWriteCloser) error {
+		if size <= 0 {
+			return fmt.Errorf("flush size must be greater than 0: %d", size)
+		}
+		sw.flushBytes = size
+		return nil
+	}
+}
+
 // BufSize is used to configure a new SpooledWriteCloser's buffer size.
 func BufSize(size int) SpooledWriteCloserSetter {
 	return func(sw *SpooledWriteCloser) error {
@@ -52,11 +88,97 @@ func BufSize(size int) SpooledWriteCloserSetter {
 	}
 }
 
+// AutoTune is used to configure a new SpooledWriteCloser to grow and shrink its buffer between the
+// specified bounds based on observed usage, removing the need to guess the buffer size up front.
+// At each periodic flush, the buffer size is doubled when a write did not fit in the space
+// available during the preceding period, and halved when less than one quarter of the buffer was
+// used. The initial buffer size is the configured BufSize, limited to the specified bounds.
+func AutoTune(min, max int) SpooledWriteCloserSetter {
+	return func(sw *SpooledWriteCloser) error {
+		if min <= 0 {
+			return fmt.Errorf("minimum buffer size must be greater than 0: %d", min)
+		}
+		if max < min {
+			return fmt.Errorf("maximum buffer size must not be less than minimum buffer size %d: %d", min, max)
+		}
+		sw.autoLatency, sw.autoMin, sw.autoMax = 0, min, max
+		return nil
+	}
+}
+
+// AutoTuneThroughput is used to configure a new SpooledWriteCloser to grow and shrink its buffer
+// between the specified bounds based on the measured throughput of the underlying io.WriteCloser,
+// rather than on whether writes fit in the buffer like AutoTune. The SpooledWriteCloser times each
+// write to the underlying io.WriteCloser with its clock, and after each periodic flush computes the
+// throughput of the preceding period as the bytes written divided by the time spent writing them.
+// It then resizes the buffer to the number of bytes the underlying io.WriteCloser writes in
+// latency, so that a fast destination gets a large buffer and fewer writes, and a slow destination
+// gets a small buffer that does not block writes for long while it is flushed. The buffer is only
+// resized when that size is at least double or at most half the current size, and it is halved
+// after a period in which nothing was written. The initial buffer size is the configured BufSize,
+// limited to the specified bounds.
+//
+//   tune := gorill.AutoTuneThroughput(4096, 1<<20, 10*time.Millisecond)
+//   sw, err := gorill.NewSpooledWriteCloser(conn, tune)
+func AutoTuneThroughput(min, max int, latency time.Duration) SpooledWriteCloserSetter {
+	return func(sw *SpooledWriteCloser) error {
+		if err := AutoTune(min, max)(sw); err != nil {
+			return err
+		}
+		if latency <= 0 {
+			return fmt.Errorf("latency must be greater than 0: %s", latency)
+		}
+		sw.autoLatency = latency
+		return nil
+	}
+}
+
+// SpillToDisk is used to configure a new SpooledWriteCloser to write flushed bytes to the
+// underlying io.WriteCloser from a background go-routine, so that a slow sink does not block
+// writes. Up to approximately memoryCap bytes waiting to be written are held in memory, and the
+// bytes beyond that are spilled to a temporary file in dir, which is removed once it has been
+// drained. An empty dir uses the directory returned by os.TempDir. Flush and Close wait for the
+// spilled bytes to be written. An error writing to the underlying io.WriteCloser is returned by a
+// subsequent write, Flush, or Close.
+//
+//   sw, err := gorill.NewSpooledWriteCloser(conn, gorill.SpillToDisk(16<<20, ""))
+func SpillToDisk(memoryCap int, dir string) SpooledWriteCloserSetter {
+	return func(sw *SpooledWriteCloser) error {
+		if memoryCap <= 0 {
+			return fmt.Errorf("memory cap must be greater than 0: %d", memoryCap)
+		}
+		sw.spillCap, sw.spillDir = memoryCap, dir
+		return nil
+	}
+}
+
+// SpoolHook is used to configure a new SpooledWriteCloser to report each flush of its buffer to
+// hook as an OpFlush operation.
+func SpoolHook(hook OperationHook) SpooledWriteCloserSetter {
+	return func(sw *SpooledWriteCloser) error {
+		sw.hook = hook
+		return nil
+	}
+}
+
+// SpoolClock is used to configure the clock whose ticker drives the periodic flushes of a new
+// SpooledWriteCloser.
+func SpoolClock(clock Clock) SpooledWriteCloserSetter {
+	return func(sw *SpooledWriteCloser) error {
+		if clock == nil {
+			return fmt.Errorf("clock must not be nil")
+		}
+		sw.clock = clock
+		return nil
+	}
+}
+
 // NewSpooledWriteCloser returns a SpooledWriteCloser that spools bytes written to it through a
 // bufio.Writer, periodically forcing the bufio.Writer to flush its contents.
 func NewSpooledWriteCloser(iowc io.WriteCloser, setters ...SpooledWriteCloserSetter) (*SpooledWriteCloser, error) {
 	w := &SpooledWriteCloser{
 		bufSize:     DefaultBufSize,
+		clock:       systemClock{},
 		flushPeriod: DefaultFlushPeriod,
 		iowc:        iowc,
 		jobs:        make(chan *rillJob, 1),
@@ -66,12 +188,24 @@ func NewSpooledWriteCloser(iowc io.WriteCloser, setters ...SpooledWriteCloserSet
 			return nil, err
 		}
 	}
-	w.bw = bufio.NewWriterSize(iowc, w.bufSize)
+	if w.autoMax > 0 {
+		if w.bufSize < w.autoMin {
+			w.bufSize = w.autoMin
+		} else if w.bufSize > w.autoMax {
+			w.bufSize = w.autoMax
+		}
+	}
+	if w.spillCap > 0 {
+		w.spill = newSpillQueue(w.iowc, w.spillCap, w.spillDir)
+	}
+	w.bw = w.newBuffer()
+	w.statBufSize = int64(w.bufSize)
 	w.jobsDone.Add(1)
 	go func() {
-		ticker := time.NewTicker(w.flushPeriod)
+		ticker := w.clock.NewTicker(w.flushPeriod)
 		defer ticker.Stop()
 		defer w.jobsDone.Done()
+		var saturated bool // saturated is set when a write did not fit in the buffer
 		for {
 			select {
 			case job, more := <-w.jobs:
@@ -79,21 +213,175 @@ func NewSpooledWriteCloser(iowc io.WriteCloser, setters ...SpooledWriteCloserSet
 					return
 				}
 				switch job.op {
-				case _write:
-					n, err := w.bw.Write(job.data)
+				case _write, _writeString:
+					if len(job.data)+len(job.str) > w.bw.Available() {
+						saturated = true
+					}
+					n, err := w.spool(job)
+					atomic.AddInt64(&w.statWrites, 1)
+					atomic.AddInt64(&w.statBytes, int64(n))
+					w.flushFull()
 					job.results <- rillResult{n, err}
 				case _flush:
-					err := w.bw.Flush()
+					err := w.flush()
 					job.results <- rillResult{0, err}
 				}
-			case <-ticker.C:
-				w.bw.Flush()
+			case <-ticker.C():
+				if w.autoMax > 0 && w.autoLatency == 0 {
+					w.retune(saturated)
+					saturated = false
+				}
+				w.flush()
+				if w.autoLatency > 0 {
+					w.retuneThroughput()
+				}
 			}
 		}
 	}()
 	return w, nil
 }
 
+// spool writes the data of a write job to the buffer. Only invoked by the go-routine that owns the
+// buffer.
+func (w *SpooledWriteCloser) spool(job *rillJob) (int, error) {
+	var buffered int
+	var started time.Time
+	if w.autoLatency > 0 {
+		buffered, started = w.bw.Buffered(), w.clock.Now()
+	}
+	var n int
+	var err error
+	if job.op == _writeString {
+		n, err = io.WriteString(w.bw, job.str)
+	} else {
+		n, err = w.bw.Write(job.data)
+	}
+	if w.autoLatency > 0 {
+		w.measure(buffered+n, started)
+	}
+	return n, err
+}
+
+// measure records the bytes the buffer wrote to the underlying io.WriteCloser since started, given
+// the number of bytes it would hold had it written none, for AutoTuneThroughput. Only invoked by
+// the go-routine that owns the buffer.
+func (w *SpooledWriteCloser) measure(buffered int, started time.Time) {
+	if drained := buffered - w.bw.Buffered(); drained > 0 {
+		w.drainBytes += int64(drained)
+		w.drainTime += w.clock.Now().Sub(started)
+	}
+}
+
+// flush flushes the buffer, reporting the operation to the hook. Only invoked by the go-routine
+// that owns the buffer, or after that go-routine has exited.
+func (w *SpooledWriteCloser) flush() error {
+	end := startOperation(w.hook, OpFlush)
+	buffered := w.bw.Buffered()
+	var started time.Time
+	if w.autoLatency > 0 {
+		started = w.clock.Now()
+	}
+	err := w.bw.Flush()
+	if w.autoLatency > 0 {
+		w.measure(buffered, started)
+	}
+	end(buffered-w.bw.Buffered(), err)
+	atomic.AddInt64(&w.statFlushes, 1)
+	return err
+}
+
+// flushFull flushes the buffer when it holds at least the number of bytes configured by FlushBytes.
+// Like a periodic flush, an error is retained by the buffer and returned by a later write. Only
+// invoked by the go-routine that owns the buffer.
+func (w *SpooledWriteCloser) flushFull() {
+	if w.flushBytes > 0 && w.bw.Buffered() >= w.flushBytes {
+		w.flush()
+	}
+}
+
+// newBuffer returns the buffer through which bytes written are spooled.
+func (w *SpooledWriteCloser) newBuffer() flushWriter {
+	if w.spill != nil {
+		return bufio.NewWriterSize(w.spill, w.bufSize)
+	}
+	if _, ok := w.iowc.(net.Conn); ok {
+		// Flush queued payloads with a single vectored write rather than
+		// copying them into one contiguous buffer.
+		return newVectoredWriter(w.iowc, w.bufSize)
+	}
+	return bufio.NewWriterSize(w.iowc, w.bufSize)
+}
+
+// retune resizes the buffer based on its usage during the preceding period.
+// Only invoked by the go-routine that owns the buffer.
+func (w *SpooledWriteCloser) retune(saturated bool) {
+	size := w.bufSize
+	if saturated {
+		size *= 2
+		if size > w.autoMax {
+			size = w.autoMax
+		}
+	} else if w.bw.Buffered() < w.bufSize/4 {
+		size /= 2
+		if size < w.autoMin {
+			size = w.autoMin
+		}
+	}
+	if size == w.bufSize {
+		return
+	}
+	if err := w.bw.Flush(); err != nil {
+		return // retain existing buffer, which retains the error
+	}
+	w.bufSize = size
+	w.bw = w.newBuffer()
+	atomic.StoreInt64(&w.statBufSize, int64(size))
+}
+
+// retuneThroughput resizes the buffer to the number of bytes the underlying io.WriteCloser wrote
+// in autoLatency during the preceding period, then resets the measurements. Only invoked by the
+// go-routine that owns the buffer, after it flushes the buffer.
+func (w *SpooledWriteCloser) retuneThroughput() {
+	drainBytes, drainTime := w.drainBytes, w.drainTime
+	w.drainBytes, w.drainTime = 0, 0
+
+	size := w.bufSize / 2 // idle period
+	if drainBytes > 0 {
+		size = w.autoMax // also when faster than the clock can measure
+		if drainTime > 0 {
+			if target := drainBytes * int64(w.autoLatency) / int64(drainTime); target < int64(size) {
+				size = int(target)
+			}
+		}
+		if size < w.bufSize*2 && size > w.bufSize/2 {
+			return // within hysteresis band
+		}
+	}
+	if size < w.autoMin {
+		size = w.autoMin
+	}
+	if size == w.bufSize {
+		return
+	}
+	if err := w.bw.Flush(); err != nil {
+		return // retain existing buffer, which retains the error
+	}
+	w.bufSize = size
+	w.bw = w.newBuffer()
+	atomic.StoreInt64(&w.statBufSize, int64(size))
+}
+
+// Stats returns the number of writes, bytes written, and flushes, along with
+// the current buffer size.
+func (w *SpooledWriteCloser) Stats() map[string]int64 {
+	return map[string]int64{
+		"buffer_size": atomic.LoadInt64(&w.statBufSize),
+		"bytes":       atomic.LoadInt64(&w.statBytes),
+		"flushes":     atomic.LoadInt64(&w.statFlushes),
+		"writes":      atomic.LoadInt64(&w.statWrites),
+	}
+}
+
 // Write spools a byte slice of data to be written to the SpooledWriteCloser.
 func (w *SpooledWriteCloser) Write(data []byte) (int, error) {
 	w.lock.RLock()
@@ -110,7 +398,25 @@ func (w *SpooledWriteCloser) Write(data []byte) (int, error) {
 	return result.n, result.err
 }
 
-// Flush causes all data not yet written to the output stream to be flushed.
+// WriteString spools s to be written to the SpooledWriteCloser, copying it into the buffer without
+// first converting it to a byte slice when the buffer allows it.
+func (w *SpooledWriteCloser) WriteString(s string) (int, error) {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	if w.halted {
+		return 0, ErrWriteAfterClose{}
+	}
+
+	job := newRillJob(_writeString, nil)
+	job.str = s
+	w.jobs <- job
+	result := <-job.results
+	return result.n, result.err
+}
+
+// Flush causes all data not yet written to the output stream to be flushed. When spilling to disk,
+// it also waits for the spilled data to be written.
 func (w *SpooledWriteCloser) Flush() error {
 	w.lock.RLock()
 	defer w.lock.RUnlock()
@@ -123,20 +429,57 @@ func (w *SpooledWriteCloser) Flush() error {
 	w.jobs <- job
 	result := <-job.results
 	// wait for results
+	if result.err == nil && w.spill != nil {
+		return w.spill.wait()
+	}
 	return result.err
 }
 
-// Close frees resources when a SpooledWriteCloser is no longer needed.
+// Sync flushes all data not yet written to the underlying io.WriteCloser like Flush, then, when
+// the underlying io.WriteCloser has a Sync method returning an error, such as *os.File, invokes it
+// to commit the data to stable storage, such as before acknowledging work that must not be lost.
+//
+//   if err := sw.Sync(); err != nil {
+//       return err
+//   }
+//   ack(job)
+func (w *SpooledWriteCloser) Sync() error {
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if s, ok := w.iowc.(interface{ Sync() error }); ok {
+		return s.Sync()
+	}
+	return nil
+}
+
+// SetWriteDeadline sets the write deadline of the underlying io.WriteCloser,
+// which bounds the writes made when the spooled data is flushed.
+func (w *SpooledWriteCloser) SetWriteDeadline(t time.Time) error {
+	return SetWriteDeadline(w.iowc, t)
+}
+
+// Unwrap returns the underlying io.WriteCloser.
+func (w *SpooledWriteCloser) Unwrap() io.WriteCloser { return w.iowc }
+
+// Close frees resources when a SpooledWriteCloser is no longer needed. It waits for writes in
+// progress from other go-routines to complete, and subsequent calls return nil.
 func (w *SpooledWriteCloser) Close() error {
 	w.lock.Lock()
 	defer w.lock.Unlock()
 
+	if w.halted {
+		return nil
+	}
 	close(w.jobs)
 	w.jobsDone.Wait()
 	w.halted = true
 
 	var errors ErrList
-	errors.Append(w.bw.Flush())
+	errors.Append(w.flush())
+	if w.spill != nil {
+		errors.Append(w.spill.Close())
+	}
 	errors.Append(w.iowc.Close())
 	return errors.Err()
 }
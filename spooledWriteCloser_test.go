@@ -2,6 +2,10 @@ package gorill
 
 import (
 	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -65,3 +69,362 @@ func TestSpooledWriteCloserCloseCausesFlush(t *testing.T) {
 	test(smallBuf, time.Hour)
 	test(largeBuf, time.Hour)
 }
+
+func TestSpooledWriteCloserAutoTune(t *testing.T) {
+	t.Run("invalid bounds", func(t *testing.T) {
+		_, err := NewSpooledWriteCloser(NewNopCloseBuffer(), AutoTune(0, 10))
+		ensureError(t, err, "minimum buffer size must be greater than 0")
+		_, err = NewSpooledWriteCloser(NewNopCloseBuffer(), AutoTune(10, 5))
+		ensureError(t, err, "maximum buffer size must not be less than minimum")
+	})
+
+	t.Run("initial size within bounds", func(t *testing.T) {
+		w, err := NewSpooledWriteCloser(NewNopCloseBuffer(), BufSize(1), AutoTune(512, 1024), Flush(time.Hour))
+		ensureError(t, err)
+		ensureError(t, w.Close())
+		if got, want := w.bufSize, 512; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("grows when saturated", func(t *testing.T) {
+		clock := NewFakeClock(time.Unix(0, 0))
+		bb := NewNopCloseBuffer()
+		w, err := NewSpooledWriteCloser(bb, BufSize(1024), AutoTune(1024, 8192), Flush(time.Minute), SpoolClock(clock))
+		ensureError(t, err)
+		clock.BlockUntil(1) // the flush ticker
+		payload := make([]byte, 10000)
+		for _, want := range []int{2048, 4096, 8192, 8192} {
+			_, err = w.Write(payload)
+			ensureError(t, err)
+			advanceSpool(t, w, clock, time.Minute)
+			if got := int(w.Stats()["buffer_size"]); got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		}
+		ensureError(t, w.Close())
+		if got, want := bb.Len(), 4*len(payload); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("shrinks when idle", func(t *testing.T) {
+		clock := NewFakeClock(time.Unix(0, 0))
+		w, err := NewSpooledWriteCloser(NewNopCloseBuffer(), BufSize(8192), AutoTune(1024, 8192), Flush(time.Minute), SpoolClock(clock))
+		ensureError(t, err)
+		clock.BlockUntil(1) // the flush ticker
+		for _, want := range []int{4096, 2048, 1024, 1024} {
+			advanceSpool(t, w, clock, time.Minute)
+			if got := int(w.Stats()["buffer_size"]); got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		}
+		ensureError(t, w.Close())
+	})
+}
+
+func TestSpooledWriteCloserFakeClock(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	lr := new(lockedRecorder)
+	spoolWriter, err := NewSpooledWriteCloser(lr, Flush(time.Minute), SpoolClock(clock))
+	ensureError(t, err)
+
+	_, err = spoolWriter.Write([]byte("spooled"))
+	ensureError(t, err)
+	clock.BlockUntil(1) // the flush ticker
+	if got, _ := lr.snapshot(); got != "" {
+		t.Errorf("GOT: %q; WANT: %q", got, "")
+	}
+
+	clock.Advance(time.Minute)
+	deadline := time.Now().Add(time.Second)
+	for {
+		got, _ := lr.snapshot()
+		if got == "spooled" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("GOT: %q; WANT: %q", got, "spooled")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	ensureError(t, spoolWriter.Close())
+}
+
+func TestSpooledWriteCloserWriteString(t *testing.T) {
+	bb := NewNopCloseBuffer()
+	spoolWriter, err := NewSpooledWriteCloser(bb, Flush(time.Hour))
+	ensureError(t, err)
+
+	n, err := spoolWriter.WriteString(alphabet)
+	ensureError(t, err)
+	if got, want := n, len(alphabet); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	ensureError(t, spoolWriter.Close())
+	if got, want := bb.String(), alphabet; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+
+	_, err = spoolWriter.WriteString(alphabet)
+	testErrorType(t, err, ErrWriteAfterClose{})
+}
+
+func TestSpooledWriteCloserFlushBytes(t *testing.T) {
+	_, err := NewSpooledWriteCloser(NewNopCloseBuffer(), FlushBytes(0))
+	ensureError(t, err, "flush size must be greater than 0")
+
+	bb := NewNopCloseBuffer()
+	spoolWriter, err := NewSpooledWriteCloser(bb, Flush(time.Hour), FlushBytes(10))
+	ensureError(t, err)
+
+	_, err = spoolWriter.Write([]byte("12345"))
+	ensureError(t, err)
+	if got, want := bb.String(), ""; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	_, err = spoolWriter.WriteString("67890")
+	ensureError(t, err)
+	if got, want := bb.String(), "1234567890"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	_, err = spoolWriter.Write([]byte("abc"))
+	ensureError(t, err)
+	if got, want := bb.String(), "1234567890"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	ensureError(t, spoolWriter.Close())
+	if got, want := bb.String(), "1234567890abc"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+}
+
+// gatedRecorder blocks each write until its gate is closed.
+type gatedRecorder struct {
+	lockedRecorder
+	gate chan struct{}
+}
+
+func (g *gatedRecorder) Write(data []byte) (int, error) {
+	<-g.gate
+	return g.lockedRecorder.Write(data)
+}
+
+func TestSpooledWriteCloserSpillToDisk(t *testing.T) {
+	_, err := NewSpooledWriteCloser(NewNopCloseBuffer(), SpillToDisk(0, ""))
+	ensureError(t, err, "memory cap must be greater than 0")
+
+	t.Run("spills while sink is slow", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "gorill")
+		ensureError(t, err)
+		defer os.RemoveAll(dir)
+
+		gr := &gatedRecorder{gate: make(chan struct{})}
+		spoolWriter, err := NewSpooledWriteCloser(gr, Flush(time.Hour), BufSize(4), SpillToDisk(8, dir))
+		ensureError(t, err)
+
+		for i := 0; i < len(alphabet); i += 9 {
+			_, err = spoolWriter.Write([]byte(alphabet[i : i+9]))
+			ensureError(t, err)
+		}
+		files, err := ioutil.ReadDir(dir)
+		ensureError(t, err)
+		if got, want := len(files), 1; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		close(gr.gate)
+		ensureError(t, spoolWriter.Flush())
+		if got, _ := gr.snapshot(); got != alphabet {
+			t.Errorf("GOT: %q; WANT: %q", got, alphabet)
+		}
+		files, err = ioutil.ReadDir(dir)
+		ensureError(t, err)
+		if got, want := len(files), 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		_, err = spoolWriter.Write([]byte("more"))
+		ensureError(t, err)
+		ensureError(t, spoolWriter.Close())
+		if got, want := gr.lockedRecorder.br.String(), alphabet+"more"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("sink error", func(t *testing.T) {
+		spoolWriter, err := NewSpooledWriteCloser(&testWriteCloser{}, Flush(time.Hour), SpillToDisk(8, ""))
+		ensureError(t, err)
+		_, err = spoolWriter.Write([]byte(alphabet))
+		ensureError(t, err)
+		ensureError(t, spoolWriter.Flush(), "short write")
+		ensureError(t, spoolWriter.Close(), "short write")
+	})
+}
+
+func TestSpooledWriteCloserConcurrentWriters(t *testing.T) {
+	lr := new(lockedRecorder)
+	spoolWriter, err := NewSpooledWriteCloser(lr, Flush(time.Millisecond), BufSize(16))
+	ensureError(t, err)
+
+	const writers, writes = 8, 100
+	var wg sync.WaitGroup
+	wg.Add(writers)
+	for i := 0; i < writers; i++ {
+		go func() {
+			defer wg.Done()
+			for j := 0; j < writes; j++ {
+				if j%2 == 0 {
+					_, err := spoolWriter.Write([]byte("abc\n"))
+					ensureError(t, err)
+				} else {
+					_, err := spoolWriter.WriteString("abc\n")
+					ensureError(t, err)
+				}
+				if j%10 == 0 {
+					ensureError(t, spoolWriter.Flush())
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	ensureError(t, spoolWriter.Close())
+	ensureError(t, spoolWriter.Close()) // subsequent calls return nil
+
+	got, _ := lr.snapshot()
+	if want := strings.Repeat("abc\n", writers*writes); got != want {
+		t.Errorf("GOT: %d bytes; WANT: %d bytes", len(got), len(want))
+	}
+	stats := spoolWriter.Stats()
+	if got, want := stats["writes"], int64(writers*writes); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
+
+// syncRecorder records the data written to it when synced.
+type syncRecorder struct {
+	*NopCloseBuffer
+	synced string
+}
+
+func (s *syncRecorder) Sync() error {
+	s.synced = s.String()
+	return nil
+}
+
+func TestSpooledWriteCloserSync(t *testing.T) {
+	sr := &syncRecorder{NopCloseBuffer: NewNopCloseBuffer()}
+	spoolWriter, err := NewSpooledWriteCloser(sr, Flush(time.Hour))
+	ensureError(t, err)
+	_, err = spoolWriter.Write([]byte(alphabet))
+	ensureError(t, err)
+	ensureError(t, spoolWriter.Sync())
+	if got, want := sr.synced, alphabet; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	ensureError(t, spoolWriter.Close())
+
+	// Without a Sync method on the underlying writer, Sync only flushes.
+	bb := NewNopCloseBuffer()
+	spoolWriter, err = NewSpooledWriteCloser(bb, Flush(time.Hour))
+	ensureError(t, err)
+	_, err = spoolWriter.Write([]byte(alphabet))
+	ensureError(t, err)
+	ensureError(t, spoolWriter.Sync())
+	if got, want := bb.String(), alphabet; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	ensureError(t, spoolWriter.Close())
+	testErrorType(t, spoolWriter.Sync(), ErrWriteAfterClose{})
+}
+
+// meteredWriteCloser advances clock by the time it would take a destination
+// of a fixed speed to write each slice of bytes.
+type meteredWriteCloser struct {
+	*NopCloseBuffer
+	clock   *FakeClock
+	perByte time.Duration
+}
+
+func (m *meteredWriteCloser) Write(p []byte) (int, error) {
+	m.clock.Advance(time.Duration(len(p)) * m.perByte)
+	return m.NopCloseBuffer.Write(p)
+}
+
+func TestSpooledWriteCloserAutoTuneThroughput(t *testing.T) {
+	t.Run("invalid latency", func(t *testing.T) {
+		_, err := NewSpooledWriteCloser(NewNopCloseBuffer(), AutoTuneThroughput(512, 1024, 0))
+		ensureError(t, err, "latency must be greater than 0")
+		_, err = NewSpooledWriteCloser(NewNopCloseBuffer(), AutoTuneThroughput(0, 1024, time.Millisecond))
+		ensureError(t, err, "minimum buffer size must be greater than 0")
+	})
+
+	t.Run("sizes to slow destination then shrinks when idle", func(t *testing.T) {
+		clock := NewFakeClock(time.Unix(0, 0))
+		// The destination writes 1,000 bytes in the 1ms target latency.
+		mw := &meteredWriteCloser{NopCloseBuffer: NewNopCloseBuffer(), clock: clock, perByte: time.Microsecond}
+		w, err := NewSpooledWriteCloser(mw, BufSize(8192), AutoTuneThroughput(256, 16384, time.Millisecond), Flush(time.Hour), SpoolClock(clock))
+		ensureError(t, err)
+		clock.BlockUntil(1) // the flush ticker
+		_, err = w.Write(make([]byte, 10000))
+		ensureError(t, err)
+		for _, want := range []int{1000, 500, 256, 256} {
+			advanceSpool(t, w, clock, time.Hour)
+			if got := int(w.Stats()["buffer_size"]); got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		}
+		ensureError(t, w.Close())
+		if got, want := mw.Len(), 10000; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("grows for fast destination", func(t *testing.T) {
+		clock := NewFakeClock(time.Unix(0, 0))
+		mw := &meteredWriteCloser{NopCloseBuffer: NewNopCloseBuffer(), clock: clock, perByte: time.Nanosecond}
+		w, err := NewSpooledWriteCloser(mw, BufSize(1024), AutoTuneThroughput(256, 16384, time.Millisecond), Flush(time.Hour), SpoolClock(clock))
+		ensureError(t, err)
+		clock.BlockUntil(1) // the flush ticker
+		_, err = w.Write(make([]byte, 100))
+		ensureError(t, err)
+		advanceSpool(t, w, clock, time.Hour)
+		if got, want := int(w.Stats()["buffer_size"]), 16384; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureError(t, w.Close())
+	})
+
+	t.Run("retains size within hysteresis band", func(t *testing.T) {
+		clock := NewFakeClock(time.Unix(0, 0))
+		// The destination writes 1,500 bytes in the 1ms target latency.
+		mw := &meteredWriteCloser{NopCloseBuffer: NewNopCloseBuffer(), clock: clock, perByte: time.Millisecond / 1500}
+		w, err := NewSpooledWriteCloser(mw, BufSize(1024), AutoTuneThroughput(256, 16384, time.Millisecond), Flush(time.Hour), SpoolClock(clock))
+		ensureError(t, err)
+		clock.BlockUntil(1) // the flush ticker
+		_, err = w.Write(make([]byte, 3000))
+		ensureError(t, err)
+		advanceSpool(t, w, clock, time.Hour)
+		if got, want := int(w.Stats()["buffer_size"]), 1024; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureError(t, w.Close())
+	})
+}
+
+// advanceSpool advances clock by one flush period of w, and waits for the
+// go-routine that owns the buffer of w to handle the tick.
+func advanceSpool(t *testing.T, w *SpooledWriteCloser, clock *FakeClock, period time.Duration) {
+	t.Helper()
+	flushes := w.Stats()["flushes"]
+	clock.Advance(period)
+	deadline := time.Now().Add(5 * time.Second)
+	for w.Stats()["flushes"] == flushes {
+		if time.Now().After(deadline) {
+			t.Fatalf("GOT: %v; WANT: a periodic flush", flushes)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// The go-routine handles the tick before the job of this Flush.
+	ensureError(t, w.Flush())
+}
@@ -0,0 +1,208 @@
+package gorill
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SSEWriter is an io.WriteCloser that frames each write as a Server-Sent Event,
+// with each line of the write becoming a "data:" field of the event. Combined
+// with a ResponseWriteCloser and a MultiWriteCloserFanOut, it provides a
+// complete pipeline for streaming a live tail to browsers using the
+// EventSource API.
+type SSEWriter struct {
+	autoID    bool
+	clock     Clock
+	event     string
+	halted    bool
+	heartbeat time.Duration
+	id        uint64
+	iowc      io.WriteCloser
+	lock      sync.Mutex
+	stop      chan struct{}
+	stopped   sync.WaitGroup
+	written   bool // written is set when an event was written since the last heartbeat
+}
+
+// SSEWriterSetter is any function that modifies a SSEWriter being instantiated.
+type SSEWriterSetter func(*SSEWriter) error
+
+// SSEEvent is used to configure a new SSEWriter to include an "event:" field
+// with the specified name in each event it writes.
+func SSEEvent(name string) SSEWriterSetter {
+	return func(sw *SSEWriter) error {
+		if strings.ContainsAny(name, "\r\n") {
+			return fmt.Errorf("event name must not contain newline characters: %q", name)
+		}
+		sw.event = name
+		return nil
+	}
+}
+
+// SSEAutoID is used to configure a new SSEWriter to include an "id:" field in
+// each event it writes, numbered sequentially starting at 1, allowing a client
+// that reconnects to report the last event it received.
+func SSEAutoID() SSEWriterSetter {
+	return func(sw *SSEWriter) error {
+		sw.autoID = true
+		return nil
+	}
+}
+
+// SSEHeartbeat is used to configure a new SSEWriter to write a comment after
+// each period during which no event was written, preventing proxies from
+// closing idle connections.
+func SSEHeartbeat(period time.Duration) SSEWriterSetter {
+	return func(sw *SSEWriter) error {
+		if period <= 0 {
+			return fmt.Errorf("heartbeat period must be greater than 0: %s", period)
+		}
+		sw.heartbeat = period
+		return nil
+	}
+}
+
+// SSEClock is used to configure the clock whose ticker paces the heartbeats of
+// a new SSEWriter.
+func SSEClock(clock Clock) SSEWriterSetter {
+	return func(sw *SSEWriter) error {
+		if clock == nil {
+			return fmt.Errorf("clock must not be nil")
+		}
+		sw.clock = clock
+		return nil
+	}
+}
+
+// NewSSEWriter returns a SSEWriter that writes framed events to iowc.
+//
+//   func events(w http.ResponseWriter, r *http.Request) {
+//       w.Header().Set("Content-Type", "text/event-stream")
+//       w.Header().Set("Cache-Control", "no-cache")
+//       rwc, err := gorill.NewResponseWriteCloser(w, r)
+//       if err != nil {
+//           http.Error(w, err.Error(), http.StatusInternalServerError)
+//           return
+//       }
+//       sse, _ := gorill.NewSSEWriter(rwc, gorill.SSEHeartbeat(15*time.Second))
+//       fanOut.Add(sse)
+//       <-rwc.Done()
+//       fanOut.Remove(sse)
+//   }
+func NewSSEWriter(iowc io.WriteCloser, setters ...SSEWriterSetter) (*SSEWriter, error) {
+	sw := &SSEWriter{clock: systemClock{}, iowc: iowc}
+	for _, setter := range setters {
+		if err := setter(sw); err != nil {
+			return nil, err
+		}
+	}
+	if sw.heartbeat > 0 {
+		sw.stop = make(chan struct{})
+		sw.stopped.Add(1)
+		go sw.beat()
+	}
+	return sw, nil
+}
+
+// beat writes a heartbeat comment after each idle period.
+func (sw *SSEWriter) beat() {
+	defer sw.stopped.Done()
+	ticker := sw.clock.NewTicker(sw.heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-sw.stop:
+			return
+		case <-ticker.C():
+			sw.lock.Lock()
+			if !sw.written && !sw.halted {
+				_, _ = sw.iowc.Write([]byte(":\n\n")) // a failing sink fails next Write
+			}
+			sw.written = false
+			sw.lock.Unlock()
+		}
+	}
+}
+
+// Write writes data as a single event, with each line of data becoming a
+// "data:" field. A final newline in data does not start another line.
+func (sw *SSEWriter) Write(data []byte) (int, error) {
+	if _, err := sw.WriteEvent(sw.event, data); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// WriteEvent writes data as a single event with the specified event name,
+// which overrides any name configured with SSEEvent. It returns the number of
+// bytes written to the underlying io.WriteCloser.
+func (sw *SSEWriter) WriteEvent(event string, data []byte) (int, error) {
+	if strings.ContainsAny(event, "\r\n") {
+		return 0, fmt.Errorf("event name must not contain newline characters: %q", event)
+	}
+
+	sw.lock.Lock()
+	defer sw.lock.Unlock()
+
+	if sw.halted {
+		return 0, ErrWriteAfterClose{}
+	}
+
+	var bb bytes.Buffer
+	if event != "" {
+		bb.WriteString("event: ")
+		bb.WriteString(event)
+		bb.WriteByte('\n')
+	}
+	if sw.autoID {
+		sw.id++
+		bb.WriteString("id: ")
+		bb.WriteString(strconv.FormatUint(sw.id, 10))
+		bb.WriteByte('\n')
+	}
+	if len(data) > 0 && data[len(data)-1] == '\n' {
+		data = data[:len(data)-1]
+	}
+	for {
+		line := data
+		i := bytes.IndexByte(data, '\n')
+		if i >= 0 {
+			line, data = data[:i], data[i+1:]
+		}
+		bb.WriteString("data: ")
+		bb.Write(bytes.TrimSuffix(line, []byte{'\r'}))
+		bb.WriteByte('\n')
+		if i < 0 {
+			break
+		}
+	}
+	bb.WriteByte('\n')
+
+	sw.written = true
+	return sw.iowc.Write(bb.Bytes())
+}
+
+// Unwrap returns the underlying io.WriteCloser.
+func (sw *SSEWriter) Unwrap() io.WriteCloser { return sw.iowc }
+
+// Close stops writing heartbeats, and closes the underlying io.WriteCloser.
+func (sw *SSEWriter) Close() error {
+	sw.lock.Lock()
+	if sw.halted {
+		sw.lock.Unlock()
+		return nil
+	}
+	sw.halted = true
+	sw.lock.Unlock()
+
+	if sw.stop != nil {
+		close(sw.stop)
+		sw.stopped.Wait()
+	}
+	return sw.iowc.Close()
+}
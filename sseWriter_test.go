@@ -0,0 +1,67 @@
+package gorill
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSSEWriter(t *testing.T) {
+	t.Run("invalid event name", func(t *testing.T) {
+		_, err := NewSSEWriter(NewNopCloseBuffer(), SSEEvent("a\nb"))
+		ensureError(t, err, "event name must not contain newline characters")
+	})
+
+	t.Run("frames writes", func(t *testing.T) {
+		bb := NewNopCloseBuffer()
+		sw, err := NewSSEWriter(bb)
+		ensureError(t, err)
+
+		n, err := sw.Write([]byte("one\n"))
+		ensureError(t, err)
+		if got, want := n, 4; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		_, err = sw.Write([]byte("two\r\nthree"))
+		ensureError(t, err)
+		_, err = sw.Write(nil)
+		ensureError(t, err)
+
+		want := "data: one\n\ndata: two\ndata: three\n\ndata: \n\n"
+		if got := bb.String(); got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+		ensureError(t, sw.Close())
+		if !bb.IsClosed() {
+			t.Errorf("GOT: %v; WANT: %v", false, true)
+		}
+		_, err = sw.Write([]byte("four"))
+		testErrorType(t, err, ErrWriteAfterClose{})
+	})
+
+	t.Run("event and id", func(t *testing.T) {
+		bb := NewNopCloseBuffer()
+		sw, err := NewSSEWriter(bb, SSEEvent("log"), SSEAutoID())
+		ensureError(t, err)
+		_, err = sw.Write([]byte("one"))
+		ensureError(t, err)
+		_, err = sw.WriteEvent("status", []byte("two"))
+		ensureError(t, err)
+
+		want := "event: log\nid: 1\ndata: one\n\nevent: status\nid: 2\ndata: two\n\n"
+		if got := bb.String(); got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("heartbeat", func(t *testing.T) {
+		bb := NewNopCloseBuffer()
+		sw, err := NewSSEWriter(bb, SSEHeartbeat(time.Millisecond))
+		ensureError(t, err)
+		time.Sleep(20 * time.Millisecond)
+		ensureError(t, sw.Close())
+		if got := bb.String(); !strings.HasPrefix(got, ":\n\n") {
+			t.Errorf("GOT: %q; WANT: heartbeat comments", got)
+		}
+	})
+}
@@ -0,0 +1,287 @@
+package gorill
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// WriteLayer wraps an io.WriteCloser in another layer of a WriteStack, such as
+// a function that returns a SpooledWriteCloser or a gzip.Writer.
+type WriteLayer func(io.WriteCloser) (io.WriteCloser, error)
+
+// ReadLayer wraps an io.ReadCloser in another layer of a ReadStack, such as a
+// function that returns a LinesReader or a gzip.Reader.
+type ReadLayer func(io.ReadCloser) (io.ReadCloser, error)
+
+// StackSetter is any function that modifies a WriteStack or ReadStack being
+// instantiated.
+type StackSetter func(*stackConfig) error
+
+// stackConfig holds the configuration shared by WriteStack and ReadStack.
+type stackConfig struct {
+	clock   Clock
+	timeout time.Duration
+}
+
+// StackClock is used to configure the Clock that times the close timeout of a
+// stack. It is intended for tests, which may provide a FakeClock.
+func StackClock(clock Clock) StackSetter {
+	return func(c *stackConfig) error {
+		if clock == nil {
+			return fmt.Errorf("clock must not be nil")
+		}
+		c.clock = clock
+		return nil
+	}
+}
+
+// StackCloseTimeout is used to configure the maximum duration Close waits for
+// each layer of a stack to flush and close. When a layer exceeds it, Close
+// records ErrTimeout for that layer and proceeds to close the next inner
+// layer, which often releases a layer blocked writing to it.
+func StackCloseTimeout(timeout time.Duration) StackSetter {
+	return func(c *stackConfig) error {
+		if timeout <= 0 {
+			return fmt.Errorf("close timeout must be greater than 0: %s", timeout)
+		}
+		c.timeout = timeout
+		return nil
+	}
+}
+
+// stackLayer guards one layer of a stack, so that it is flushed and closed at
+// most once, whether by the stack or by the layer wrapped around it.
+type stackLayer struct {
+	closed bool
+	closer io.Closer
+	lock   sync.Mutex
+}
+
+// Close flushes and closes the layer, unless it has already been closed.
+func (l *stackLayer) Close() error {
+	_, err := l.shut()
+	return err
+}
+
+// String returns the type of the layer, which identifies it in errors.
+func (l *stackLayer) String() string {
+	return fmt.Sprintf("%T", l.closer)
+}
+
+// shut flushes and closes the layer, returning false when it has already been
+// closed.
+func (l *stackLayer) shut() (bool, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.closed {
+		return false, nil
+	}
+	l.closed = true
+
+	var errors ErrList
+	if f, ok := l.closer.(interface{ Flush() error }); ok {
+		errors.Append(f.Flush())
+	}
+	errors.Append(l.closer.Close())
+	return true, errors.Err()
+}
+
+// closeStack closes layers from the last, which is outermost, to the first,
+// and returns the errors from each layer it closed.
+func closeStack(layers []*stackLayer, c *stackConfig) error {
+	type result struct {
+		ran bool
+		err error
+	}
+	var errors ErrList
+	for i := len(layers) - 1; i >= 0; i-- {
+		l := layers[i]
+		var r result
+		if c.timeout <= 0 {
+			r.ran, r.err = l.shut()
+		} else {
+			results := make(chan result, 1)
+			go func() {
+				ran, err := l.shut()
+				results <- result{ran, err}
+			}()
+			timer := c.clock.NewTimer(c.timeout)
+			select {
+			case r = <-results:
+			case <-timer.C():
+				r = result{true, ErrTimeout(c.timeout)}
+			}
+			timer.Stop()
+		}
+		if r.ran {
+			errors.AppendMember(len(layers)-1-i, l, r.err)
+		}
+	}
+	return errors.Err()
+}
+
+func newStackConfig(setters []StackSetter) (*stackConfig, error) {
+	c := &stackConfig{clock: systemClock{}}
+	for _, setter := range setters {
+		if err := setter(c); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// stackWriter is the io.WriteCloser passed to each WriteLayer.
+type stackWriter struct {
+	stackLayer
+	iowc io.WriteCloser
+}
+
+func (w *stackWriter) Write(data []byte) (int, error) { return w.iowc.Write(data) }
+
+// ReadFrom preserves any zero-copy optimization of the wrapped layer.
+func (w *stackWriter) ReadFrom(r io.Reader) (int64, error) { return Transfer(w.iowc, r) }
+
+// SetWriteDeadline sets the write deadline of the wrapped layer.
+func (w *stackWriter) SetWriteDeadline(t time.Time) error { return SetWriteDeadline(w.iowc, t) }
+
+// Unwrap returns the wrapped layer.
+func (w *stackWriter) Unwrap() io.WriteCloser { return w.iowc }
+
+// WriteStack is an io.WriteCloser composed of layers of writers, whose Close
+// flushes and closes each layer in turn, from the outermost to the innermost,
+// and aggregates their errors. It replaces hand-written chains of deferred
+// Close calls, which are easy to get in the wrong order.
+type WriteStack struct {
+	config *stackConfig
+	layers []*stackLayer
+	top    io.WriteCloser
+}
+
+// NewWriteStack returns a WriteStack that applies the layers to iowc in order,
+// so the last layer is outermost, and receives the data written to the stack.
+// When a layer returns an error, the layers already created are closed.
+//
+//   ws, err := gorill.NewWriteStack(conn,
+//       []gorill.WriteLayer{
+//           func(iowc io.WriteCloser) (io.WriteCloser, error) {
+//               return gorill.NewLockingWriteCloser(iowc), nil
+//           },
+//           func(iowc io.WriteCloser) (io.WriteCloser, error) {
+//               return gorill.NewSpooledWriteCloser(iowc)
+//           },
+//       },
+//       gorill.StackCloseTimeout(5*time.Second))
+//   if err != nil {
+//       return err
+//   }
+//   defer ws.Close() // closes the spool, then the lock, then conn
+func NewWriteStack(iowc io.WriteCloser, layers []WriteLayer, setters ...StackSetter) (*WriteStack, error) {
+	c, err := newStackConfig(setters)
+	if err != nil {
+		return nil, err
+	}
+	ws := &WriteStack{config: c}
+	sw := &stackWriter{stackLayer: stackLayer{closer: iowc}, iowc: iowc}
+	ws.layers = append(ws.layers, &sw.stackLayer)
+	for i, layer := range layers {
+		next, err := layer(sw)
+		if err != nil {
+			_ = closeStack(ws.layers, c)
+			return nil, fmt.Errorf("cannot create write layer %d: %s", i, err)
+		}
+		sw = &stackWriter{stackLayer: stackLayer{closer: next}, iowc: next}
+		ws.layers = append(ws.layers, &sw.stackLayer)
+	}
+	ws.top = sw
+	return ws, nil
+}
+
+// Write writes data to the outermost layer.
+func (ws *WriteStack) Write(data []byte) (int, error) { return ws.top.Write(data) }
+
+// ReadFrom reads from r into the outermost layer, preserving any zero-copy
+// optimization it supports.
+func (ws *WriteStack) ReadFrom(r io.Reader) (int64, error) { return Transfer(ws.top, r) }
+
+// SetWriteDeadline sets the write deadline of the innermost layer that
+// supports one.
+func (ws *WriteStack) SetWriteDeadline(t time.Time) error { return SetWriteDeadline(ws.top, t) }
+
+// Unwrap returns the outermost layer.
+func (ws *WriteStack) Unwrap() io.WriteCloser { return ws.top }
+
+// Close flushes and closes each layer from the outermost to the innermost,
+// skipping layers already closed by the layer wrapped around them. It returns
+// an ErrList identifying each layer that failed to close, where the outermost
+// layer has index 0.
+func (ws *WriteStack) Close() error { return closeStack(ws.layers, ws.config) }
+
+// stackReader is the io.ReadCloser passed to each ReadLayer.
+type stackReader struct {
+	stackLayer
+	iorc io.ReadCloser
+}
+
+func (r *stackReader) Read(p []byte) (int, error) { return r.iorc.Read(p) }
+
+// Unwrap returns the wrapped layer.
+func (r *stackReader) Unwrap() io.ReadCloser { return r.iorc }
+
+// ReadStack is an io.ReadCloser composed of layers of readers, whose Close
+// closes each layer in turn, from the outermost to the innermost, and
+// aggregates their errors.
+type ReadStack struct {
+	config *stackConfig
+	layers []*stackLayer
+	top    io.ReadCloser
+}
+
+// NewReadStack returns a ReadStack that applies the layers to iorc in order, so
+// the last layer is outermost, and provides the data read from the stack. When
+// a layer returns an error, the layers already created are closed.
+//
+//   rs, err := gorill.NewReadStack(fh,
+//       []gorill.ReadLayer{
+//           func(iorc io.ReadCloser) (io.ReadCloser, error) {
+//               return gzip.NewReader(iorc)
+//           },
+//       })
+//   if err != nil {
+//       return err
+//   }
+//   defer rs.Close() // closes the gzip.Reader, then fh
+func NewReadStack(iorc io.ReadCloser, layers []ReadLayer, setters ...StackSetter) (*ReadStack, error) {
+	c, err := newStackConfig(setters)
+	if err != nil {
+		return nil, err
+	}
+	rs := &ReadStack{config: c}
+	sr := &stackReader{stackLayer: stackLayer{closer: iorc}, iorc: iorc}
+	rs.layers = append(rs.layers, &sr.stackLayer)
+	for i, layer := range layers {
+		next, err := layer(sr)
+		if err != nil {
+			_ = closeStack(rs.layers, c)
+			return nil, fmt.Errorf("cannot create read layer %d: %s", i, err)
+		}
+		sr = &stackReader{stackLayer: stackLayer{closer: next}, iorc: next}
+		rs.layers = append(rs.layers, &sr.stackLayer)
+	}
+	rs.top = sr
+	return rs, nil
+}
+
+// Read reads from the outermost layer.
+func (rs *ReadStack) Read(p []byte) (int, error) { return rs.top.Read(p) }
+
+// Unwrap returns the outermost layer.
+func (rs *ReadStack) Unwrap() io.ReadCloser { return rs.top }
+
+// Close closes each layer from the outermost to the innermost, skipping layers
+// already closed by the layer wrapped around them. It returns an ErrList
+// identifying each layer that failed to close, where the outermost layer has
+// index 0.
+func (rs *ReadStack) Close() error { return closeStack(rs.layers, rs.config) }
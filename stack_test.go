@@ -0,0 +1,218 @@
+package gorill
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+// closeLogger is a layer that records its Close in a shared log, and closes
+// the layer it wraps only when propagate is true.
+type closeLogger struct {
+	io.WriteCloser
+	err       error
+	log       *[]string
+	name      string
+	propagate bool
+}
+
+func (c *closeLogger) Close() error {
+	*c.log = append(*c.log, c.name)
+	if c.propagate {
+		if err := c.WriteCloser.Close(); err != nil {
+			return err
+		}
+	}
+	return c.err
+}
+
+func closeLoggerLayer(log *[]string, name string, propagate bool, err error) WriteLayer {
+	return func(iowc io.WriteCloser) (io.WriteCloser, error) {
+		return &closeLogger{WriteCloser: iowc, err: err, log: log, name: name, propagate: propagate}, nil
+	}
+}
+
+func TestWriteStackInvalidSetter(t *testing.T) {
+	_, err := NewWriteStack(NewNopCloseBuffer(), nil, StackCloseTimeout(0))
+	ensureError(t, err, "close timeout must be greater than 0")
+}
+
+func TestWriteStackCloseOrder(t *testing.T) {
+	var log []string
+	bb := NewNopCloseBuffer()
+	ws, err := NewWriteStack(bb, []WriteLayer{
+		closeLoggerLayer(&log, "inner", false, nil),
+		closeLoggerLayer(&log, "middle", true, nil),
+		closeLoggerLayer(&log, "outer", false, nil),
+	})
+	ensureError(t, err)
+
+	_, err = ws.Write([]byte(alphabet))
+	ensureError(t, err)
+	ensureError(t, ws.Close())
+
+	// Middle closes inner itself, and the stack does not close inner again.
+	if got, want := len(log), 3; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	for i, want := range []string{"outer", "middle", "inner"} {
+		if got := log[i]; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	}
+	if got, want := bb.String(), alphabet; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	if got, want := bb.IsClosed(), true; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	// Closing the stack again does nothing.
+	ensureError(t, ws.Close())
+	if got, want := len(log), 3; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
+
+func TestWriteStackFlushesLayers(t *testing.T) {
+	bb := NewNopCloseBuffer()
+	ws, err := NewWriteStack(bb, []WriteLayer{
+		func(iowc io.WriteCloser) (io.WriteCloser, error) {
+			return NewFlushingWriter(iowc) // does not close iowc
+		},
+		func(iowc io.WriteCloser) (io.WriteCloser, error) {
+			return NewSpooledWriteCloser(iowc)
+		},
+	})
+	ensureError(t, err)
+
+	_, err = ws.Write([]byte(alphabet))
+	ensureError(t, err)
+	ensureError(t, ws.Close())
+	if got, want := bb.String(), alphabet; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	if got, want := bb.IsClosed(), true; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
+
+func TestWriteStackAggregatesErrors(t *testing.T) {
+	var log []string
+	bb := NewNopCloseBuffer()
+	ws, err := NewWriteStack(bb, []WriteLayer{
+		closeLoggerLayer(&log, "inner", false, errors.New("inner failed")),
+		closeLoggerLayer(&log, "outer", false, errors.New("outer failed")),
+	})
+	ensureError(t, err)
+
+	err = ws.Close()
+	ensureError(t, err, "member 0 (*gorill.closeLogger): outer failed", "member 1 (*gorill.closeLogger): inner failed")
+	if got, want := bb.IsClosed(), true; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
+
+func TestWriteStackCloseTimeout(t *testing.T) {
+	stuck := &gatedWriteCloser{started: make(chan struct{}, 1), gate: make(chan struct{})}
+	defer close(stuck.gate)
+
+	bb := NewNopCloseBuffer()
+	ws, err := NewWriteStack(bb, []WriteLayer{
+		func(io.WriteCloser) (io.WriteCloser, error) { return stuckCloser{stuck}, nil },
+	}, StackCloseTimeout(10*time.Millisecond))
+	ensureError(t, err)
+
+	ensureError(t, ws.Close(), "timeout after 10ms")
+	if got, want := bb.IsClosed(), true; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
+
+func TestWriteStackClock(t *testing.T) {
+	t.Run("nil clock", func(t *testing.T) {
+		_, err := NewWriteStack(NewNopCloseBuffer(), nil, StackClock(nil))
+		ensureError(t, err, "clock must not be nil")
+	})
+
+	t.Run("close timeout", func(t *testing.T) {
+		stuck := &gatedWriteCloser{started: make(chan struct{}, 1), gate: make(chan struct{})}
+		defer close(stuck.gate)
+
+		fc := NewFakeClock(time.Now())
+		bb := NewNopCloseBuffer()
+		ws, err := NewWriteStack(bb, []WriteLayer{
+			func(io.WriteCloser) (io.WriteCloser, error) { return stuckCloser{stuck}, nil },
+		}, StackCloseTimeout(time.Hour), StackClock(fc))
+		ensureError(t, err)
+
+		errs := make(chan error, 1)
+		go func() { errs <- ws.Close() }()
+
+		fc.BlockUntil(1)
+		fc.Advance(time.Hour)
+		ensureError(t, <-errs, "timeout after 1h0m0s")
+		if got, want := bb.IsClosed(), true; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
+
+// stuckCloser is an io.WriteCloser whose Close blocks until its gate is
+// closed.
+type stuckCloser struct{ *gatedWriteCloser }
+
+func (s stuckCloser) Close() error {
+	<-s.gate
+	return nil
+}
+
+func TestWriteStackLayerError(t *testing.T) {
+	bb := NewNopCloseBuffer()
+	_, err := NewWriteStack(bb, []WriteLayer{
+		func(io.WriteCloser) (io.WriteCloser, error) { return nil, errors.New("boom") },
+	})
+	ensureError(t, err, "cannot create write layer 0: boom")
+	if got, want := bb.IsClosed(), true; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
+
+func TestReadStack(t *testing.T) {
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, err := gz.Write([]byte(alphabet))
+	ensureError(t, err)
+	ensureError(t, gz.Close())
+
+	source := &testReadCloser{Reader: &compressed}
+	rs, err := NewReadStack(source, []ReadLayer{
+		func(iorc io.ReadCloser) (io.ReadCloser, error) { return gzip.NewReader(iorc) },
+	})
+	ensureError(t, err)
+
+	buf, err := ioutil.ReadAll(rs)
+	ensureError(t, err)
+	if got, want := string(buf), alphabet; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	ensureError(t, rs.Close())
+	if got, want := source.closed, true; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
+
+// testReadCloser is an io.ReadCloser that records whether it was closed.
+type testReadCloser struct {
+	io.Reader
+	closed bool
+}
+
+func (r *testReadCloser) Close() error {
+	r.closed = true
+	return nil
+}
@@ -0,0 +1,53 @@
+package gorill
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// StatsReporter is implemented by wrappers that report statistics about the
+// data that passed through them, such as SpooledWriteCloser,
+// TimedWriteCloser, MultiWriteCloserFanOut, and CountingStatsReader. The
+// returned map is a snapshot, keyed by statistic name, which the caller may
+// modify. The metrics subpackage publishes the statistics of registered
+// reporters via expvar and the Prometheus text exposition format.
+type StatsReporter interface {
+	Stats() map[string]int64
+}
+
+// WriterStats holds the statistics of one writer of a MultiWriteCloserFanOut or
+// an AsyncMultiWriteCloser, such as for reporting the health of each
+// destination.
+type WriterStats struct {
+	Writer      io.WriteCloser
+	Bytes       int64         // Bytes is the number of bytes written
+	Errors      int64         // Errors is the number of writes that returned an error
+	LastErr     error         // LastErr is the error returned by the most recent failed write
+	LastLatency time.Duration // LastLatency is the duration of the most recent write
+}
+
+// writerCounters accumulates the WriterStats of one writer.
+type writerCounters struct {
+	lock  sync.Mutex
+	stats WriterStats
+}
+
+// record updates the counters with the outcome of a write.
+func (wc *writerCounters) record(n int, err error, latency time.Duration) {
+	wc.lock.Lock()
+	wc.stats.Bytes += int64(n)
+	if err != nil {
+		wc.stats.Errors++
+		wc.stats.LastErr = err
+	}
+	wc.stats.LastLatency = latency
+	wc.lock.Unlock()
+}
+
+// snapshot returns a copy of the counters.
+func (wc *writerCounters) snapshot() WriterStats {
+	wc.lock.Lock()
+	defer wc.lock.Unlock()
+	return wc.stats
+}
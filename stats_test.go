@@ -0,0 +1,58 @@
+package gorill
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// Ensure each wrapper that reports statistics satisfies StatsReporter.
+var (
+	_ StatsReporter = &CountingStatsReader{}
+	_ StatsReporter = &MultiWriteCloserFanOut{}
+	_ StatsReporter = &SpooledWriteCloser{}
+	_ StatsReporter = &TimedWriteCloser{}
+)
+
+func ensureStats(tb testing.TB, got map[string]int64, want map[string]int64) {
+	tb.Helper()
+	for k, v := range want {
+		if got[k] != v {
+			tb.Errorf("%s: GOT: %v; WANT: %v", k, got[k], v)
+		}
+	}
+}
+
+func TestStats(t *testing.T) {
+	t.Run("spooled", func(t *testing.T) {
+		w, err := NewSpooledWriteCloser(NewNopCloseBuffer(), BufSize(64), Flush(time.Hour))
+		ensureError(t, err)
+		_, err = w.Write([]byte(alphabet))
+		ensureError(t, err)
+		ensureError(t, w.Flush())
+		ensureError(t, w.Close())
+		ensureStats(t, w.Stats(), map[string]int64{"buffer_size": 64, "bytes": 27, "flushes": 2, "writes": 1})
+	})
+
+	t.Run("timed", func(t *testing.T) {
+		w := NewTimedWriteCloser(NewNopCloseBuffer(), time.Second)
+		_, err := w.Write([]byte(alphabet))
+		ensureError(t, err)
+		ensureError(t, w.Close())
+		ensureStats(t, w.Stats(), map[string]int64{"bytes": 27, "timeouts": 0, "writes": 1})
+	})
+
+	t.Run("fan out", func(t *testing.T) {
+		w := NewMultiWriteCloserFanOut(NewNopCloseBuffer(), &testWriteCloser{})
+		_, err := w.Write([]byte(alphabet))
+		ensureError(t, err)
+		ensureStats(t, w.Stats(), map[string]int64{"bytes": 27, "evicted": 1, "writers": 1, "writes": 1})
+	})
+
+	t.Run("counting", func(t *testing.T) {
+		r := NewCountingStatsReader(strings.NewReader(alphabet))
+		_, err := r.Read(make([]byte, 10))
+		ensureError(t, err)
+		ensureStats(t, r.Stats(), map[string]int64{"bytes": 10})
+	})
+}
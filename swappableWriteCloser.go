@@ -0,0 +1,91 @@
+package gorill
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// SwappableWriteCloser is an io.WriteCloser whose underlying io.WriteCloser
+// may be replaced while it is in use, so a long-lived stack of writers may be
+// redirected, for instance from a local fallback file to a restored network
+// sink, without tearing the stack down.
+type SwappableWriteCloser struct {
+	halted   bool
+	iowc     io.WriteCloser
+	lock     sync.RWMutex
+	sinkLock sync.Mutex // sinkLock also guards iowc, for methods that must not wait for writes
+}
+
+// NewSwappableWriteCloser returns a SwappableWriteCloser that initially writes
+// to iowc.
+//
+//   sw := gorill.NewSwappableWriteCloser(fallback)
+//   spool, err := gorill.NewSpooledWriteCloser(sw)
+//   if err != nil {
+//       return err
+//   }
+//   // later, once the network is restored:
+//   if err = sw.Swap(conn).Close(); err != nil {
+//       log.Printf("cannot close fallback: %s", err)
+//   }
+func NewSwappableWriteCloser(iowc io.WriteCloser) *SwappableWriteCloser {
+	return &SwappableWriteCloser{iowc: iowc}
+}
+
+// Write writes data to the current underlying io.WriteCloser.
+func (sw *SwappableWriteCloser) Write(data []byte) (int, error) {
+	sw.lock.RLock()
+	defer sw.lock.RUnlock()
+
+	if sw.halted {
+		return 0, ErrWriteAfterClose{}
+	}
+	return sw.iowc.Write(data)
+}
+
+// Swap waits for writes in progress to complete, then replaces the underlying
+// io.WriteCloser with iowc, and returns the one it replaced, which the caller
+// is responsible for closing. After Close, Swap does not install iowc, and
+// returns it instead.
+func (sw *SwappableWriteCloser) Swap(iowc io.WriteCloser) io.WriteCloser {
+	sw.lock.Lock()
+	defer sw.lock.Unlock()
+
+	if sw.halted {
+		return iowc
+	}
+	sw.sinkLock.Lock()
+	old := sw.iowc
+	sw.iowc = iowc
+	sw.sinkLock.Unlock()
+	return old
+}
+
+// SetWriteDeadline sets the write deadline of the current underlying
+// io.WriteCloser. Like LockingWriteCloser, it does not wait for writes in
+// progress, or for a Swap waiting on them, so that a deadline may interrupt a
+// Write that is blocked.
+func (sw *SwappableWriteCloser) SetWriteDeadline(t time.Time) error {
+	return SetWriteDeadline(sw.Unwrap(), t)
+}
+
+// Unwrap returns the current underlying io.WriteCloser.
+func (sw *SwappableWriteCloser) Unwrap() io.WriteCloser {
+	sw.sinkLock.Lock()
+	defer sw.sinkLock.Unlock()
+	return sw.iowc
+}
+
+// Close closes the current underlying io.WriteCloser. Subsequent writes return
+// ErrWriteAfterClose.
+func (sw *SwappableWriteCloser) Close() error {
+	sw.lock.Lock()
+	defer sw.lock.Unlock()
+
+	if sw.halted {
+		return nil
+	}
+	sw.halted = true
+	return sw.iowc.Close()
+}
@@ -0,0 +1,139 @@
+package gorill
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSwappableWriteCloserSwap(t *testing.T) {
+	first, second := NewNopCloseBuffer(), NewNopCloseBuffer()
+	sw := NewSwappableWriteCloser(first)
+
+	_, err := sw.Write([]byte("one"))
+	ensureError(t, err)
+
+	if got, want := sw.Swap(second), first; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	_, err = sw.Write([]byte("two"))
+	ensureError(t, err)
+
+	if got, want := first.String(), "one"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	if got, want := second.String(), "two"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	if got, want := Unwrap(sw), interface{}(second); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	ensureError(t, sw.Close())
+	if got, want := first.IsClosed(), false; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := second.IsClosed(), true; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	_, err = sw.Write([]byte("three"))
+	testErrorType(t, err, ErrWriteAfterClose{})
+
+	third := NewNopCloseBuffer()
+	if got, want := sw.Swap(third), third; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
+
+func TestSwappableWriteCloserWaitsForWrites(t *testing.T) {
+	stuck := &gatedWriteCloser{started: make(chan struct{}, 1), gate: make(chan struct{})}
+	sw := NewSwappableWriteCloser(stuck)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, err := sw.Write([]byte(alphabet))
+		ensureError(t, err)
+	}()
+	<-stuck.started
+
+	swapped := make(chan struct{})
+	go func() {
+		sw.Swap(NewNopCloseBuffer())
+		close(swapped)
+	}()
+	select {
+	case <-swapped:
+		t.Fatal("GOT: swap during write; WANT: swap after write")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	close(stuck.gate)
+	<-swapped
+	wg.Wait()
+}
+
+func TestSwappableWriteCloserConcurrent(t *testing.T) {
+	sinks := []*LockingWriteCloser{
+		NewLockingWriteCloser(NewNopCloseBuffer()),
+		NewLockingWriteCloser(NewNopCloseBuffer()),
+	}
+	sw := NewSwappableWriteCloser(sinks[0])
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_, err := sw.Write([]byte("x"))
+				ensureError(t, err)
+			}
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		sw.Swap(sinks[(i+1)%2])
+	}
+	wg.Wait()
+
+	var total int
+	for _, s := range sinks {
+		total += len(s.Unwrap().(*NopCloseBuffer).String())
+	}
+	if got, want := total, 1000; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
+
+func TestSwappableWriteCloserDeadlineInterruptsBlockedWrite(t *testing.T) {
+	local, remote, err := NewBufferedPipe(1)
+	ensureError(t, err)
+	defer remote.Close()
+	sw := NewSwappableWriteCloser(local)
+
+	written := make(chan error, 1)
+	go func() {
+		_, err := sw.Write([]byte(alphabet)) // blocks once the pipe is full
+		written <- err
+	}()
+	swapped := make(chan struct{})
+	go func() {
+		time.Sleep(10 * time.Millisecond) // let the write block first
+		sw.Swap(NewNopCloseBuffer())
+		close(swapped)
+	}()
+	time.Sleep(20 * time.Millisecond) // let the swap wait for the write
+
+	deadlined := make(chan error, 1)
+	go func() { deadlined <- sw.SetWriteDeadline(time.Now()) }()
+	select {
+	case err = <-deadlined:
+		ensureError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("GOT: SetWriteDeadline blocked behind Write and Swap; WANT: return")
+	}
+	testErrorType(t, <-written, ErrDeadlineExceeded{})
+	<-swapped
+}
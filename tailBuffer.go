@@ -0,0 +1,62 @@
+package gorill
+
+import (
+	"bytes"
+	"sync"
+)
+
+// tailBuffer retains the most recent bytes written to it, bounded either by a
+// number of bytes, or by a number of complete lines plus any partial final
+// line.
+type tailBuffer struct {
+	buf      []byte
+	lock     sync.Mutex
+	maxBytes int
+	maxLines int
+}
+
+func (tb *tailBuffer) Write(data []byte) {
+	tb.lock.Lock()
+	defer tb.lock.Unlock()
+
+	tb.buf = append(tb.buf, data...)
+
+	start := 0
+	if tb.maxBytes > 0 && len(tb.buf) > tb.maxBytes {
+		start = len(tb.buf) - tb.maxBytes
+	}
+	if tb.maxLines > 0 {
+		// Walk backwards past the partial final line, if any, then past
+		// maxLines complete lines.
+		end := len(tb.buf)
+		if i := bytes.LastIndexByte(tb.buf, '\n'); i < len(tb.buf)-1 {
+			end = i + 1
+		}
+		for lines := 0; end > 0; lines++ {
+			i := bytes.LastIndexByte(tb.buf[:end-1], '\n')
+			if lines == tb.maxLines-1 {
+				if i+1 > start {
+					start = i + 1
+				}
+				break
+			}
+			end = i + 1
+		}
+	}
+	if start > 0 {
+		if start > len(tb.buf)/2 {
+			// Copy the retained bytes so the discarded ones may be reclaimed.
+			tb.buf = append([]byte(nil), tb.buf[start:]...)
+		} else {
+			tb.buf = tb.buf[start:]
+		}
+	}
+}
+
+// Bytes returns a copy of the retained bytes.
+func (tb *tailBuffer) Bytes() []byte {
+	tb.lock.Lock()
+	defer tb.lock.Unlock()
+
+	return append([]byte(nil), tb.buf...)
+}
@@ -0,0 +1,27 @@
+package gorill
+
+import "testing"
+
+func TestTailBuffer(t *testing.T) {
+	tests := []struct {
+		maxBytes, maxLines int
+		input, want        string
+	}{
+		{0, 2, "a\nb\nc\npar", "b\nc\npar"},
+		{0, 2, "a\nb\n", "a\nb\n"},
+		{0, 1, "a\nb\n", "b\n"},
+		{0, 3, "partial", "partial"},
+		{4, 0, "abcdefg", "defg"},
+		{3, 2, "a\nb\nc\n", "\nc\n"},
+		{10, 1, "a\nb\nc\n", "c\n"},
+	}
+	for _, test := range tests {
+		tb := &tailBuffer{maxBytes: test.maxBytes, maxLines: test.maxLines}
+		for i := 0; i < len(test.input); i++ {
+			tb.Write([]byte(test.input[i : i+1]))
+		}
+		if got := string(tb.Bytes()); got != test.want {
+			t.Errorf("%d bytes, %d lines: GOT: %q; WANT: %q", test.maxBytes, test.maxLines, got, test.want)
+		}
+	}
+}
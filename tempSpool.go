@@ -0,0 +1,195 @@
+package gorill
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// DefaultTempSpoolThreshold is the default number of bytes a TempSpool holds in
+// memory before it moves its contents to a temporary file.
+const DefaultTempSpoolThreshold = 1 << 20
+
+// TempSpool is an io.WriteCloser that accumulates the bytes written to it in
+// memory until their size exceeds a threshold, after which it moves the
+// accumulated bytes to an automatically created temporary file, and writes all
+// subsequent bytes to that file. The accumulated contents may be read back
+// using its ReadAt method or a reader returned by its Reader method. The
+// temporary file, if any, is removed when the TempSpool is closed.
+type TempSpool struct {
+	dir       string
+	fh        *os.File
+	halted    bool
+	lock      sync.RWMutex
+	mem       []byte
+	size      int64
+	threshold int
+}
+
+// TempSpoolSetter is any function that modifies a TempSpool being
+// instantiated.
+type TempSpoolSetter func(*TempSpool) error
+
+// TempSpoolThreshold is used to configure the maximum number of bytes a new
+// TempSpool holds in memory.
+func TempSpoolThreshold(size int) TempSpoolSetter {
+	return func(ts *TempSpool) error {
+		if size < 0 {
+			return fmt.Errorf("threshold must be greater than or equal to 0: %d", size)
+		}
+		ts.threshold = size
+		return nil
+	}
+}
+
+// TempSpoolDir is used to configure the directory in which a new TempSpool
+// creates its temporary file. By default the directory returned by
+// os.TempDir is used.
+func TempSpoolDir(dir string) TempSpoolSetter {
+	return func(ts *TempSpool) error {
+		ts.dir = dir
+		return nil
+	}
+}
+
+// NewTempSpool returns a TempSpool.
+//
+//   ts, err := gorill.NewTempSpool(gorill.TempSpoolThreshold(64 * 1024))
+//   if err != nil {
+//       return err
+//   }
+//   defer ts.Close() // removes temporary file
+//   if _, err = io.Copy(ts, r.Body); err != nil {
+//       return err
+//   }
+//   rc := ts.Reader()
+func NewTempSpool(setters ...TempSpoolSetter) (*TempSpool, error) {
+	ts := &TempSpool{threshold: DefaultTempSpoolThreshold}
+	for _, setter := range setters {
+		if err := setter(ts); err != nil {
+			return nil, err
+		}
+	}
+	return ts, nil
+}
+
+// Write appends data to the TempSpool, moving the contents to a temporary file
+// when the total size would exceed the threshold.
+func (ts *TempSpool) Write(data []byte) (int, error) {
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+
+	if ts.halted {
+		return 0, ErrWriteAfterClose{}
+	}
+
+	if ts.fh == nil {
+		if len(ts.mem)+len(data) <= ts.threshold {
+			ts.mem = append(ts.mem, data...)
+			ts.size += int64(len(data))
+			return len(data), nil
+		}
+		if err := ts.spill(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := ts.fh.WriteAt(data, ts.size)
+	ts.size += int64(n)
+	return n, err
+}
+
+// spill moves the bytes accumulated in memory to a new temporary file.
+func (ts *TempSpool) spill() error {
+	fh, err := ioutil.TempFile(ts.dir, "gorill-spool-")
+	if err != nil {
+		return err
+	}
+	if _, err = fh.Write(ts.mem); err != nil {
+		_ = fh.Close()
+		_ = os.Remove(fh.Name())
+		return err
+	}
+	ts.fh = fh
+	ts.mem = nil
+	return nil
+}
+
+// IsSpilled returns true when the TempSpool has moved its contents to a
+// temporary file.
+func (ts *TempSpool) IsSpilled() bool {
+	ts.lock.RLock()
+	defer ts.lock.RUnlock()
+
+	return ts.fh != nil
+}
+
+// Size returns the number of bytes written to the TempSpool.
+func (ts *TempSpool) Size() int64 {
+	ts.lock.RLock()
+	defer ts.lock.RUnlock()
+
+	return ts.size
+}
+
+// ReadAt reads len(p) bytes from the accumulated contents starting at offset
+// off.
+func (ts *TempSpool) ReadAt(p []byte, off int64) (int, error) {
+	ts.lock.RLock()
+	defer ts.lock.RUnlock()
+
+	if ts.halted {
+		return 0, ErrReadAfterClose{}
+	}
+	if off < 0 {
+		return 0, fmt.Errorf("cannot read at negative offset: %d", off)
+	}
+	if off >= ts.size {
+		return 0, io.EOF
+	}
+	if ts.fh != nil {
+		if max := ts.size - off; int64(len(p)) > max {
+			// Do not read bytes written to file after this call began.
+			n, err := ts.fh.ReadAt(p[:max], off)
+			if err == nil {
+				err = io.EOF
+			}
+			return n, err
+		}
+		return ts.fh.ReadAt(p, off)
+	}
+	n := copy(p, ts.mem[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Reader returns an io.ReadCloser that reads the contents accumulated in the
+// TempSpool at the time Reader is called. Closing the returned io.ReadCloser
+// does not close the TempSpool.
+func (ts *TempSpool) Reader() io.ReadCloser {
+	return NopCloseReader(io.NewSectionReader(ts, 0, ts.Size()))
+}
+
+// Close releases the memory used by the TempSpool, and closes and removes its
+// temporary file, if any.
+func (ts *TempSpool) Close() error {
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+
+	if ts.halted {
+		return nil
+	}
+	ts.halted = true
+	ts.mem = nil
+	if ts.fh == nil {
+		return nil
+	}
+	var errors ErrList
+	errors.Append(ts.fh.Close())
+	errors.Append(os.Remove(ts.fh.Name()))
+	return errors.Err()
+}
@@ -0,0 +1,74 @@
+package gorill
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestTempSpool(t *testing.T) {
+	t.Run("invalid threshold", func(t *testing.T) {
+		_, err := NewTempSpool(TempSpoolThreshold(-1))
+		ensureError(t, err, "threshold must be greater than or equal to 0")
+	})
+
+	t.Run("remains in memory", func(t *testing.T) {
+		ts, err := NewTempSpool(TempSpoolThreshold(len(largeBuf)))
+		ensureError(t, err)
+		defer ts.Close()
+
+		_, err = ts.Write(largeBuf)
+		ensureError(t, err)
+		if got, want := ts.IsSpilled(), false; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		buf, err := ioutil.ReadAll(ts.Reader())
+		ensureError(t, err)
+		if got, want := string(buf), string(largeBuf); got != want {
+			t.Errorf("GOT: %v; WANT: %v", len(got), len(want))
+		}
+	})
+
+	t.Run("spills to file", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "gorill")
+		ensureError(t, err)
+		defer os.RemoveAll(dir)
+
+		ts, err := NewTempSpool(TempSpoolThreshold(16), TempSpoolDir(dir))
+		ensureError(t, err)
+
+		_, err = ts.Write(smallBuf[:10])
+		ensureError(t, err)
+		_, err = ts.Write(smallBuf[10:])
+		ensureError(t, err)
+		if got, want := ts.IsSpilled(), true; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := ts.Size(), int64(len(smallBuf)); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		buf := make([]byte, 4)
+		n, err := ts.ReadAt(buf, 8)
+		ensureError(t, err)
+		if got, want := string(buf[:n]), string(smallBuf[8:12]); got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+
+		all, err := ioutil.ReadAll(ts.Reader())
+		ensureError(t, err)
+		if got, want := string(all), string(smallBuf); got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+
+		ensureError(t, ts.Close())
+		entries, err := ioutil.ReadDir(dir)
+		ensureError(t, err)
+		if got, want := len(entries), 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		_, err = ts.Write(smallBuf)
+		testErrorType(t, err, ErrWriteAfterClose{})
+	})
+}
@@ -9,7 +9,9 @@ import (
 
 // TimedReadCloser is an io.Reader that enforces a preset timeout period on every Read operation.
 type TimedReadCloser struct {
+	clock    Clock
 	halted   bool
+	hook     OperationHook
 	iorc     io.ReadCloser
 	jobs     chan *rillJob
 	jobsDone sync.WaitGroup
@@ -17,17 +19,57 @@ type TimedReadCloser struct {
 	timeout  time.Duration
 }
 
+// TimedReadCloserSetter is any function that modifies a TimedReadCloser being instantiated.
+type TimedReadCloserSetter func(*TimedReadCloser) error
+
+// TimedReadHook is used to configure a new TimedReadCloser to report each read to hook as an
+// OpRead operation. A read that times out is reported with an ErrTimeout error.
+func TimedReadHook(hook OperationHook) TimedReadCloserSetter {
+	return func(rc *TimedReadCloser) error {
+		rc.hook = hook
+		return nil
+	}
+}
+
+// TimedReadClock is used to configure the clock a new TimedReadCloser uses to time out reads.
+func TimedReadClock(clock Clock) TimedReadCloserSetter {
+	return func(rc *TimedReadCloser) error {
+		if clock == nil {
+			return fmt.Errorf("clock must not be nil")
+		}
+		rc.clock = clock
+		return nil
+	}
+}
+
 // NewTimedReadCloser returns a TimedReadCloser that enforces a preset timeout period on every Read
 // operation.  It panics when timeout is less than or equal to 0.
 func NewTimedReadCloser(iowc io.ReadCloser, timeout time.Duration) *TimedReadCloser {
+	rc, err := NewTimedReadCloserWithSetters(iowc, timeout)
+	if err != nil {
+		panic(err)
+	}
+	return rc
+}
+
+// NewTimedReadCloserWithSetters returns a TimedReadCloser configured by setters, that enforces a
+// preset timeout period on every Read operation.  It returns an error when timeout is less than or
+// equal to 0, or when a setter returns an error.
+func NewTimedReadCloserWithSetters(iowc io.ReadCloser, timeout time.Duration, setters ...TimedReadCloserSetter) (*TimedReadCloser, error) {
 	if timeout <= 0 {
-		panic(fmt.Errorf("timeout must be greater than 0: %s", timeout))
+		return nil, fmt.Errorf("timeout must be greater than 0: %s", timeout)
 	}
 	rc := &TimedReadCloser{
+		clock:   systemClock{},
 		iorc:    iowc,
 		jobs:    make(chan *rillJob, 1),
 		timeout: timeout,
 	}
+	for _, setter := range setters {
+		if err := setter(rc); err != nil {
+			return nil, err
+		}
+	}
 	rc.jobsDone.Add(1)
 	go func() {
 		for job := range rc.jobs {
@@ -36,7 +78,7 @@ func NewTimedReadCloser(iowc io.ReadCloser, timeout time.Duration) *TimedReadClo
 		}
 		rc.jobsDone.Done()
 	}()
-	return rc
+	return rc, nil
 }
 
 // Read reads data to the underlying io.Reader, but returns ErrTimeout if the Read operation exceeds
@@ -53,6 +95,7 @@ func (rc *TimedReadCloser) Read(data []byte) (int, error) {
 		return 0, ErrReadAfterClose{}
 	}
 
+	end := startOperation(rc.hook, OpRead)
 	job := newRillJob(_read, make([]byte, len(data)))
 	rc.jobs <- job
 
@@ -60,19 +103,27 @@ func (rc *TimedReadCloser) Read(data []byte) (int, error) {
 	select {
 	case result := <-job.results:
 		copy(data, job.data)
+		end(result.n, result.err)
 		return result.n, result.err
-	case <-time.After(rc.timeout):
+	case <-rc.clock.After(rc.timeout):
+		end(0, ErrTimeout(rc.timeout))
 		return 0, ErrTimeout(rc.timeout)
 	}
 }
 
+// Unwrap returns the underlying io.ReadCloser.
+func (rc *TimedReadCloser) Unwrap() io.ReadCloser { return rc.iorc }
+
 // Close frees resources when a SpooledReadCloser is no longer needed.
 func (rc *TimedReadCloser) Close() error {
 	rc.lock.Lock()
 	defer rc.lock.Unlock()
 
 	close(rc.jobs)
-	rc.jobsDone.Wait()
 	rc.halted = true
-	return rc.iorc.Close()
+	// Close the source before waiting, to unblock a read that timed out but
+	// is still in progress.
+	err := rc.iorc.Close()
+	rc.jobsDone.Wait()
+	return err
 }
@@ -45,6 +45,37 @@ func TestTimedReadCloserTimesOut(t *testing.T) {
 	}
 }
 
+func TestTimedReadCloserInvalidArguments(t *testing.T) {
+	_, err := NewTimedReadCloserWithSetters(NopCloseReader(NewNopCloseBuffer()), 0)
+	ensureError(t, err, "timeout must be greater than 0")
+
+	_, err = NewTimedReadCloserWithSetters(NopCloseReader(NewNopCloseBuffer()), time.Second, TimedReadClock(nil))
+	ensureError(t, err, "clock must not be nil")
+}
+
+func TestTimedReadCloserFakeClock(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	sr := SlowReaderWithClock(bytes.NewReader([]byte("this is a test")), time.Hour, clock)
+	rc, err := NewTimedReadCloserWithSetters(NopCloseReader(sr), time.Second, TimedReadClock(clock))
+	ensureError(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := rc.Read(make([]byte, 1000))
+		done <- err
+	}()
+	clock.BlockUntil(2) // the read timeout and the slow reader
+	clock.Advance(time.Second)
+	if got, want := <-done, ErrTimeout(time.Second); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	clock.Advance(time.Hour) // release the slow reader so Close does not wait
+	if err := rc.Close(); err != nil {
+		t.Errorf("GOT: %v; WANT: %v", err, nil)
+	}
+}
+
 func TestTimedReadCloserReadAfterCloseReturnsError(t *testing.T) {
 	bb := NewNopCloseBuffer()
 	rc := NewTimedReadCloser(NopCloseReader(bb), time.Millisecond)
@@ -1,9 +1,12 @@
 package gorill
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -18,25 +21,65 @@ func (e ErrTimeout) Error() string {
 
 // TimedWriteCloser is an io.Writer that enforces a preset timeout period on every Write operation.
 type TimedWriteCloser struct {
-	halted   bool
-	iowc     io.WriteCloser
-	jobs     chan *rillJob
-	jobsDone sync.WaitGroup
-	lock     sync.RWMutex
-	timeout  time.Duration
+	// Statistics are accessed atomically, and kept first for 64-bit alignment.
+	statBytes    int64
+	statTimeouts int64
+	statWrites   int64
+
+	clock        Clock
+	deadline     time.Time  // deadline is set when the underlying io.WriteCloser has none
+	deadlineLock sync.Mutex // deadlineLock protects deadline
+	halted       bool
+	iowc         io.WriteCloser
+	jobs         chan *rillJob
+	jobsDone     sync.WaitGroup
+	lock         sync.RWMutex
+	timeout      time.Duration
+}
+
+// TimedWriteCloserSetter is any function that modifies a TimedWriteCloser being instantiated.
+type TimedWriteCloserSetter func(*TimedWriteCloser) error
+
+// TimedWriteClock is used to configure the clock a new TimedWriteCloser uses to time out writes and
+// to enforce write deadlines.
+func TimedWriteClock(clock Clock) TimedWriteCloserSetter {
+	return func(wc *TimedWriteCloser) error {
+		if clock == nil {
+			return fmt.Errorf("clock must not be nil")
+		}
+		wc.clock = clock
+		return nil
+	}
 }
 
 // NewTimedWriteCloser returns a TimedWriteCloser that enforces a preset timeout period on every Write
 // operation.  It panics when timeout is less than or equal to 0.
 func NewTimedWriteCloser(iowc io.WriteCloser, timeout time.Duration) *TimedWriteCloser {
+	wc, err := NewTimedWriteCloserWithSetters(iowc, timeout)
+	if err != nil {
+		panic(err)
+	}
+	return wc
+}
+
+// NewTimedWriteCloserWithSetters returns a TimedWriteCloser configured by setters, that enforces a
+// preset timeout period on every Write operation.  It returns an error when timeout is less than or
+// equal to 0, or when a setter returns an error.
+func NewTimedWriteCloserWithSetters(iowc io.WriteCloser, timeout time.Duration, setters ...TimedWriteCloserSetter) (*TimedWriteCloser, error) {
 	if timeout <= 0 {
-		panic(fmt.Errorf("timeout must be greater than 0: %s", timeout))
+		return nil, fmt.Errorf("timeout must be greater than 0: %s", timeout)
 	}
 	wc := &TimedWriteCloser{
+		clock:   systemClock{},
 		iowc:    iowc,
 		jobs:    make(chan *rillJob, 1),
 		timeout: timeout,
 	}
+	for _, setter := range setters {
+		if err := setter(wc); err != nil {
+			return nil, err
+		}
+	}
 	wc.jobsDone.Add(1)
 	go func() {
 		for job := range wc.jobs {
@@ -45,12 +88,14 @@ func NewTimedWriteCloser(iowc io.WriteCloser, timeout time.Duration) *TimedWrite
 		}
 		wc.jobsDone.Done()
 	}()
-	return wc
+	return wc, nil
 }
 
 // Write writes data to the underlying io.Writer, but returns ErrTimeout if the Write
 // operation exceeds a preset timeout duration.  Even after a timeout takes place, the write may
-// still independantly complete as writes are queued from a different go routine.
+// still independantly complete as writes are queued from a different go routine.  When a write
+// deadline is enforced by the TimedWriteCloser itself, and it expires before the timeout, Write
+// returns ErrDeadlineExceeded instead.
 func (wc *TimedWriteCloser) Write(data []byte) (int, error) {
 	wc.lock.RLock()
 	defer wc.lock.RUnlock()
@@ -59,18 +104,62 @@ func (wc *TimedWriteCloser) Write(data []byte) (int, error) {
 		return 0, ErrWriteAfterClose{}
 	}
 
+	timeout, timeoutErr := wc.timeout, error(ErrTimeout(wc.timeout))
+	wc.deadlineLock.Lock()
+	deadline := wc.deadline
+	wc.deadlineLock.Unlock()
+	if !deadline.IsZero() {
+		until := deadline.Sub(wc.clock.Now())
+		if until <= 0 {
+			atomic.AddInt64(&wc.statTimeouts, 1)
+			return 0, ErrDeadlineExceeded{}
+		}
+		if until < timeout {
+			timeout, timeoutErr = until, ErrDeadlineExceeded{}
+		}
+	}
+
 	job := newRillJob(_write, data)
 	wc.jobs <- job
+	atomic.AddInt64(&wc.statWrites, 1)
 
 	// wait for result or timeout
 	select {
 	case result := <-job.results:
+		atomic.AddInt64(&wc.statBytes, int64(result.n))
 		return result.n, result.err
-	case <-time.After(wc.timeout):
-		return 0, ErrTimeout(wc.timeout)
+	case <-wc.clock.After(timeout):
+		atomic.AddInt64(&wc.statTimeouts, 1)
+		return 0, timeoutErr
+	}
+}
+
+// SetWriteDeadline sets the write deadline of the underlying io.WriteCloser. When the underlying
+// io.WriteCloser does not support deadlines, the TimedWriteCloser enforces the deadline itself, in
+// addition to its preset timeout period.
+func (wc *TimedWriteCloser) SetWriteDeadline(t time.Time) error {
+	err := SetWriteDeadline(wc.iowc, t)
+	if err != nil && errors.Is(err, os.ErrNoDeadline) {
+		wc.deadlineLock.Lock()
+		wc.deadline = t
+		wc.deadlineLock.Unlock()
+		err = nil
+	}
+	return err
+}
+
+// Stats returns the number of writes, bytes written, and writes that timed out.
+func (wc *TimedWriteCloser) Stats() map[string]int64 {
+	return map[string]int64{
+		"bytes":    atomic.LoadInt64(&wc.statBytes),
+		"timeouts": atomic.LoadInt64(&wc.statTimeouts),
+		"writes":   atomic.LoadInt64(&wc.statWrites),
 	}
 }
 
+// Unwrap returns the underlying io.WriteCloser.
+func (wc *TimedWriteCloser) Unwrap() io.WriteCloser { return wc.iowc }
+
 // Close frees resources when a SpooledWriteCloser is no longer needed.
 func (wc *TimedWriteCloser) Close() error {
 	wc.lock.Lock()
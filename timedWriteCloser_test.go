@@ -52,6 +52,37 @@ func TestTimedWriteCloserAfterTimeout(t *testing.T) {
 	// NOTE: cannot check for contents of buffer, because write independently completes.
 }
 
+func TestTimedWriteCloserInvalidArguments(t *testing.T) {
+	_, err := NewTimedWriteCloserWithSetters(NewNopCloseBuffer(), 0)
+	ensureError(t, err, "timeout must be greater than 0")
+
+	_, err = NewTimedWriteCloserWithSetters(NewNopCloseBuffer(), time.Second, TimedWriteClock(nil))
+	ensureError(t, err, "clock must not be nil")
+}
+
+func TestTimedWriteCloserFakeClock(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	sw := SlowWriterWithClock(new(bytes.Buffer), time.Hour, clock)
+	tw, err := NewTimedWriteCloserWithSetters(NopCloseWriter(sw), time.Second, TimedWriteClock(clock))
+	ensureError(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := tw.Write([]byte(alphabet))
+		done <- err
+	}()
+	clock.BlockUntil(2) // the write timeout and the slow writer
+	clock.Advance(time.Second)
+	if got, want := <-done, ErrTimeout(time.Second); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	clock.Advance(time.Hour) // release the slow writer so Close does not wait
+	if err := tw.Close(); err != nil {
+		t.Errorf("GOT: %v; WANT: %v", err, nil)
+	}
+}
+
 func TestTimedWriteCloserWriteAfterCloseReturnsError(t *testing.T) {
 	tw := NewTimedWriteCloser(NopCloseWriter(new(bytes.Buffer)), time.Millisecond)
 
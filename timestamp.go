@@ -0,0 +1,324 @@
+package gorill
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimestampFormatter converts a time to the timestamp written into a stream by
+// a TimestampWriter. The result must not contain a space or a newline.
+type TimestampFormatter func(time.Time) string
+
+// TimestampParser converts a timestamp read from a stream by a TimestampReader
+// back to a time.
+type TimestampParser func(string) (time.Time, error)
+
+// TimestampRFC3339Nano formats t as an RFC3339 timestamp in UTC with nanosecond
+// precision. It is the default TimestampFormatter.
+func TimestampRFC3339Nano(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// ParseTimestampRFC3339Nano parses a timestamp written by TimestampRFC3339Nano.
+// It is the default TimestampParser.
+func ParseTimestampRFC3339Nano(s string) (time.Time, error) {
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+// TimestampUnixNano formats t as the decimal number of nanoseconds since the
+// Unix epoch.
+func TimestampUnixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// ParseTimestampUnixNano parses a timestamp written by TimestampUnixNano.
+func ParseTimestampUnixNano(s string) (time.Time, error) {
+	ns, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, ns), nil
+}
+
+// timestampConfig holds the settings shared by TimestampWriter and
+// TimestampReader, so the two ends of a capture can be configured alike.
+type timestampConfig struct {
+	clock     Clock
+	eachWrite bool
+	format    TimestampFormatter
+	parse     TimestampParser
+}
+
+// TimestampSetter is any function that modifies a TimestampWriter or
+// TimestampReader being instantiated.
+type TimestampSetter func(*timestampConfig) error
+
+// TimestampEachWrite is used to configure a TimestampWriter to mark each write,
+// rather than each line, with a timestamp. Each write is framed with its length
+// so the matching TimestampReader can recover write boundaries. Both ends of a
+// capture must be configured with this setter.
+func TimestampEachWrite() TimestampSetter {
+	return func(tc *timestampConfig) error {
+		tc.eachWrite = true
+		return nil
+	}
+}
+
+// TimestampFormat is used to configure how a TimestampWriter formats
+// timestamps.
+func TimestampFormat(format TimestampFormatter) TimestampSetter {
+	return func(tc *timestampConfig) error {
+		tc.format = format
+		return nil
+	}
+}
+
+// TimestampClock is used to configure the clock a TimestampWriter reads the
+// time of each marker from.
+func TimestampClock(clock Clock) TimestampSetter {
+	return func(tc *timestampConfig) error {
+		if clock == nil {
+			return fmt.Errorf("clock must not be nil")
+		}
+		tc.clock = clock
+		return nil
+	}
+}
+
+// TimestampParse is used to configure how a TimestampReader parses timestamps.
+func TimestampParse(parse TimestampParser) TimestampSetter {
+	return func(tc *timestampConfig) error {
+		tc.parse = parse
+		return nil
+	}
+}
+
+func newTimestampConfig(setters []TimestampSetter) (*timestampConfig, error) {
+	tc := &timestampConfig{clock: systemClock{}, format: TimestampRFC3339Nano, parse: ParseTimestampRFC3339Nano}
+	for _, setter := range setters {
+		if err := setter(tc); err != nil {
+			return nil, err
+		}
+	}
+	return tc, nil
+}
+
+// TimestampWriter is an io.WriteCloser that injects time markers into the
+// stream written to the underlying io.WriteCloser, for building captures of a
+// stream that may later be replayed with their original timing.
+//
+// By default each line is preceded by the time its first byte was written and a
+// space. When configured with TimestampEachWrite, each write is instead
+// preceded by a header line holding the time and the length of the write.
+type TimestampWriter struct {
+	atLineStart bool
+	config      *timestampConfig
+	iowc        io.WriteCloser
+	now         func() time.Time
+}
+
+// NewTimestampWriter returns a TimestampWriter that writes a timestamped copy
+// of what is written to it to iowc.
+//
+//   tw, err := gorill.NewTimestampWriter(capture)
+//   if err != nil {
+//       return err
+//   }
+//   cmd.Stdout = tw
+func NewTimestampWriter(iowc io.WriteCloser, setters ...TimestampSetter) (*TimestampWriter, error) {
+	config, err := newTimestampConfig(setters)
+	if err != nil {
+		return nil, err
+	}
+	return &TimestampWriter{atLineStart: true, config: config, iowc: iowc, now: config.clock.Now}, nil
+}
+
+// Write writes data preceded by the appropriate time markers to the underlying
+// io.WriteCloser. It returns the number of bytes of data written, not counting
+// the markers.
+func (tw *TimestampWriter) Write(data []byte) (int, error) {
+	if len(data) == 0 {
+		return 0, nil
+	}
+	stamp := tw.config.format(tw.now())
+
+	if tw.config.eachWrite {
+		header := fmt.Sprintf("%s %d\n", stamp, len(data))
+		buf := append([]byte(header), data...)
+		n, err := tw.iowc.Write(buf)
+		if n -= len(header); n < 0 {
+			n = 0
+		}
+		if err == nil && n < len(data) {
+			err = io.ErrShortWrite
+		}
+		return n, err
+	}
+
+	// Assemble a single buffer in which each line starting within data is
+	// preceded by the timestamp, remembering where payload bytes lie.
+	var buf []byte
+	var marks []int // marks holds the offset in buf after each inserted marker
+	prefix := stamp + " "
+	for remaining := data; len(remaining) > 0; {
+		if tw.atLineStart {
+			buf = append(buf, prefix...)
+			marks = append(marks, len(buf))
+			tw.atLineStart = false
+		}
+		i := bytes.IndexByte(remaining, '\n')
+		if i < 0 {
+			buf = append(buf, remaining...)
+			break
+		}
+		buf = append(buf, remaining[:i+1]...)
+		remaining = remaining[i+1:]
+		tw.atLineStart = true
+	}
+
+	n, err := tw.iowc.Write(buf)
+	if err == nil {
+		return len(data), nil
+	}
+	// Do not count the marker bytes that were written.
+	payload := n
+	for _, mark := range marks {
+		start := mark - len(prefix)
+		if n <= start {
+			break
+		}
+		if n < mark {
+			payload -= n - start
+			break
+		}
+		payload -= len(prefix)
+	}
+	return payload, err
+}
+
+// Unwrap returns the underlying io.WriteCloser.
+func (tw *TimestampWriter) Unwrap() io.WriteCloser { return tw.iowc }
+
+// Close closes the underlying io.WriteCloser.
+func (tw *TimestampWriter) Close() error {
+	return tw.iowc.Close()
+}
+
+// TimestampReader is an io.ReadCloser that reads a stream written by a
+// TimestampWriter, and strips its time markers. The timestamps are exposed by
+// Next, which returns one record at a time, and by Time, which returns the
+// timestamp of the record most recently returned by Read.
+type TimestampReader struct {
+	br      *bufio.Reader
+	config  *timestampConfig
+	iorc    io.ReadCloser
+	pending []byte
+	stamp   time.Time
+}
+
+// NewTimestampReader returns a TimestampReader that reads a timestamped stream
+// from iorc. It must be configured with the same TimestampEachWrite setting as
+// the TimestampWriter that wrote the stream.
+//
+//   tr, err := gorill.NewTimestampReader(capture, gorill.TimestampParse(gorill.ParseTimestampUnixNano))
+//   if err != nil {
+//       return err
+//   }
+//   for {
+//       when, record, err := tr.Next()
+//       if err != nil {
+//           break
+//       }
+//       replay(when, record)
+//   }
+func NewTimestampReader(iorc io.ReadCloser, setters ...TimestampSetter) (*TimestampReader, error) {
+	config, err := newTimestampConfig(setters)
+	if err != nil {
+		return nil, err
+	}
+	return &TimestampReader{br: bufio.NewReader(iorc), config: config, iorc: iorc}, nil
+}
+
+// Next returns the timestamp and payload of the next record in the stream: a
+// line, including its newline, or a write when configured with
+// TimestampEachWrite. It returns io.EOF after the final record. Next ought not
+// be mixed with Read, because it skips any portion of a record not yet
+// returned by Read.
+func (tr *TimestampReader) Next() (time.Time, []byte, error) {
+	tr.pending = nil
+
+	line, err := tr.br.ReadString('\n')
+	if line == "" {
+		if err == nil {
+			err = io.EOF
+		}
+		return time.Time{}, nil, err
+	}
+	if err != nil && err != io.EOF {
+		return time.Time{}, nil, err
+	}
+
+	var stamp, rest string
+	if i := strings.IndexByte(line, ' '); i >= 0 {
+		stamp, rest = line[:i], line[i+1:]
+	} else {
+		return time.Time{}, nil, fmt.Errorf("cannot find timestamp: %q", line)
+	}
+	when, perr := tr.config.parse(stamp)
+	if perr != nil {
+		return time.Time{}, nil, fmt.Errorf("cannot parse timestamp: %s", perr)
+	}
+
+	if !tr.config.eachWrite {
+		tr.stamp = when
+		return when, []byte(rest), nil
+	}
+
+	size, perr := strconv.Atoi(strings.TrimSuffix(rest, "\n"))
+	if perr != nil || size < 0 {
+		return time.Time{}, nil, fmt.Errorf("cannot parse record length: %q", rest)
+	}
+	record := make([]byte, size)
+	if _, err = io.ReadFull(tr.br, record); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return time.Time{}, nil, err
+	}
+	tr.stamp = when
+	return when, record, nil
+}
+
+// Read reads the payload of the stream, with its time markers removed, into
+// buf. It never returns bytes from more than one record.
+func (tr *TimestampReader) Read(buf []byte) (int, error) {
+	for len(tr.pending) == 0 {
+		_, record, err := tr.Next()
+		if err != nil {
+			return 0, err
+		}
+		tr.pending = record
+	}
+	n := copy(buf, tr.pending)
+	tr.pending = tr.pending[n:]
+	return n, nil
+}
+
+// Time returns the timestamp of the record most recently returned by Next or
+// Read.
+func (tr *TimestampReader) Time() time.Time {
+	return tr.stamp
+}
+
+// Unwrap returns the underlying io.ReadCloser.
+func (tr *TimestampReader) Unwrap() io.ReadCloser { return tr.iorc }
+
+// Close closes the underlying io.ReadCloser.
+func (tr *TimestampReader) Close() error {
+	return tr.iorc.Close()
+}
@@ -0,0 +1,125 @@
+package gorill
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+// fakeClock returns a function that returns successive seconds after the Unix
+// epoch each time it is invoked.
+func fakeClock() func() time.Time {
+	var tick int64
+	return func() time.Time {
+		tick++
+		return time.Unix(tick, 0)
+	}
+}
+
+func TestTimestampWriterLines(t *testing.T) {
+	bb := new(bytes.Buffer)
+	tw, err := NewTimestampWriter(NopCloseWriter(bb), TimestampFormat(TimestampUnixNano))
+	ensureError(t, err)
+	tw.now = fakeClock()
+
+	for _, chunk := range []string{"one\ntw", "o\n", "three\nfour"} {
+		n, err := tw.Write([]byte(chunk))
+		ensureError(t, err)
+		if got, want := n, len(chunk); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	}
+	ensureError(t, tw.Close())
+
+	want := "1000000000 one\n1000000000 two\n3000000000 three\n3000000000 four"
+	if got := bb.String(); got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+}
+
+func TestTimestampWriterShortWrite(t *testing.T) {
+	tw, err := NewTimestampWriter(&testWriteCloser{})
+	ensureError(t, err)
+
+	n, err := tw.Write([]byte(alphabet))
+	testErrorType(t, err, io.ErrShortWrite)
+	if got, want := n, 0; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
+
+func TestTimestampRoundTripLines(t *testing.T) {
+	bb := new(bytes.Buffer)
+	tw, err := NewTimestampWriter(NopCloseWriter(bb))
+	ensureError(t, err)
+	tw.now = fakeClock()
+	_, err = tw.Write([]byte("one\ntwo\n"))
+	ensureError(t, err)
+	_, err = tw.Write([]byte("three"))
+	ensureError(t, err)
+
+	tr, err := NewTimestampReader(ioutil.NopCloser(bytes.NewReader(bb.Bytes())))
+	ensureError(t, err)
+
+	when, record, err := tr.Next()
+	ensureError(t, err)
+	if got, want := string(record), "one\n"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	if got, want := when, time.Unix(1, 0); !got.Equal(want) {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	rest, err := ioutil.ReadAll(tr)
+	ensureError(t, err)
+	if got, want := string(rest), "two\nthree"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	if got, want := tr.Time(), time.Unix(2, 0); !got.Equal(want) {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	ensureError(t, tr.Close())
+}
+
+func TestTimestampRoundTripEachWrite(t *testing.T) {
+	bb := new(bytes.Buffer)
+	tw, err := NewTimestampWriter(NopCloseWriter(bb), TimestampEachWrite())
+	ensureError(t, err)
+	tw.now = fakeClock()
+	for _, chunk := range []string{"ab\ncd", "\n", alphabet} {
+		_, err = tw.Write([]byte(chunk))
+		ensureError(t, err)
+	}
+
+	tr, err := NewTimestampReader(ioutil.NopCloser(bytes.NewReader(bb.Bytes())), TimestampEachWrite())
+	ensureError(t, err)
+
+	var records []string
+	for i := int64(1); ; i++ {
+		when, record, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		ensureError(t, err)
+		if got, want := when, time.Unix(i, 0); !got.Equal(want) {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		records = append(records, string(record))
+	}
+	ensureStringSlicesMatch(t, records, []string{"ab\ncd", "\n", alphabet})
+}
+
+func TestTimestampReaderInvalid(t *testing.T) {
+	tr, err := NewTimestampReader(ioutil.NopCloser(bytes.NewReader([]byte("yesterday hello\n"))))
+	ensureError(t, err)
+	_, _, err = tr.Next()
+	ensureError(t, err, "cannot parse timestamp")
+
+	tr, err = NewTimestampReader(ioutil.NopCloser(bytes.NewReader([]byte("12 oops\n"))),
+		TimestampEachWrite(), TimestampParse(ParseTimestampUnixNano))
+	ensureError(t, err)
+	_, _, err = tr.Next()
+	ensureError(t, err, "cannot parse record length")
+}
@@ -0,0 +1,42 @@
+package gorill
+
+import (
+	"io"
+	"sync"
+)
+
+var transferBuffers = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 32*1024)
+		return &buf
+	},
+}
+
+// Transfer copies from src to dst until either io.EOF is reached on src or an
+// error occurs, returning the number of bytes copied. Unlike io.Copy, it
+// sees through the wrappers in this library that do not need to inspect the
+// bytes they pass, such as NopCloseReader, NopCloseWriter, and
+// LockingWriteCloser. This allows the runtime to use zero-copy system calls
+// such as sendfile(2) and splice(2) when the underlying pair is, for instance,
+// an *os.File and a net.Conn, or a pipe and an *os.File. Other pairs are
+// copied through a pooled buffer.
+//
+//   fh, err := os.Open("payload.bin")
+//   if err != nil {
+//       return err
+//   }
+//   defer fh.Close()
+//   lwc := gorill.NewLockingWriteCloser(conn)
+//   _, err = gorill.Transfer(lwc, fh) // uses sendfile when conn is a *net.TCPConn
+func Transfer(dst io.Writer, src io.Reader) (int64, error) {
+	if wt, ok := src.(io.WriterTo); ok {
+		return wt.WriteTo(dst)
+	}
+	if rf, ok := dst.(io.ReaderFrom); ok {
+		return rf.ReadFrom(src)
+	}
+	bp := transferBuffers.Get().(*[]byte)
+	defer transferBuffers.Put(bp)
+	// Hide any io.WriterTo and io.ReaderFrom methods already declined above.
+	return io.CopyBuffer(struct{ io.Writer }{dst}, struct{ io.Reader }{src}, *bp)
+}
@@ -0,0 +1,83 @@
+package gorill
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+// readerFromRecorder records whether its ReadFrom method was invoked.
+type readerFromRecorder struct {
+	bytes.Buffer
+	invoked bool
+}
+
+func (r *readerFromRecorder) ReadFrom(src io.Reader) (int64, error) {
+	r.invoked = true
+	return r.Buffer.ReadFrom(src)
+}
+
+func (r *readerFromRecorder) Close() error { return nil }
+
+func TestTransfer(t *testing.T) {
+	t.Run("plain copy", func(t *testing.T) {
+		bb := new(bytes.Buffer)
+		n, err := Transfer(struct{ io.Writer }{bb}, struct{ io.Reader }{strings.NewReader(alphabet)})
+		ensureError(t, err)
+		if got, want := n, int64(len(alphabet)); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := bb.String(), alphabet; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("passes through wrappers", func(t *testing.T) {
+		rec := new(readerFromRecorder)
+		dst := NewLockingWriteCloser(NopCloseWriter(rec))
+		src := NopCloseReader(struct{ io.Reader }{strings.NewReader(alphabet)})
+
+		_, err := Transfer(dst, src)
+		ensureError(t, err)
+		if !rec.invoked {
+			t.Errorf("GOT: %v; WANT: %v", rec.invoked, true)
+		}
+		if got, want := rec.String(), alphabet; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("io.Copy through locking wrapper", func(t *testing.T) {
+		rec := new(readerFromRecorder)
+		_, err := io.Copy(NewLockingWriteCloser(rec), struct{ io.Reader }{strings.NewReader(alphabet)})
+		ensureError(t, err)
+		if !rec.invoked {
+			t.Errorf("GOT: %v; WANT: %v", rec.invoked, true)
+		}
+	})
+
+	t.Run("file to network connection", func(t *testing.T) {
+		want := bytes.Repeat([]byte(alphabet), 10000)
+		fh, err := ioutil.TempFile("", "gorill")
+		ensureError(t, err)
+		defer os.Remove(fh.Name())
+		defer fh.Close()
+		_, err = fh.Write(want)
+		ensureError(t, err)
+		_, err = fh.Seek(0, io.SeekStart)
+		ensureError(t, err)
+
+		conn, done := loopback(t)
+		n, err := Transfer(NewLockingWriteCloser(conn), fh)
+		ensureError(t, err)
+		if got, want := n, int64(len(want)); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got := done(); !bytes.Equal(got, want) {
+			t.Errorf("GOT: %v bytes; WANT: %v bytes", len(got), len(want))
+		}
+	})
+}
@@ -6,12 +6,14 @@ const (
 	_read opcode = iota
 	_write
 	_flush
+	_writeString
 )
 
 // rillJob represents a job to perform either a read or write operation to a stream
 type rillJob struct {
 	op      opcode
 	data    []byte
+	str     string // str is the data of a _writeString job
 	results chan rillResult
 }
 
@@ -40,3 +42,19 @@ type ErrWriteAfterClose struct{}
 func (e ErrWriteAfterClose) Error() string {
 	return "write on closed writer"
 }
+
+// ErrDeadlineExceeded is returned if a Read or Write is attempted after its deadline has passed. It
+// implements net.Error, and its Timeout method returns true. When built with Go 1.15 or later,
+// errors.Is also reports that it matches os.ErrDeadlineExceeded.
+type ErrDeadlineExceeded struct{}
+
+// Error returns a string representation of a ErrDeadlineExceeded error instance.
+func (e ErrDeadlineExceeded) Error() string {
+	return "i/o timeout"
+}
+
+// Temporary returns true because a new deadline may allow the operation to succeed.
+func (e ErrDeadlineExceeded) Temporary() bool { return true }
+
+// Timeout returns true because the operation timed out.
+func (e ErrDeadlineExceeded) Timeout() bool { return true }
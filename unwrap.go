@@ -0,0 +1,83 @@
+package gorill
+
+import (
+	"io"
+	"os"
+	"reflect"
+)
+
+// Unwrap returns the value wrapped by v when v has an Unwrap method returning
+// an io.Reader, io.Writer, or one of the other stream types wrapped by the
+// types in this package. It returns nil when v does not wrap another value.
+func Unwrap(v interface{}) interface{} {
+	var inner interface{}
+	switch u := v.(type) {
+	case interface{ Unwrap() io.Reader }:
+		inner = u.Unwrap()
+	case interface{ Unwrap() io.ReadCloser }:
+		inner = u.Unwrap()
+	case interface{ Unwrap() io.ReaderAt }:
+		inner = u.Unwrap()
+	case interface{ Unwrap() io.ReadWriteCloser }:
+		inner = u.Unwrap()
+	case interface{ Unwrap() io.Writer }:
+		inner = u.Unwrap()
+	case interface{ Unwrap() io.WriteCloser }:
+		inner = u.Unwrap()
+	case interface{ Unwrap() MessageConn }:
+		inner = u.Unwrap()
+	case interface{ Unwrap() *os.File }:
+		if fh := u.Unwrap(); fh != nil {
+			inner = fh
+		}
+	}
+	return inner
+}
+
+// Innermost returns the last value in the chain of values starting at v and
+// repeatedly calling Unwrap, such as the *os.File or net.Conn at the bottom of
+// a stack of wrappers.
+//
+//   if fh, ok := gorill.Innermost(iowc).(*os.File); ok {
+//       fi, err := fh.Stat()
+//       // ...
+//   }
+func Innermost(v interface{}) interface{} {
+	for {
+		inner := Unwrap(v)
+		if inner == nil {
+			return v
+		}
+		v = inner
+	}
+}
+
+// As finds the first value in the chain of values starting at v and
+// repeatedly calling Unwrap that is assignable to the value pointed to by
+// target, and if one is found, sets target to that value and returns true.
+// Like errors.As, it panics when target is not a non-nil pointer to either an
+// interface or a type.
+//
+//   var conn net.Conn
+//   if gorill.As(iowc, &conn) {
+//       sc, err := conn.(syscall.Conn).SyscallConn()
+//       // ...
+//   }
+func As(v interface{}, target interface{}) bool {
+	if target == nil {
+		panic("gorill: target cannot be nil")
+	}
+	val := reflect.ValueOf(target)
+	if val.Kind() != reflect.Ptr || val.IsNil() {
+		panic("gorill: target must be a non-nil pointer")
+	}
+	targetType := val.Type().Elem()
+	for v != nil {
+		if reflect.TypeOf(v).AssignableTo(targetType) {
+			val.Elem().Set(reflect.ValueOf(v))
+			return true
+		}
+		v = Unwrap(v)
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package gorill
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUnwrapNotWrapper(t *testing.T) {
+	if got := Unwrap(NewNopCloseBuffer()); got != nil {
+		t.Errorf("GOT: %v; WANT: %v", got, nil)
+	}
+	bb := NewNopCloseBuffer()
+	if got, want := Innermost(bb), interface{}(bb); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
+
+func TestInnermostFindsFile(t *testing.T) {
+	fh, err := ioutil.TempFile("", "gorill-unwrap")
+	ensureError(t, err)
+	defer os.Remove(fh.Name())
+
+	sw, err := NewSpooledWriteCloser(NewLockingWriteCloser(NewSafeWriteCloser(fh)))
+	ensureError(t, err)
+	defer sw.Close()
+
+	got, ok := Innermost(sw).(*os.File)
+	if !ok {
+		t.Fatalf("GOT: %T; WANT: %T", Innermost(sw), fh)
+	}
+	if got != fh {
+		t.Errorf("GOT: %v; WANT: %v", got, fh)
+	}
+}
+
+func TestInnermostReaders(t *testing.T) {
+	sr := strings.NewReader(alphabet)
+	pr := NewPeekReader(NopCloseReader(sr))
+	if got, want := Innermost(pr), interface{}(sr); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
+
+func TestInnermostIdleCloser(t *testing.T) {
+	ic, err := NewIdleCloser(func() (io.WriteCloser, error) { return NewNopCloseBuffer(), nil }, time.Minute)
+	ensureError(t, err)
+	defer ic.Close()
+
+	// Until written to, the IdleCloser has nothing to unwrap.
+	if got, want := Innermost(ic), interface{}(ic); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
+
+func TestAs(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	ws, err := NewWriteStack(client, []WriteLayer{
+		func(iowc io.WriteCloser) (io.WriteCloser, error) {
+			return NewTimedWriteCloser(iowc, time.Second), nil
+		},
+		func(iowc io.WriteCloser) (io.WriteCloser, error) {
+			return NewLockingWriteCloser(iowc), nil
+		},
+	})
+	ensureError(t, err)
+	defer ws.Close()
+
+	var conn net.Conn
+	if got, want := As(ws, &conn), true; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := conn, client; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	var twc *TimedWriteCloser
+	if got, want := As(ws, &twc), true; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	var fh *os.File
+	if got, want := As(ws, &fh), false; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
+
+func TestAsInvalidTarget(t *testing.T) {
+	ensurePanic(t, "gorill: target cannot be nil", func() {
+		As(NewNopCloseBuffer(), nil)
+	})
+	ensurePanic(t, "gorill: target must be a non-nil pointer", func() {
+		var fh *os.File
+		As(NewNopCloseBuffer(), fh)
+	})
+}
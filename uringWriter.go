@@ -0,0 +1,233 @@
+package gorill
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"syscall"
+)
+
+// DefaultUringQueueDepth is the default maximum number of writes a UringWriter
+// has outstanding at once.
+const DefaultUringQueueDepth = 32
+
+// AsyncWriteCloser is an io.WriteCloser whose writes may complete after Write
+// returns. An error from a write that completes in the background is returned
+// by a subsequent Write, Flush, or Close.
+type AsyncWriteCloser interface {
+	io.WriteCloser
+
+	// Flush waits for all outstanding writes to complete.
+	Flush() error
+}
+
+// uringBackend is implemented by the platform specific io_uring support.
+type uringBackend interface {
+	submit(fd uintptr, buf []byte, off int64, id uint64) error
+	reap(wait bool) (id uint64, res int32, ok bool, err error)
+	close() error
+}
+
+// uringOp is a write submitted to the ring and not yet completed.
+type uringOp struct {
+	buf []byte // buf is referenced here so it stays alive until completion
+	off int64
+}
+
+// UringWriter is an experimental AsyncWriteCloser that submits writes to a file
+// through io_uring on Linux 5.6 and later, so that the writing go-routine need
+// not wait for each write to complete. Where io_uring is unavailable, such as
+// on other platforms or when disabled by a seccomp policy, it falls back to
+// writing synchronously.
+//
+// Writes are made at explicit offsets, starting from the file's offset when
+// the UringWriter is created, and the file's offset is advanced past them when
+// the UringWriter is closed.
+type UringWriter struct {
+	depth    int
+	err      error // err is the sticky error from a completed write
+	fh       *os.File
+	inflight map[uint64]*uringOp
+	lock     sync.Mutex
+	nextID   uint64
+	offset   int64
+	ring     uringBackend
+}
+
+// UringWriterSetter is any function that modifies a UringWriter being
+// instantiated.
+type UringWriterSetter func(*UringWriter) error
+
+// UringQueueDepth is used to configure the maximum number of writes a
+// UringWriter has outstanding at once.
+func UringQueueDepth(depth int) UringWriterSetter {
+	return func(uw *UringWriter) error {
+		if depth <= 0 {
+			return fmt.Errorf("queue depth must be greater than 0: %d", depth)
+		}
+		uw.depth = depth
+		return nil
+	}
+}
+
+// NewUringWriter returns a UringWriter that writes to fh.
+//
+//   fh, err := os.Create(path)
+//   if err != nil {
+//       return err
+//   }
+//   uw, err := gorill.NewUringWriter(fh, gorill.UringQueueDepth(64))
+//   if err != nil {
+//       return err
+//   }
+//   defer uw.Close()
+func NewUringWriter(fh *os.File, setters ...UringWriterSetter) (*UringWriter, error) {
+	uw := &UringWriter{depth: DefaultUringQueueDepth, fh: fh, inflight: make(map[uint64]*uringOp)}
+	for _, setter := range setters {
+		if err := setter(uw); err != nil {
+			return nil, err
+		}
+	}
+	offset, err := fh.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	uw.offset = offset
+	if ring, err := newUringBackend(uw.depth); err == nil {
+		uw.ring = ring
+	}
+	return uw, nil
+}
+
+// IsAsync returns true when writes are submitted through io_uring, and false
+// when the UringWriter has fallen back to writing synchronously.
+func (uw *UringWriter) IsAsync() bool {
+	return uw.ring != nil
+}
+
+// Write submits a copy of data to be written, and returns without waiting for
+// the write to complete, unless the maximum number of writes are outstanding.
+func (uw *UringWriter) Write(data []byte) (int, error) {
+	uw.lock.Lock()
+	defer uw.lock.Unlock()
+
+	if uw.err != nil {
+		return 0, uw.err
+	}
+	if len(data) == 0 {
+		return 0, nil
+	}
+	if uw.ring == nil {
+		n, err := uw.fh.WriteAt(data, uw.offset)
+		uw.offset += int64(n)
+		return n, err
+	}
+
+	for len(uw.inflight) >= uw.depth {
+		if _, err := uw.complete(true); err != nil {
+			return 0, err
+		}
+	}
+	if uw.err != nil {
+		return 0, uw.err
+	}
+	if err := uw.submit(append([]byte(nil), data...), uw.offset); err != nil {
+		return 0, err
+	}
+	uw.offset += int64(len(data))
+
+	// Collect whatever completions are ready without waiting.
+	for {
+		ok, err := uw.complete(false)
+		if err != nil || !ok {
+			break
+		}
+	}
+	return len(data), nil
+}
+
+func (uw *UringWriter) submit(buf []byte, off int64) error {
+	id := uw.nextID
+	uw.nextID++
+	uw.inflight[id] = &uringOp{buf: buf, off: off}
+	if err := uw.ring.submit(uw.fh.Fd(), buf, off, id); err != nil {
+		delete(uw.inflight, id)
+		uw.err = err
+		return err
+	}
+	return nil
+}
+
+// complete processes one completion, resubmitting the remainder of a short
+// write, and recording the error from a failed write. It returns false when
+// wait is false and no completion is ready, and only returns an error when the
+// ring itself fails. Caller must hold the lock.
+func (uw *UringWriter) complete(wait bool) (bool, error) {
+	id, res, ok, err := uw.ring.reap(wait)
+	if err != nil {
+		uw.err = err
+		return false, err
+	}
+	if !ok {
+		return false, nil
+	}
+	op := uw.inflight[id]
+	delete(uw.inflight, id)
+
+	switch {
+	case res < 0:
+		if uw.err == nil {
+			uw.err = os.NewSyscallError("write", syscall.Errno(-res))
+		}
+	case res == 0:
+		if uw.err == nil {
+			uw.err = io.ErrShortWrite
+		}
+	case int(res) < len(op.buf):
+		if err := uw.submit(op.buf[res:], op.off+int64(res)); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// Flush waits for all outstanding writes to complete, and returns the first
+// error from any of them.
+func (uw *UringWriter) Flush() error {
+	uw.lock.Lock()
+	defer uw.lock.Unlock()
+
+	return uw.flush()
+}
+
+func (uw *UringWriter) flush() error {
+	for len(uw.inflight) > 0 {
+		if _, err := uw.complete(true); err != nil {
+			return err
+		}
+	}
+	return uw.err
+}
+
+// Unwrap returns the underlying file; writing to it while writes are in flight
+// corrupts the output.
+func (uw *UringWriter) Unwrap() *os.File { return uw.fh }
+
+// Close waits for all outstanding writes to complete, advances the file's
+// offset past them, and closes the file.
+func (uw *UringWriter) Close() error {
+	uw.lock.Lock()
+	defer uw.lock.Unlock()
+
+	var errors ErrList
+	if uw.ring != nil {
+		errors.Append(uw.flush())
+		errors.Append(uw.ring.close())
+		uw.ring = nil
+	}
+	_, err := uw.fh.Seek(uw.offset, io.SeekStart)
+	errors.Append(err)
+	errors.Append(uw.fh.Close())
+	return errors.Err()
+}
@@ -0,0 +1,92 @@
+package gorill
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestUringWriter(t *testing.T) {
+	fh, err := ioutil.TempFile("", "gorill")
+	ensureError(t, err)
+	defer os.Remove(fh.Name())
+	_, err = fh.WriteString("head:")
+	ensureError(t, err)
+
+	uw, err := NewUringWriter(fh, UringQueueDepth(4))
+	ensureError(t, err)
+	t.Logf("io_uring in use: %v", uw.IsAsync())
+
+	var want bytes.Buffer
+	want.WriteString("head:")
+	buf := []byte(alphabet)
+	for i := 0; i < 100; i++ {
+		n, err := uw.Write(buf)
+		ensureError(t, err)
+		if got, want := n, len(alphabet); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		want.Write(buf)
+		buf[0]++ // the writer must not retain the caller's buffer
+	}
+	ensureError(t, uw.Flush())
+	ensureError(t, uw.Close())
+
+	got, err := ioutil.ReadFile(fh.Name())
+	ensureError(t, err)
+	if !bytes.Equal(got, want.Bytes()) {
+		t.Errorf("GOT: %d bytes; WANT: %d bytes", len(got), want.Len())
+	}
+}
+
+func TestUringWriterWriteError(t *testing.T) {
+	fh, err := ioutil.TempFile("", "gorill")
+	ensureError(t, err)
+	defer os.Remove(fh.Name())
+	ensureError(t, fh.Close())
+
+	ro, err := os.Open(fh.Name())
+	ensureError(t, err)
+	uw, err := NewUringWriter(ro)
+	ensureError(t, err)
+
+	_, err = uw.Write([]byte(alphabet))
+	if err == nil {
+		err = uw.Flush()
+	}
+	ensureError(t, err, "bad file descriptor")
+	_ = uw.Close()
+}
+
+func TestUringWriterInvalidDepth(t *testing.T) {
+	_, err := NewUringWriter(os.Stdout, UringQueueDepth(0))
+	ensureError(t, err, "queue depth must be greater than 0")
+}
+
+func TestUringWriterFallback(t *testing.T) {
+	fh, err := ioutil.TempFile("", "gorill")
+	ensureError(t, err)
+	defer os.Remove(fh.Name())
+
+	uw, err := NewUringWriter(fh)
+	ensureError(t, err)
+	if uw.ring != nil {
+		ensureError(t, uw.ring.close())
+		uw.ring = nil // simulate a platform without io_uring
+	}
+	if uw.IsAsync() {
+		t.Errorf("GOT: %v; WANT: %v", true, false)
+	}
+
+	_, err = uw.Write([]byte(alphabet))
+	ensureError(t, err)
+	ensureError(t, uw.Flush())
+	ensureError(t, uw.Close())
+
+	got, err := ioutil.ReadFile(fh.Name())
+	ensureError(t, err)
+	if string(got) != alphabet {
+		t.Errorf("GOT: %q; WANT: %q", got, alphabet)
+	}
+}
@@ -0,0 +1,209 @@
+//go:build linux && (amd64 || arm64)
+// +build linux
+// +build amd64 arm64
+
+package gorill
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	sysIoUringSetup = 425
+	sysIoUringEnter = 426
+
+	uringOffSQRing = 0
+	uringOffCQRing = 0x8000000
+	uringOffSQEs   = 0x10000000
+
+	uringOpWrite        = 23 // IORING_OP_WRITE, added in Linux 5.6
+	uringEnterGetEvents = 1  // IORING_ENTER_GETEVENTS
+	uringMaxEntries     = 4096
+	uringSubmissionSize = 64
+	uringCompletionSize = 16
+)
+
+// uringParams mirrors struct io_uring_params.
+type uringParams struct {
+	sqEntries    uint32
+	cqEntries    uint32
+	flags        uint32
+	sqThreadCPU  uint32
+	sqThreadIdle uint32
+	features     uint32
+	wqFd         uint32
+	resv         [3]uint32
+	sqOff        struct {
+		head, tail, ringMask, ringEntries, flags, dropped, array, resv1 uint32
+		userAddr                                                        uint64
+	}
+	cqOff struct {
+		head, tail, ringMask, ringEntries, overflow, cqes, flags, resv1 uint32
+		userAddr                                                        uint64
+	}
+}
+
+// uringSQE mirrors struct io_uring_sqe.
+type uringSQE struct {
+	opcode   uint8
+	flags    uint8
+	ioprio   uint16
+	fd       int32
+	off      uint64
+	addr     uint64
+	len      uint32
+	rwFlags  uint32
+	userData uint64
+	pad      [3]uint64
+}
+
+// uringCQE mirrors struct io_uring_cqe.
+type uringCQE struct {
+	userData uint64
+	res      int32
+	flags    uint32
+}
+
+// uring is a minimal io_uring instance that submits writes and reaps their
+// completions. It is not safe for concurrent use.
+type uring struct {
+	fd      int
+	sqRing  []byte
+	cqRing  []byte
+	sqeMem  []byte
+	sqHead  *uint32
+	sqTail  *uint32
+	sqMask  uint32
+	sqArray []uint32
+	sqes    []uringSQE
+	cqHead  *uint32
+	cqTail  *uint32
+	cqMask  uint32
+	cqes    []uringCQE
+}
+
+func newUringBackend(entries int) (uringBackend, error) {
+	if entries > uringMaxEntries {
+		entries = uringMaxEntries
+	}
+	var p uringParams
+	fd, _, errno := syscall.Syscall(sysIoUringSetup, uintptr(entries), uintptr(unsafe.Pointer(&p)), 0)
+	if errno != 0 {
+		return nil, os.NewSyscallError("io_uring_setup", errno)
+	}
+	r := &uring{fd: int(fd)}
+
+	var err error
+	mmap := func(offset int64, size uint32) []byte {
+		if err != nil {
+			return nil
+		}
+		var buf []byte
+		buf, err = syscall.Mmap(r.fd, offset, int(size), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED|syscall.MAP_POPULATE)
+		return buf
+	}
+	r.sqRing = mmap(uringOffSQRing, p.sqOff.array+p.sqEntries*4)
+	r.cqRing = mmap(uringOffCQRing, p.cqOff.cqes+p.cqEntries*uringCompletionSize)
+	r.sqeMem = mmap(uringOffSQEs, p.sqEntries*uringSubmissionSize)
+	if err != nil {
+		_ = r.close()
+		return nil, os.NewSyscallError("mmap", err)
+	}
+
+	r.sqHead = (*uint32)(unsafe.Pointer(&r.sqRing[p.sqOff.head]))
+	r.sqTail = (*uint32)(unsafe.Pointer(&r.sqRing[p.sqOff.tail]))
+	r.sqMask = *(*uint32)(unsafe.Pointer(&r.sqRing[p.sqOff.ringMask]))
+	r.sqArray = (*[uringMaxEntries]uint32)(unsafe.Pointer(&r.sqRing[p.sqOff.array]))[:p.sqEntries:p.sqEntries]
+	r.sqes = (*[uringMaxEntries]uringSQE)(unsafe.Pointer(&r.sqeMem[0]))[:p.sqEntries:p.sqEntries]
+	r.cqHead = (*uint32)(unsafe.Pointer(&r.cqRing[p.cqOff.head]))
+	r.cqTail = (*uint32)(unsafe.Pointer(&r.cqRing[p.cqOff.tail]))
+	r.cqMask = *(*uint32)(unsafe.Pointer(&r.cqRing[p.cqOff.ringMask]))
+	r.cqes = (*[2 * uringMaxEntries]uringCQE)(unsafe.Pointer(&r.cqRing[p.cqOff.cqes]))[:p.cqEntries:p.cqEntries]
+	return r, nil
+}
+
+func (r *uring) enter(toSubmit, minComplete, flags uint32) error {
+	for {
+		_, _, errno := syscall.Syscall6(sysIoUringEnter, uintptr(r.fd), uintptr(toSubmit), uintptr(minComplete), uintptr(flags), 0, 0)
+		if errno == syscall.EINTR {
+			continue
+		}
+		if errno != 0 {
+			return os.NewSyscallError("io_uring_enter", errno)
+		}
+		return nil
+	}
+}
+
+// submit queues a write of buf to fd at off. The caller must keep buf
+// reachable and unmodified until the write completes. It returns an error
+// rather than overwrite an entry the kernel has not yet consumed, when the
+// submission queue is full.
+func (r *uring) submit(fd uintptr, buf []byte, off int64, id uint64) error {
+	if r.sqes == nil {
+		return ErrWriteAfterClose{}
+	}
+	tail := atomic.LoadUint32(r.sqTail)
+	if tail-atomic.LoadUint32(r.sqHead) >= uint32(len(r.sqes)) {
+		return fmt.Errorf("cannot submit write: io_uring submission queue is full: %d entries", len(r.sqes))
+	}
+	idx := tail & r.sqMask
+	r.sqes[idx] = uringSQE{
+		opcode:   uringOpWrite,
+		fd:       int32(fd),
+		off:      uint64(off),
+		addr:     uint64(uintptr(unsafe.Pointer(&buf[0]))),
+		len:      uint32(len(buf)),
+		userData: id,
+	}
+	r.sqArray[idx] = idx
+	atomic.StoreUint32(r.sqTail, tail+1)
+	return r.enter(1, 0, 0)
+}
+
+// reap returns the next completion. When none is ready, it waits for one when
+// wait is true, and otherwise returns false.
+func (r *uring) reap(wait bool) (uint64, int32, bool, error) {
+	if r.cqes == nil {
+		return 0, 0, false, ErrWriteAfterClose{}
+	}
+	for {
+		head := atomic.LoadUint32(r.cqHead)
+		if head != atomic.LoadUint32(r.cqTail) {
+			cqe := r.cqes[head&r.cqMask]
+			atomic.StoreUint32(r.cqHead, head+1)
+			return cqe.userData, cqe.res, true, nil
+		}
+		if !wait {
+			return 0, 0, false, nil
+		}
+		if err := r.enter(0, 1, uringEnterGetEvents); err != nil {
+			return 0, 0, false, err
+		}
+	}
+}
+
+// close unmaps the rings and closes the io_uring file descriptor. It may be
+// invoked more than once, and after newUringBackend fails part way through.
+func (r *uring) close() error {
+	// Clear the views into the rings before unmapping them.
+	r.sqHead, r.sqTail, r.sqArray, r.sqes = nil, nil, nil, nil
+	r.cqHead, r.cqTail, r.cqes = nil, nil, nil
+
+	var errors ErrList
+	for _, buf := range []*[]byte{&r.sqRing, &r.cqRing, &r.sqeMem} {
+		if *buf != nil {
+			errors.Append(syscall.Munmap(*buf))
+			*buf = nil
+		}
+	}
+	if r.fd >= 0 {
+		errors.Append(syscall.Close(r.fd))
+		r.fd = -1
+	}
+	return errors.Err()
+}
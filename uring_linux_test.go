@@ -0,0 +1,38 @@
+//go:build linux && (amd64 || arm64)
+// +build linux
+// +build amd64 arm64
+
+package gorill
+
+import "testing"
+
+func TestUringSubmitQueueFull(t *testing.T) {
+	// The kernel has not consumed either of the two submitted entries.
+	head, tail := uint32(5), uint32(7)
+	r := &uring{
+		fd:      -1,
+		sqArray: make([]uint32, 2),
+		sqHead:  &head,
+		sqMask:  1,
+		sqTail:  &tail,
+		sqes:    make([]uringSQE, 2),
+	}
+	err := r.submit(0, []byte(alphabet), 0, 1)
+	ensureError(t, err, "submission queue is full")
+	if got, want := tail, uint32(7); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
+
+func TestUringCloseTwice(t *testing.T) {
+	backend, err := newUringBackend(4)
+	if err != nil {
+		t.Skipf("io_uring not available: %s", err)
+	}
+	r := backend.(*uring)
+	ensureError(t, r.close())
+	ensureError(t, r.close())
+	testErrorType(t, r.submit(0, []byte(alphabet), 0, 1), ErrWriteAfterClose{})
+	_, _, _, err = r.reap(false)
+	testErrorType(t, err, ErrWriteAfterClose{})
+}
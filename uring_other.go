@@ -0,0 +1,10 @@
+//go:build !linux || !(amd64 || arm64)
+// +build !linux !amd64,!arm64
+
+package gorill
+
+import "errors"
+
+func newUringBackend(entries int) (uringBackend, error) {
+	return nil, errors.New("io_uring is not supported on this platform")
+}
@@ -0,0 +1,93 @@
+package gorill
+
+import (
+	"io"
+	"unicode/utf8"
+)
+
+// UTF8SanitizingReader is an io.Reader that replaces each invalid UTF-8
+// sequence read from the source io.Reader with the Unicode replacement
+// character, U+FFFD, so downstream encoders and line processors never receive
+// invalid text. A valid multi-byte rune split across two Read operations of the
+// source is preserved.
+type UTF8SanitizingReader struct {
+	ior     io.Reader
+	carry   []byte // carry holds an incomplete rune from the previous read
+	pending []byte // pending holds sanitized bytes not yet returned
+	scratch []byte
+	err     error
+}
+
+// NewUTF8SanitizingReader returns a UTF8SanitizingReader that reads from ior.
+//
+//   r := gorill.NewUTF8SanitizingReader(fh)
+//   if err := json.NewEncoder(w).Encode(ioutil.ReadAll(r)); err != nil {
+//       return err
+//   }
+func NewUTF8SanitizingReader(ior io.Reader) *UTF8SanitizingReader {
+	return &UTF8SanitizingReader{ior: ior}
+}
+
+// Read reads up to len(p) bytes into p. It returns the number of bytes read (0
+// <= n <= len(p)) and any error encountered.
+func (r *UTF8SanitizingReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.err != nil {
+			if len(r.carry) == 0 {
+				return 0, r.err
+			}
+			// Incomplete rune at end of stream.
+			r.pending = append(r.pending[:0], string(utf8.RuneError)...)
+			r.carry = r.carry[:0]
+			break
+		}
+		if r.scratch == nil {
+			r.scratch = make([]byte, DefaultBufSize)
+		}
+		var n int
+		n, r.err = r.ior.Read(r.scratch)
+		if n == 0 {
+			continue
+		}
+		if len(r.carry) == 0 {
+			r.sanitize(r.scratch[:n])
+		} else {
+			r.carry = append(r.carry, r.scratch[:n]...)
+			carry := r.carry
+			r.carry = nil
+			r.sanitize(carry)
+			if r.carry == nil {
+				r.carry = carry[:0]
+			}
+		}
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// sanitize appends the sanitized form of buf to pending, saving any incomplete
+// rune at the end of buf to carry.
+func (r *UTF8SanitizingReader) sanitize(buf []byte) {
+	out := r.pending[:0]
+	for len(buf) > 0 {
+		if buf[0] < utf8.RuneSelf {
+			out = append(out, buf[0])
+			buf = buf[1:]
+			continue
+		}
+		if !utf8.FullRune(buf) && r.err == nil {
+			// Rune may be completed by the next read.
+			r.carry = append(r.carry, buf...)
+			break
+		}
+		rn, size := utf8.DecodeRune(buf)
+		if rn == utf8.RuneError && size == 1 {
+			out = append(out, string(utf8.RuneError)...)
+		} else {
+			out = append(out, buf[:size]...)
+		}
+		buf = buf[size:]
+	}
+	r.pending = out
+}
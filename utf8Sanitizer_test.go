@@ -0,0 +1,44 @@
+package gorill
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+	"testing/iotest"
+)
+
+func TestUTF8SanitizingReader(t *testing.T) {
+	test := func(t *testing.T, input, want string) {
+		t.Helper()
+		buf, err := ioutil.ReadAll(NewUTF8SanitizingReader(bytes.NewReader([]byte(input))))
+		ensureError(t, err)
+		if got := string(buf); got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+
+		buf, err = ioutil.ReadAll(NewUTF8SanitizingReader(iotest.OneByteReader(bytes.NewReader([]byte(input)))))
+		ensureError(t, err)
+		if got := string(buf); got != want {
+			t.Errorf("one byte reads: GOT: %q; WANT: %q", got, want)
+		}
+	}
+
+	t.Run("empty", func(t *testing.T) {
+		test(t, "", "")
+	})
+	t.Run("valid", func(t *testing.T) {
+		test(t, "a€😀\n", "a€😀\n")
+	})
+	t.Run("invalid byte", func(t *testing.T) {
+		test(t, "a\xffb", "a�b")
+	})
+	t.Run("truncated rune in middle", func(t *testing.T) {
+		test(t, "a\xe2\x82b", "a��b")
+	})
+	t.Run("truncated rune at end", func(t *testing.T) {
+		test(t, "a\xf0\x9f\x98", "a�")
+	})
+	t.Run("surrogate half", func(t *testing.T) {
+		test(t, "\xed\xa0\x80", "���")
+	})
+}
@@ -0,0 +1,76 @@
+package gorill
+
+import (
+	"io"
+	"net"
+)
+
+// flushWriter is the interface a SpooledWriteCloser uses to buffer its writes.
+type flushWriter interface {
+	io.Writer
+	Available() int
+	Buffered() int
+	Flush() error
+}
+
+// vectoredWriter buffers payloads written to it as a list of byte slices rather
+// than copying them into one contiguous buffer, and flushes them with a single
+// call to net.Buffers.WriteTo, which uses writev(2) when the underlying writer
+// is a network connection that supports it.
+type vectoredWriter struct {
+	bufs  net.Buffers
+	iow   io.Writer
+	limit int // limit is the number of buffered bytes that triggers a flush
+	size  int // size is the number of bytes buffered
+}
+
+func newVectoredWriter(iow io.Writer, limit int) *vectoredWriter {
+	return &vectoredWriter{iow: iow, limit: limit}
+}
+
+// Write buffers a copy of data, flushing the buffered payloads first when data
+// would cause the buffer to exceed its limit. Like bufio.Writer, a payload at
+// least as large as the limit is written directly to the underlying io.Writer
+// without being copied.
+func (v *vectoredWriter) Write(data []byte) (int, error) {
+	if v.size+len(data) > v.limit {
+		if err := v.Flush(); err != nil {
+			return 0, err
+		}
+	}
+	if len(data) >= v.limit {
+		return v.iow.Write(data)
+	}
+	v.bufs = append(v.bufs, append([]byte(nil), data...))
+	v.size += len(data)
+	return len(data), nil
+}
+
+// Available returns the number of bytes that may be written before the
+// buffered payloads are flushed.
+func (v *vectoredWriter) Available() int { return v.limit - v.size }
+
+// Buffered returns the number of bytes buffered.
+func (v *vectoredWriter) Buffered() int { return v.size }
+
+// Flush writes all buffered payloads to the underlying io.Writer.
+func (v *vectoredWriter) Flush() error {
+	if v.size == 0 {
+		return nil
+	}
+	bufs := v.bufs // WriteTo consumes the slice it is invoked on
+	n, err := bufs.WriteTo(v.iow)
+	if err != nil {
+		// Retain the payloads that were not written so a subsequent flush
+		// may retry them.
+		v.bufs = append(v.bufs[:0], bufs...)
+		v.size -= int(n)
+		return err
+	}
+	for i := range v.bufs {
+		v.bufs[i] = nil // release references to payloads
+	}
+	v.bufs = v.bufs[:0]
+	v.size = 0
+	return nil
+}
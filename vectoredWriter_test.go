@@ -0,0 +1,161 @@
+package gorill
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+)
+
+func TestVectoredWriter(t *testing.T) {
+	t.Run("buffers until limit", func(t *testing.T) {
+		br := new(blockRecorder)
+		v := newVectoredWriter(br, 8)
+
+		for _, piece := range []string{"abc", "def", "gh", "ijk"} {
+			n, err := v.Write([]byte(piece))
+			ensureError(t, err)
+			if got, want := n, len(piece); got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		}
+		if got, want := br.Buffer.String(), "abcdefgh"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+		ensureError(t, v.Flush())
+		if got, want := br.Buffer.String(), "abcdefghijk"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("copies payloads", func(t *testing.T) {
+		bb := new(bytes.Buffer)
+		v := newVectoredWriter(bb, 8)
+
+		buf := []byte("abc")
+		_, err := v.Write(buf)
+		ensureError(t, err)
+		copy(buf, "xyz")
+		ensureError(t, v.Flush())
+		if got, want := bb.String(), "abc"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("large payload written directly", func(t *testing.T) {
+		br := new(blockRecorder)
+		v := newVectoredWriter(br, 4)
+
+		_, err := v.Write([]byte("ab"))
+		ensureError(t, err)
+		_, err = v.Write([]byte(alphabet))
+		ensureError(t, err)
+		if got, want := br.Buffer.String(), "ab"+alphabet; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+		if got, want := len(br.sizes), 2; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("retains payloads after error", func(t *testing.T) {
+		v := newVectoredWriter(ShortWriter(new(bytes.Buffer), 2), 16)
+
+		_, err := v.Write([]byte("abcd"))
+		ensureError(t, err)
+		if err = v.Flush(); err == nil {
+			t.Fatalf("GOT: %v; WANT: %v", err, "some error")
+		}
+		if got, want := v.size, 2; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
+
+// loopback returns a TCP connection whose peer discards everything it reads,
+// and a function to close both.
+func loopback(tb testing.TB) (net.Conn, func() []byte) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Skip(err)
+	}
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			received <- nil
+			return
+		}
+		buf, _ := ioutil.ReadAll(conn)
+		conn.Close()
+		received <- buf
+	}()
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		l.Close()
+		tb.Fatal(err)
+	}
+	return conn, func() []byte {
+		conn.Close()
+		l.Close()
+		return <-received
+	}
+}
+
+func TestSpooledWriteCloserVectored(t *testing.T) {
+	conn, done := loopback(t)
+
+	w, err := NewSpooledWriteCloser(conn, BufSize(64))
+	ensureError(t, err)
+	if _, ok := w.bw.(*vectoredWriter); !ok {
+		t.Errorf("GOT: %T; WANT: %T", w.bw, &vectoredWriter{})
+	}
+
+	var want []byte
+	for i := 0; i < 100; i++ {
+		piece := []byte(alphabet[i%26:])
+		want = append(want, piece...)
+		_, err = w.Write(piece)
+		ensureError(t, err)
+	}
+	ensureError(t, w.Close())
+
+	if got := done(); !bytes.Equal(got, want) {
+		t.Errorf("GOT: %v bytes; WANT: %v bytes", len(got), len(want))
+	}
+}
+
+func benchmarkSpooledLoopback(b *testing.B, wrap func(net.Conn) io.WriteCloser) {
+	conn, done := loopback(b)
+	defer done()
+
+	w, err := NewSpooledWriteCloser(wrap(conn), BufSize(64*1024))
+	if err != nil {
+		b.Fatal(err)
+	}
+	payload := []byte(alphabet + alphabet + alphabet + alphabet)
+	b.SetBytes(int64(len(payload)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := w.Write(payload); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		b.Fatal(err)
+	}
+}
+
+func BenchmarkSpooledLoopbackContiguous(b *testing.B) {
+	benchmarkSpooledLoopback(b, func(conn net.Conn) io.WriteCloser {
+		return struct{ io.WriteCloser }{conn} // hides net.Conn
+	})
+}
+
+func BenchmarkSpooledLoopbackVectored(b *testing.B) {
+	benchmarkSpooledLoopback(b, func(conn net.Conn) io.WriteCloser {
+		return conn
+	})
+}
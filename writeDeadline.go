@@ -0,0 +1,38 @@
+package gorill
+
+import (
+	"io"
+	"os"
+	"time"
+)
+
+// WriteDeadliner is implemented by an io.Writer whose writes may be bounded by
+// a deadline, such as a net.Conn. Writer wrappers in this package implement it
+// by passing the deadline to the io.Writer they wrap, so a deadline set at the
+// top of a stack of wrappers reaches the innermost io.Writer that supports
+// one.
+type WriteDeadliner interface {
+	// SetWriteDeadline sets the deadline for future and pending writes. A zero
+	// value for t means writes will not time out.
+	SetWriteDeadline(t time.Time) error
+}
+
+// SetWriteDeadline sets the write deadline of iow when it is a WriteDeadliner,
+// and returns os.ErrNoDeadline otherwise.
+//
+//   conn, err := net.Dial("tcp", address)
+//   if err != nil {
+//       return err
+//   }
+//   sw, err := gorill.NewSpooledWriteCloser(gorill.NewLockingWriteCloser(conn))
+//   if err != nil {
+//       return err
+//   }
+//   // The deadline reaches conn through both wrappers.
+//   err = gorill.SetWriteDeadline(sw, time.Now().Add(5*time.Second))
+func SetWriteDeadline(iow io.Writer, t time.Time) error {
+	if wd, ok := iow.(WriteDeadliner); ok {
+		return wd.SetWriteDeadline(t)
+	}
+	return os.ErrNoDeadline
+}
@@ -0,0 +1,118 @@
+package gorill
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+// deadlineRecorder is an io.WriteCloser that records the write deadline set on
+// it.
+type deadlineRecorder struct {
+	*NopCloseBuffer
+	deadline time.Time
+}
+
+func (d *deadlineRecorder) SetWriteDeadline(t time.Time) error {
+	d.deadline = t
+	return nil
+}
+
+func TestSetWriteDeadlineUnsupported(t *testing.T) {
+	err := SetWriteDeadline(NewNopCloseBuffer(), time.Now())
+	if err != os.ErrNoDeadline {
+		t.Errorf("GOT: %v; WANT: %v", err, os.ErrNoDeadline)
+	}
+
+	err = SetWriteDeadline(NewLockingWriteCloser(NewNopCloseBuffer()), time.Now())
+	if err != os.ErrNoDeadline {
+		t.Errorf("GOT: %v; WANT: %v", err, os.ErrNoDeadline)
+	}
+}
+
+func TestSetWriteDeadlineWrappers(t *testing.T) {
+	wrappers := map[string]func(io.WriteCloser) (io.WriteCloser, error){
+		"CoalescingWriter": func(iowc io.WriteCloser) (io.WriteCloser, error) {
+			return NewCoalescingWriter(iowc)
+		},
+		"DrainBarrier": func(iowc io.WriteCloser) (io.WriteCloser, error) {
+			return NewDrainBarrier(iowc), nil
+		},
+		"FlushingWriter": func(iowc io.WriteCloser) (io.WriteCloser, error) {
+			return NewFlushingWriter(iowc)
+		},
+		"LockingWriteCloser": func(iowc io.WriteCloser) (io.WriteCloser, error) {
+			return NewLockingWriteCloser(iowc), nil
+		},
+		"NopCloseWriter": func(iowc io.WriteCloser) (io.WriteCloser, error) {
+			return NopCloseWriter(iowc), nil
+		},
+		"QuotaWriter": func(iowc io.WriteCloser) (io.WriteCloser, error) {
+			return NewQuotaWriter(iowc, 1024)
+		},
+		"SafeWriteCloser": func(iowc io.WriteCloser) (io.WriteCloser, error) {
+			return NewSafeWriteCloser(iowc), nil
+		},
+		"SpooledWriteCloser": func(iowc io.WriteCloser) (io.WriteCloser, error) {
+			return NewSpooledWriteCloser(iowc)
+		},
+		"TimedWriteCloser": func(iowc io.WriteCloser) (io.WriteCloser, error) {
+			return NewTimedWriteCloser(iowc, time.Second), nil
+		},
+	}
+	for name, wrap := range wrappers {
+		t.Run(name, func(t *testing.T) {
+			dr := &deadlineRecorder{NopCloseBuffer: NewNopCloseBuffer()}
+			inner, err := wrap(dr)
+			ensureError(t, err)
+			// Wrap twice to show the deadline passes through a stack.
+			outer, err := wrap(inner)
+			ensureError(t, err)
+
+			deadline := time.Now().Add(time.Minute)
+			ensureError(t, SetWriteDeadline(outer, deadline))
+			if got, want := dr.deadline, deadline; !got.Equal(want) {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+	}
+}
+
+func TestSetWriteDeadlineReachesConn(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	sw, err := NewSpooledWriteCloser(NewLockingWriteCloser(client))
+	ensureError(t, err)
+
+	ensureError(t, SetWriteDeadline(sw, time.Now().Add(10*time.Millisecond)))
+	_, err = sw.Write([]byte(alphabet))
+	ensureError(t, err) // spooled, not yet written to the connection
+
+	// Nothing reads from server, so the flush blocks until the deadline.
+	err = sw.Flush()
+	var ne net.Error
+	if !errors.As(err, &ne) || !ne.Timeout() {
+		t.Errorf("GOT: %v; WANT: timeout", err)
+	}
+}
+
+func TestTimedWriteCloserEnforcesDeadline(t *testing.T) {
+	stuck := &gatedWriteCloser{started: make(chan struct{}, 1), gate: make(chan struct{})}
+	defer close(stuck.gate)
+
+	wc := NewTimedWriteCloser(stuck, time.Minute)
+	ensureError(t, SetWriteDeadline(wc, time.Now().Add(10*time.Millisecond)))
+
+	_, err := wc.Write([]byte(alphabet))
+	testErrorType(t, err, ErrDeadlineExceeded{})
+
+	_, err = wc.Write([]byte(alphabet))
+	testErrorType(t, err, ErrDeadlineExceeded{})
+	if got, want := wc.Stats()["timeouts"], int64(2); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
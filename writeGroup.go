@@ -0,0 +1,232 @@
+package gorill
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// WriteGroupMember is one destination of a WriteGroup. Delivery to a member
+// happens in two phases: Prepare stages the payload, then either Commit makes
+// it permanent once every member has prepared it, or Rollback undoes it when
+// any member failed to prepare it.
+type WriteGroupMember interface {
+	Name() string
+	Prepare(data []byte) error
+	Commit() error
+	Rollback() error
+}
+
+// writerMember is a WriteGroupMember that writes directly to an io.Writer.
+type writerMember struct {
+	iow      io.Writer
+	name     string
+	rollback func() error
+}
+
+// NewWriterMember returns a WriteGroupMember that writes the payload to iow
+// while preparing. Because the payload cannot be unwritten, rollback is invoked
+// to compensate when another member fails. A nil rollback does nothing.
+func NewWriterMember(name string, iow io.Writer, rollback func() error) WriteGroupMember {
+	return &writerMember{iow: iow, name: name, rollback: rollback}
+}
+
+func (m *writerMember) Name() string { return m.name }
+
+func (m *writerMember) Prepare(data []byte) error {
+	n, err := m.iow.Write(data)
+	if err == nil && n < len(data) {
+		err = io.ErrShortWrite
+	}
+	return err
+}
+
+func (m *writerMember) Commit() error { return nil }
+
+func (m *writerMember) Rollback() error {
+	if m.rollback == nil {
+		return nil
+	}
+	return m.rollback()
+}
+
+// stagedFileMember is a WriteGroupMember that stages the payload in a
+// temporary file, and renames it into place on commit.
+type stagedFileMember struct {
+	name    string
+	path    string
+	staging string
+}
+
+// NewStagedFileMember returns a WriteGroupMember that writes the payload to a
+// temporary file in the same directory as path while preparing, renames it to
+// path on commit, and removes it on rollback.
+func NewStagedFileMember(name, path string) WriteGroupMember {
+	return &stagedFileMember{name: name, path: path}
+}
+
+func (m *stagedFileMember) Name() string { return m.name }
+
+func (m *stagedFileMember) Prepare(data []byte) error {
+	fh, err := ioutil.TempFile(filepath.Dir(m.path), filepath.Base(m.path)+".staged-")
+	if err != nil {
+		return err
+	}
+	m.staging = fh.Name()
+	_, err = fh.Write(data)
+	if err == nil {
+		err = fh.Sync()
+	}
+	if cerr := fh.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(m.staging) // discard the partially written staging file
+		m.staging = ""
+	}
+	return err
+}
+
+func (m *stagedFileMember) Commit() error {
+	staging := m.staging
+	m.staging = ""
+	return os.Rename(staging, m.path)
+}
+
+func (m *stagedFileMember) Rollback() error {
+	if m.staging == "" {
+		return nil
+	}
+	staging := m.staging
+	m.staging = ""
+	return os.Remove(staging)
+}
+
+// WriteGroupMemberOutcome describes what happened to a single member of a
+// WriteGroup during one delivery.
+type WriteGroupMemberOutcome struct {
+	Name        string
+	Prepared    bool  // Prepared is true when the member staged the payload
+	PrepareErr  error // PrepareErr is the error returned while preparing
+	CommitErr   error // CommitErr is the error returned while committing
+	RolledBack  bool  // RolledBack is true when the member was rolled back
+	RollbackErr error // RollbackErr is the error returned while rolling back
+}
+
+// WriteGroupOutcome describes the result of one delivery to a WriteGroup.
+type WriteGroupOutcome struct {
+	// Committed is true when every member prepared the payload, and commit
+	// was attempted for every member.
+	Committed bool
+
+	Members []WriteGroupMemberOutcome
+}
+
+// Err returns nil when the payload was committed to every member without
+// error, and otherwise returns an ErrWriteGroup describing the outcome.
+func (o *WriteGroupOutcome) Err() error {
+	if !o.Committed {
+		return ErrWriteGroup{Outcome: o}
+	}
+	for _, m := range o.Members {
+		if m.CommitErr != nil {
+			return ErrWriteGroup{Outcome: o}
+		}
+	}
+	return nil
+}
+
+// ErrWriteGroup is returned when a WriteGroup delivery did not succeed for
+// every member.
+type ErrWriteGroup struct {
+	Outcome *WriteGroupOutcome
+}
+
+// Error returns a string representation of a ErrWriteGroup error instance.
+func (e ErrWriteGroup) Error() string {
+	var failures []string
+	for _, m := range e.Outcome.Members {
+		if m.PrepareErr != nil {
+			failures = append(failures, fmt.Sprintf("%s: cannot prepare: %s", m.Name, m.PrepareErr))
+		}
+		if m.CommitErr != nil {
+			failures = append(failures, fmt.Sprintf("%s: cannot commit: %s", m.Name, m.CommitErr))
+		}
+		if m.RollbackErr != nil {
+			failures = append(failures, fmt.Sprintf("%s: cannot roll back: %s", m.Name, m.RollbackErr))
+		}
+	}
+	state := "rolled back"
+	if e.Outcome.Committed {
+		state = "partially committed"
+	}
+	return fmt.Sprintf("write group %s: %s", state, strings.Join(failures, "; "))
+}
+
+// WriteGroup delivers each payload to all of its members, or to none of them,
+// such as when writing to both a local journal and a remote sink. Each payload
+// is first prepared by every member. When all members succeed, each member
+// commits it. When any member fails, each member that prepared it is rolled
+// back.
+type WriteGroup struct {
+	lock    sync.Mutex
+	members []WriteGroupMember
+}
+
+// NewWriteGroup returns a WriteGroup that delivers payloads to members.
+//
+//   group := gorill.NewWriteGroup(
+//       gorill.NewStagedFileMember("journal", journalPath),
+//       gorill.NewWriterMember("remote", conn, func() error { return sendRetraction(conn) }))
+//   if outcome := group.Deliver(payload); !outcome.Committed {
+//       return outcome.Err()
+//   }
+func NewWriteGroup(members ...WriteGroupMember) *WriteGroup {
+	return &WriteGroup{members: members}
+}
+
+// Deliver attempts to deliver data to all members, and returns the outcome.
+// Deliveries are serialized.
+func (g *WriteGroup) Deliver(data []byte) *WriteGroupOutcome {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	outcome := &WriteGroupOutcome{Members: make([]WriteGroupMemberOutcome, len(g.members))}
+	failed := false
+	for i, m := range g.members {
+		mo := &outcome.Members[i]
+		mo.Name = m.Name()
+		if mo.PrepareErr = m.Prepare(data); mo.PrepareErr != nil {
+			failed = true
+		} else {
+			mo.Prepared = true
+		}
+	}
+
+	for i, m := range g.members {
+		mo := &outcome.Members[i]
+		if failed {
+			if mo.Prepared {
+				mo.RolledBack = true
+				mo.RollbackErr = m.Rollback()
+			}
+			continue
+		}
+		mo.CommitErr = m.Commit()
+	}
+	outcome.Committed = !failed
+	return outcome
+}
+
+// Write delivers data to all members. It returns len(data) when data was
+// committed to every member, and otherwise returns 0 and an ErrWriteGroup.
+func (g *WriteGroup) Write(data []byte) (int, error) {
+	if err := g.Deliver(data).Err(); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
@@ -0,0 +1,85 @@
+package gorill
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteGroupCommits(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gorill")
+	ensureError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "journal")
+
+	bb := NewNopCloseBuffer()
+	group := NewWriteGroup(NewStagedFileMember("journal", path), NewWriterMember("remote", bb, nil))
+
+	n, err := group.Write([]byte(alphabet))
+	ensureError(t, err)
+	if got, want := n, len(alphabet); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	buf, err := ioutil.ReadFile(path)
+	ensureError(t, err)
+	if got, want := string(buf), alphabet; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	if got, want := bb.String(), alphabet; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	entries, err := ioutil.ReadDir(dir)
+	ensureError(t, err)
+	if got, want := len(entries), 1; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
+
+func TestWriteGroupRollsBack(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gorill")
+	ensureError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "journal")
+
+	var rolledBack bool
+	bb := NewNopCloseBuffer()
+	group := NewWriteGroup(
+		NewStagedFileMember("journal", path),
+		NewWriterMember("local", bb, func() error { rolledBack = true; return nil }),
+		NewWriterMember("remote", &testWriteCloser{}, func() error {
+			t.Errorf("GOT: rollback of failed member; WANT: none")
+			return nil
+		}))
+
+	outcome := group.Deliver([]byte(alphabet))
+	if outcome.Committed {
+		t.Errorf("GOT: %v; WANT: %v", true, false)
+	}
+	if !rolledBack {
+		t.Errorf("GOT: %v; WANT: %v", false, true)
+	}
+	if got, want := outcome.Members[2].PrepareErr, io.ErrShortWrite; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if !outcome.Members[0].RolledBack || !outcome.Members[1].RolledBack || outcome.Members[2].RolledBack {
+		t.Errorf("GOT: %+v; WANT: first two members rolled back", outcome.Members)
+	}
+	ensureError(t, outcome.Err(), "write group rolled back", "remote: cannot prepare: short write")
+
+	entries, err := ioutil.ReadDir(dir)
+	ensureError(t, err)
+	if got, want := len(entries), 0; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	n, err := group.Write([]byte(alphabet))
+	if got, want := n, 0; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if _, ok := err.(ErrWriteGroup); !ok {
+		t.Errorf("GOT: %T; WANT: %T", err, ErrWriteGroup{})
+	}
+}